@@ -21,12 +21,19 @@ type Document struct {
 type Block interface {
 	GetAlignment() AlignmentType
 	GetWrap() bool
+	GetClasses() []string
+	GetID() string
 }
 
 // Base block.
 type BaseBlock struct {
 	Alignment AlignmentType
 	Wrap      bool
+
+	// Classes and an identifier for styling and linking, written by formats
+	// that have them, like HTML.
+	Classes []string
+	ID      string
 }
 
 // Get alignment.
@@ -39,6 +46,16 @@ func (b *BaseBlock) GetWrap() bool {
 	return b.Wrap
 }
 
+// Get classes.
+func (b *BaseBlock) GetClasses() []string {
+	return b.Classes
+}
+
+// Get the identifier.
+func (b *BaseBlock) GetID() string {
+	return b.ID
+}
+
 // Block alignment types.
 type AlignmentType int64
 
@@ -75,6 +92,7 @@ type Image struct {
 	BaseBlock
 
 	Source     string
+	Alt        string
 	HasCaption bool
 	Caption    []InlineBlock
 
@@ -150,8 +168,33 @@ type PageBreak struct {
 	BaseBlock
 }
 
-// Inline block for AST. An inline block may be a base inline block or string.
-type InlineBlock interface{}
+// Speaker notes block. Notes are shown by slide decks and left out of other
+// output.
+type Notes struct {
+	BaseBlock
+
+	Content []Block
+}
+
+// Inline block for AST. Inline blocks are pointers, like blocks.
+type InlineBlock interface {
+	Children() []InlineBlock
+}
+
+// Text inline block.
+type Text struct {
+	Value string
+}
+
+// Get the text's children. Text has no children.
+func (t *Text) Children() []InlineBlock {
+	return nil
+}
+
+// Get the text's value.
+func (t *Text) String() string {
+	return t.Value
+}
 
 // Base inline block.
 type BaseInlineBlock struct {
@@ -159,6 +202,11 @@ type BaseInlineBlock struct {
 	Content []InlineBlock
 }
 
+// Get the block's children.
+func (b *BaseInlineBlock) Children() []InlineBlock {
+	return b.Content
+}
+
 // Hyperlink block.
 type HyperlinkBlock struct {
 	BaseInlineBlock
@@ -224,6 +272,7 @@ type InlineImageBlock struct {
 	BaseInlineBlock
 
 	Source string
+	Alt    string
 
 	// Image size information.
 	HasWidthParameter  bool
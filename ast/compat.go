@@ -0,0 +1,62 @@
+// ast/compat.go
+// Compatibility helpers for untyped inline blocks.
+
+package ast
+
+import "errors"
+
+// Create a new text inline block.
+func NewText(value string) *Text {
+	return &Text{Value: value}
+}
+
+// Convert a legacy inline block into an InlineBlock. Legacy inline blocks are
+// plain strings and inline block struct values, as used before InlineBlock
+// was a typed interface. InlineBlock values are returned as they are.
+func ConvertInline(v interface{}) (InlineBlock, error) {
+	switch b := v.(type) {
+	case InlineBlock:
+		return b, nil
+	case string:
+		return &Text{Value: b}, nil
+	case Text:
+		return &b, nil
+	case HyperlinkBlock:
+		return &b, nil
+	case FormattingBlock:
+		return &b, nil
+	case ColorBlock:
+		return &b, nil
+	case SizeBlock:
+		return &b, nil
+	case FontBlock:
+		return &b, nil
+	case InlineImageBlock:
+		return &b, nil
+	}
+	return nil, errors.New("invalid inline block")
+}
+
+// Convert a slice of legacy inline blocks into InlineBlocks. See
+// ConvertInline.
+func ConvertInlines(v []interface{}) ([]InlineBlock, error) {
+	blocks := make([]InlineBlock, 0, len(v))
+	for i := range v {
+		b, err := ConvertInline(v[i])
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+// Build a slice of inline blocks from legacy inline blocks. Panics if a value
+// is not a valid inline block, so it should only be used with literals.
+func Inlines(v ...interface{}) []InlineBlock {
+	blocks, err := ConvertInlines(v)
+	if err != nil {
+		panic("ast: " + err.Error())
+	}
+	return blocks
+}
@@ -0,0 +1,616 @@
+// ast/json.go
+// JSON serialization of the abstract syntax tree.
+
+package ast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gopkg.in/go-playground/colors.v1"
+)
+
+// The version of the JSON schema written by MarshalJSON. Documents with a
+// newer version are rejected by UnmarshalJSON, as are documents using fields
+// or node types added after their version.
+//
+// Version 1 is the initial schema. Version 2 adds the "alt" field of images
+// and inline images, version 3 adds the "notes" node and version 4 adds the
+// "class" and "id" fields of blocks.
+const JSONSchemaVersion = 4
+
+// JSON document.
+type jsonDocument struct {
+	Version  int        `json:"version"`
+	Title    string     `json:"title,omitempty"`
+	Subtitle string     `json:"subtitle,omitempty"`
+	Date     string     `json:"date,omitempty"`
+	Author   string     `json:"author,omitempty"`
+	Content  []jsonNode `json:"content"`
+}
+
+// JSON node. Every block and inline block is a JSON node, discriminated by
+// its type.
+type jsonNode struct {
+	Type string `json:"type"`
+
+	// Block fields.
+	Align string   `json:"align,omitempty"`
+	Wrap  bool     `json:"wrap,omitempty"`
+	Class []string `json:"class,omitempty"`
+	ID    string   `json:"id,omitempty"`
+
+	// Node-specific fields.
+	Value      string         `json:"value,omitempty"`
+	Source     string         `json:"src,omitempty"`
+	Alt        string         `json:"alt,omitempty"`
+	HasCaption bool           `json:"has_caption,omitempty"`
+	Caption    []jsonNode     `json:"caption,omitempty"`
+	Width      *jsonSize      `json:"width,omitempty"`
+	Height     *jsonSize      `json:"height,omitempty"`
+	Level      int            `json:"level,omitempty"`
+	Ordered    bool           `json:"ordered,omitempty"`
+	Items      []jsonNode     `json:"items,omitempty"`
+	Rows       []jsonTableRow `json:"rows,omitempty"`
+	Summary    []jsonNode     `json:"summary,omitempty"`
+	Dest       string         `json:"dest,omitempty"`
+	Format     string         `json:"format,omitempty"`
+	Foreground string         `json:"fg,omitempty"`
+	Background string         `json:"bg,omitempty"`
+	Size       *jsonSize      `json:"size,omitempty"`
+	Family     string         `json:"family,omitempty"`
+
+	Content []jsonNode `json:"content,omitempty"`
+}
+
+// JSON table row.
+type jsonTableRow struct {
+	Cells []jsonTableCell `json:"cells"`
+}
+
+// JSON table cell.
+type jsonTableCell struct {
+	Header  bool       `json:"header,omitempty"`
+	Content []jsonNode `json:"content"`
+}
+
+// JSON size value.
+type jsonSize struct {
+	Value float32 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// Marshal the document to JSON.
+func (d Document) MarshalJSON() ([]byte, error) {
+	content, err := blocksToJSON(d.Content)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonDocument{
+		Version:  JSONSchemaVersion,
+		Title:    d.Title,
+		Subtitle: d.Subtitle,
+		Date:     d.Date,
+		Author:   d.Author,
+		Content:  content,
+	})
+}
+
+// Unmarshal the document from JSON.
+func (d *Document) UnmarshalJSON(data []byte) error {
+	var doc jsonDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.Version < 1 || doc.Version > JSONSchemaVersion {
+		return fmt.Errorf("unsupported json schema version %d", doc.Version)
+	}
+	if err := checkJSONVersion(doc.Content, doc.Version); err != nil {
+		return err
+	}
+	content, err := blocksFromJSON(doc.Content)
+	if err != nil {
+		return err
+	}
+	*d = Document{
+		Title:    doc.Title,
+		Subtitle: doc.Subtitle,
+		Date:     doc.Date,
+		Author:   doc.Author,
+		Content:  content,
+	}
+	return nil
+}
+
+// Check that JSON nodes only use the fields and node types of a schema
+// version.
+func checkJSONVersion(nodes []jsonNode, version int) error {
+	for i := range nodes {
+		node := &nodes[i]
+		required, feature := 0, ""
+		switch {
+		case (len(node.Class) != 0 || node.ID != "") && version < 4:
+			required, feature = 4, "the 'class' and 'id' fields"
+		case node.Type == "notes" && version < 3:
+			required, feature = 3, "the 'notes' node"
+		case node.Alt != "" && version < 2:
+			required, feature = 2, "the 'alt' field"
+		}
+		if required != 0 {
+			return fmt.Errorf("json schema version %d is needed for %s, but the document has version %d", required, feature, version)
+		}
+
+		children := [][]jsonNode{node.Caption, node.Items, node.Summary, node.Content}
+		for j := range node.Rows {
+			for k := range node.Rows[j].Cells {
+				children = append(children, node.Rows[j].Cells[k].Content)
+			}
+		}
+		for j := range children {
+			if err := checkJSONVersion(children[j], version); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Convert a slice of blocks to JSON nodes.
+func blocksToJSON(blocks []Block) ([]jsonNode, error) {
+	nodes := make([]jsonNode, 0, len(blocks))
+	for i := range blocks {
+		node, err := blockToJSON(blocks[i])
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// Convert a block to a JSON node.
+func blockToJSON(b Block) (jsonNode, error) {
+	var node jsonNode
+	var err error
+
+	switch block := b.(type) {
+	case *Paragraph:
+		node.Type = "paragraph"
+		node.Content, err = inlineBlocksToJSON(block.Content)
+	case *BasicBlock:
+		node.Type = "block"
+		node.Content, err = blocksToJSON(block.Content)
+	case *Quote:
+		node.Type = "quote"
+		node.Content, err = blocksToJSON(block.Content)
+	case *Image:
+		node.Type = "image"
+		node.Source = block.Source
+		node.Alt = block.Alt
+		node.HasCaption = block.HasCaption
+		node.Width, node.Height, err = imageSizeToJSON(block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+		if err != nil {
+			return node, err
+		}
+		node.Caption, err = inlineBlocksToJSON(block.Caption)
+	case *Heading:
+		if block.Class < Heading1Type || block.Class > Heading5Type {
+			return node, errors.New("invalid ast")
+		}
+		node.Type = "heading"
+		node.Level = int(block.Class-Heading1Type) + 1
+		node.Content, err = inlineBlocksToJSON(block.Content)
+	case *HorizontalRule:
+		node.Type = "hr"
+	case *List:
+		node.Type = "list"
+		node.Ordered = block.Ordered
+		node.Items, err = blocksToJSON(block.Items)
+	case *Table:
+		node.Type = "table"
+		node.Rows = make([]jsonTableRow, 0, len(block.Rows))
+		for i := range block.Rows {
+			row := jsonTableRow{Cells: make([]jsonTableCell, 0, len(block.Rows[i].Cells))}
+			for j := range block.Rows[i].Cells {
+				content, err := blocksToJSON(block.Rows[i].Cells[j].Content)
+				if err != nil {
+					return node, err
+				}
+				row.Cells = append(row.Cells, jsonTableCell{Header: block.Rows[i].Cells[j].IsHeader, Content: content})
+			}
+			node.Rows = append(node.Rows, row)
+		}
+	case *Collapse:
+		node.Type = "collapse"
+		node.Summary, err = inlineBlocksToJSON(block.Summary)
+		if err != nil {
+			return node, err
+		}
+		node.Content, err = blocksToJSON(block.Content)
+	case *PageBreak:
+		node.Type = "break"
+	case *Notes:
+		node.Type = "notes"
+		node.Content, err = blocksToJSON(block.Content)
+	default:
+		return node, errors.New("invalid ast")
+	}
+	if err != nil {
+		return node, err
+	}
+
+	node.Align, err = alignmentToJSON(b.GetAlignment())
+	node.Wrap = b.GetWrap()
+	node.Class = b.GetClasses()
+	node.ID = b.GetID()
+	return node, err
+}
+
+// Convert a slice of inline blocks to JSON nodes.
+func inlineBlocksToJSON(blocks []InlineBlock) ([]jsonNode, error) {
+	nodes := make([]jsonNode, 0, len(blocks))
+	for i := range blocks {
+		node, err := inlineBlockToJSON(blocks[i])
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// Convert an inline block to a JSON node.
+func inlineBlockToJSON(b InlineBlock) (jsonNode, error) {
+	var node jsonNode
+	var err error
+
+	switch block := b.(type) {
+	case *Text:
+		node.Type = "text"
+		node.Value = block.Value
+		return node, nil
+	case *HyperlinkBlock:
+		node.Type = "link"
+		node.Dest = block.Destination
+	case *FormattingBlock:
+		node.Type = "formatting"
+		node.Format, err = formattingToJSON(block.Attribute)
+	case *ColorBlock:
+		node.Type = "color"
+		if block.ForegroundValue != nil {
+			node.Foreground = block.ForegroundValue.String()
+		}
+		if block.BackgroundValue != nil {
+			node.Background = block.BackgroundValue.String()
+		}
+	case *SizeBlock:
+		node.Type = "size"
+		node.Size, err = sizeToJSON(block.Value, block.Type)
+	case *FontBlock:
+		node.Type = "font"
+		node.Family = block.Family
+	case *InlineImageBlock:
+		node.Type = "inline-image"
+		node.Source = block.Source
+		node.Alt = block.Alt
+		node.Width, node.Height, err = imageSizeToJSON(block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+		return node, err
+	default:
+		return node, errors.New("invalid ast")
+	}
+	if err != nil {
+		return node, err
+	}
+
+	node.Content, err = inlineBlocksToJSON(b.Children())
+	return node, err
+}
+
+// Convert a slice of JSON nodes to blocks.
+func blocksFromJSON(nodes []jsonNode) ([]Block, error) {
+	blocks := make([]Block, 0, len(nodes))
+	for i := range nodes {
+		block, err := blockFromJSON(&nodes[i])
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// Convert a JSON node to a block.
+func blockFromJSON(node *jsonNode) (Block, error) {
+	alignment, err := alignmentFromJSON(node.Align)
+	if err != nil {
+		return nil, err
+	}
+	base := BaseBlock{Alignment: alignment, Wrap: node.Wrap, Classes: node.Class, ID: node.ID}
+
+	switch node.Type {
+	case "paragraph":
+		content, err := inlineBlocksFromJSON(node.Content)
+		if err != nil {
+			return nil, err
+		}
+		return &Paragraph{BaseBlock: base, Content: content}, nil
+	case "block":
+		content, err := blocksFromJSON(node.Content)
+		if err != nil {
+			return nil, err
+		}
+		return &BasicBlock{BaseBlock: base, Content: content}, nil
+	case "quote":
+		content, err := blocksFromJSON(node.Content)
+		if err != nil {
+			return nil, err
+		}
+		return &Quote{BaseBlock: base, Content: content}, nil
+	case "image":
+		caption, err := inlineBlocksFromJSON(node.Caption)
+		if err != nil {
+			return nil, err
+		}
+		block := &Image{BaseBlock: base, Source: node.Source, Alt: node.Alt, HasCaption: node.HasCaption, Caption: caption}
+		block.HasWidthParameter, block.WidthValue, block.WidthType, err = sizeFromJSON(node.Width)
+		if err != nil {
+			return nil, err
+		}
+		block.HasHeightParameter, block.HeightValue, block.HeightType, err = sizeFromJSON(node.Height)
+		if err != nil {
+			return nil, err
+		}
+		return block, nil
+	case "heading":
+		if node.Level < 1 || node.Level > 5 {
+			return nil, errors.New("heading level should be between 1 and 5")
+		}
+		content, err := inlineBlocksFromJSON(node.Content)
+		if err != nil {
+			return nil, err
+		}
+		return &Heading{BaseBlock: base, Class: Heading1Type + HeadingType(node.Level-1), Content: content}, nil
+	case "hr":
+		return &HorizontalRule{BaseBlock: base}, nil
+	case "list":
+		items, err := blocksFromJSON(node.Items)
+		if err != nil {
+			return nil, err
+		}
+		return &List{BaseBlock: base, Items: items, Ordered: node.Ordered}, nil
+	case "table":
+		rows := make([]TableRow, 0, len(node.Rows))
+		for i := range node.Rows {
+			row := TableRow{Cells: make([]TableCell, 0, len(node.Rows[i].Cells))}
+			for j := range node.Rows[i].Cells {
+				content, err := blocksFromJSON(node.Rows[i].Cells[j].Content)
+				if err != nil {
+					return nil, err
+				}
+				row.Cells = append(row.Cells, TableCell{Content: content, IsHeader: node.Rows[i].Cells[j].Header})
+			}
+			rows = append(rows, row)
+		}
+		return &Table{BaseBlock: base, Rows: rows}, nil
+	case "collapse":
+		summary, err := inlineBlocksFromJSON(node.Summary)
+		if err != nil {
+			return nil, err
+		}
+		content, err := blocksFromJSON(node.Content)
+		if err != nil {
+			return nil, err
+		}
+		return &Collapse{BaseBlock: base, Summary: summary, Content: content}, nil
+	case "break":
+		return &PageBreak{BaseBlock: base}, nil
+	case "notes":
+		content, err := blocksFromJSON(node.Content)
+		if err != nil {
+			return nil, err
+		}
+		return &Notes{BaseBlock: base, Content: content}, nil
+	}
+	return nil, fmt.Errorf("invalid block type '%s'", node.Type)
+}
+
+// Convert a slice of JSON nodes to inline blocks.
+func inlineBlocksFromJSON(nodes []jsonNode) ([]InlineBlock, error) {
+	blocks := make([]InlineBlock, 0, len(nodes))
+	for i := range nodes {
+		block, err := inlineBlockFromJSON(&nodes[i])
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// Convert a JSON node to an inline block.
+func inlineBlockFromJSON(node *jsonNode) (InlineBlock, error) {
+	if node.Type == "text" {
+		return &Text{Value: node.Value}, nil
+	}
+	if node.Type == "inline-image" {
+		block := &InlineImageBlock{Source: node.Source, Alt: node.Alt}
+		var err error
+		block.HasWidthParameter, block.WidthValue, block.WidthType, err = sizeFromJSON(node.Width)
+		if err != nil {
+			return nil, err
+		}
+		block.HasHeightParameter, block.HeightValue, block.HeightType, err = sizeFromJSON(node.Height)
+		if err != nil {
+			return nil, err
+		}
+		return block, nil
+	}
+
+	content, err := inlineBlocksFromJSON(node.Content)
+	if err != nil {
+		return nil, err
+	}
+	base := BaseInlineBlock{Content: content}
+
+	switch node.Type {
+	case "link":
+		return &HyperlinkBlock{BaseInlineBlock: base, Destination: node.Dest}, nil
+	case "formatting":
+		attribute, err := formattingFromJSON(node.Format)
+		if err != nil {
+			return nil, err
+		}
+		return &FormattingBlock{BaseInlineBlock: base, Attribute: attribute}, nil
+	case "color":
+		block := &ColorBlock{BaseInlineBlock: base}
+		if node.Foreground != "" {
+			block.ForegroundValue, err = colors.Parse(node.Foreground)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if node.Background != "" {
+			block.BackgroundValue, err = colors.Parse(node.Background)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return block, nil
+	case "size":
+		if node.Size == nil {
+			return nil, errors.New("'size' node expects a 'size' field")
+		}
+		_, value, sizeType, err := sizeFromJSON(node.Size)
+		if err != nil {
+			return nil, err
+		}
+		return &SizeBlock{BaseInlineBlock: base, Value: value, Type: sizeType}, nil
+	case "font":
+		return &FontBlock{BaseInlineBlock: base, Family: node.Family}, nil
+	}
+	return nil, fmt.Errorf("invalid inline block type '%s'", node.Type)
+}
+
+// Get the JSON value of an alignment type.
+func alignmentToJSON(a AlignmentType) (string, error) {
+	switch a {
+	case NoAlign:
+		return "", nil
+	case LeftAlign:
+		return "left", nil
+	case RightAlign:
+		return "right", nil
+	case CenterAlign:
+		return "center", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Get the alignment type of a JSON value.
+func alignmentFromJSON(s string) (AlignmentType, error) {
+	switch s {
+	case "", "none":
+		return NoAlign, nil
+	case "left":
+		return LeftAlign, nil
+	case "right":
+		return RightAlign, nil
+	case "center":
+		return CenterAlign, nil
+	}
+	return 0, fmt.Errorf("invalid alignment '%s'", s)
+}
+
+// Get the JSON value of a formatting type.
+func formattingToJSON(t FormattingType) (string, error) {
+	switch t {
+	case BoldFormatting:
+		return "bold", nil
+	case ItalicFormatting:
+		return "italic", nil
+	case StrikethroughFormatting:
+		return "strikethrough", nil
+	case UnderlineFormatting:
+		return "underline", nil
+	case TeletypeFormatting:
+		return "teletype", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Get the formatting type of a JSON value.
+func formattingFromJSON(s string) (FormattingType, error) {
+	switch s {
+	case "bold":
+		return BoldFormatting, nil
+	case "italic":
+		return ItalicFormatting, nil
+	case "strikethrough":
+		return StrikethroughFormatting, nil
+	case "underline":
+		return UnderlineFormatting, nil
+	case "teletype":
+		return TeletypeFormatting, nil
+	}
+	return 0, fmt.Errorf("invalid formatting '%s'", s)
+}
+
+// Get the JSON value of a size.
+func sizeToJSON(value float32, t SizeType) (*jsonSize, error) {
+	var unit string
+	switch t {
+	case PercentageSizeType:
+		unit = "percent"
+	case PixelSizeType:
+		unit = "px"
+	case PointSizeType:
+		unit = "pt"
+	case CentimeterSizeType:
+		unit = "cm"
+	case MillimeterSizeType:
+		unit = "mm"
+	default:
+		return nil, errors.New("invalid ast")
+	}
+	return &jsonSize{Value: value, Unit: unit}, nil
+}
+
+// Get the size of a JSON value. Returns false if the value is nil.
+func sizeFromJSON(s *jsonSize) (bool, float32, SizeType, error) {
+	if s == nil {
+		return false, 0, 0, nil
+	}
+	switch s.Unit {
+	case "percent":
+		return true, s.Value, PercentageSizeType, nil
+	case "px":
+		return true, s.Value, PixelSizeType, nil
+	case "pt":
+		return true, s.Value, PointSizeType, nil
+	case "cm":
+		return true, s.Value, CentimeterSizeType, nil
+	case "mm":
+		return true, s.Value, MillimeterSizeType, nil
+	}
+	return false, 0, 0, fmt.Errorf("invalid size unit '%s'", s.Unit)
+}
+
+// Get the JSON values of an image's width and height.
+func imageSizeToJSON(hasWidth bool, widthValue float32, widthType SizeType, hasHeight bool, heightValue float32, heightType SizeType) (*jsonSize, *jsonSize, error) {
+	var width, height *jsonSize
+	var err error
+	if hasWidth {
+		width, err = sizeToJSON(widthValue, widthType)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if hasHeight {
+		height, err = sizeToJSON(heightValue, heightType)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return width, height, nil
+}
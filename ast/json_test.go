@@ -0,0 +1,127 @@
+// ast/json_test.go
+// JSON serialization tests.
+
+package ast
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/go-playground/colors.v1"
+)
+
+// Create inline content holding text.
+func testText(s string) []InlineBlock {
+	return []InlineBlock{&Text{Value: s}}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	red, err := colors.Parse("#ff0000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blue, err := colors.Parse("rgb(0,0,255)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		doc  *Document
+	}{
+		{"empty", &Document{Content: []Block{}}},
+		{"information", &Document{Title: "a", Subtitle: "b", Date: "c", Author: "d", Content: []Block{}}},
+		{"blocks", &Document{Content: []Block{
+			&Heading{BaseBlock: BaseBlock{Alignment: RightAlign, ID: "h"}, Class: Heading5Type, Content: testText("heading")},
+			&Paragraph{BaseBlock: BaseBlock{Wrap: true, Classes: []string{"a", "b"}}, Content: testText("a\nb")},
+			&Image{Source: "a.png", Alt: "alt", HasCaption: true, Caption: testText("caption"), HasWidthParameter: true, WidthValue: 2.5, WidthType: MillimeterSizeType, HasHeightParameter: true, HeightValue: 12, HeightType: PointSizeType},
+			&List{Ordered: true, Items: []Block{testParagraph("one"), &List{Items: []Block{testParagraph("two")}}}},
+			&Table{Rows: []TableRow{
+				{Cells: []TableCell{{IsHeader: true, Content: []Block{testParagraph("h")}}, {IsHeader: true, Content: []Block{}}}},
+				{Cells: []TableCell{{Content: []Block{testParagraph("c")}}, {Content: []Block{&Quote{Content: []Block{testParagraph("q")}}}}}},
+			}},
+			&HorizontalRule{BaseBlock: BaseBlock{Alignment: CenterAlign}},
+			&Collapse{Summary: testText("summary"), Content: []Block{&BasicBlock{Content: []Block{testParagraph("inside")}}}},
+			&PageBreak{},
+			&Notes{Content: []Block{testParagraph("notes")}},
+		}}},
+		{"inline blocks", &Document{Content: []Block{&Paragraph{Content: []InlineBlock{
+			&HyperlinkBlock{BaseInlineBlock: BaseInlineBlock{Content: testText("link")}, Destination: "https://example.com"},
+			&FormattingBlock{BaseInlineBlock: BaseInlineBlock{Content: []InlineBlock{
+				&FormattingBlock{BaseInlineBlock: BaseInlineBlock{Content: testText("nested")}, Attribute: ItalicFormatting},
+			}}, Attribute: BoldFormatting},
+			&FormattingBlock{BaseInlineBlock: BaseInlineBlock{Content: testText("s")}, Attribute: StrikethroughFormatting},
+			&FormattingBlock{BaseInlineBlock: BaseInlineBlock{Content: testText("u")}, Attribute: UnderlineFormatting},
+			&FormattingBlock{BaseInlineBlock: BaseInlineBlock{Content: testText("t")}, Attribute: TeletypeFormatting},
+			&ColorBlock{BaseInlineBlock: BaseInlineBlock{Content: testText("fg")}, ForegroundValue: red},
+			&ColorBlock{BaseInlineBlock: BaseInlineBlock{Content: testText("both")}, ForegroundValue: red, BackgroundValue: blue},
+			&SizeBlock{BaseInlineBlock: BaseInlineBlock{Content: testText("size")}, Value: 150, Type: PercentageSizeType},
+			&FontBlock{BaseInlineBlock: BaseInlineBlock{Content: testText("font")}, Family: "Georgia, serif"},
+			&InlineImageBlock{Source: "i.png", Alt: "i", HasHeightParameter: true, HeightValue: 1, HeightType: CentimeterSizeType},
+		}}}}},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(test.doc)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		got := &Document{}
+		if err := json.Unmarshal(data, got); err != nil {
+			t.Errorf("%s: %v\n%s", test.name, err, data)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.doc) {
+			again, _ := json.Marshal(got)
+			t.Errorf("%s: round trip changed the document\n%s\n%s", test.name, data, again)
+		}
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := []struct {
+		json string
+		err  string
+	}{
+		{`{"version":5,"content":[]}`, "unsupported json schema version 5"},
+		{`{"content":[]}`, "unsupported json schema version 0"},
+		{`{"version":1,"content":[{"type":"unknown"}]}`, "unknown"},
+		{`{"version":1,"content":[{"type":"paragraph","content":[{"type":"color","fg":"nope"}]}]}`, "color"},
+		{`{"version":1,"content":[{"type":"image","src":"a.png","alt":"a"}]}`, "json schema version 2 is needed for the 'alt' field, but the document has version 1"},
+		{`{"version":2,"content":[{"type":"notes","content":[]}]}`, "json schema version 3 is needed for the 'notes' node"},
+		{`{"version":3,"content":[{"type":"list","items":[{"type":"paragraph","id":"a","content":[]}]}]}`, "json schema version 4 is needed for the 'class' and 'id' fields"},
+		{`{"version":3,"content":[{"type":"table","rows":[{"cells":[{"content":[{"type":"paragraph","class":["a"],"content":[]}]}]}]}]}`, "json schema version 4 is needed"},
+	}
+	for _, test := range tests {
+		err := json.Unmarshal([]byte(test.json), &Document{})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.json, err, test.err)
+		}
+	}
+}
+
+func TestJSONVersions(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"version 1", `{"version":1,"content":[{"type":"paragraph","content":[{"type":"text","value":"a"}]}]}`},
+		{"version 2", `{"version":2,"content":[{"type":"paragraph","content":[{"type":"inline-image","src":"a.png","alt":"a"}]}]}`},
+		{"version 3", `{"version":3,"content":[{"type":"notes","content":[{"type":"image","src":"a.png","alt":"a"}]}]}`},
+		{"version 4", `{"version":4,"content":[{"type":"notes","id":"n","class":["a"],"content":[]}]}`},
+	}
+	for _, test := range tests {
+		if err := json.Unmarshal([]byte(test.json), &Document{}); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+
+	data, err := json.Marshal(&Document{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"version":4,"content":[]}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,258 @@
+// ast/transform.go
+// Rewriting traversal of the abstract syntax tree.
+
+package ast
+
+// A cursor describes a node encountered during Transform. The node can be
+// replaced, deleted or have nodes inserted around it.
+type Cursor struct {
+	parent Node
+	name   string
+	list   nodeList
+	index  int
+
+	// The number of nodes inserted after the current node, which are
+	// skipped by the traversal.
+	inserted int
+
+	// If the current node was deleted. The index is then that of the node
+	// following it.
+	deleted bool
+}
+
+// Transform function. If the function returns false in the pre-order call,
+// the node's children and post-order call are skipped.
+type TransformFunc func(c *Cursor) bool
+
+// Transform the document's nodes in depth-first order. For each node, pre is
+// called before the node's children are traversed and post is called after.
+// Either function may be nil. Nodes inserted with the cursor are not
+// traversed, but a node replacing the current node in pre is.
+func Transform(d *Document, pre, post TransformFunc) {
+	t := transformer{pre: pre, post: post}
+	t.applyList(d, "Content", blockList{&d.Content})
+}
+
+// Get the current node.
+func (c *Cursor) Node() Node {
+	if c.deleted {
+		return nil
+	}
+	return c.list.at(c.index)
+}
+
+// Get the parent of the current node.
+func (c *Cursor) Parent() Node {
+	return c.parent
+}
+
+// Get the name of the parent's field holding the current node, such as
+// "Content", "Caption", "Summary", "Items", "Rows" or "Cells".
+func (c *Cursor) Name() string {
+	return c.name
+}
+
+// Get the index of the current node in the parent's field. After Delete, this
+// is the index the node had.
+func (c *Cursor) Index() int {
+	return c.index
+}
+
+// Replace the current node. The node must have the same kind (block, inline
+// block, row or cell) as the node it replaces.
+func (c *Cursor) Replace(n Node) {
+	if c.deleted {
+		panic("ast: Replace called on a deleted node")
+	}
+	c.list.set(c.index, n)
+}
+
+// Delete the current node.
+func (c *Cursor) Delete() {
+	if c.deleted {
+		panic("ast: Delete called on a deleted node")
+	}
+	c.list.remove(c.index)
+	c.deleted = true
+}
+
+// Insert a node before the current node, or where it was if it was deleted.
+func (c *Cursor) InsertBefore(n Node) {
+	c.list.insert(c.index, n)
+	c.index++
+}
+
+// Insert a node after the current node, or where it was if it was deleted.
+func (c *Cursor) InsertAfter(n Node) {
+	if c.deleted {
+		c.list.insert(c.index, n)
+	} else {
+		c.list.insert(c.index+1, n)
+	}
+	c.inserted++
+}
+
+// The AST transformer.
+type transformer struct {
+	pre, post TransformFunc
+}
+
+// Apply the transform functions to each node in a list.
+func (t *transformer) applyList(parent Node, name string, list nodeList) {
+	for i := 0; i < list.len(); {
+		c := &Cursor{parent: parent, name: name, list: list, index: i}
+		t.apply(c)
+		i = c.index + c.inserted
+		if !c.deleted {
+			i++
+		}
+	}
+}
+
+// Apply the transform functions to a node and its children.
+func (t *transformer) apply(c *Cursor) {
+	if t.pre != nil && !t.pre(c) {
+		return
+	}
+	if c.deleted {
+		return
+	}
+
+	// Transform the children.
+	switch n := c.Node().(type) {
+	case *Paragraph:
+		t.applyList(n, "Content", inlineList{&n.Content})
+	case *BasicBlock:
+		t.applyList(n, "Content", blockList{&n.Content})
+	case *Quote:
+		t.applyList(n, "Content", blockList{&n.Content})
+	case *Image:
+		t.applyList(n, "Caption", inlineList{&n.Caption})
+	case *Heading:
+		t.applyList(n, "Content", inlineList{&n.Content})
+	case *List:
+		t.applyList(n, "Items", blockList{&n.Items})
+	case *Table:
+		t.applyList(n, "Rows", rowList{&n.Rows})
+	case *TableRow:
+		t.applyList(n, "Cells", cellList{&n.Cells})
+	case *TableCell:
+		t.applyList(n, "Content", blockList{&n.Content})
+	case *Collapse:
+		t.applyList(n, "Summary", inlineList{&n.Summary})
+		t.applyList(n, "Content", blockList{&n.Content})
+	case *Notes:
+		t.applyList(n, "Content", blockList{&n.Content})
+	case *HyperlinkBlock:
+		t.applyList(n, "Content", inlineList{&n.Content})
+	case *FormattingBlock:
+		t.applyList(n, "Content", inlineList{&n.Content})
+	case *ColorBlock:
+		t.applyList(n, "Content", inlineList{&n.Content})
+	case *SizeBlock:
+		t.applyList(n, "Content", inlineList{&n.Content})
+	case *FontBlock:
+		t.applyList(n, "Content", inlineList{&n.Content})
+	}
+
+	if t.post != nil {
+		t.post(c)
+	}
+}
+
+// A slice of nodes being transformed.
+type nodeList interface {
+	len() int
+	at(i int) Node
+	set(i int, n Node)
+	insert(i int, n Node)
+	remove(i int)
+}
+
+// A slice of blocks.
+type blockList struct {
+	s *[]Block
+}
+
+func (l blockList) len() int      { return len(*l.s) }
+func (l blockList) at(i int) Node { return (*l.s)[i] }
+
+func (l blockList) set(i int, n Node) {
+	(*l.s)[i] = n.(Block)
+}
+
+func (l blockList) insert(i int, n Node) {
+	*l.s = append(*l.s, nil)
+	copy((*l.s)[i+1:], (*l.s)[i:])
+	(*l.s)[i] = n.(Block)
+}
+
+func (l blockList) remove(i int) {
+	*l.s = append((*l.s)[:i], (*l.s)[i+1:]...)
+}
+
+// A slice of inline blocks.
+type inlineList struct {
+	s *[]InlineBlock
+}
+
+func (l inlineList) len() int      { return len(*l.s) }
+func (l inlineList) at(i int) Node { return (*l.s)[i] }
+
+func (l inlineList) set(i int, n Node) {
+	(*l.s)[i] = n.(InlineBlock)
+}
+
+func (l inlineList) insert(i int, n Node) {
+	*l.s = append(*l.s, nil)
+	copy((*l.s)[i+1:], (*l.s)[i:])
+	(*l.s)[i] = n.(InlineBlock)
+}
+
+func (l inlineList) remove(i int) {
+	*l.s = append((*l.s)[:i], (*l.s)[i+1:]...)
+}
+
+// A slice of table rows.
+type rowList struct {
+	s *[]TableRow
+}
+
+func (l rowList) len() int      { return len(*l.s) }
+func (l rowList) at(i int) Node { return &(*l.s)[i] }
+
+func (l rowList) set(i int, n Node) {
+	(*l.s)[i] = *n.(*TableRow)
+}
+
+func (l rowList) insert(i int, n Node) {
+	*l.s = append(*l.s, TableRow{})
+	copy((*l.s)[i+1:], (*l.s)[i:])
+	(*l.s)[i] = *n.(*TableRow)
+}
+
+func (l rowList) remove(i int) {
+	*l.s = append((*l.s)[:i], (*l.s)[i+1:]...)
+}
+
+// A slice of table cells.
+type cellList struct {
+	s *[]TableCell
+}
+
+func (l cellList) len() int      { return len(*l.s) }
+func (l cellList) at(i int) Node { return &(*l.s)[i] }
+
+func (l cellList) set(i int, n Node) {
+	(*l.s)[i] = *n.(*TableCell)
+}
+
+func (l cellList) insert(i int, n Node) {
+	*l.s = append(*l.s, TableCell{})
+	copy((*l.s)[i+1:], (*l.s)[i:])
+	(*l.s)[i] = *n.(*TableCell)
+}
+
+func (l cellList) remove(i int) {
+	*l.s = append((*l.s)[:i], (*l.s)[i+1:]...)
+}
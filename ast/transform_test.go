@@ -0,0 +1,148 @@
+// ast/transform_test.go
+// Transform tests.
+
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Create a paragraph holding text.
+func testParagraph(text string) *Paragraph {
+	return &Paragraph{Content: []InlineBlock{&Text{Value: text}}}
+}
+
+// Get the text of each paragraph in a document.
+func paragraphTexts(d *Document) []string {
+	texts := []string{}
+	for i := range d.Content {
+		texts = append(texts, PlainText(d.Content[i].(*Paragraph).Content))
+	}
+	return texts
+}
+
+func TestTransformCursor(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(c *Cursor)
+
+		// The results of editing the first, middle and last of the paragraphs
+		// "a", "b" and "c".
+		results [3][]string
+	}{
+		{
+			name:    "delete",
+			edit:    func(c *Cursor) { c.Delete() },
+			results: [3][]string{{"b", "c"}, {"a", "c"}, {"a", "b"}},
+		},
+		{
+			name:    "insert before",
+			edit:    func(c *Cursor) { c.InsertBefore(testParagraph("x")) },
+			results: [3][]string{{"x", "a", "b", "c"}, {"a", "x", "b", "c"}, {"a", "b", "x", "c"}},
+		},
+		{
+			name: "insert after",
+			edit: func(c *Cursor) {
+				c.InsertAfter(testParagraph("x"))
+				c.InsertAfter(testParagraph("y"))
+			},
+			results: [3][]string{{"a", "y", "x", "b", "c"}, {"a", "b", "y", "x", "c"}, {"a", "b", "c", "y", "x"}},
+		},
+		{
+			name:    "replace",
+			edit:    func(c *Cursor) { c.Replace(testParagraph("x")) },
+			results: [3][]string{{"x", "b", "c"}, {"a", "x", "c"}, {"a", "b", "x"}},
+		},
+		{
+			name: "delete and insert before",
+			edit: func(c *Cursor) {
+				c.Delete()
+				c.InsertBefore(testParagraph("x"))
+			},
+			results: [3][]string{{"x", "b", "c"}, {"a", "x", "c"}, {"a", "b", "x"}},
+		},
+		{
+			name: "delete and insert after",
+			edit: func(c *Cursor) {
+				c.Delete()
+				c.InsertAfter(testParagraph("x"))
+			},
+			results: [3][]string{{"x", "b", "c"}, {"a", "x", "c"}, {"a", "b", "x"}},
+		},
+		{
+			name: "insert around",
+			edit: func(c *Cursor) {
+				c.InsertBefore(testParagraph("x"))
+				c.InsertAfter(testParagraph("y"))
+			},
+			results: [3][]string{{"x", "a", "y", "b", "c"}, {"a", "x", "b", "y", "c"}, {"a", "b", "x", "c", "y"}},
+		},
+	}
+
+	for _, test := range tests {
+		for i, target := range []string{"a", "b", "c"} {
+			d := &Document{Content: []Block{testParagraph("a"), testParagraph("b"), testParagraph("c")}}
+			visited := []string{}
+			Transform(d, func(c *Cursor) bool {
+				if p, ok := c.Node().(*Paragraph); ok {
+					text := PlainText(p.Content)
+					visited = append(visited, text)
+					if text == target {
+						test.edit(c)
+					}
+				}
+				return true
+			}, nil)
+
+			// Each original paragraph is visited once, and a replacing
+			// paragraph's children are visited, but not the paragraph itself.
+			if want := []string{"a", "b", "c"}; !reflect.DeepEqual(visited, want) {
+				t.Errorf("%s %s: visited %q, want %q", test.name, target, visited, want)
+			}
+			if result := paragraphTexts(d); !reflect.DeepEqual(result, test.results[i]) {
+				t.Errorf("%s %s: got %q, want %q", test.name, target, result, test.results[i])
+			}
+		}
+	}
+}
+
+func TestTransformReplaceChildren(t *testing.T) {
+	d := &Document{Content: []Block{testParagraph("a")}}
+	visited := []string{}
+	Transform(d, func(c *Cursor) bool {
+		switch n := c.Node().(type) {
+		case *Paragraph:
+			if PlainText(n.Content) == "a" {
+				c.Replace(testParagraph("x"))
+			}
+		case *Text:
+			visited = append(visited, n.Value)
+		}
+		return true
+	}, nil)
+	if want := []string{"x"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %q, want %q", visited, want)
+	}
+}
+
+func TestTransformPostInsertAfter(t *testing.T) {
+	d := &Document{Content: []Block{testParagraph("a"), testParagraph("b")}}
+	visited := []string{}
+	Transform(d, nil, func(c *Cursor) bool {
+		if p, ok := c.Node().(*Paragraph); ok {
+			text := PlainText(p.Content)
+			visited = append(visited, text)
+			if text == "a" {
+				c.InsertAfter(testParagraph("x"))
+			}
+		}
+		return true
+	})
+	if want := []string{"a", "b"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %q, want %q", visited, want)
+	}
+	if want, result := []string{"a", "x", "b"}, paragraphTexts(d); !reflect.DeepEqual(result, want) {
+		t.Errorf("got %q, want %q", result, want)
+	}
+}
@@ -0,0 +1,219 @@
+// ast/validate.go
+// Schema and semantic validation of the abstract syntax tree.
+
+package ast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Validation rules. The zero value checks nothing.
+type ValidationRules struct {
+	// Allowed child block types, by parent type name (see TypeName). Parents
+	// missing from the map allow any block.
+	AllowedChildren map[string][]string
+
+	// Report headings that skip a level, like a level 3 heading following a
+	// level 1 heading.
+	HeadingLevelSkips bool
+
+	// Report links without a destination or content.
+	EmptyLinks bool
+
+	// Report images without a caption.
+	ImageCaptions bool
+
+	// Report images and inline images without alternate text.
+	ImageAltText bool
+
+	// The maximum block nesting depth. Blocks in the document's content have
+	// a depth of 1. Zero means unlimited.
+	MaxDepth int
+}
+
+// Get the default validation rules.
+func DefaultValidationRules() ValidationRules {
+	content := []string{"paragraph", "block", "quote", "image", "heading", "hr", "list", "table", "collapse", "notes"}
+	return ValidationRules{
+		AllowedChildren: map[string][]string{
+			"block":      content,
+			"quote":      content,
+			"collapse":   content,
+			"notes":      content[:len(content)-1],
+			"list":       {"paragraph", "block", "quote", "image", "list"},
+			"table-cell": {"paragraph", "block", "quote", "image", "list", "hr"},
+		},
+		HeadingLevelSkips: true,
+		EmptyLinks:        true,
+		ImageAltText:      true,
+		MaxDepth:          16,
+	}
+}
+
+// Source position. A line of zero means the position is unknown.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// Validation warning.
+type Warning struct {
+	// The rule that produced the warning, like "allowed-children".
+	Rule    string
+	Message string
+
+	// The node the warning is about, and its path from the document, like
+	// "content[2].items[0]".
+	Node Node
+	Path string
+
+	// The node's source position, if known.
+	Position Position
+}
+
+// Format the warning as "line:column: message (rule)".
+func (w Warning) String() string {
+	if w.Position.Line == 0 {
+		return w.Path + ": " + w.Message + " (" + w.Rule + ")"
+	}
+	return strconv.Itoa(w.Position.Line) + ":" + strconv.Itoa(w.Position.Column) + ": " + w.Message + " (" + w.Rule + ")"
+}
+
+// Validate a document. Returns the warnings in document order.
+func Validate(d *Document, rules ValidationRules) []Warning {
+	// The title, if any, is rendered as the first level heading. Without one,
+	// a document may still start at level 2.
+	v := validator{rules: rules, lastHeading: 1}
+	v.validateBlocks(d, "content", d.Content, 1)
+	return v.warnings
+}
+
+// The document validator.
+type validator struct {
+	rules    ValidationRules
+	warnings []Warning
+
+	lastHeading int
+}
+
+// Add a warning.
+func (v *validator) warn(rule string, node Node, path, format string, args ...interface{}) {
+	v.warnings = append(v.warnings, Warning{
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+		Node:    node,
+		Path:    path,
+	})
+}
+
+// Validate a slice of blocks.
+func (v *validator) validateBlocks(parent Node, path string, blocks []Block, depth int) {
+	parentType := TypeName(parent)
+	allowed, restricted := v.rules.AllowedChildren[parentType]
+	for i := range blocks {
+		blockPath := path + "[" + strconv.Itoa(i) + "]"
+		if restricted && !containsString(allowed, TypeName(blocks[i])) {
+			v.warn("allowed-children", blocks[i], blockPath, "'%s' is not allowed in '%s'", TypeName(blocks[i]), parentType)
+		}
+		v.validateBlock(blocks[i], blockPath, depth)
+	}
+}
+
+// Validate a block.
+func (v *validator) validateBlock(b Block, path string, depth int) {
+	if v.rules.MaxDepth != 0 && depth == v.rules.MaxDepth+1 {
+		v.warn("max-depth", b, path, "block is nested deeper than %d levels", v.rules.MaxDepth)
+	}
+
+	switch block := b.(type) {
+	case *Paragraph:
+		v.validateInlineBlocks(block.Content, path+".content")
+	case *BasicBlock:
+		v.validateBlocks(block, path+".content", block.Content, depth+1)
+	case *Quote:
+		v.validateBlocks(block, path+".content", block.Content, depth+1)
+	case *Image:
+		if v.rules.ImageCaptions && (!block.HasCaption || strings.TrimSpace(PlainText(block.Caption)) == "") {
+			v.warn("image-caption", block, path, "image '%s' has no caption", block.Source)
+		}
+		if v.rules.ImageAltText && strings.TrimSpace(block.Alt) == "" {
+			v.warn("image-alt-text", block, path, "image '%s' has no alternate text", block.Source)
+		}
+		v.validateInlineBlocks(block.Caption, path+".caption")
+	case *Heading:
+		level := int(block.Class-Heading1Type) + 1
+		if v.rules.HeadingLevelSkips && level > v.lastHeading+1 {
+			v.warn("heading-level-skip", block, path, "level %d heading follows level %d heading", level, v.lastHeading)
+		}
+		v.lastHeading = level
+		v.validateInlineBlocks(block.Content, path+".content")
+	case *List:
+		v.validateBlocks(block, path+".items", block.Items, depth+1)
+	case *Table:
+		for i := range block.Rows {
+			for j := range block.Rows[i].Cells {
+				cell := &block.Rows[i].Cells[j]
+				v.validateBlocks(cell, path+".rows["+strconv.Itoa(i)+"].cells["+strconv.Itoa(j)+"].content", cell.Content, depth+1)
+			}
+		}
+	case *Collapse:
+		v.validateInlineBlocks(block.Summary, path+".summary")
+		v.validateBlocks(block, path+".content", block.Content, depth+1)
+	case *Notes:
+		v.validateBlocks(block, path+".content", block.Content, depth+1)
+	}
+}
+
+// Validate a slice of inline blocks.
+func (v *validator) validateInlineBlocks(blocks []InlineBlock, path string) {
+	for i := range blocks {
+		blockPath := path + "[" + strconv.Itoa(i) + "]"
+		switch block := blocks[i].(type) {
+		case *HyperlinkBlock:
+			if v.rules.EmptyLinks {
+				if strings.TrimSpace(block.Destination) == "" {
+					v.warn("empty-link", block, blockPath, "link has no destination")
+				} else if !hasVisibleContent(block.Content) {
+					v.warn("empty-link", block, blockPath, "link to '%s' has no content", block.Destination)
+				}
+			}
+		case *InlineImageBlock:
+			if v.rules.ImageAltText && strings.TrimSpace(block.Alt) == "" {
+				v.warn("image-alt-text", block, blockPath, "inline image '%s' has no alternate text", block.Source)
+			}
+		}
+		v.validateInlineBlocks(blocks[i].Children(), blockPath+".content")
+	}
+}
+
+// Check if inline content has text or images.
+func hasVisibleContent(blocks []InlineBlock) bool {
+	for i := range blocks {
+		switch block := blocks[i].(type) {
+		case *Text:
+			if strings.TrimSpace(block.Value) != "" {
+				return true
+			}
+		case *InlineImageBlock:
+			return true
+		default:
+			if hasVisibleContent(block.Children()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check if a slice contains a string.
+func containsString(s []string, v string) bool {
+	for i := range s {
+		if s[i] == v {
+			return true
+		}
+	}
+	return false
+}
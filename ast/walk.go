@@ -0,0 +1,160 @@
+// ast/walk.go
+// Generic traversal of the abstract syntax tree.
+
+package ast
+
+import "strings"
+
+// Node for AST traversal. A node is a *Document, a Block, a *TableRow, a
+// *TableCell or an InlineBlock.
+type Node interface{}
+
+// A visitor's Visit method is called for each node encountered by Walk. If
+// the returned visitor is not nil, Walk visits each of the node's children
+// with it, followed by a call of Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk the tree in depth-first order, starting with the node.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	children := Children(node)
+	for i := range children {
+		Walk(v, children[i])
+	}
+	v.Visit(nil)
+}
+
+// Function visitor for Inspect.
+type inspector func(Node) bool
+
+// Visit a node.
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect the tree in depth-first order, starting with the node. The function
+// is called for each node. If it returns true, the node's children are
+// inspected, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Get a node's children, in document order. A collapse block's summary comes
+// before its content and an image's caption is its only children.
+func Children(node Node) []Node {
+	children := make([]Node, 0)
+	appendBlocks := func(blocks []Block) {
+		for i := range blocks {
+			children = append(children, blocks[i])
+		}
+	}
+	appendInlineBlocks := func(blocks []InlineBlock) {
+		for i := range blocks {
+			children = append(children, blocks[i])
+		}
+	}
+
+	switch n := node.(type) {
+	case *Document:
+		appendBlocks(n.Content)
+	case *Paragraph:
+		appendInlineBlocks(n.Content)
+	case *BasicBlock:
+		appendBlocks(n.Content)
+	case *Quote:
+		appendBlocks(n.Content)
+	case *Image:
+		appendInlineBlocks(n.Caption)
+	case *Heading:
+		appendInlineBlocks(n.Content)
+	case *List:
+		appendBlocks(n.Items)
+	case *Table:
+		for i := range n.Rows {
+			children = append(children, &n.Rows[i])
+		}
+	case *TableRow:
+		for i := range n.Cells {
+			children = append(children, &n.Cells[i])
+		}
+	case *TableCell:
+		appendBlocks(n.Content)
+	case *Collapse:
+		appendInlineBlocks(n.Summary)
+		appendBlocks(n.Content)
+	case *Notes:
+		appendBlocks(n.Content)
+	case InlineBlock:
+		appendInlineBlocks(n.Children())
+	}
+	return children
+}
+
+// Get the plain text content of a slice of inline blocks.
+func PlainText(blocks []InlineBlock) string {
+	out := strings.Builder{}
+	for i := range blocks {
+		if t, ok := blocks[i].(*Text); ok {
+			out.WriteString(t.Value)
+			continue
+		}
+		out.WriteString(PlainText(blocks[i].Children()))
+	}
+	return out.String()
+}
+
+// Get a node's type name. Type names match the JSON schema's node types.
+func TypeName(node Node) string {
+	switch node.(type) {
+	case *Document:
+		return "document"
+	case *Paragraph:
+		return "paragraph"
+	case *BasicBlock:
+		return "block"
+	case *Quote:
+		return "quote"
+	case *Image:
+		return "image"
+	case *Heading:
+		return "heading"
+	case *HorizontalRule:
+		return "hr"
+	case *List:
+		return "list"
+	case *Table:
+		return "table"
+	case *TableRow:
+		return "table-row"
+	case *TableCell:
+		return "table-cell"
+	case *Collapse:
+		return "collapse"
+	case *PageBreak:
+		return "break"
+	case *Notes:
+		return "notes"
+	case *Text:
+		return "text"
+	case *HyperlinkBlock:
+		return "link"
+	case *FormattingBlock:
+		return "formatting"
+	case *ColorBlock:
+		return "color"
+	case *SizeBlock:
+		return "size"
+	case *FontBlock:
+		return "font"
+	case *InlineImageBlock:
+		return "inline-image"
+	}
+	return ""
+}
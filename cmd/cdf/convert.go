@@ -0,0 +1,309 @@
+// cmd/cdf/convert.go
+// Document conversion.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	"github.com/cubeflix/cdf/export/asciidoc"
+	"github.com/cubeflix/cdf/export/docx"
+	"github.com/cubeflix/cdf/export/epub"
+	"github.com/cubeflix/cdf/export/gemtext"
+	"github.com/cubeflix/cdf/export/gopher"
+	"github.com/cubeflix/cdf/export/html"
+	"github.com/cubeflix/cdf/export/latex"
+	"github.com/cubeflix/cdf/export/man"
+	"github.com/cubeflix/cdf/export/markdown"
+	"github.com/cubeflix/cdf/export/odt"
+	"github.com/cubeflix/cdf/export/pandoc"
+	"github.com/cubeflix/cdf/export/pdf"
+	"github.com/cubeflix/cdf/export/rst"
+	"github.com/cubeflix/cdf/export/slides"
+	"github.com/cubeflix/cdf/export/text"
+	"github.com/cubeflix/cdf/importer"
+	htmlimporter "github.com/cubeflix/cdf/importer/html"
+	mdimporter "github.com/cubeflix/cdf/importer/markdown"
+	pandocimporter "github.com/cubeflix/cdf/importer/pandoc"
+	"github.com/cubeflix/cdf/parser"
+)
+
+// The supported input and output formats.
+const (
+	inputFormatNames  = "cdf, html, json, markdown, pandoc"
+	themeNames        = "none, plain, serif, dark"
+	outputFormatNames = "asciidoc, cdf, docx, epub, gemtext, gopher, html, json, latex, man, markdown, commonmark, odt, pandoc, pdf, rst, slides, text"
+)
+
+// Get a format from a file name's extension.
+func formatFromExtension(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".cdf":
+		return "cdf"
+	case ".json":
+		return "json"
+	case ".html", ".htm":
+		return "html"
+	case ".md", ".markdown":
+		return "markdown"
+	case ".tex":
+		return "latex"
+	case ".pdf":
+		return "pdf"
+	case ".epub":
+		return "epub"
+	case ".docx":
+		return "docx"
+	case ".odt":
+		return "odt"
+	case ".txt":
+		return "text"
+	case ".rst":
+		return "rst"
+	case ".adoc", ".asciidoc":
+		return "asciidoc"
+	case ".1", ".man":
+		return "man"
+	case ".gmi", ".gemini":
+		return "gemtext"
+	case ".gophermap":
+		return "gopher"
+	}
+	return ""
+}
+
+// Read a document in a format. Constructs the importer couldn't map are
+// returned as warnings, or as an error in strict mode.
+func readDocument(format string, data []byte, strict bool) (*ast.Document, []importer.Warning, error) {
+	switch format {
+	case "cdf":
+		p := parser.NewParser(data)
+		if err := p.Parse(); err != nil {
+			return nil, nil, err
+		}
+		return &p.Tree, nil, nil
+	case "json":
+		d := &ast.Document{}
+		if err := json.Unmarshal(data, d); err != nil {
+			return nil, nil, err
+		}
+		return d, nil, nil
+	case "html":
+		i := htmlimporter.NewHTMLImporter(htmlimporter.HTMLSettings{Strict: strict, TitleHeadings: true})
+		d, err := i.Import(data)
+		return d, i.Warnings(), err
+	case "markdown":
+		i := mdimporter.NewMarkdownImporter(mdimporter.MarkdownSettings{})
+		d, err := i.Import(data)
+		return d, i.Warnings(), err
+	case "pandoc":
+		i := pandocimporter.NewPandocImporter(pandocimporter.PandocSettings{Strict: strict})
+		d, err := i.Import(data)
+		return d, i.Warnings(), err
+	}
+	return nil, nil, errors.New("unsupported input format '" + format + "' (expected " + inputFormatNames + ")")
+}
+
+// Write a document in a format to an output file. Relative image sources
+// are read from a directory.
+func writeDocument(format string, w io.Writer, d *ast.Document, dir, output string) error {
+	switch format {
+	case "cdf":
+		return parser.Format(w, d)
+	case "html":
+		settings, err := htmlSettings(output)
+		if err != nil {
+			return err
+		}
+		e := html.NewHTMLExporter(w, settings)
+		if err := e.Export(d); err != nil {
+			return err
+		}
+		if htmlClassStylesheet == "" {
+			return nil
+		}
+		return writeOutput(htmlClassStylesheet, func(w io.Writer) error {
+			_, err := io.WriteString(w, e.ClassStylesheet())
+			return err
+		})
+	case "epub":
+		return epub.NewEPUBExporter(w, epub.EPUBSettings{ImageDirectory: dir, SplitAtPageBreaks: true}).Export(d)
+	case "docx":
+		return docx.NewDOCXExporter(w, docx.DOCXSettings{ImageDirectory: dir}).Export(d)
+	case "latex":
+		return latex.NewLaTeXExporter(w, latex.LaTeXSettings{}).Export(d)
+	case "man":
+		return man.NewManExporter(w, man.ManSettings{}).Export(d)
+	case "markdown":
+		return markdown.NewMarkdownExporter(w, markdown.MarkdownSettings{Flavor: markdown.GFMFlavor}).Export(d)
+	case "commonmark":
+		return markdown.NewMarkdownExporter(w, markdown.MarkdownSettings{Flavor: markdown.CommonMarkFlavor}).Export(d)
+	case "odt":
+		return odt.NewODTExporter(w, odt.ODTSettings{ImageDirectory: dir}).Export(d)
+	case "pandoc":
+		return pandoc.NewPandocExporter(w, pandoc.PandocSettings{}).Export(d)
+	case "pdf":
+		return pdf.NewPDFExporter(w, pdf.PDFSettings{ImageDirectory: dir}).Export(d)
+	case "rst":
+		return rst.NewRSTExporter(w, rst.RSTSettings{}).Export(d)
+	case "slides":
+		return slides.NewSlidesExporter(w, slides.SlidesSettings{ImageDirectory: dir}).Export(d)
+	case "asciidoc":
+		return asciidoc.NewAsciiDocExporter(w, asciidoc.AsciiDocSettings{}).Export(d)
+	case "text":
+		return text.NewTextExporter(w, text.TextSettings{}).Export(d)
+	case "gemtext":
+		return gemtext.NewGemtextExporter(w, gemtext.GemtextSettings{}).Export(d)
+	case "gopher":
+		return gopher.NewGopherExporter(w, gopher.GopherSettings{}).Export(d)
+	case "json":
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		return e.Encode(d)
+	}
+	return errors.New("unsupported output format '" + format + "' (expected " + outputFormatNames + ")")
+}
+
+// Get the HTML settings from the flags, for a page written to an output
+// file. Pages are standalone unless --fragment is set.
+func htmlSettings(output string) (html.HTMLSettings, error) {
+	if htmlFragment {
+		if htmlClassStyles && htmlClassStylesheet == "" {
+			return html.HTMLSettings{}, errors.New("class styles need the page's head or a class stylesheet file, and cannot be used with a fragment")
+		}
+		return html.HTMLSettings{ClassStyles: htmlClassStylesheet != "", Strict: htmlSafe}, nil
+	}
+	settings := html.HTMLSettings{IncludeHeader: true, IncludeFooter: true, Stylesheets: htmlStylesheets, ClassStyles: htmlClassStyles, Strict: htmlSafe}
+	if htmlClassStylesheet != "" {
+		link, err := relativeURL(output, htmlClassStylesheet)
+		if err != nil {
+			return settings, err
+		}
+		settings.ClassStyles, settings.ClassStylesheetURL = true, link
+	}
+	switch htmlTheme {
+	case "none":
+		settings.Theme = html.NoTheme
+	case "plain", "":
+		settings.Theme = html.PlainTheme
+	case "serif":
+		settings.Theme = html.SerifTheme
+	case "dark":
+		settings.Theme = html.DarkTheme
+	default:
+		return settings, errors.New("unknown theme '" + htmlTheme + "' (expected " + themeNames + ")")
+	}
+
+	var err error
+	if settings.HeaderTemplate, err = readTemplate("header", htmlHeader); err != nil {
+		return settings, err
+	}
+	if settings.FooterTemplate, err = readTemplate("footer", htmlFooter); err != nil {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// Get the URL of a file, relative to the directory of an output file.
+func relativeURL(output, file string) (string, error) {
+	base, err := filepath.Abs(filepath.Dir(output))
+	if err != nil {
+		return "", err
+	}
+	target, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.Rel(base, target)
+	if err != nil {
+		return "", err
+	}
+	return (&url.URL{Path: filepath.ToSlash(path)}).String(), nil
+}
+
+// Read an HTML template file. Returns nil if there is no file.
+func readTemplate(name, file string) (*template.Template, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(name).Parse(string(data))
+}
+
+// Convert an input file into an output file.
+func convert(input, output, from, to string, strict bool) error {
+	if from == "" {
+		from = formatFromExtension(input)
+		if from == "" {
+			from = "cdf"
+		}
+	}
+	if to == "" {
+		to = formatFromExtension(output)
+		if to == "" {
+			to = "html"
+		}
+	}
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	d, warnings, err := readDocument(from, data, strict)
+	if err != nil {
+		return err
+	}
+	for i := range warnings {
+		fmt.Fprintln(os.Stderr, input+":"+warnings[i].String())
+	}
+
+	return writeOutput(output, func(w io.Writer) error {
+		return writeDocument(to, w, d, filepath.Dir(input), output)
+	})
+}
+
+// Write an output file. The output is written to a temporary file in the
+// same directory, which replaces the output file only if writing succeeds.
+func writeOutput(output string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	// Keep the permissions of an existing output file.
+	mode := os.FileMode(0644)
+	if info, statErr := os.Stat(output); statErr == nil {
+		mode = info.Mode().Perm()
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), output)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+// cmd/cdf/convert_test.go
+// Conversion tests.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertKeepsOutputOnError(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.cdf")
+	output := filepath.Join(dir, "out.html")
+	if err := os.WriteFile(input, []byte("[[cdf]]\n[[p]][[link dest=javascript:alert(1)]]a[[/]][[/]]\n[[/]]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(output, []byte("previous"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed conversion leaves the output as it was.
+	htmlSafe = true
+	err := convert(input, output, "", "", false)
+	htmlSafe = false
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "previous" {
+		t.Errorf("output was changed to %q", data)
+	}
+
+	// A successful conversion replaces it, keeping its permissions.
+	if err := convert(input, output, "", "", false); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "<a>a</a>") {
+		t.Errorf("unexpected output:\n%s", data)
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("got mode %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		names := []string{}
+		for i := range entries {
+			names = append(names, entries[i].Name())
+		}
+		t.Errorf("unexpected files: %v", names)
+	}
+}
+
+func TestConvertClassStylesheet(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.cdf")
+	output := filepath.Join(dir, "site", "out.html")
+	stylesheet := filepath.Join(dir, "css", "class styles.css")
+	for _, d := range []string{filepath.Dir(output), filepath.Dir(stylesheet)} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(input, []byte("[[cdf]]\n[[p align=center]]a[[/]]\n[[/]]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	htmlClassStylesheet = stylesheet
+	err := convert(input, output, "", "", false)
+	htmlClassStylesheet = ""
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<link rel=\"stylesheet\" href=\"../css/class%20styles.css\">\n",
+		"<p class=\"cdf-align-center\">a</p>",
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("got page:\n%s\nwant it to contain:\n%s", page, want)
+		}
+	}
+	if strings.Contains(string(page), "cdf-align-center {") {
+		t.Errorf("class rules were written in the page:\n%s", page)
+	}
+	css, err := os.ReadFile(stylesheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(css), ".cdf-align-center { text-align: center; }") {
+		t.Errorf("unexpected stylesheet:\n%s", css)
+	}
+}
@@ -7,43 +7,80 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/cubeflix/cdf/export/html"
-	"github.com/cubeflix/cdf/parser"
+	"github.com/spf13/cobra"
 )
 
-func main() {
-	args := os.Args[:1]
-	if len(args) != 2 {
-		// Invalid number of arguments.
-		fmt.Println("cdf is the Cubeflix Document Format converter for HTML")
-		fmt.Println("usage: cdf input output")
-		os.Exit(1)
-	}
+var fromFormat, toFormat string
+var strictImport bool
+var htmlTheme, htmlHeader, htmlFooter, htmlClassStylesheet string
+var htmlStylesheets []string
+var htmlFragment, htmlClassStyles, htmlSafe bool
 
-	input := args[0]
-	output := args[1]
-	data, err := os.ReadFile(input)
-	if err != nil {
-		fmt.Println("cdf:", err.Error())
-		os.Exit(1)
-	}
-	outFile, err := os.Create(output)
-	if err != nil {
-		fmt.Println("cdf:", err.Error())
-		os.Exit(1)
-	}
-	defer outFile.Close()
+var rootCmd = &cobra.Command{
+	Use:   "cdf",
+	Short: "the Cubeflix Document Format tool",
+	Long:  `cdf is the Cubeflix Document Format converter.`,
+}
 
-	p := parser.NewParser(data)
-	err = p.Parse()
-	if err != nil {
-		fmt.Println("cdf:", err.Error())
-		os.Exit(1)
-	}
-	h := html.NewHTMLExporter(outFile, html.HTMLSettings{})
-	err = h.Export(&p.Tree)
-	if err != nil {
-		fmt.Println("cdf:", err.Error())
+var convertCmd = &cobra.Command{
+	Use:   "convert input output",
+	Short: "convert a document",
+	Long: `Convert a document between formats. If the input or output format is not
+set, it is detected from the file's extension.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := convert(args[0], args[1], fromFormat, toFormat, strictImport)
+		if err != nil {
+			fmt.Println("cdf:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "print the cdf version",
+	Long:  `Print the cdf version.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("cdf version 1.0.0")
+	},
+}
+
+func Execute() {
+	// Add arguments.
+	convertCmd.PersistentFlags().StringVar(&fromFormat, "from", "", "the input format ("+inputFormatNames+")")
+	convertCmd.PersistentFlags().StringVar(&toFormat, "to", "", "the output format ("+outputFormatNames+")")
+	convertCmd.PersistentFlags().BoolVar(&strictImport, "strict", false, "fail on input constructs that cannot be converted")
+	convertCmd.PersistentFlags().StringVar(&htmlTheme, "theme", "plain", "the HTML page theme ("+themeNames+")")
+	convertCmd.PersistentFlags().StringArrayVar(&htmlStylesheets, "stylesheet", nil, "a stylesheet URL to link from the HTML page (repeatable)")
+	convertCmd.PersistentFlags().StringVar(&htmlHeader, "header", "", "an HTML template file to write at the start of the page's body")
+	convertCmd.PersistentFlags().StringVar(&htmlFooter, "footer", "", "an HTML template file to write at the end of the page's body")
+	convertCmd.PersistentFlags().BoolVar(&htmlFragment, "fragment", false, "write HTML without the page's head, theme and templates")
+	convertCmd.PersistentFlags().BoolVar(&htmlSafe, "safe", false, "fail on unsafe URLs and font families in HTML output, instead of leaving them out")
+	convertCmd.PersistentFlags().BoolVar(&htmlClassStyles, "class-styles", false, "write HTML styles as classes defined in the page's head")
+	convertCmd.PersistentFlags().StringVar(&htmlClassStylesheet, "class-stylesheet", "", "write HTML styles as classes defined in a stylesheet file, linked from the page")
+
+	validateCmd.PersistentFlags().IntVar(&maxDepth, "max-depth", 16, "the maximum block nesting depth (0 for unlimited)")
+	validateCmd.PersistentFlags().BoolVar(&requireCaptions, "require-captions", false, "report images without captions")
+	validateCmd.PersistentFlags().BoolVar(&allowMissingAlt, "allow-missing-alt", false, "do not report images without alternate text")
+
+	viewCmd.PersistentFlags().StringVar(&fromFormat, "from", "", "the input format ("+inputFormatNames+")")
+	viewCmd.PersistentFlags().IntVar(&viewWidth, "width", 0, "the width to wrap text at (defaults to the terminal's width)")
+	viewCmd.PersistentFlags().StringVar(&viewColor, "color", "auto", "the color mode (auto, truecolor, 256, 16, none, plain)")
+	viewCmd.PersistentFlags().BoolVar(&viewASCII, "ascii", false, "draw tables, bullets and rules with ASCII characters")
+	viewCmd.PersistentFlags().BoolVar(&noPager, "no-pager", false, "write to the terminal without a pager")
+
+	rootCmd.AddCommand(convertCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(viewCmd)
+	rootCmd.AddCommand(versionCmd)
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Println("cdf:", err)
 		os.Exit(1)
 	}
 }
+
+func main() {
+	Execute()
+}
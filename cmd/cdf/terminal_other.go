@@ -0,0 +1,14 @@
+// cmd/cdf/terminal_other.go
+// Terminal size on other systems.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import "os"
+
+// Get the width of the terminal a file is attached to, or zero.
+func terminalWidth(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,25 @@
+// cmd/cdf/terminal_unix.go
+// Terminal size on Unix systems.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Get the width of the terminal a file is attached to, or zero.
+func terminalWidth(f *os.File) int {
+	var size struct {
+		rows, columns, x, y uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	if errno != 0 {
+		return 0
+	}
+	return int(size.columns)
+}
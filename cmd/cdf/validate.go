@@ -0,0 +1,52 @@
+// cmd/cdf/validate.go
+// Document validation.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cubeflix/cdf/parser"
+	"github.com/spf13/cobra"
+)
+
+var maxDepth int
+var requireCaptions, allowMissingAlt bool
+
+var validateCmd = &cobra.Command{
+	Use:   "validate file...",
+	Short: "validate documents",
+	Long: `Validate CDF documents. Each warning is printed with its position. The exit
+status is non-zero if any document has warnings.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rules := parser.DefaultValidationRules()
+		rules.MaxDepth = maxDepth
+		rules.ImageCaptions = requireCaptions
+		rules.ImageAltText = !allowMissingAlt
+
+		failed := false
+		for i := range args {
+			data, err := os.ReadFile(args[i])
+			if err != nil {
+				fmt.Println("cdf:", err)
+				failed = true
+				continue
+			}
+			warnings, err := parser.Validate(data, rules)
+			if err != nil {
+				fmt.Println(args[i] + ": " + err.Error())
+				failed = true
+				continue
+			}
+			for j := range warnings {
+				fmt.Println(args[i] + ":" + warnings[j].String())
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,139 @@
+// cmd/cdf/view.go
+// Document viewing in the terminal.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/export/ansi"
+	"github.com/spf13/cobra"
+)
+
+var viewWidth int
+var viewColor string
+var viewASCII, noPager bool
+
+var viewCmd = &cobra.Command{
+	Use:   "view file",
+	Short: "view a document in the terminal",
+	Long: `View a document in the terminal. The document is wrapped to the terminal's
+width and shown in a pager when the output is a terminal. The pager is $PAGER,
+or less.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := view(args[0], fromFormat, viewWidth, viewColor, viewASCII, noPager); err != nil {
+			fmt.Println("cdf:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// View a file in the terminal.
+func view(input, from string, width int, color string, ascii, noPager bool) error {
+	if from == "" {
+		from = formatFromExtension(input)
+		if from == "" {
+			from = "cdf"
+		}
+	}
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	d, _, err := readDocument(from, data, false)
+	if err != nil {
+		return err
+	}
+
+	terminal := isTerminal(os.Stdout)
+	if width <= 0 && terminal {
+		width = terminalWidth(os.Stdout)
+	}
+	if width <= 0 {
+		width, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+	}
+	mode, err := colorMode(color, terminal)
+	if err != nil {
+		return err
+	}
+
+	out := bytes.Buffer{}
+	settings := ansi.ANSISettings{Width: width, ColorMode: mode, ASCII: ascii}
+	if err := ansi.NewANSIExporter(&out, settings).Export(d); err != nil {
+		return err
+	}
+	if !terminal || noPager {
+		_, err := os.Stdout.Write(out.Bytes())
+		return err
+	}
+	return page(&out)
+}
+
+// Get whether a file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Get the color mode for a --color value. Automatic detection uses the
+// NO_COLOR, COLORTERM and TERM environment variables.
+func colorMode(name string, terminal bool) (ansi.ColorMode, error) {
+	switch name {
+	case "truecolor":
+		return ansi.TrueColorMode, nil
+	case "256":
+		return ansi.Color256Mode, nil
+	case "16":
+		return ansi.Color16Mode, nil
+	case "none":
+		return ansi.MonochromeMode, nil
+	case "plain":
+		return ansi.PlainMode, nil
+	case "auto", "":
+	default:
+		return 0, errors.New("unsupported color mode '" + name + "' (expected auto, truecolor, 256, 16, none, plain)")
+	}
+
+	term := os.Getenv("TERM")
+	colorTerm := os.Getenv("COLORTERM")
+	switch {
+	case !terminal || term == "dumb":
+		return ansi.PlainMode, nil
+	case os.Getenv("NO_COLOR") != "":
+		return ansi.MonochromeMode, nil
+	case colorTerm == "truecolor" || colorTerm == "24bit":
+		return ansi.TrueColorMode, nil
+	case strings.Contains(term, "256color"):
+		return ansi.Color256Mode, nil
+	}
+	return ansi.Color16Mode, nil
+}
+
+// Show output in the pager. If the pager can't be started, the output is
+// written directly.
+func page(out io.Reader) error {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
+	}
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = out, os.Stdout, os.Stderr
+	cmd.Env = os.Environ()
+	if os.Getenv("LESS") == "" {
+		// Keep colors and exit if the document fits on the screen.
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	if err := cmd.Start(); err != nil {
+		_, err := io.Copy(os.Stdout, out)
+		return err
+	}
+	return cmd.Wait()
+}
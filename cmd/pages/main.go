@@ -4,6 +4,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"os"
@@ -14,6 +15,7 @@ import (
 
 var addr, path string
 var certFile, keyFile string
+var geminiAddr, hostname string
 
 var rootCmd = &cobra.Command{
 	Use:   "cdf-pages",
@@ -44,6 +46,40 @@ var serveCmd = &cobra.Command{
 	},
 }
 
+var geminiCmd = &cobra.Command{
+	Use:   "gemini",
+	Short: "start the server over the Gemini protocol",
+	Long: `Start serving the cdf-pages server over the Gemini protocol. If cert and key
+aren't set, a self-signed certificate is created for local testing. Requests
+for hosts other than the host name are refused.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := pages.LoadServer(path)
+		if err != nil {
+			fmt.Println("cdf-pages:", err)
+			os.Exit(1)
+			return
+		}
+		var cert tls.Certificate
+		if len(certFile) != 0 && len(keyFile) != 0 {
+			cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+		} else {
+			cert, err = pages.SelfSignedCertificate(hostname)
+			s.GeminiHost = hostname
+		}
+		if cmd.Flags().Changed("hostname") {
+			s.GeminiHost = hostname
+		}
+		if err != nil {
+			fmt.Println("cdf-pages:", err)
+			os.Exit(1)
+			return
+		}
+		err = s.ListenAndServeGemini(geminiAddr, &tls.Config{Certificates: []tls.Certificate{cert}})
+		fmt.Println("cdf-pages:", err)
+		return
+	},
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "print the cdf-pages version",
@@ -59,8 +95,14 @@ func Execute() {
 	serveCmd.PersistentFlags().StringVar(&path, "path", "", "the path to the cdf-pages project (defaults to current directory)")
 	serveCmd.PersistentFlags().StringVar(&certFile, "cert", "", "the certificate file")
 	serveCmd.PersistentFlags().StringVar(&keyFile, "key", "", "the key file")
+	geminiCmd.PersistentFlags().StringVar(&geminiAddr, "addr", ":1965", "the address to serve to (defaults to :1965)")
+	geminiCmd.PersistentFlags().StringVar(&path, "path", "", "the path to the cdf-pages project (defaults to current directory)")
+	geminiCmd.PersistentFlags().StringVar(&certFile, "cert", "", "the certificate file")
+	geminiCmd.PersistentFlags().StringVar(&keyFile, "key", "", "the key file")
+	geminiCmd.PersistentFlags().StringVar(&hostname, "hostname", "localhost", "the host name served, and of the self-signed certificate")
 
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(geminiCmd)
 	rootCmd.AddCommand(versionCmd)
 
 	if err := rootCmd.Execute(); err != nil {
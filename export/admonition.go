@@ -0,0 +1,64 @@
+// export/admonition.go
+// Admonition detection for formats with note and warning blocks.
+
+package export
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Admonition kinds, by their lowercase label.
+var admonitions = map[string]bool{
+	"attention": true,
+	"caution":   true,
+	"danger":    true,
+	"error":     true,
+	"hint":      true,
+	"important": true,
+	"note":      true,
+	"tip":       true,
+	"warning":   true,
+}
+
+// Get the kind of admonition a quote is, like "note" or "warning", and its
+// content without the label. A quote is an admonition when it starts with
+// a bold label, like "[[b]]Note:[[/]] Text". Returns an empty kind for
+// other quotes. The quote is not changed.
+func Admonition(q *ast.Quote) (string, []ast.Block) {
+	if len(q.Content) == 0 {
+		return "", nil
+	}
+	p, ok := q.Content[0].(*ast.Paragraph)
+	if !ok || len(p.Content) == 0 {
+		return "", nil
+	}
+	label, ok := p.Content[0].(*ast.FormattingBlock)
+	if !ok || label.Attribute != ast.BoldFormatting {
+		return "", nil
+	}
+	kind := strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(ast.PlainText(label.Content)), ":")))
+	if !admonitions[kind] {
+		return "", nil
+	}
+
+	// Remove the label and the colon and spaces following it.
+	rest := append([]ast.InlineBlock{}, p.Content[1:]...)
+	if len(rest) > 0 {
+		if t, ok := rest[0].(*ast.Text); ok {
+			value := strings.TrimLeftFunc(strings.TrimPrefix(strings.TrimLeftFunc(t.Value, unicode.IsSpace), ":"), unicode.IsSpace)
+			if value == "" {
+				rest = rest[1:]
+			} else {
+				rest[0] = &ast.Text{Value: value}
+			}
+		}
+	}
+	content := append([]ast.Block{}, q.Content[1:]...)
+	if len(rest) > 0 {
+		content = append([]ast.Block{&ast.Paragraph{BaseBlock: p.BaseBlock, Content: rest}}, content...)
+	}
+	return kind, content
+}
@@ -0,0 +1,433 @@
+// export/ansi/ansi.go
+// Package ansi provides functionality for rendering documents to terminals
+// with ANSI escape sequences.
+
+package ansi
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Characters for drawing lists, quotes, rules and tables.
+type glyphs struct {
+	bullets                             []string
+	quote, rule, heading, pageBreak     string
+	collapse                            string
+	horizontal, vertical                string
+	topLeft, topJoin, topRight          string
+	middleLeft, middleJoin, middleRight string
+	bottomLeft, bottomJoin, bottomRight string
+}
+
+var unicodeGlyphs = glyphs{
+	bullets:    []string{"•", "◦", "▪"},
+	quote:      "│ ",
+	rule:       "─",
+	heading:    "═",
+	pageBreak:  "┄",
+	collapse:   "▸ ",
+	horizontal: "─", vertical: "│",
+	topLeft: "┌", topJoin: "┬", topRight: "┐",
+	middleLeft: "├", middleJoin: "┼", middleRight: "┤",
+	bottomLeft: "└", bottomJoin: "┴", bottomRight: "┘",
+}
+
+var asciiGlyphs = glyphs{
+	bullets:    []string{"*", "-", "+"},
+	quote:      "| ",
+	rule:       "-",
+	heading:    "=",
+	pageBreak:  ".",
+	collapse:   "> ",
+	horizontal: "-", vertical: "|",
+	topLeft: "+", topJoin: "+", topRight: "+",
+	middleLeft: "+", middleJoin: "+", middleRight: "+",
+	bottomLeft: "+", bottomJoin: "+", bottomRight: "+",
+}
+
+// Layout properties inherited by nested blocks.
+type context struct {
+	// The width available to the block.
+	width int
+
+	align ast.AlignmentType
+
+	// The list nesting depth, for choosing bullets.
+	depth int
+
+	// Whether text is bold, for table header cells.
+	bold bool
+}
+
+// ANSI terminal exporter.
+type ANSIExporter struct {
+	stream   io.Writer
+	settings ANSISettings
+	glyphs   glyphs
+}
+
+// Create a new ANSI terminal exporter.
+func NewANSIExporter(stream io.Writer, settings ANSISettings) *ANSIExporter {
+	if settings.Width <= 0 {
+		settings.Width = DefaultWidth
+	}
+	g := unicodeGlyphs
+	if settings.ASCII {
+		g = asciiGlyphs
+	}
+
+	return &ANSIExporter{
+		stream:   stream,
+		settings: settings,
+		glyphs:   g,
+	}
+}
+
+// Export the document to the terminal.
+func (a *ANSIExporter) Export(d *ast.Document) error {
+	ctx := context{width: a.settings.Width}
+	groups := [][]line{}
+
+	// Write the title block.
+	title := []line{}
+	if !a.settings.OmitTitle && d.Title != "" {
+		title = append(title, a.heading([]piece{{d.Title, style{bold: true}}}, ctx, a.glyphs.heading)...)
+	}
+	if !a.settings.OmitSubtitle && d.Subtitle != "" {
+		title = append(title, a.wrap([]piece{{d.Subtitle, style{italic: true}}}, ctx.width)...)
+	}
+	if !a.settings.OmitDate && d.Date != "" {
+		title = append(title, a.wrap([]piece{{d.Date, style{dim: true}}}, ctx.width)...)
+	}
+	if !a.settings.OmitAuthor && d.Author != "" {
+		title = append(title, a.wrap([]piece{{d.Author, style{dim: true}}}, ctx.width)...)
+	}
+	if len(title) > 0 {
+		groups = append(groups, title)
+	}
+
+	content, err := a.exportBlocks(d.Content, ctx)
+	if err != nil {
+		return err
+	}
+	if len(content) > 0 {
+		groups = append(groups, content)
+	}
+
+	out := strings.Builder{}
+	for i, lines := range groups {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		for _, l := range lines {
+			out.WriteString(strings.TrimRight(l.text, " ") + "\n")
+		}
+	}
+	_, err = io.WriteString(a.stream, out.String())
+	return err
+}
+
+// Export a slice of blocks, separated by blank lines.
+func (a *ANSIExporter) exportBlocks(blocks []ast.Block, ctx context) ([]line, error) {
+	lines := []line{}
+	for i := range blocks {
+		block, err := a.exportBlock(blocks[i], ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(block) == 0 {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, line{})
+		}
+		lines = append(lines, block...)
+	}
+	return lines, nil
+}
+
+// Export a block. Blocks without an alignment inherit their parent's.
+func (a *ANSIExporter) exportBlock(b ast.Block, ctx context) ([]line, error) {
+	if b.GetAlignment() != ast.NoAlign {
+		ctx.align = b.GetAlignment()
+	}
+	if ctx.width < 1 {
+		ctx.width = 1
+	}
+
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		pieces, err := a.inline(block.Content, style{bold: ctx.bold})
+		if err != nil {
+			return nil, err
+		}
+		return align(a.wrap(pieces, ctx.width), ctx), nil
+	case *ast.BasicBlock:
+		return a.exportBlocks(block.Content, ctx)
+	case *ast.Quote:
+		inner := ctx
+		inner.width -= textWidth(a.glyphs.quote)
+		lines, err := a.exportBlocks(block.Content, inner)
+		if err != nil {
+			return nil, err
+		}
+		return a.prefix(lines, a.glyphs.quote, a.glyphs.quote), nil
+	case *ast.Image:
+		lines := align(a.wrap([]piece{{imageLabel(block.Alt, block.Source), style{dim: true}}}, ctx.width), ctx)
+		if block.HasCaption {
+			pieces, err := a.inline(block.Caption, style{italic: true})
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, align(a.wrap(pieces, ctx.width), ctx)...)
+		}
+		return lines, nil
+	case *ast.Heading:
+		s := style{bold: true}
+		rule := ""
+		switch block.Class {
+		case ast.Heading1Type:
+			rule = a.glyphs.heading
+		case ast.Heading2Type:
+			rule = a.glyphs.rule
+		case ast.Heading3Type, ast.Heading4Type, ast.Heading5Type:
+		default:
+			return nil, errors.New("invalid ast")
+		}
+		pieces, err := a.inline(block.Content, s)
+		if err != nil {
+			return nil, err
+		}
+		return a.heading(pieces, ctx, rule), nil
+	case *ast.HorizontalRule:
+		return []line{a.styled(strings.Repeat(a.glyphs.rule, ctx.width), style{dim: true})}, nil
+	case *ast.List:
+		return a.exportList(block, ctx)
+	case *ast.Table:
+		return a.exportTable(block, ctx)
+	case *ast.Collapse:
+		// Collapse blocks are expanded, with their summary as a label.
+		marker := textWidth(a.glyphs.collapse)
+		pieces, err := a.inline(block.Summary, style{bold: true})
+		if err != nil {
+			return nil, err
+		}
+		inner := ctx
+		inner.width -= marker
+		lines := a.prefix(a.wrap(pieces, inner.width), a.glyphs.collapse, strings.Repeat(" ", marker))
+		content, err := a.exportBlocks(block.Content, inner)
+		if err != nil {
+			return nil, err
+		}
+		return append(lines, a.prefix(content, strings.Repeat(" ", marker), strings.Repeat(" ", marker))...), nil
+	case *ast.PageBreak:
+		return []line{a.styled(strings.Repeat(a.glyphs.pageBreak, ctx.width), style{dim: true})}, nil
+	case *ast.Notes:
+		// Speaker notes are left out.
+		return nil, nil
+	}
+	return nil, errors.New("invalid ast")
+}
+
+// Write a heading, with a rule under it if the rule isn't empty.
+func (a *ANSIExporter) heading(pieces []piece, ctx context, rule string) []line {
+	lines := a.wrap(pieces, ctx.width)
+	if rule != "" {
+		width := 0
+		for _, l := range lines {
+			if l.width > width {
+				width = l.width
+			}
+		}
+		lines = append(lines, a.styled(strings.Repeat(rule, width), style{dim: true}))
+	}
+	return align(lines, ctx)
+}
+
+// Export a list. Items are marked with bullets or numbers, and their
+// following lines are indented to the text.
+func (a *ANSIExporter) exportList(block *ast.List, ctx context) ([]line, error) {
+	markers := make([]string, len(block.Items))
+	width := 0
+	for i := range block.Items {
+		if block.Ordered {
+			markers[i] = strconv.Itoa(i+1) + ". "
+		} else {
+			markers[i] = a.glyphs.bullets[ctx.depth%len(a.glyphs.bullets)] + " "
+		}
+		if textWidth(markers[i]) > width {
+			width = textWidth(markers[i])
+		}
+	}
+
+	inner := ctx
+	inner.width -= width
+	inner.depth++
+	lines := []line{}
+	for i := range block.Items {
+		item, err := a.exportBlock(block.Items[i], inner)
+		if err != nil {
+			return nil, err
+		}
+
+		// Nested lists aren't marked.
+		marker := strings.Repeat(" ", width)
+		if _, nested := block.Items[i].(*ast.List); !nested {
+			marker = strings.Repeat(" ", width-textWidth(markers[i])) + markers[i]
+		}
+		lines = append(lines, a.prefix(item, marker, strings.Repeat(" ", width))...)
+	}
+	return lines, nil
+}
+
+// Export a table with box-drawn borders. Columns get their natural widths,
+// and the widest columns are narrowed until the table fits.
+func (a *ANSIExporter) exportTable(block *ast.Table, ctx context) ([]line, error) {
+	columns := 0
+	for i := range block.Rows {
+		if len(block.Rows[i].Cells) > columns {
+			columns = len(block.Rows[i].Cells)
+		}
+	}
+	if columns == 0 {
+		return nil, nil
+	}
+
+	available := ctx.width - 3*columns - 1
+	if available < columns {
+		available = columns
+	}
+	widths := make([]int, columns)
+	for i := range block.Rows {
+		for j, cell := range block.Rows[i].Cells {
+			lines, err := a.exportBlocks(cell.Content, context{width: available, bold: cell.IsHeader})
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range lines {
+				if l.width > widths[j] {
+					widths[j] = l.width
+				}
+			}
+		}
+	}
+	total := 0
+	for j := range widths {
+		if widths[j] < 1 {
+			widths[j] = 1
+		}
+		total += widths[j]
+	}
+	for total > available {
+		widest := 0
+		for j := range widths {
+			if widths[j] > widths[widest] {
+				widest = j
+			}
+		}
+		if widths[widest] == 1 {
+			break
+		}
+		widths[widest]--
+		total--
+	}
+
+	g := a.glyphs
+	border := func(left, join, right string) line {
+		s := left
+		for j := range widths {
+			if j > 0 {
+				s += join
+			}
+			s += strings.Repeat(g.horizontal, widths[j]+2)
+		}
+		return a.styled(s+right, style{dim: true})
+	}
+	vertical := a.styled(g.vertical, style{dim: true})
+
+	lines := []line{border(g.topLeft, g.topJoin, g.topRight)}
+	for i := range block.Rows {
+		if i > 0 {
+			lines = append(lines, border(g.middleLeft, g.middleJoin, g.middleRight))
+		}
+		cells := make([][]line, columns)
+		height := 1
+		for j := range cells {
+			if j >= len(block.Rows[i].Cells) {
+				continue
+			}
+			cell := block.Rows[i].Cells[j]
+			var err error
+			cells[j], err = a.exportBlocks(cell.Content, context{width: widths[j], align: ctx.align, bold: cell.IsHeader})
+			if err != nil {
+				return nil, err
+			}
+			if len(cells[j]) > height {
+				height = len(cells[j])
+			}
+		}
+		for k := 0; k < height; k++ {
+			row := vertical
+			for j := range cells {
+				content := line{}
+				if k < len(cells[j]) {
+					content = cells[j][k]
+				}
+				padding := widths[j] - content.width + 1
+				if padding < 1 {
+					padding = 1
+				}
+				row.text += " " + content.text + strings.Repeat(" ", padding) + vertical.text
+				row.width += content.width + padding + 1 + vertical.width
+			}
+			lines = append(lines, row)
+		}
+	}
+	lines = append(lines, border(g.bottomLeft, g.bottomJoin, g.bottomRight))
+	return align(lines, ctx), nil
+}
+
+// Get a line of text in a style.
+func (a *ANSIExporter) styled(text string, s style) line {
+	return line{a.render([]piece{{text, s}}), textWidth(text)}
+}
+
+// Prefix lines, with a different prefix for the first line. Prefixes are
+// dimmed.
+func (a *ANSIExporter) prefix(lines []line, first, rest string) []line {
+	prefixed := make([]line, len(lines))
+	for i, l := range lines {
+		p := rest
+		if i == 0 {
+			p = first
+		}
+		s := a.styled(p, style{dim: true})
+		if strings.TrimSpace(p) == "" {
+			s = line{p, textWidth(p)}
+		}
+		prefixed[i] = line{s.text + l.text, s.width + l.width}
+	}
+	return prefixed
+}
+
+// Align lines within the context's width.
+func align(lines []line, ctx context) []line {
+	for i, l := range lines {
+		padding := 0
+		switch ctx.align {
+		case ast.CenterAlign:
+			padding = (ctx.width - l.width) / 2
+		case ast.RightAlign:
+			padding = ctx.width - l.width
+		}
+		if padding > 0 && l.width > 0 {
+			lines[i] = line{strings.Repeat(" ", padding) + l.text, l.width + padding}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,87 @@
+// export/ansi/ansi_test.go
+// ANSI terminal export tests.
+
+package ansi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+// Create a formatting block.
+func testFormat(attribute ast.FormattingType, content ...ast.InlineBlock) ast.InlineBlock {
+	return &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: attribute}
+}
+
+// Export blocks.
+func testExport(t *testing.T, settings ANSISettings, blocks ...ast.Block) string {
+	out := strings.Builder{}
+	if err := NewANSIExporter(&out, settings).Export(&ast.Document{Content: blocks}); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []ast.InlineBlock
+		want    string
+	}{
+		{"words", testText("aaaa bbbb cccc"), "aaaa bbbb\ncccc\n"},
+		{"styled space", []ast.InlineBlock{&ast.Text{Value: "aaaa"}, testFormat(ast.BoldFormatting, &ast.Text{Value: " "}), &ast.Text{Value: "bbbbbb"}}, "aaaa\nbbbbbb\n"},
+		{"spaces", testText("aaaa   \t bbbbbb  "), "aaaa\nbbbbbb\n"},
+		{"control characters", testText("aaaa \x01 \x02 bbbb"), "aaaa bbbb\n"},
+		{"line break", testText("aaaa \n  bbbb"), "aaaa\nbbbb\n"},
+		{"long word", testText("aa bbbbbbbbbbbb cc"), "aa\nbbbbbbbbbb\nbb cc\n"},
+	}
+	for _, test := range tests {
+		got := testExport(t, ANSISettings{Width: 10, ColorMode: PlainMode}, &ast.Paragraph{Content: test.content})
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  ColorMode
+		block ast.Block
+		want  string
+	}{
+		{"escape sequences", PlainMode, &ast.Paragraph{Content: testText("\x1b[31mred\x07")}, "[31mred\n"},
+		{
+			"nested markup",
+			MonochromeMode,
+			&ast.Paragraph{Content: []ast.InlineBlock{testFormat(ast.BoldFormatting, &ast.Text{Value: "a"}, testFormat(ast.ItalicFormatting, &ast.Text{Value: "b"}))}},
+			"\x1b[1ma\x1b[0m\x1b[1;3mb\x1b[0m\n",
+		},
+		{
+			"list",
+			PlainMode,
+			&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}},
+			"• a\n  1. b\n",
+		},
+		{
+			"table",
+			PlainMode,
+			&ast.Table{Rows: []ast.TableRow{{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("a")}}, IsHeader: true}, {}}}}},
+			"┌───┬───┐\n│ a │   │\n└───┴───┘\n",
+		},
+		{"missing image", PlainMode, &ast.Image{Source: "missing.png", Alt: "a"}, "[image: a]\n"},
+		{"image without alt", PlainMode, &ast.Image{Source: "missing.png"}, "[image: missing.png]\n"},
+	}
+	for _, test := range tests {
+		if got := testExport(t, ANSISettings{Width: 20, ColorMode: test.mode}, test.block); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
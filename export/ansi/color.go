@@ -0,0 +1,93 @@
+// export/ansi/color.go
+// Color conversion for each color mode.
+
+package ansi
+
+import "strconv"
+
+// The levels of the 256-color palette's color cube.
+var cubeLevels = []int{0, 95, 135, 175, 215, 255}
+
+// The 16 standard terminal colors, as xterm draws them.
+var basicColors = [][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// Get the SGR parameters for a foreground or background color. Colors are
+// approximated in the 256-color and 16-color modes, and dropped without
+// colors.
+func (a *ANSIExporter) color(r, g, b uint8, background bool) string {
+	base := 38
+	if background {
+		base = 48
+	}
+	switch a.settings.ColorMode {
+	case TrueColorMode:
+		return strconv.Itoa(base) + ";2;" + strconv.Itoa(int(r)) + ";" + strconv.Itoa(int(g)) + ";" + strconv.Itoa(int(b))
+	case Color256Mode:
+		return strconv.Itoa(base) + ";5;" + strconv.Itoa(palette256(int(r), int(g), int(b)))
+	case Color16Mode:
+		i := nearest(basicColors, int(r), int(g), int(b))
+		if i >= 8 {
+			return strconv.Itoa(base + 52 + i - 8)
+		}
+		return strconv.Itoa(base - 8 + i)
+	}
+	return ""
+}
+
+// Get the closest 256-color palette index, from the color cube or the gray
+// ramp.
+func palette256(r, g, b int) int {
+	level := func(v int) int {
+		best := 0
+		for i := range cubeLevels {
+			if abs(cubeLevels[i]-v) < abs(cubeLevels[best]-v) {
+				best = i
+			}
+		}
+		return best
+	}
+	ri, gi, bi := level(r), level(g), level(b)
+	cube := [3]int{cubeLevels[ri], cubeLevels[gi], cubeLevels[bi]}
+
+	gray := ((r+g+b)/3 - 8) / 10
+	if gray < 0 {
+		gray = 0
+	} else if gray > 23 {
+		gray = 23
+	}
+	grayValue := 8 + 10*gray
+
+	if distance([3]int{grayValue, grayValue, grayValue}, r, g, b) < distance(cube, r, g, b) {
+		return 232 + gray
+	}
+	return 16 + 36*ri + 6*gi + bi
+}
+
+// Get the index of the closest color.
+func nearest(colors [][3]int, r, g, b int) int {
+	best := 0
+	for i := range colors {
+		if distance(colors[i], r, g, b) < distance(colors[best], r, g, b) {
+			best = i
+		}
+	}
+	return best
+}
+
+// Get the squared distance between colors.
+func distance(c [3]int, r, g, b int) int {
+	return (c[0]-r)*(c[0]-r) + (c[1]-g)*(c[1]-g) + (c[2]-b)*(c[2]-b)
+}
+
+// Get the absolute value of an integer.
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
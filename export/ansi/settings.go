@@ -0,0 +1,39 @@
+// export/ansi/settings.go
+// ANSI terminal export settings.
+
+package ansi
+
+import "github.com/cubeflix/cdf/export"
+
+// The default line width.
+const DefaultWidth = 80
+
+// How colors are written.
+type ColorMode int64
+
+const (
+	// 24-bit colors.
+	TrueColorMode ColorMode = iota
+	// The 256-color palette.
+	Color256Mode
+	// The 16 standard terminal colors.
+	Color16Mode
+	// Text attributes without colors.
+	MonochromeMode
+	// No escape sequences at all.
+	PlainMode
+)
+
+// ANSI terminal export settings.
+type ANSISettings struct {
+	export.Settings
+
+	// The width text is wrapped at. Defaults to 80 columns.
+	Width int
+
+	ColorMode ColorMode
+
+	// Draw tables, bullets and rules with ASCII characters instead of
+	// Unicode box drawing characters.
+	ASCII bool
+}
@@ -0,0 +1,292 @@
+// export/ansi/text.go
+// Styled text, word wrapping and display widths.
+
+package ansi
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Text attributes. Colors are SGR parameters for the color mode.
+type style struct {
+	bold, italic, underline, strike, dim bool
+	fg, bg                               string
+
+	// Whether the text is teletype or in a hyperlink, which are colored
+	// unless a color is set.
+	code, link bool
+}
+
+// A piece of text in one style.
+type piece struct {
+	text  string
+	style style
+}
+
+// A line of output and its display width.
+type line struct {
+	text  string
+	width int
+}
+
+// Colors for teletype text and hyperlinks.
+var (
+	codeColor = [3]uint8{215, 175, 95}
+	linkColor = [3]uint8{95, 135, 215}
+)
+
+// Collect the pieces of inline blocks. Hyperlinks are followed by their
+// destination.
+func (a *ANSIExporter) inline(blocks []ast.InlineBlock, s style) ([]piece, error) {
+	pieces := []piece{}
+	for i := range blocks {
+		switch block := blocks[i].(type) {
+		case *ast.Text:
+			pieces = append(pieces, piece{block.Value, s})
+		case *ast.HyperlinkBlock:
+			link := s
+			link.link, link.underline = true, true
+			content, err := a.inline(block.Content, link)
+			if err != nil {
+				return nil, err
+			}
+			pieces = append(pieces, content...)
+			if !s.link && !strings.HasPrefix(block.Destination, "#") && block.Destination != ast.PlainText(block.Content) {
+				pieces = append(pieces, piece{" <" + block.Destination + ">", style{dim: true}})
+			}
+		case *ast.FormattingBlock:
+			inner := s
+			switch block.Attribute {
+			case ast.BoldFormatting:
+				inner.bold = true
+			case ast.ItalicFormatting:
+				inner.italic = true
+			case ast.UnderlineFormatting:
+				inner.underline = true
+			case ast.StrikethroughFormatting:
+				inner.strike = true
+			case ast.TeletypeFormatting:
+				inner.code = true
+			default:
+				return nil, errors.New("invalid ast")
+			}
+			content, err := a.inline(block.Content, inner)
+			if err != nil {
+				return nil, err
+			}
+			pieces = append(pieces, content...)
+		case *ast.ColorBlock:
+			inner := s
+			if block.ForegroundValue != nil {
+				rgb := block.ForegroundValue.ToRGB()
+				inner.fg = a.color(rgb.R, rgb.G, rgb.B, false)
+			}
+			if block.BackgroundValue != nil {
+				rgb := block.BackgroundValue.ToRGB()
+				inner.bg = a.color(rgb.R, rgb.G, rgb.B, true)
+			}
+			content, err := a.inline(block.Content, inner)
+			if err != nil {
+				return nil, err
+			}
+			pieces = append(pieces, content...)
+		case *ast.SizeBlock:
+			// Terminals have a single text size.
+			content, err := a.inline(block.Content, s)
+			if err != nil {
+				return nil, err
+			}
+			pieces = append(pieces, content...)
+		case *ast.FontBlock:
+			content, err := a.inline(block.Content, s)
+			if err != nil {
+				return nil, err
+			}
+			pieces = append(pieces, content...)
+		case *ast.InlineImageBlock:
+			pieces = append(pieces, piece{imageLabel(block.Alt, block.Source), style{dim: true}})
+		default:
+			return nil, errors.New("invalid ast")
+		}
+	}
+	return pieces, nil
+}
+
+// Get the placeholder for an image.
+func imageLabel(alt, src string) string {
+	if alt == "" {
+		alt = src
+	}
+	return "[image: " + alt + "]"
+}
+
+// Wrap pieces into lines of at most a width. Runs of white space collapse to
+// a single space, newlines break lines and words longer than the width are
+// split. Other control characters are dropped.
+func (a *ANSIExporter) wrap(pieces []piece, width int) []line {
+	if width < 1 {
+		width = 1
+	}
+	lines := []line{}
+	current, currentWidth := []piece{}, 0
+	word, wordWidth := []piece{}, 0
+	var space *style
+
+	flushLine := func() {
+		lines = append(lines, line{a.render(current), currentWidth})
+		current, currentWidth, space = []piece{}, 0, nil
+	}
+	flushWord := func() {
+		if len(word) == 0 {
+			return
+		}
+		gap := 0
+		if space != nil && currentWidth > 0 {
+			gap = 1
+		}
+		if currentWidth > 0 && currentWidth+gap+wordWidth > width {
+			flushLine()
+			gap = 0
+		}
+		if gap == 1 {
+			current = append(current, piece{" ", *space})
+			currentWidth++
+		}
+		for _, p := range word {
+			for _, c := range p.text {
+				w := runeWidth(c)
+				if currentWidth+w > width && currentWidth > 0 {
+					flushLine()
+				}
+				current = appendRune(current, c, p.style)
+				currentWidth += w
+			}
+		}
+		word, wordWidth, space = []piece{}, 0, nil
+	}
+
+	for _, p := range pieces {
+		for _, c := range p.text {
+			switch {
+			case c == '\n':
+				flushWord()
+				flushLine()
+			case unicode.IsSpace(c):
+				flushWord()
+				if space == nil {
+					s := p.style
+					space = &s
+				}
+			case unicode.IsControl(c):
+				// Control characters could change the terminal's state.
+			default:
+				word = appendRune(word, c, p.style)
+				wordWidth += runeWidth(c)
+			}
+		}
+	}
+	flushWord()
+	if len(current) > 0 || len(lines) == 0 {
+		flushLine()
+	}
+	return lines
+}
+
+// Append a rune to pieces, extending the last piece if it has the same
+// style.
+func appendRune(pieces []piece, c rune, s style) []piece {
+	if len(pieces) > 0 && pieces[len(pieces)-1].style == s {
+		pieces[len(pieces)-1].text += string(c)
+		return pieces
+	}
+	return append(pieces, piece{string(c), s})
+}
+
+// Render pieces with escape sequences. The line ends with all attributes
+// reset.
+func (a *ANSIExporter) render(pieces []piece) string {
+	out := strings.Builder{}
+	open := false
+	for i, p := range pieces {
+		if i == 0 || p.style != pieces[i-1].style {
+			sgr := a.sgr(p.style)
+			if open {
+				out.WriteString("\x1b[0m")
+			}
+			if sgr != "" {
+				out.WriteString("\x1b[" + sgr + "m")
+			}
+			open = sgr != ""
+		}
+		out.WriteString(p.text)
+	}
+	if open {
+		out.WriteString("\x1b[0m")
+	}
+	return out.String()
+}
+
+// Get the SGR parameters for a style.
+func (a *ANSIExporter) sgr(s style) string {
+	if a.settings.ColorMode == PlainMode {
+		return ""
+	}
+	params := []string{}
+	if s.bold {
+		params = append(params, "1")
+	}
+	if s.dim {
+		params = append(params, "2")
+	}
+	if s.italic {
+		params = append(params, "3")
+	}
+	if s.underline {
+		params = append(params, "4")
+	}
+	if s.strike {
+		params = append(params, "9")
+	}
+	fg := s.fg
+	if fg == "" && s.link {
+		fg = a.color(linkColor[0], linkColor[1], linkColor[2], false)
+	} else if fg == "" && s.code {
+		fg = a.color(codeColor[0], codeColor[1], codeColor[2], false)
+	}
+	if fg != "" {
+		params = append(params, fg)
+	}
+	if s.bg != "" {
+		params = append(params, s.bg)
+	}
+	return strings.Join(params, ";")
+}
+
+// Get a string's display width.
+func textWidth(s string) int {
+	width := 0
+	for _, c := range s {
+		width += runeWidth(c)
+	}
+	return width
+}
+
+// Get the number of columns a character takes. Wide East Asian characters
+// and emoji take two columns and combining marks take none.
+func runeWidth(c rune) int {
+	switch {
+	case c == utf8.RuneError || unicode.Is(unicode.Mn, c) || unicode.Is(unicode.Me, c) || c == '\u200b':
+		return 0
+	case c >= 0x1100 && c <= 0x115f, c >= 0x2e80 && c <= 0xa4cf && c != 0x303f,
+		c >= 0xac00 && c <= 0xd7a3, c >= 0xf900 && c <= 0xfaff, c >= 0xfe30 && c <= 0xfe4f,
+		c >= 0xff00 && c <= 0xff60, c >= 0xffe0 && c <= 0xffe6, c >= 0x1f300 && c <= 0x1f64f,
+		c >= 0x1f900 && c <= 0x1f9ff, c >= 0x20000 && c <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,547 @@
+// export/asciidoc/asciidoc.go
+// Package asciidoc provides functionality for exporting into AsciiDoc.
+
+package asciidoc
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	"github.com/cubeflix/cdf/export"
+)
+
+// Text that would be read as markup or replaced, like "*", "{name}" or
+// "--".
+var special = regexp.MustCompile("[*_`#^~+\\[\\]{}<>\\\\|=!]|://|--|\\.\\.\\.|::|;;|\\((C|R|TM)\\)|->|<-")
+
+// Line starts that would start a block, like list items, block titles,
+// comments and admonition paragraphs.
+var blockStart = regexp.MustCompile(`^([.:\-/']|(\d+|[a-zA-Z]|[ivxlcdmIVXLCDM]+)[.)](\s|$)|(NOTE|TIP|IMPORTANT|WARNING|CAUTION):\s)`)
+
+// The widths of CSS units in pixels.
+var pixels = map[ast.SizeType]float32{
+	ast.PixelSizeType:      1,
+	ast.PointSizeType:      4.0 / 3,
+	ast.CentimeterSizeType: 96 / 2.54,
+	ast.MillimeterSizeType: 96 / 25.4,
+}
+
+// AsciiDoc exporter.
+type AsciiDocExporter struct {
+	stream   io.Writer
+	settings AsciiDocSettings
+
+	// The nesting of delimited blocks, which sets the length of their
+	// delimiters, and of lists, which sets the length of their markers.
+	depth, listDepth int
+
+	// The cell separator of the table being exported.
+	separator string
+
+	// Whether blocks are attached to a list item.
+	attached bool
+}
+
+// Create a new AsciiDoc exporter.
+func NewAsciiDocExporter(stream io.Writer, settings AsciiDocSettings) *AsciiDocExporter {
+	return &AsciiDocExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to AsciiDoc.
+func (a *AsciiDocExporter) Export(d *ast.Document) error {
+	a.depth, a.listDepth, a.separator, a.attached = 0, 0, "", false
+
+	// Write the document header.
+	header := []string{}
+	title := ""
+	if !a.settings.OmitTitle {
+		title = escapeLine(d.Title)
+	}
+	if !a.settings.OmitSubtitle && d.Subtitle != "" {
+		if title != "" {
+			title += ": "
+		}
+		title += escapeLine(d.Subtitle)
+	}
+	if title != "" {
+		header = append(header, "= "+title)
+	}
+	if !a.settings.OmitAuthor && d.Author != "" {
+		header = append(header, ":author: "+oneLine(d.Author))
+	}
+	if !a.settings.OmitDate && d.Date != "" {
+		header = append(header, ":revdate: "+oneLine(d.Date))
+	}
+
+	content, err := a.exportBlocks(d.Content)
+	if err != nil {
+		return err
+	}
+
+	out := strings.Join(header, "\n")
+	if out != "" && content != "" {
+		out += "\n\n"
+	}
+	out += content
+	if out != "" {
+		out += "\n"
+	}
+	_, err = io.WriteString(a.stream, out)
+	return err
+}
+
+// Export a slice of blocks, separated by blank lines, or by list
+// continuations in list items.
+func (a *AsciiDocExporter) exportBlocks(blocks []ast.Block) (string, error) {
+	parts := make([]string, 0, len(blocks))
+	list := false
+	for i := range blocks {
+		part, err := a.exportBlock(blocks[i])
+		if err != nil {
+			return "", err
+		}
+		if part == "" {
+			continue
+		}
+
+		// Lists following a list would be joined to it.
+		_, isList := blocks[i].(*ast.List)
+		if list && isList && !a.attached {
+			parts = append(parts, "//")
+		}
+		list = isList
+		parts = append(parts, part)
+	}
+	if a.attached {
+		return strings.Join(parts, "\n+\n"), nil
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// Export a block to AsciiDoc.
+func (a *AsciiDocExporter) exportBlock(b ast.Block) (string, error) {
+	var out string
+	var err error
+
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		out, err = a.exportParagraph(block.Content)
+	case *ast.BasicBlock:
+		out, err = a.exportBlocks(block.Content)
+		if role := alignmentRole(block.Alignment); role != "" && out != "" && !a.attached {
+			// Open blocks can't be nested, so only the outer block is
+			// aligned.
+			return role + "\n--\n" + out + "\n--", err
+		}
+		return out, err
+	case *ast.Quote:
+		// Quotes starting with a label like "Note:" are admonitions.
+		if kind, content := export.Admonition(block); kind != "" {
+			return a.delimited("["+admonitionStyle(kind)+"]", "=", content)
+		}
+		return a.delimited("", "_", block.Content)
+	case *ast.Image:
+		return a.exportImage(block)
+	case *ast.Heading:
+		if block.Class < ast.Heading1Type || block.Class > ast.Heading5Type {
+			return "", errors.New("invalid ast")
+		}
+		out, err = a.exportInlineBlocks(block.Content)
+		if out = oneLine(out); out == "" {
+			return "", err
+		}
+		out = strings.Repeat("=", int(block.Class-ast.Heading1Type)+2) + " " + out
+
+		// Sections can't be nested in other blocks.
+		if a.depth > 0 || a.listDepth > 0 {
+			out = "[discrete]\n" + out
+		}
+	case *ast.HorizontalRule:
+		return "'''", nil
+	case *ast.List:
+		return a.exportList(block)
+	case *ast.Table:
+		return a.exportTable(block)
+	case *ast.Collapse:
+		summary, err := a.exportInlineBlocks(block.Summary)
+		if err != nil {
+			return "", err
+		}
+		title := ""
+		if summary = oneLine(summary); summary != "" {
+			title = "." + summary + "\n"
+		}
+		return a.delimited(title+"[%collapsible]", "=", block.Content)
+	case *ast.PageBreak:
+		return "<<<", nil
+	case *ast.Notes:
+		// Speaker notes are left out.
+		return "", nil
+	default:
+		return "", errors.New("invalid ast")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if role := alignmentRole(b.GetAlignment()); role != "" && out != "" {
+		out = role + "\n" + out
+	}
+	return out, nil
+}
+
+// Export a paragraph. Line breaks are hard line breaks.
+func (a *AsciiDocExporter) exportParagraph(content []ast.InlineBlock) (string, error) {
+	out, err := a.exportInlineBlocks(content)
+	if err != nil {
+		return "", err
+	}
+	lines := []string{}
+	for _, l := range strings.Split(out, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			if blockStart.MatchString(l) {
+				l = "{empty}" + l
+			}
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, " +\n"), nil
+}
+
+// Export blocks in a delimited block, with lines of attributes before it.
+// Delimiters get longer with nesting, so nested blocks are told apart.
+func (a *AsciiDocExporter) delimited(attributes, c string, blocks []ast.Block) (string, error) {
+	a.depth++
+	attached := a.attached
+	a.attached = false
+	content, err := a.exportBlocks(blocks)
+	a.depth--
+	a.attached = attached
+	if err != nil {
+		return "", err
+	}
+
+	delimiter := strings.Repeat(c, 3+a.depth+1)
+	out := delimiter + "\n"
+	if content != "" {
+		out += content + "\n"
+	}
+	out += delimiter
+	if attributes != "" {
+		out = attributes + "\n" + out
+	}
+	return out, nil
+}
+
+// Export an image block. The caption is the block's title.
+func (a *AsciiDocExporter) exportImage(block *ast.Image) (string, error) {
+	attributes, err := imageAttributes(block.Alt, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+	if err != nil {
+		return "", err
+	}
+	side := map[ast.AlignmentType]string{ast.LeftAlign: "left", ast.RightAlign: "right", ast.CenterAlign: "center"}[block.Alignment]
+	if side != "" {
+		if block.Wrap && side != "center" {
+			attributes = append(attributes, "float="+side)
+		} else {
+			attributes = append(attributes, "align="+side)
+		}
+	}
+
+	out := "image::" + target(block.Source) + "[" + strings.Join(attributes, ",") + "]"
+	if block.HasCaption && len(block.Caption) != 0 {
+		caption, err := a.exportInlineBlocks(block.Caption)
+		if err != nil {
+			return "", err
+		}
+		if caption = oneLine(caption); caption != "" {
+			out = "." + caption + "\n" + out
+		}
+	}
+	return out, nil
+}
+
+// Export a list. Items are attached to their first line with list
+// continuations, and nested lists are attached to the previous item.
+func (a *AsciiDocExporter) exportList(block *ast.List) (string, error) {
+	a.listDepth++
+	attached := a.attached
+	a.attached = true
+	defer func() {
+		a.listDepth--
+		a.attached = attached
+	}()
+
+	marker := strings.Repeat("*", a.listDepth)
+	if block.Ordered {
+		marker = strings.Repeat(".", a.listDepth)
+	}
+	items := []string{}
+	for i := range block.Items {
+		item, err := a.exportBlock(block.Items[i])
+		if err != nil {
+			return "", err
+		}
+		if _, nested := block.Items[i].(*ast.List); nested && len(items) > 0 {
+			items[len(items)-1] += "\n" + item
+			continue
+		}
+
+		// Items that don't start with text start with an empty line.
+		if item == "" {
+			item = "{empty}"
+		} else if _, ok := firstBlock(block.Items[i]).(*ast.Paragraph); !ok {
+			item = "{empty}\n+\n" + item
+		}
+		items = append(items, marker+" "+item)
+	}
+	return strings.Join(items, "\n"), nil
+}
+
+// Export a table. Cells of a single paragraph are written inline, and
+// other cells are AsciiDoc cells. Tables in tables use ! to separate cells.
+func (a *AsciiDocExporter) exportTable(block *ast.Table) (string, error) {
+	columns := 0
+	for i := range block.Rows {
+		if len(block.Rows[i].Cells) > columns {
+			columns = len(block.Rows[i].Cells)
+		}
+	}
+	if columns == 0 {
+		return "", nil
+	}
+
+	separator := a.separator
+	a.separator = "|"
+	if separator != "" {
+		a.separator = "!"
+	}
+	attached := a.attached
+	a.attached = false
+	a.depth++
+	defer func() {
+		a.separator = separator
+		a.attached = attached
+		a.depth--
+	}()
+
+	header := len(block.Rows[0].Cells) > 0
+	for _, cell := range block.Rows[0].Cells {
+		header = header && cell.IsHeader
+	}
+	rows := make([]string, len(block.Rows))
+	for i := range block.Rows {
+		cells := make([]string, columns)
+		for j := range cells {
+			cells[j] = a.separator
+			if j >= len(block.Rows[i].Cells) {
+				continue
+			}
+			cell := block.Rows[i].Cells[j]
+			content, err := a.exportBlocks(cell.Content)
+			if err != nil {
+				return "", err
+			}
+			content = strings.ReplaceAll(content, a.separator, "\\"+a.separator)
+			simple := len(cell.Content) == 0
+			if len(cell.Content) == 1 {
+				_, paragraph := cell.Content[0].(*ast.Paragraph)
+				simple = paragraph && !strings.Contains(content, "\n")
+			}
+
+			switch {
+			case !simple:
+				cells[j] = "a" + a.separator + "\n" + content
+			case cell.IsHeader && !(header && i == 0):
+				cells[j] = "h" + a.separator + content
+			default:
+				cells[j] = a.separator + content
+			}
+		}
+		rows[i] = strings.Join(cells, "\n")
+	}
+
+	options := "cols=\"" + strconv.Itoa(columns) + "*\""
+	if header {
+		options = "%header," + options
+	}
+	delimiter := a.separator + "==="
+	return "[" + options + "]\n" + delimiter + "\n" + strings.Join(rows, "\n\n") + "\n" + delimiter, nil
+}
+
+// Export a slice of inline blocks.
+func (a *AsciiDocExporter) exportInlineBlocks(blocks []ast.InlineBlock) (string, error) {
+	out := strings.Builder{}
+	for i := range blocks {
+		s, err := a.exportInlineBlock(blocks[i])
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(s)
+	}
+	return out.String(), nil
+}
+
+// Export an inline block to AsciiDoc.
+func (a *AsciiDocExporter) exportInlineBlock(b ast.InlineBlock) (string, error) {
+	switch block := b.(type) {
+	case *ast.Text:
+		return escapeText(block.Value), nil
+	case *ast.InlineImageBlock:
+		attributes, err := imageAttributes(block.Alt, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+		if err != nil {
+			return "", err
+		}
+		return "image:" + target(block.Source) + "[" + strings.Join(attributes, ",") + "]", nil
+	}
+
+	content, err := a.exportInlineBlocks(b.Children())
+	if err != nil {
+		return "", err
+	}
+	switch block := b.(type) {
+	case *ast.HyperlinkBlock:
+		return "link:" + target(block.Destination) + "[" + oneLine(content) + "]", nil
+	case *ast.FormattingBlock:
+		switch block.Attribute {
+		case ast.BoldFormatting:
+			return wrap(content, "**", "**"), nil
+		case ast.ItalicFormatting:
+			return wrap(content, "__", "__"), nil
+		case ast.TeletypeFormatting:
+			return wrap(content, "``", "``"), nil
+		case ast.StrikethroughFormatting:
+			return wrap(content, "[.line-through]##", "##"), nil
+		case ast.UnderlineFormatting:
+			return wrap(content, "[.underline]##", "##"), nil
+		}
+	case *ast.ColorBlock, *ast.SizeBlock, *ast.FontBlock:
+		// Colors, sizes and fonts are dropped.
+		return content, nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Wrap content in unconstrained formatting marks. Surrounding white space
+// is moved outside of the marks.
+func wrap(content, open, close string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return content
+	}
+	start := strings.Index(content, trimmed)
+	return content[:start] + open + trimmed + close + content[start+len(trimmed):]
+}
+
+// Get the attributes of an image macro. Sizes are in pixels, or
+// percentages.
+func imageAttributes(alt string, hasWidth bool, widthValue float32, widthType ast.SizeType, hasHeight bool, heightValue float32, heightType ast.SizeType) ([]string, error) {
+	attributes := []string{"\"" + strings.ReplaceAll(oneLine(alt), "\"", "\\\"") + "\""}
+	if hasWidth {
+		size, err := pixelSize(widthValue, widthType)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, "width="+size)
+	}
+	if hasHeight {
+		size, err := pixelSize(heightValue, heightType)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, "height="+size)
+	}
+	return attributes, nil
+}
+
+// Get a size in pixels, or a percentage.
+func pixelSize(value float32, t ast.SizeType) (string, error) {
+	if t == ast.PercentageSizeType {
+		return strconv.FormatFloat(float64(value), 'f', -1, 32) + "%", nil
+	}
+	scale, ok := pixels[t]
+	if !ok {
+		return "", errors.New("invalid ast")
+	}
+	return strconv.Itoa(int(value*scale + 0.5)), nil
+}
+
+// Get the role of an alignment. Returns an empty string for no alignment.
+func alignmentRole(a ast.AlignmentType) string {
+	switch a {
+	case ast.LeftAlign:
+		return "[.text-left]"
+	case ast.RightAlign:
+		return "[.text-right]"
+	case ast.CenterAlign:
+		return "[.text-center]"
+	}
+	return ""
+}
+
+// Get the admonition style for an admonition kind. AsciiDoc has five, so
+// the others are mapped to the closest one.
+func admonitionStyle(kind string) string {
+	switch kind {
+	case "tip", "hint":
+		return "TIP"
+	case "important", "attention":
+		return "IMPORTANT"
+	case "warning", "error":
+		return "WARNING"
+	case "caution", "danger":
+		return "CAUTION"
+	}
+	return "NOTE"
+}
+
+// Get the first block of a list item, looking into basic blocks.
+func firstBlock(b ast.Block) ast.Block {
+	if basic, ok := b.(*ast.BasicBlock); ok && len(basic.Content) > 0 {
+		return firstBlock(basic.Content[0])
+	}
+	return b
+}
+
+// Format a macro target. White space is encoded, and targets with brackets
+// are passed through.
+func target(dest string) string {
+	dest = strings.NewReplacer(" ", "%20", "\t", "%09", "\n", "%0A").Replace(dest)
+	if strings.ContainsAny(dest, "[]") {
+		return "++" + strings.ReplaceAll(dest, "+", "%2B") + "++"
+	}
+	return dest
+}
+
+// Escape text. Lines that would be read as markup are passed through with
+// only special characters replaced.
+func escapeText(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if !special.MatchString(l) {
+			continue
+		}
+
+		// A trailing backslash would escape the end of the passthrough.
+		trimmed := strings.TrimRight(l, `\`)
+		lines[i] = "pass:c[" + strings.ReplaceAll(trimmed, "]", `\]`) + "]" + l[len(trimmed):]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Escape a single line of text, like the title.
+func escapeLine(s string) string {
+	return escapeText(oneLine(s))
+}
+
+// Collapse text onto one line.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
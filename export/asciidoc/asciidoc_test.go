@@ -0,0 +1,92 @@
+// export/rst/asciidoc_test.go
+// AsciiDoc export tests.
+
+package asciidoc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+// Create a formatting block.
+func testFormat(attribute ast.FormattingType, content ...ast.InlineBlock) ast.InlineBlock {
+	return &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: attribute}
+}
+
+// Export blocks.
+func testExport(t *testing.T, blocks ...ast.Block) string {
+	out := strings.Builder{}
+	if err := NewAsciiDocExporter(&out, AsciiDocSettings{}).Export(&ast.Document{Content: blocks}); err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(out.String())
+}
+
+func TestExportInline(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []ast.InlineBlock
+		want    string
+	}{
+		{"escaping", testText("*a* `b` _c |d| \\"), "pass:c[*a* `b` _c |d| ]\\"},
+		{"bold italic", []ast.InlineBlock{testFormat(ast.BoldFormatting, &ast.Text{Value: "a "}, testFormat(ast.ItalicFormatting, &ast.Text{Value: "b*"}))}, "**a __pass:c[b*]__**"},
+		{"italic bold", []ast.InlineBlock{&ast.Text{Value: "x"}, testFormat(ast.ItalicFormatting, testFormat(ast.BoldFormatting, &ast.Text{Value: " a "})), &ast.Text{Value: "y"}}, "x __**a**__ y"},
+		{"code in bold", []ast.InlineBlock{testFormat(ast.BoldFormatting, testFormat(ast.TeletypeFormatting, &ast.Text{Value: "a"}))}, "**``a``**"},
+		{"bold in code", []ast.InlineBlock{testFormat(ast.TeletypeFormatting, &ast.Text{Value: "a "}, testFormat(ast.BoldFormatting, &ast.Text{Value: "b"}))}, "``a **b**``"},
+		{"code", []ast.InlineBlock{testFormat(ast.TeletypeFormatting, &ast.Text{Value: "a*b"})}, "``pass:c[a*b]``"},
+		{"code with backtick", []ast.InlineBlock{testFormat(ast.TeletypeFormatting, &ast.Text{Value: "a`"})}, "``pass:c[a`]``"},
+		{"code with backticks", []ast.InlineBlock{testFormat(ast.TeletypeFormatting, &ast.Text{Value: "``a\\b``"})}, "``pass:c[``a\\b``]``"},
+		{
+			"link in bold",
+			[]ast.InlineBlock{testFormat(ast.BoldFormatting, &ast.Text{Value: "a "}, &ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("b")}, Destination: "https://example.com"})},
+			"**a link:https://example.com[b]**",
+		},
+	}
+	for _, test := range tests {
+		if got := testExport(t, &ast.Paragraph{Content: test.content}); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.Block
+		want  string
+	}{
+		{
+			"nested list",
+			&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}},
+			"* a\n** b",
+		},
+		{
+			"nested ordered list",
+			&ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("b")}, &ast.Paragraph{Content: testText("c")}}}, &ast.Paragraph{Content: testText("d")}}},
+			". a\n** b\n** c\n. d",
+		},
+		{
+			"first nested list",
+			&ast.List{Items: []ast.Block{&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}}}}},
+			"* {empty}\n+\n** a",
+		},
+		{
+			"table",
+			&ast.Table{Rows: []ast.TableRow{{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("a|b")}}, IsHeader: true}, {}}}}},
+			"[cols=\"2*\"]\n|===\nh|pass:c[a\\|b]\n|\n|===",
+		},
+		{"missing image", &ast.Image{Source: "missing.png", Alt: "a"}, "image::missing.png[\"a\"]"},
+	}
+	for _, test := range tests {
+		if got := testExport(t, test.block); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
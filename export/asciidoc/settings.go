@@ -0,0 +1,11 @@
+// export/asciidoc/settings.go
+// AsciiDoc export settings.
+
+package asciidoc
+
+import "github.com/cubeflix/cdf/export"
+
+// AsciiDoc export settings.
+type AsciiDocSettings struct {
+	export.Settings
+}
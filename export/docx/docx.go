@@ -0,0 +1,397 @@
+// export/docx/docx.go
+// Package docx provides functionality for exporting into Office Open XML
+// word processing documents.
+
+package docx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Twips per point.
+const twips = 20
+
+// Heading font sizes in points.
+var headingSizes = []float64{16, 14, 13, 12, 11}
+
+// Paragraph properties inherited by nested blocks.
+type context struct {
+	// The paragraph style and alignment.
+	style string
+	align ast.AlignmentType
+
+	// The left indent in twips, and the list nesting depth.
+	indent int
+	depth  int
+
+	// Whether text is bold, for table header cells.
+	bold bool
+}
+
+// A package relationship of the main document.
+type relationship struct {
+	id, kind, target string
+	external         bool
+}
+
+// An image in the package.
+type picture struct {
+	rel           string
+	name          string
+	width, height int
+}
+
+// DOCX exporter.
+type DOCXExporter struct {
+	stream   io.Writer
+	settings DOCXSettings
+
+	relationships []relationship
+	media         map[string][]byte
+	mediaNames    []string
+	pictures      map[string]*picture
+	drawings      int
+
+	// Whether each numbering instance is ordered. Numbering IDs start at 1.
+	lists []bool
+
+	// The numbering of the next paragraph, for the first paragraph of a list
+	// item.
+	pendingNum, pendingLevel int
+}
+
+// Create a new DOCX exporter.
+func NewDOCXExporter(stream io.Writer, settings DOCXSettings) *DOCXExporter {
+	if settings.PageWidth <= 0 || settings.PageHeight <= 0 {
+		settings.PageWidth, settings.PageHeight = DefaultPageWidth, DefaultPageHeight
+	}
+	if settings.Margin <= 0 {
+		settings.Margin = DefaultMargin
+	}
+	if settings.Font == "" {
+		settings.Font = DefaultFont
+	}
+	if settings.FontSize <= 0 {
+		settings.FontSize = DefaultFontSize
+	}
+
+	return &DOCXExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to DOCX.
+func (x *DOCXExporter) Export(d *ast.Document) error {
+	x.relationships = []relationship{
+		{"rId1", relStyles, "styles.xml", false},
+		{"rId2", relNumbering, "numbering.xml", false},
+	}
+	x.media, x.mediaNames = map[string][]byte{}, nil
+	x.pictures = map[string]*picture{}
+	x.drawings = 0
+	x.lists = nil
+	x.pendingNum = 0
+
+	body := strings.Builder{}
+
+	// Write the title block.
+	info := []struct {
+		omit        bool
+		text, style string
+	}{
+		{x.settings.OmitTitle, d.Title, "Title"},
+		{x.settings.OmitSubtitle, d.Subtitle, "Subtitle"},
+		{x.settings.OmitDate, d.Date, "Subtitle"},
+		{x.settings.OmitAuthor, d.Author, "Subtitle"},
+	}
+	for _, i := range info {
+		if !i.omit && i.text != "" {
+			body.WriteString(x.paragraph(context{}, i.style, "", x.textRuns(i.text, run{})))
+		}
+	}
+
+	content, err := x.exportBlocks(d.Content, context{})
+	if err != nil {
+		return err
+	}
+	body.WriteString(content)
+
+	return x.write(d, body.String())
+}
+
+// Export a slice of blocks.
+func (x *DOCXExporter) exportBlocks(blocks []ast.Block, ctx context) (string, error) {
+	out := strings.Builder{}
+	for i := range blocks {
+		s, err := x.exportBlock(blocks[i], ctx)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(s)
+	}
+	return out.String(), nil
+}
+
+// Export a block to paragraphs and tables. Blocks without an alignment
+// inherit their parent's.
+func (x *DOCXExporter) exportBlock(b ast.Block, ctx context) (string, error) {
+	if b.GetAlignment() != ast.NoAlign {
+		ctx.align = b.GetAlignment()
+	}
+
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		runs, err := x.exportInlineBlocks(block.Content, x.baseRun(ctx))
+		if err != nil {
+			return "", err
+		}
+		return x.paragraph(ctx, "", "", runs), nil
+	case *ast.BasicBlock:
+		return x.exportBlocks(block.Content, ctx)
+	case *ast.Quote:
+		ctx.style = "Quote"
+		ctx.indent += 720
+		return x.exportBlocks(block.Content, ctx)
+	case *ast.Image:
+		return x.exportImage(block, ctx)
+	case *ast.Heading:
+		if block.Class < ast.Heading1Type || block.Class > ast.Heading5Type {
+			return "", errors.New("invalid ast")
+		}
+		level := int(block.Class - ast.Heading1Type)
+		r := x.baseRun(ctx)
+		r.size = headingSizes[level]
+		runs, err := x.exportInlineBlocks(block.Content, r)
+		if err != nil {
+			return "", err
+		}
+		return x.paragraph(ctx, "Heading"+strconv.Itoa(level+1), "", runs), nil
+	case *ast.HorizontalRule:
+		return x.paragraph(ctx, "", `<w:pBdr><w:bottom w:val="single" w:sz="6" w:space="1" w:color="auto"/></w:pBdr>`, ""), nil
+	case *ast.List:
+		return x.exportList(block, ctx)
+	case *ast.Table:
+		return x.exportTable(block, ctx)
+	case *ast.Collapse:
+		// Collapse blocks are expanded.
+		r := x.baseRun(ctx)
+		r.bold = true
+		summary, err := x.exportInlineBlocks(block.Summary, r)
+		if err != nil {
+			return "", err
+		}
+		content, err := x.exportBlocks(block.Content, ctx)
+		if err != nil {
+			return "", err
+		}
+		return x.paragraph(ctx, "", "", summary) + content, nil
+	case *ast.PageBreak:
+		return "<w:p><w:r><w:br w:type=\"page\"/></w:r></w:p>\n", nil
+	case *ast.Notes:
+		// Speaker notes are left out.
+		return "", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Write a paragraph. The style defaults to the context's. A pending list
+// numbering is applied and cleared.
+func (x *DOCXExporter) paragraph(ctx context, style, border, runs string) string {
+	props := ""
+	if style == "" {
+		style = ctx.style
+	}
+	if style != "" {
+		props += "<w:pStyle w:val=\"" + style + "\"/>"
+	}
+	numbered := x.pendingNum != 0
+	if numbered {
+		props += "<w:numPr><w:ilvl w:val=\"" + strconv.Itoa(x.pendingLevel) + "\"/><w:numId w:val=\"" + strconv.Itoa(x.pendingNum) + "\"/></w:numPr>"
+		x.pendingNum = 0
+	}
+	props += border
+	if ctx.indent > 0 && !numbered {
+		props += "<w:ind w:left=\"" + strconv.Itoa(ctx.indent) + "\"/>"
+	}
+	switch ctx.align {
+	case ast.LeftAlign:
+		props += "<w:jc w:val=\"left\"/>"
+	case ast.RightAlign:
+		props += "<w:jc w:val=\"right\"/>"
+	case ast.CenterAlign:
+		props += "<w:jc w:val=\"center\"/>"
+	}
+	if props != "" {
+		props = "<w:pPr>" + props + "</w:pPr>"
+	}
+	return "<w:p>" + props + runs + "</w:p>\n"
+}
+
+// Export a list with its own numbering instance. The first paragraph of
+// each item is numbered and the rest are indented to its text.
+func (x *DOCXExporter) exportList(block *ast.List, ctx context) (string, error) {
+	x.lists = append(x.lists, block.Ordered)
+	num := len(x.lists)
+	level := ctx.depth
+	if level > 8 {
+		level = 8
+	}
+	inner := ctx
+	inner.depth++
+	inner.indent = 720 * (level + 1)
+
+	out := strings.Builder{}
+	for i := range block.Items {
+		if _, nested := block.Items[i].(*ast.List); !nested {
+			x.pendingNum, x.pendingLevel = num, level
+		}
+		s, err := x.exportBlock(block.Items[i], inner)
+		x.pendingNum = 0
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(s)
+	}
+	return out.String(), nil
+}
+
+// Export a table with equal columns. Header rows repeat on each page.
+func (x *DOCXExporter) exportTable(block *ast.Table, ctx context) (string, error) {
+	x.pendingNum = 0
+	columns := 0
+	for i := range block.Rows {
+		if len(block.Rows[i].Cells) > columns {
+			columns = len(block.Rows[i].Cells)
+		}
+	}
+	if columns == 0 {
+		return "", nil
+	}
+	width := int((x.settings.PageWidth-2*x.settings.Margin)*twips) - ctx.indent
+	columnWidth := strconv.Itoa(width / columns)
+
+	out := strings.Builder{}
+	out.WriteString("<w:tbl><w:tblPr><w:tblStyle w:val=\"TableGrid\"/><w:tblW w:w=\"" + strconv.Itoa(width) + "\" w:type=\"dxa\"/>")
+	if ctx.indent > 0 {
+		out.WriteString("<w:tblInd w:w=\"" + strconv.Itoa(ctx.indent) + "\" w:type=\"dxa\"/>")
+	}
+	out.WriteString("</w:tblPr><w:tblGrid>")
+	for i := 0; i < columns; i++ {
+		out.WriteString("<w:gridCol w:w=\"" + columnWidth + "\"/>")
+	}
+	out.WriteString("</w:tblGrid>\n")
+
+	for i := range block.Rows {
+		row := block.Rows[i]
+		out.WriteString("<w:tr>")
+		header := len(row.Cells) > 0
+		for j := range row.Cells {
+			header = header && row.Cells[j].IsHeader
+		}
+		if header {
+			out.WriteString("<w:trPr><w:tblHeader/></w:trPr>")
+		}
+		for j := 0; j < columns; j++ {
+			var cell ast.TableCell
+			if j < len(row.Cells) {
+				cell = row.Cells[j]
+			}
+			content, err := x.exportBlocks(cell.Content, context{align: ctx.align, bold: cell.IsHeader})
+			if err != nil {
+				return "", err
+			}
+
+			// Cells must end with a paragraph.
+			if content == "" || strings.HasSuffix(content, "</w:tbl>\n") {
+				content += "<w:p/>"
+			}
+			out.WriteString("<w:tc><w:tcPr><w:tcW w:w=\"" + columnWidth + "\" w:type=\"dxa\"/>")
+			if cell.IsHeader {
+				out.WriteString("<w:shd w:val=\"clear\" w:color=\"auto\" w:fill=\"EEEEEE\"/>")
+			}
+			out.WriteString("</w:tcPr>" + content + "</w:tc>\n")
+		}
+		out.WriteString("</w:tr>\n")
+	}
+	out.WriteString("</w:tbl>\n")
+	return out.String(), nil
+}
+
+// Export an image block and its caption.
+func (x *DOCXExporter) exportImage(block *ast.Image, ctx context) (string, error) {
+	p, err := x.picture(block.Source)
+	if err != nil {
+		return "", err
+	}
+	var content string
+	if p == nil {
+		content = x.remoteImage(block.Source, block.Alt, x.baseRun(ctx))
+	} else {
+		content, err = x.drawing(p, block.Alt, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+		if err != nil {
+			return "", err
+		}
+	}
+	out := x.paragraph(ctx, "", "", content)
+
+	if block.HasCaption {
+		runs, err := x.exportInlineBlocks(block.Caption, x.baseRun(ctx))
+		if err != nil {
+			return "", err
+		}
+		caption := ctx
+		caption.style = "Caption"
+		out += x.paragraph(caption, "", "", runs)
+	}
+	return out, nil
+}
+
+// Add a relationship and get its ID. Hyperlink targets are reused.
+func (x *DOCXExporter) relationship(kind, target string, external bool) string {
+	if external {
+		for _, r := range x.relationships {
+			if r.kind == kind && r.target == target && r.external {
+				return r.id
+			}
+		}
+	}
+	id := "rId" + strconv.Itoa(len(x.relationships)+1)
+	x.relationships = append(x.relationships, relationship{id, kind, target, external})
+	return id
+}
+
+// Convert a size to English Metric Units. Percentages are relative to a
+// length. CSS pixels are 9525 EMU.
+func emu(value float32, t ast.SizeType, relative float64) (float64, error) {
+	switch t {
+	case ast.PercentageSizeType:
+		return float64(value) / 100 * relative, nil
+	case ast.PixelSizeType:
+		return float64(value) * 9525, nil
+	case ast.PointSizeType:
+		return float64(value) * 12700, nil
+	case ast.CentimeterSizeType:
+		return float64(value) * 360000, nil
+	case ast.MillimeterSizeType:
+		return float64(value) * 36000, nil
+	}
+	return 0, errors.New("invalid ast")
+}
+
+// Format a half-point size.
+func halfPoints(points float64) string {
+	return strconv.Itoa(int(math.Round(points * 2)))
+}
+
+// Format a color for run properties.
+func hexColor(r, g, b uint8) string {
+	return fmt.Sprintf("%02X%02X%02X", r, g, b)
+}
@@ -0,0 +1,107 @@
+// export/docx/docx_test.go
+// DOCX export tests.
+
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+// Export blocks and get the document body, without the section properties.
+func testExport(t *testing.T, settings DOCXSettings, blocks ...ast.Block) (string, error) {
+	out := bytes.Buffer{}
+	if err := NewDOCXExporter(&out, settings).Export(&ast.Document{Content: blocks}); err != nil {
+		return "", err
+	}
+	z, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range z.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		document := string(data)
+		start, end := strings.Index(document, "<w:body>\n"), strings.Index(document, "<w:sectPr>")
+		if start == -1 || end == -1 {
+			t.Fatalf("unexpected document:\n%s", document)
+		}
+		return document[start+len("<w:body>\n") : end], nil
+	}
+	t.Fatal("missing word/document.xml")
+	return "", nil
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.Block
+		want  string
+	}{
+		{"escaping", &ast.Paragraph{Content: testText(`<a & "b">`)}, `<w:t xml:space="preserve">&lt;a &amp; &#34;b&#34;&gt;</w:t>`},
+		{
+			"nested markup",
+			&ast.Paragraph{Content: []ast.InlineBlock{&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{
+				&ast.Text{Value: "a"},
+				&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("b")}, Attribute: ast.ItalicFormatting},
+			}}, Attribute: ast.BoldFormatting}}},
+			`<w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve">a</w:t></w:r><w:r><w:rPr><w:b/><w:i/></w:rPr><w:t xml:space="preserve">b</w:t></w:r>`,
+		},
+		{
+			"list",
+			&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}},
+			`<w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t xml:space="preserve">a</w:t></w:r></w:p>` + "\n" +
+				`<w:p><w:pPr><w:numPr><w:ilvl w:val="1"/><w:numId w:val="2"/></w:numPr></w:pPr><w:r><w:t xml:space="preserve">b</w:t>`,
+		},
+		{
+			"table",
+			&ast.Table{Rows: []ast.TableRow{{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("a")}}, IsHeader: true}, {}}}}},
+			`<w:shd w:val="clear" w:color="auto" w:fill="EEEEEE"/></w:tcPr><w:p><w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve">a</w:t></w:r></w:p>` + "\n" +
+				"</w:tc>\n" + `<w:tc><w:tcPr><w:tcW w:w="4513" w:type="dxa"/></w:tcPr><w:p/></w:tc>`,
+		},
+		{"remote image", &ast.Image{Source: "https://example.com/a.png", Alt: "a"}, `<w:hyperlink r:id="rId3" w:history="1"><w:r><w:rPr><w:rStyle w:val="Hyperlink"/></w:rPr><w:t xml:space="preserve">a</w:t></w:r></w:hyperlink>`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := testExport(t, DOCXSettings{}, test.block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, test.want) {
+				t.Errorf("got:\n%s\nwant it to contain:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExportMissingImage(t *testing.T) {
+	for _, b := range []ast.Block{
+		&ast.Image{Source: "missing.png"},
+		&ast.Paragraph{Content: []ast.InlineBlock{&ast.InlineImageBlock{Source: "missing.png"}}},
+	} {
+		if _, err := testExport(t, DOCXSettings{ImageDirectory: t.TempDir()}, b); err == nil || !strings.Contains(err.Error(), "image 'missing.png'") {
+			t.Errorf("got error %v, want a missing image error", err)
+		}
+	}
+}
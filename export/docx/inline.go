@@ -0,0 +1,316 @@
+// export/docx/inline.go
+// Runs, hyperlinks and images.
+
+package docx
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Font names for generic font families.
+var genericFonts = map[string]string{
+	"serif":      "Times New Roman",
+	"sans-serif": "Arial",
+	"sans":       "Arial",
+	"monospace":  "Courier New",
+}
+
+// Run properties.
+type run struct {
+	bold, italic, strike, underline bool
+
+	// The font, and the text and background colors as hexadecimal.
+	font, color, background string
+
+	// The font size in points, for relative sizes, and whether it is set on
+	// the run.
+	size  float64
+	sized bool
+
+	// Whether the run is in a hyperlink.
+	link bool
+}
+
+// Get the run properties for a context.
+func (x *DOCXExporter) baseRun(ctx context) run {
+	return run{bold: ctx.bold, size: x.settings.FontSize}
+}
+
+// Get the run properties element.
+func (r run) properties() string {
+	p := ""
+	if r.link {
+		p += "<w:rStyle w:val=\"Hyperlink\"/>"
+	}
+	if r.font != "" {
+		font := escape(r.font)
+		p += "<w:rFonts w:ascii=\"" + font + "\" w:hAnsi=\"" + font + "\" w:eastAsia=\"" + font + "\" w:cs=\"" + font + "\"/>"
+	}
+	if r.bold {
+		p += "<w:b/>"
+	}
+	if r.italic {
+		p += "<w:i/>"
+	}
+	if r.strike {
+		p += "<w:strike/>"
+	}
+	if r.color != "" {
+		p += "<w:color w:val=\"" + r.color + "\"/>"
+	}
+	if r.sized {
+		p += "<w:sz w:val=\"" + halfPoints(r.size) + "\"/><w:szCs w:val=\"" + halfPoints(r.size) + "\"/>"
+	}
+	if r.underline {
+		p += "<w:u w:val=\"single\"/>"
+	}
+	if r.background != "" {
+		p += "<w:shd w:val=\"clear\" w:color=\"auto\" w:fill=\"" + r.background + "\"/>"
+	}
+	if p == "" {
+		return ""
+	}
+	return "<w:rPr>" + p + "</w:rPr>"
+}
+
+// Export a slice of inline blocks to runs.
+func (x *DOCXExporter) exportInlineBlocks(blocks []ast.InlineBlock, r run) (string, error) {
+	out := strings.Builder{}
+	for i := range blocks {
+		s, err := x.exportInlineBlock(blocks[i], r)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(s)
+	}
+	return out.String(), nil
+}
+
+// Export an inline block to runs.
+func (x *DOCXExporter) exportInlineBlock(b ast.InlineBlock, r run) (string, error) {
+	switch block := b.(type) {
+	case *ast.Text:
+		return x.textRuns(block.Value, r), nil
+	case *ast.HyperlinkBlock:
+		// Hyperlinks can't be nested.
+		if r.link {
+			return x.exportInlineBlocks(block.Content, r)
+		}
+		r.link = true
+		content, err := x.exportInlineBlocks(block.Content, r)
+		if err != nil {
+			return "", err
+		}
+		return x.hyperlink(block.Destination, content), nil
+	case *ast.FormattingBlock:
+		switch block.Attribute {
+		case ast.BoldFormatting:
+			r.bold = true
+		case ast.ItalicFormatting:
+			r.italic = true
+		case ast.StrikethroughFormatting:
+			r.strike = true
+		case ast.UnderlineFormatting:
+			r.underline = true
+		case ast.TeletypeFormatting:
+			r.font = "Courier New"
+		default:
+			return "", errors.New("invalid ast")
+		}
+		return x.exportInlineBlocks(block.Content, r)
+	case *ast.ColorBlock:
+		if block.ForegroundValue != nil {
+			rgb := block.ForegroundValue.ToRGB()
+			r.color = hexColor(rgb.R, rgb.G, rgb.B)
+		}
+		if block.BackgroundValue != nil {
+			rgb := block.BackgroundValue.ToRGB()
+			r.background = hexColor(rgb.R, rgb.G, rgb.B)
+		}
+		return x.exportInlineBlocks(block.Content, r)
+	case *ast.SizeBlock:
+		if block.Type == ast.PercentageSizeType {
+			r.size *= float64(block.Value) / 100
+		} else {
+			size, err := emu(block.Value, block.Type, 0)
+			if err != nil {
+				return "", err
+			}
+			r.size = size / 12700
+		}
+		r.sized = true
+		return x.exportInlineBlocks(block.Content, r)
+	case *ast.FontBlock:
+		if font := fontName(block.Family); font != "" {
+			r.font = font
+		}
+		return x.exportInlineBlocks(block.Content, r)
+	case *ast.InlineImageBlock:
+		p, err := x.picture(block.Source)
+		if err != nil {
+			return "", err
+		}
+		if p == nil {
+			return x.remoteImage(block.Source, block.Alt, r), nil
+		}
+		return x.drawing(p, block.Alt, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Write text as a run. Newlines become line breaks.
+func (x *DOCXExporter) textRuns(text string, r run) string {
+	out := strings.Builder{}
+	out.WriteString("<w:r>" + r.properties())
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			out.WriteString("<w:br/>")
+		}
+		if line != "" {
+			out.WriteString("<w:t xml:space=\"preserve\">" + escape(line) + "</w:t>")
+		}
+	}
+	out.WriteString("</w:r>")
+	return out.String()
+}
+
+// Wrap runs in a hyperlink. Destinations starting with "#" link to
+// bookmarks.
+func (x *DOCXExporter) hyperlink(dest, content string) string {
+	if strings.HasPrefix(dest, "#") {
+		return "<w:hyperlink w:anchor=\"" + escape(dest[1:]) + "\" w:history=\"1\">" + content + "</w:hyperlink>"
+	}
+	return "<w:hyperlink r:id=\"" + x.relationship(relHyperlink, dest, true) + "\" w:history=\"1\">" + content + "</w:hyperlink>"
+}
+
+// Get the first font name from a comma-separated list of families.
+func fontName(family string) string {
+	names := strings.Split(family, ",")
+	for i := range names {
+		name := strings.Trim(strings.TrimSpace(names[i]), "\"'")
+		if generic, ok := genericFonts[strings.ToLower(name)]; ok {
+			return generic
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// Get an image by its source, adding it to the package. Returns nil for
+// remote images, which are not embedded.
+func (x *DOCXExporter) picture(src string) (*picture, error) {
+	if p, ok := x.pictures[src]; ok {
+		return p, nil
+	}
+	if strings.Contains(src, "://") || strings.HasPrefix(src, "data:") {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(x.settings.ImageDirectory, filepath.FromSlash(src)))
+	if err != nil {
+		return nil, errors.New("image '" + src + "': " + err.Error())
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("image '" + src + "': unsupported image format")
+	}
+
+	name := "image" + strconv.Itoa(len(x.mediaNames)+1) + "." + format
+	x.media[name] = data
+	x.mediaNames = append(x.mediaNames, name)
+	p := &picture{
+		rel:    x.relationship(relImage, "media/"+name, false),
+		name:   name,
+		width:  config.Width,
+		height: config.Height,
+	}
+	x.pictures[src] = p
+	return p, nil
+}
+
+// Write an inline drawing of an image. Images keep their aspect ratio when
+// one dimension is given and are shrunk to fit the page.
+func (x *DOCXExporter) drawing(p *picture, alt string, hasWidth bool, widthValue float32, widthType ast.SizeType, hasHeight bool, heightValue float32, heightType ast.SizeType) (string, error) {
+	maxWidth := (x.settings.PageWidth - 2*x.settings.Margin) * 12700
+	maxHeight := (x.settings.PageHeight - 2*x.settings.Margin) * 12700
+	w, h := float64(p.width)*9525, float64(p.height)*9525
+	var err error
+	switch {
+	case hasWidth && hasHeight:
+		if w, err = emu(widthValue, widthType, maxWidth); err == nil {
+			h, err = emu(heightValue, heightType, maxHeight)
+		}
+	case hasWidth:
+		natural := w
+		if w, err = emu(widthValue, widthType, maxWidth); err == nil && natural > 0 {
+			h *= w / natural
+		}
+	case hasHeight:
+		natural := h
+		if h, err = emu(heightValue, heightType, maxHeight); err == nil && natural > 0 {
+			w *= h / natural
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if w > maxWidth {
+		h *= maxWidth / w
+		w = maxWidth
+	}
+	if h > maxHeight {
+		w *= maxHeight / h
+		h = maxHeight
+	}
+
+	x.drawings++
+	id := strconv.Itoa(x.drawings)
+	cx, cy := strconv.Itoa(int(w)), strconv.Itoa(int(h))
+	descr := escape(alt)
+	return "<w:r><w:drawing><wp:inline distT=\"0\" distB=\"0\" distL=\"0\" distR=\"0\">" +
+		"<wp:extent cx=\"" + cx + "\" cy=\"" + cy + "\"/>" +
+		"<wp:docPr id=\"" + id + "\" name=\"Picture " + id + "\" descr=\"" + descr + "\"/>" +
+		"<wp:cNvGraphicFramePr><a:graphicFrameLocks noChangeAspect=\"1\"/></wp:cNvGraphicFramePr>" +
+		"<a:graphic><a:graphicData uri=\"http://schemas.openxmlformats.org/drawingml/2006/picture\"><pic:pic>" +
+		"<pic:nvPicPr><pic:cNvPr id=\"" + id + "\" name=\"" + p.name + "\" descr=\"" + descr + "\"/><pic:cNvPicPr/></pic:nvPicPr>" +
+		"<pic:blipFill><a:blip r:embed=\"" + p.rel + "\"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>" +
+		"<pic:spPr><a:xfrm><a:off x=\"0\" y=\"0\"/><a:ext cx=\"" + cx + "\" cy=\"" + cy + "\"/></a:xfrm><a:prstGeom prst=\"rect\"><a:avLst/></a:prstGeom></pic:spPr>" +
+		"</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>", nil
+}
+
+// Write a remote image as a hyperlink to it, labeled with its alternative
+// text.
+func (x *DOCXExporter) remoteImage(src, alt string, r run) string {
+	if alt == "" {
+		alt = src
+	}
+	if r.link {
+		return x.textRuns(alt, r)
+	}
+	r.link = true
+	return x.hyperlink(src, x.textRuns(alt, r))
+}
+
+// Escape text for XML, dropping characters XML doesn't allow.
+func escape(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xfffe || r == 0xffff {
+			return -1
+		}
+		return r
+	}, s)
+	return html.EscapeString(s)
+}
@@ -0,0 +1,185 @@
+// export/docx/package.go
+// DOCX package parts, styles and numbering definitions.
+
+package docx
+
+import (
+	"archive/zip"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Relationship types.
+const (
+	relOfficeDocument = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument"
+	relCoreProperties = "http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties"
+	relStyles         = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles"
+	relNumbering      = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering"
+	relHyperlink      = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink"
+	relImage          = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/image"
+)
+
+const contentTypesXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Default Extension="png" ContentType="image/png"/>
+<Default Extension="jpeg" ContentType="image/jpeg"/>
+<Default Extension="gif" ContentType="image/gif"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>
+<Override PartName="/word/numbering.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"/>
+<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>
+</Types>
+`
+
+const packageRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="` + relOfficeDocument + `" Target="word/document.xml"/>
+<Relationship Id="rId2" Type="` + relCoreProperties + `" Target="docProps/core.xml"/>
+</Relationships>
+`
+
+// Write the DOCX package.
+func (x *DOCXExporter) write(d *ast.Document, body string) error {
+	type file struct {
+		name string
+		data []byte
+	}
+	files := []file{
+		{"[Content_Types].xml", []byte(contentTypesXML)},
+		{"_rels/.rels", []byte(packageRelsXML)},
+		{"docProps/core.xml", []byte(coreProperties(d))},
+		{"word/document.xml", []byte(x.document(body))},
+		{"word/styles.xml", []byte(x.styles())},
+		{"word/numbering.xml", []byte(x.numbering())},
+		{"word/_rels/document.xml.rels", []byte(x.documentRels())},
+	}
+	for _, name := range x.mediaNames {
+		files = append(files, file{"word/media/" + name, x.media[name]})
+	}
+
+	z := zip.NewWriter(x.stream)
+	for _, f := range files {
+		w, err := z.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+// Generate the core properties.
+func coreProperties(d *ast.Document) string {
+	out := strings.Builder{}
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	if d.Title != "" {
+		out.WriteString("<dc:title>" + escape(d.Title) + "</dc:title>\n")
+	}
+	if d.Subtitle != "" {
+		out.WriteString("<dc:subject>" + escape(d.Subtitle) + "</dc:subject>\n")
+	}
+	if d.Author != "" {
+		out.WriteString("<dc:creator>" + escape(d.Author) + "</dc:creator>\n")
+	}
+	out.WriteString("</cp:coreProperties>\n")
+	return out.String()
+}
+
+// Generate the main document part.
+func (x *DOCXExporter) document(body string) string {
+	s := x.settings
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture">
+<w:body>
+` + body + `<w:sectPr><w:pgSz w:w="` + strconv.Itoa(int(s.PageWidth*twips)) + `" w:h="` + strconv.Itoa(int(s.PageHeight*twips)) + `"/>` +
+		`<w:pgMar w:top="` + strconv.Itoa(int(s.Margin*twips)) + `" w:right="` + strconv.Itoa(int(s.Margin*twips)) + `" w:bottom="` + strconv.Itoa(int(s.Margin*twips)) + `" w:left="` + strconv.Itoa(int(s.Margin*twips)) + `" w:header="720" w:footer="720" w:gutter="0"/></w:sectPr>
+</w:body>
+</w:document>
+`
+}
+
+// Generate the style definitions.
+func (x *DOCXExporter) styles() string {
+	font := escape(x.settings.Font)
+	out := strings.Builder{}
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="` + font + `" w:hAnsi="` + font + `" w:eastAsia="` + font + `" w:cs="` + font + `"/><w:sz w:val="` + halfPoints(x.settings.FontSize) + `"/><w:szCs w:val="` + halfPoints(x.settings.FontSize) + `"/></w:rPr></w:rPrDefault>
+<w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="276" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>
+<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>
+<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:spacing w:after="80"/></w:pPr><w:rPr><w:sz w:val="56"/><w:szCs w:val="56"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Subtitle"><w:name w:val="Subtitle"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:rPr><w:color w:val="595959"/><w:sz w:val="28"/><w:szCs w:val="28"/></w:rPr></w:style>
+`)
+	for i, size := range headingSizes {
+		level := strconv.Itoa(i + 1)
+		out.WriteString(`<w:style w:type="paragraph" w:styleId="Heading` + level + `"><w:name w:val="heading ` + level + `"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:keepNext/><w:spacing w:before="240" w:after="80"/><w:outlineLvl w:val="` + strconv.Itoa(i) + `"/></w:pPr><w:rPr><w:b/><w:bCs/><w:sz w:val="` + halfPoints(size) + `"/><w:szCs w:val="` + halfPoints(size) + `"/></w:rPr></w:style>
+`)
+	}
+	out.WriteString(`<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/><w:qFormat/><w:rPr><w:i/><w:iCs/><w:color w:val="404040"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Caption"><w:name w:val="caption"/><w:basedOn w:val="Normal"/><w:qFormat/><w:rPr><w:i/><w:iCs/><w:sz w:val="18"/><w:szCs w:val="18"/></w:rPr></w:style>
+<w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/><w:rPr><w:color w:val="0563C1"/><w:u w:val="single"/></w:rPr></w:style>
+<w:style w:type="table" w:default="1" w:styleId="TableNormal"><w:name w:val="Normal Table"/><w:tblPr><w:tblInd w:w="0" w:type="dxa"/><w:tblCellMar><w:top w:w="0" w:type="dxa"/><w:left w:w="108" w:type="dxa"/><w:bottom w:w="0" w:type="dxa"/><w:right w:w="108" w:type="dxa"/></w:tblCellMar></w:tblPr></w:style>
+<w:style w:type="table" w:styleId="TableGrid"><w:name w:val="Table Grid"/><w:basedOn w:val="TableNormal"/><w:pPr><w:spacing w:after="0" w:line="240" w:lineRule="auto"/></w:pPr><w:tblPr><w:tblBorders><w:top w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:left w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:bottom w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:right w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:insideH w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:insideV w:val="single" w:sz="4" w:space="0" w:color="auto"/></w:tblBorders></w:tblPr></w:style>
+</w:styles>
+`)
+	return out.String()
+}
+
+// Generate the numbering definitions. Abstract numbering 0 is for bullet
+// lists and 1 is for numbered lists; each list restarts its own instance.
+func (x *DOCXExporter) numbering() string {
+	out := strings.Builder{}
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+`)
+	bullets := []string{"•", "◦", "▪"}
+	for abstract := 0; abstract < 2; abstract++ {
+		out.WriteString("<w:abstractNum w:abstractNumId=\"" + strconv.Itoa(abstract) + "\"><w:multiLevelType w:val=\"hybridMultilevel\"/>")
+		for level := 0; level < 9; level++ {
+			format, text := "bullet", bullets[level%len(bullets)]
+			if abstract == 1 {
+				format, text = "decimal", "%"+strconv.Itoa(level+1)+"."
+			}
+			out.WriteString("<w:lvl w:ilvl=\"" + strconv.Itoa(level) + "\"><w:start w:val=\"1\"/><w:numFmt w:val=\"" + format + "\"/><w:lvlText w:val=\"" + text + "\"/><w:lvlJc w:val=\"left\"/><w:pPr><w:ind w:left=\"" + strconv.Itoa(720*(level+1)) + "\" w:hanging=\"360\"/></w:pPr></w:lvl>")
+		}
+		out.WriteString("</w:abstractNum>\n")
+	}
+	for i, ordered := range x.lists {
+		abstract := "0"
+		if ordered {
+			abstract = "1"
+		}
+		out.WriteString("<w:num w:numId=\"" + strconv.Itoa(i+1) + "\"><w:abstractNumId w:val=\"" + abstract + "\"/>")
+		for level := 0; ordered && level < 9; level++ {
+			out.WriteString("<w:lvlOverride w:ilvl=\"" + strconv.Itoa(level) + "\"><w:startOverride w:val=\"1\"/></w:lvlOverride>")
+		}
+		out.WriteString("</w:num>\n")
+	}
+	out.WriteString("</w:numbering>\n")
+	return out.String()
+}
+
+// Generate the main document's relationships.
+func (x *DOCXExporter) documentRels() string {
+	out := strings.Builder{}
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+`)
+	for _, r := range x.relationships {
+		mode := ""
+		if r.external {
+			mode = " TargetMode=\"External\""
+		}
+		out.WriteString("<Relationship Id=\"" + r.id + "\" Type=\"" + r.kind + "\" Target=\"" + escape(r.target) + "\"" + mode + "/>\n")
+	}
+	out.WriteString("</Relationships>\n")
+	return out.String()
+}
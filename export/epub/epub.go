@@ -0,0 +1,278 @@
+// export/epub/epub.go
+// Package epub provides functionality for exporting into EPUB 3 e-books.
+
+package epub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cubeflix/cdf/ast"
+	"github.com/cubeflix/cdf/export/html"
+)
+
+// Core media types for image file extensions.
+var imageTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+}
+
+// Media types for font file extensions.
+var fontTypes = map[string]string{
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+// A chapter of the book.
+type chapter struct {
+	file     string
+	title    string
+	body     []byte
+	headings []*ast.Heading
+
+	// The index of the chapter's document, and whether it starts it.
+	document int
+	first    bool
+
+	// Whether the chapter references remote images.
+	remote bool
+}
+
+// An image or font in the book. Remote resources have no data.
+type resource struct {
+	id, file, mediaType string
+	data                []byte
+	remote              bool
+
+	// The font a font file provides.
+	font *Font
+}
+
+// EPUB exporter.
+type EPUBExporter struct {
+	stream   io.Writer
+	settings EPUBSettings
+
+	chapters  []*chapter
+	resources []*resource
+
+	// The book's images by their source.
+	images map[string]*resource
+}
+
+// Create a new EPUB exporter.
+func NewEPUBExporter(stream io.Writer, settings EPUBSettings) *EPUBExporter {
+	if settings.Language == "" {
+		settings.Language = DefaultLanguage
+	}
+	settings.Modified = settings.Modified.UTC().Truncate(time.Second)
+	if !settings.HTML.UseCustomQuoteBlockClass {
+		settings.HTML.QuoteBlockClass = html.DefaultQuoteBlockClass
+	}
+	if !settings.HTML.UseCustomImageBlockClass {
+		settings.HTML.ImageBlockClass = html.DefaultImageBlockClass
+	}
+	if !settings.HTML.UseCustomImageCaptionClass {
+		settings.HTML.ImageCaptionClass = html.DefaultImageCaptionClass
+	}
+	settings.HTML.Settings = settings.Settings
+	settings.HTML.XHTML = true
+	settings.HTML.HeadingIDs = true
+	settings.HTML.IncludeHeader, settings.HTML.IncludeFooter = false, false
+	settings.HTML.ClassStyles = false
+
+	return &EPUBExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to EPUB.
+func (e *EPUBExporter) Export(d *ast.Document) error {
+	return e.ExportDocuments([]*ast.Document{d})
+}
+
+// Export the documents to a single EPUB, in order.
+func (e *EPUBExporter) ExportDocuments(docs []*ast.Document) error {
+	if len(docs) == 0 {
+		return errors.New("no documents")
+	}
+	e.chapters, e.resources = nil, nil
+	e.images = map[string]*resource{}
+
+	for i := range docs {
+		if err := e.addDocument(docs[i], i); err != nil {
+			return err
+		}
+	}
+	for i := range e.settings.Fonts {
+		if err := e.addFont(e.settings.Fonts[i]); err != nil {
+			return err
+		}
+	}
+	return e.write(docs)
+}
+
+// Add a document's chapters and images.
+func (e *EPUBExporter) addDocument(d *ast.Document, index int) error {
+	// Work on a copy, so image sources can be rewritten.
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	d = &ast.Document{}
+	if err := json.Unmarshal(data, d); err != nil {
+		return err
+	}
+
+	parts := [][]ast.Block{d.Content}
+	if e.settings.SplitAtPageBreaks {
+		parts = splitAtPageBreaks(d.Content)
+	}
+	for i := range parts {
+		c := &chapter{
+			file:     "chapter-" + strconv.Itoa(len(e.chapters)+1) + ".xhtml",
+			document: index,
+			first:    i == 0,
+		}
+		var err error
+		ast.Inspect(&ast.Document{Content: parts[i]}, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.Heading:
+				c.headings = append(c.headings, node)
+			case *ast.Image:
+				node.Source, err = e.image(node.Source, c)
+			case *ast.InlineImageBlock:
+				node.Source, err = e.image(node.Source, c)
+			}
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Only the first chapter of a document has its title block.
+		settings := e.settings.HTML
+		if i > 0 {
+			settings.OmitTitle, settings.OmitSubtitle, settings.OmitDate, settings.OmitAuthor = true, true, true, true
+		}
+		body := bytes.Buffer{}
+		if err := html.NewHTMLExporter(&body, settings).Export(&ast.Document{
+			Title:    d.Title,
+			Subtitle: d.Subtitle,
+			Date:     d.Date,
+			Author:   d.Author,
+			Content:  parts[i],
+		}); err != nil {
+			return err
+		}
+		c.body = body.Bytes()
+
+		switch {
+		case len(c.headings) > 0:
+			c.title = ast.PlainText(c.headings[0].Content)
+		case d.Title != "":
+			c.title = d.Title
+		default:
+			c.title = "Chapter " + strconv.Itoa(len(e.chapters)+1)
+		}
+		e.chapters = append(e.chapters, c)
+	}
+	return nil
+}
+
+// Split blocks into parts at page breaks. Empty parts are dropped.
+func splitAtPageBreaks(blocks []ast.Block) [][]ast.Block {
+	parts := [][]ast.Block{}
+	current := []ast.Block{}
+	for i := range blocks {
+		if _, ok := blocks[i].(*ast.PageBreak); ok {
+			if len(current) > 0 {
+				parts = append(parts, current)
+			}
+			current = []ast.Block{}
+			continue
+		}
+		current = append(current, blocks[i])
+	}
+	if len(current) > 0 || len(parts) == 0 {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// Add an image to the book and get its new source. Remote images are
+// referenced as they are and data URLs are left in place.
+func (e *EPUBExporter) image(src string, c *chapter) (string, error) {
+	if strings.HasPrefix(src, "data:") || src == "" {
+		return src, nil
+	}
+	if r, ok := e.images[src]; ok {
+		c.remote = c.remote || r.remote
+		return r.file, nil
+	}
+
+	r := &resource{id: "image-" + strconv.Itoa(len(e.images)+1)}
+	if strings.Contains(src, "://") {
+		r.file, r.remote = src, true
+		r.mediaType = imageTypes[strings.ToLower(path.Ext(strings.SplitN(strings.SplitN(src, "?", 2)[0], "#", 2)[0]))]
+		if r.mediaType == "" {
+			r.mediaType = "image/png"
+		}
+		c.remote = true
+	} else {
+		var err error
+		r.data, err = ioutil.ReadFile(filepath.Join(e.settings.ImageDirectory, filepath.FromSlash(src)))
+		if err != nil {
+			return "", errors.New("image '" + src + "': " + err.Error())
+		}
+		r.mediaType = imageTypes[strings.ToLower(path.Ext(src))]
+		if r.mediaType == "" {
+			r.mediaType = http.DetectContentType(r.data)
+		}
+		ext := ""
+		for extension, mediaType := range imageTypes {
+			if mediaType == r.mediaType && (ext == "" || len(extension) < len(ext)) {
+				ext = extension
+			}
+		}
+		if ext == "" {
+			return "", errors.New("image '" + src + "': unsupported image type " + r.mediaType)
+		}
+		r.file = "images/" + r.id + ext
+	}
+	e.images[src] = r
+	e.resources = append(e.resources, r)
+	return r.file, nil
+}
+
+// Add a font file to the book.
+func (e *EPUBExporter) addFont(f Font) error {
+	ext := strings.ToLower(filepath.Ext(f.Path))
+	mediaType, ok := fontTypes[ext]
+	if !ok {
+		return errors.New("font '" + f.Path + "': unsupported font type")
+	}
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return errors.New("font '" + f.Path + "': " + err.Error())
+	}
+	id := "font-" + strconv.Itoa(len(e.resources)-len(e.images)+1)
+	e.resources = append(e.resources, &resource{id: id, file: "fonts/" + id + ext, mediaType: mediaType, data: data, font: &f})
+	return nil
+}
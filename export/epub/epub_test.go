@@ -0,0 +1,126 @@
+// export/epub/epub_test.go
+// EPUB export tests.
+
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+// Export a document and read the files of the book.
+func testExport(t *testing.T, settings EPUBSettings, d *ast.Document) (*zip.Reader, map[string]string, error) {
+	out := bytes.Buffer{}
+	if err := NewEPUBExporter(&out, settings).Export(d); err != nil {
+		return nil, nil, err
+	}
+	z, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(data)
+	}
+	return z, files, nil
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.Block
+		want  string
+	}{
+		{"escaping", &ast.Paragraph{Content: testText(`<a & "b">`)}, `&lt;a &amp; &#34;b&#34;&gt;`},
+		{
+			"nested markup",
+			&ast.Paragraph{Content: []ast.InlineBlock{&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{
+				&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("a")}, Attribute: ast.ItalicFormatting},
+			}}, Attribute: ast.BoldFormatting}}},
+			"<b><i>a</i></b>",
+		},
+		{
+			"list",
+			&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}},
+			"<ul>\n<li><p>a</p>\n</li>\n<li><ol>\n<li><p>b</p>\n</li>\n</ol>\n</li>\n</ul>",
+		},
+		{
+			"table",
+			&ast.Table{Rows: []ast.TableRow{{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("a")}}, IsHeader: true}}}}},
+			"<table>\n<tr>\n<th><p>a</p>\n</th>\n</tr>\n</table>",
+		},
+		{"remote image", &ast.Image{Source: "https://example.com/a.png", Alt: "a"}, `src="https://example.com/a.png"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, files, err := testExport(t, EPUBSettings{}, &ast.Document{Content: []ast.Block{test.block}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if chapter := files["OEBPS/chapter-1.xhtml"]; !strings.Contains(chapter, test.want) {
+				t.Errorf("got:\n%s\nwant it to contain:\n%s", chapter, test.want)
+			}
+		})
+	}
+}
+
+func TestExportMissingImage(t *testing.T) {
+	d := &ast.Document{Content: []ast.Block{&ast.Image{Source: "missing.png"}}}
+	if _, _, err := testExport(t, EPUBSettings{ImageDirectory: t.TempDir()}, d); err == nil || !strings.Contains(err.Error(), "image 'missing.png'") {
+		t.Errorf("got error %v, want a missing image error", err)
+	}
+}
+
+func TestExportModified(t *testing.T) {
+	tests := []struct {
+		name     string
+		date     string
+		modified time.Time
+		want     time.Time
+	}{
+		{"no date", "", time.Time{}, time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"unreadable date", "someday", time.Time{}, time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"early date", "1970", time.Time{}, time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"document date", "March 5, 2024", time.Time{}, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"setting", "2024", time.Date(2025, 6, 7, 8, 9, 10, 11, time.UTC), time.Date(2025, 6, 7, 8, 9, 10, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			z, files, err := testExport(t, EPUBSettings{Modified: test.modified}, &ast.Document{Date: test.date, Content: []ast.Block{}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			meta := `<meta property="dcterms:modified">` + test.want.Format("2006-01-02T15:04:05Z") + "</meta>"
+			if !strings.Contains(files["OEBPS/content.opf"], meta) {
+				t.Errorf("got:\n%s\nwant it to contain:\n%s", files["OEBPS/content.opf"], meta)
+			}
+			for _, f := range z.File {
+				if !f.Modified.Equal(test.want) {
+					t.Errorf("%s: got modification time %v, want %v", f.Name, f.Modified, test.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+// export/epub/package.go
+// EPUB container, package document and navigation document.
+
+package epub
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`
+
+// Date layouts that can be converted to W3C dates for the package metadata.
+var dateLayouts = []struct {
+	layout, format string
+}{
+	{"2006-01-02", "2006-01-02"},
+	{"2006-01", "2006-01"},
+	{"2006", "2006"},
+	{"January 2, 2006", "2006-01-02"},
+	{"Jan 2, 2006", "2006-01-02"},
+	{"2 January 2006", "2006-01-02"},
+	{"January 2006", "2006-01"},
+}
+
+// An entry of the navigation document.
+type navEntry struct {
+	level      int
+	text, href string
+	children   []*navEntry
+}
+
+// Write the EPUB container.
+func (e *EPUBExporter) write(docs []*ast.Document) error {
+	z := zip.NewWriter(e.stream)
+	modified := e.modified(docs)
+
+	// The mimetype must come first and be stored uncompressed.
+	w, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: modified})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("application/epub+zip")); err != nil {
+		return err
+	}
+
+	type file struct {
+		name string
+		data []byte
+	}
+	files := []file{
+		{"META-INF/container.xml", []byte(containerXML)},
+		{"OEBPS/content.opf", []byte(e.packageDocument(docs))},
+		{"OEBPS/nav.xhtml", []byte(e.navDocument(docs))},
+		{"OEBPS/style.css", []byte(e.stylesheet())},
+	}
+	for _, c := range e.chapters {
+		files = append(files, file{"OEBPS/" + c.file, []byte(xhtmlDocument(c.title, e.settings.Language, string(c.body)))})
+	}
+	for _, r := range e.resources {
+		if !r.remote {
+			files = append(files, file{"OEBPS/" + r.file, r.data})
+		}
+	}
+
+	for _, f := range files {
+		w, err := z.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+// Wrap a body in an XHTML content document.
+func xhtmlDocument(title, language, body string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="` + html.EscapeString(language) + `" xml:lang="` + html.EscapeString(language) + `">
+<head>
+<meta charset="UTF-8" />
+<title>` + html.EscapeString(title) + `</title>
+<link rel="stylesheet" type="text/css" href="style.css" />
+</head>
+<body>
+` + body + `</body>
+</html>
+`
+}
+
+// Get the book's title.
+func (e *EPUBExporter) title(docs []*ast.Document) string {
+	if e.settings.Title != "" {
+		return e.settings.Title
+	}
+	for i := range docs {
+		if docs[i].Title != "" {
+			return docs[i].Title
+		}
+	}
+	return "Untitled"
+}
+
+// Get the book's authors, without duplicates.
+func (e *EPUBExporter) authors(docs []*ast.Document) []string {
+	if e.settings.Author != "" {
+		return []string{e.settings.Author}
+	}
+	authors := []string{}
+	seen := map[string]bool{}
+	for i := range docs {
+		if docs[i].Author != "" && !seen[docs[i].Author] {
+			seen[docs[i].Author] = true
+			authors = append(authors, docs[i].Author)
+		}
+	}
+	return authors
+}
+
+// Get the book's identifier. The default is a name-based UUID, so the same
+// book keeps its identifier.
+func (e *EPUBExporter) identifier(docs []*ast.Document) string {
+	if e.settings.Identifier != "" {
+		return e.settings.Identifier
+	}
+	h := sha1.New()
+	for i := range docs {
+		fmt.Fprintf(h, "%q %q %q %q\n", docs[i].Title, docs[i].Subtitle, docs[i].Author, docs[i].Date)
+	}
+	sum := h.Sum(nil)
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// Convert a document date to a W3C date. Returns an empty string if the
+// date can't be read.
+func w3cDate(date string) string {
+	date = strings.TrimSpace(date)
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l.layout, date); err == nil {
+			return t.Format(l.format)
+		}
+	}
+	return ""
+}
+
+// Get the book's modification time. Zip files can't hold times before 1980.
+func (e *EPUBExporter) modified(docs []*ast.Document) time.Time {
+	if !e.settings.Modified.IsZero() {
+		return e.settings.Modified
+	}
+	earliest := time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	date := strings.TrimSpace(docs[0].Date)
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l.layout, date); err == nil && t.After(earliest) {
+			return t
+		}
+	}
+	return earliest
+}
+
+// Generate the package document.
+func (e *EPUBExporter) packageDocument(docs []*ast.Document) string {
+	out := strings.Builder{}
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="` + html.EscapeString(e.settings.Language) + `">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	out.WriteString("<dc:identifier id=\"book-id\">" + html.EscapeString(e.identifier(docs)) + "</dc:identifier>\n")
+	out.WriteString("<dc:title>" + html.EscapeString(e.title(docs)) + "</dc:title>\n")
+	for _, author := range e.authors(docs) {
+		out.WriteString("<dc:creator>" + html.EscapeString(author) + "</dc:creator>\n")
+	}
+	if date := w3cDate(docs[0].Date); date != "" {
+		out.WriteString("<dc:date>" + date + "</dc:date>\n")
+	}
+	out.WriteString("<dc:language>" + html.EscapeString(e.settings.Language) + "</dc:language>\n")
+	out.WriteString("<meta property=\"dcterms:modified\">" + e.modified(docs).Format("2006-01-02T15:04:05Z") + "</meta>\n")
+	out.WriteString("</metadata>\n<manifest>\n")
+	out.WriteString("<item id=\"nav\" href=\"nav.xhtml\" media-type=\"application/xhtml+xml\" properties=\"nav\"/>\n")
+	out.WriteString("<item id=\"style\" href=\"style.css\" media-type=\"text/css\"/>\n")
+	for _, c := range e.chapters {
+		properties := ""
+		if c.remote {
+			properties = " properties=\"remote-resources\""
+		}
+		out.WriteString("<item id=\"" + strings.TrimSuffix(c.file, ".xhtml") + "\" href=\"" + c.file + "\" media-type=\"application/xhtml+xml\"" + properties + "/>\n")
+	}
+	for _, r := range e.resources {
+		out.WriteString("<item id=\"" + r.id + "\" href=\"" + html.EscapeString(r.file) + "\" media-type=\"" + r.mediaType + "\"/>\n")
+	}
+	out.WriteString("</manifest>\n<spine>\n")
+	for _, c := range e.chapters {
+		out.WriteString("<itemref idref=\"" + strings.TrimSuffix(c.file, ".xhtml") + "\"/>\n")
+	}
+	out.WriteString("</spine>\n</package>\n")
+	return out.String()
+}
+
+// Generate the navigation document from the chapters' headings. With
+// several documents, each titled document is an entry containing its
+// headings.
+func (e *EPUBExporter) navDocument(docs []*ast.Document) string {
+	entries := []*navEntry{}
+	for _, c := range e.chapters {
+		offset := 0
+		if len(docs) > 1 && docs[c.document].Title != "" {
+			offset = 1
+			if c.first {
+				entries = append(entries, &navEntry{level: 0, text: docs[c.document].Title, href: c.file})
+			}
+		}
+		for i, h := range c.headings {
+			entries = append(entries, &navEntry{
+				level: int(h.Class-ast.Heading1Type) + 1 + offset,
+				text:  ast.PlainText(h.Content),
+				href:  c.file + "#heading-" + strconv.Itoa(i+1),
+			})
+		}
+		if len(c.headings) == 0 && offset == 0 {
+			entries = append(entries, &navEntry{level: 1, text: c.title, href: c.file})
+		}
+	}
+
+	// Nest entries under the closest preceding entry of a lower level.
+	root := &navEntry{level: -1}
+	stack := []*navEntry{root}
+	for _, entry := range entries {
+		for stack[len(stack)-1].level >= entry.level {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, entry)
+		stack = append(stack, entry)
+	}
+
+	title := e.title(docs)
+	return xhtmlDocument(title, e.settings.Language, "<nav epub:type=\"toc\" id=\"toc\">\n<h1>"+html.EscapeString(title)+"</h1>\n"+navList(root.children)+"</nav>\n")
+}
+
+// Write a navigation list.
+func navList(entries []*navEntry) string {
+	out := strings.Builder{}
+	out.WriteString("<ol>\n")
+	for _, entry := range entries {
+		text := entry.text
+		if strings.TrimSpace(text) == "" {
+			text = "Untitled"
+		}
+		out.WriteString("<li><a href=\"" + html.EscapeString(entry.href) + "\">" + html.EscapeString(text) + "</a>")
+		if len(entry.children) > 0 {
+			out.WriteString("\n" + navList(entry.children))
+		}
+		out.WriteString("</li>\n")
+	}
+	out.WriteString("</ol>\n")
+	return out.String()
+}
+
+// Generate the stylesheet, with the embedded fonts.
+func (e *EPUBExporter) stylesheet() string {
+	h := e.settings.HTML
+	quote, image, caption := h.QuoteBlockClass, h.ImageBlockClass, h.ImageCaptionClass
+
+	out := strings.Builder{}
+	for _, r := range e.resources {
+		if r.font == nil {
+			continue
+		}
+		weight, style := "normal", "normal"
+		if r.font.Bold {
+			weight = "bold"
+		}
+		if r.font.Italic {
+			style = "italic"
+		}
+		out.WriteString("@font-face {\n\tfont-family: \"" + strings.ReplaceAll(r.font.Family, "\"", "") + "\";\n\tfont-weight: " + weight + ";\n\tfont-style: " + style + ";\n\tsrc: url(\"" + r.file + "\");\n}\n\n")
+	}
+	out.WriteString(`body {
+	line-height: 1.4;
+}
+
+.` + quote + ` {
+	margin: 1em 0;
+	padding-left: 1em;
+	border-left: 3px solid #ccc;
+}
+
+.` + image + ` {
+	margin: 1em 0;
+}
+
+.` + image + ` img {
+	max-width: 100%;
+}
+
+.` + caption + ` {
+	font-size: 0.9em;
+	font-style: italic;
+}
+
+table {
+	border-collapse: collapse;
+}
+
+th, td {
+	border: 1px solid #999;
+	padding: 0.25em 0.5em;
+}
+`)
+	if e.settings.Stylesheet != "" {
+		out.WriteString("\n" + strings.TrimRight(e.settings.Stylesheet, "\n") + "\n")
+	}
+	return out.String()
+}
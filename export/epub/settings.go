@@ -0,0 +1,58 @@
+// export/epub/settings.go
+// EPUB export settings.
+
+package epub
+
+import (
+	"time"
+
+	"github.com/cubeflix/cdf/export"
+	"github.com/cubeflix/cdf/export/html"
+)
+
+const DefaultLanguage = "en"
+
+// A font file to embed, and the family and style it provides.
+type Font struct {
+	Family string
+	Path   string
+	Bold   bool
+	Italic bool
+}
+
+// EPUB export settings.
+type EPUBSettings struct {
+	export.Settings
+
+	// The book's title and author. They default to the first document's
+	// title and the documents' authors.
+	Title  string
+	Author string
+
+	// The book's language, as a BCP 47 tag. Defaults to "en".
+	Language string
+
+	// The book's unique identifier. Defaults to a UUID derived from the
+	// documents' metadata.
+	Identifier string
+
+	// The last modification time. Defaults to the first document's date, or
+	// to the start of 1980 if it has none, so that exports are reproducible.
+	Modified time.Time
+
+	// Start a new chapter at each top-level page break.
+	SplitAtPageBreaks bool
+
+	// The directory relative image sources are read from.
+	ImageDirectory string
+
+	// Font files to embed. Font blocks naming a family use it.
+	Fonts []Font
+
+	// CSS appended to the book's stylesheet.
+	Stylesheet string
+
+	// Settings for the chapters' HTML. XHTML and heading ids are always
+	// used, and the book writes the chapters' headers and footers.
+	HTML html.HTMLSettings
+}
@@ -0,0 +1,338 @@
+// export/gemtext/gemtext.go
+// Package gemtext provides functionality for exporting into Gemini's gemtext
+// format.
+
+package gemtext
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	"github.com/cubeflix/cdf/export/text"
+)
+
+// A link pulled out of text.
+type link struct {
+	dest, label string
+}
+
+// Gemtext exporter.
+type GemtextExporter struct {
+	stream   io.Writer
+	settings GemtextSettings
+}
+
+// Create a new gemtext exporter.
+func NewGemtextExporter(stream io.Writer, settings GemtextSettings) *GemtextExporter {
+	if settings.TableWidth <= 0 {
+		settings.TableWidth = DefaultTableWidth
+	}
+
+	return &GemtextExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to gemtext.
+func (g *GemtextExporter) Export(d *ast.Document) error {
+	groups := [][]string{}
+
+	// Write the title block.
+	title := []string{}
+	if !g.settings.OmitTitle && d.Title != "" {
+		title = append(title, "# "+oneLine(d.Title))
+	}
+	for _, field := range []struct {
+		omit  bool
+		value string
+	}{
+		{g.settings.OmitSubtitle, d.Subtitle},
+		{g.settings.OmitDate, d.Date},
+		{g.settings.OmitAuthor, d.Author},
+	} {
+		if !field.omit && field.value != "" {
+			title = append(title, textLines(field.value)...)
+		}
+	}
+	if len(title) > 0 {
+		groups = append(groups, title)
+	}
+
+	content, err := g.exportBlocks(d.Content)
+	if err != nil {
+		return err
+	}
+	if len(content) > 0 {
+		groups = append(groups, content)
+	}
+
+	out := strings.Builder{}
+	for i, lines := range groups {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		for _, l := range lines {
+			out.WriteString(l + "\n")
+		}
+	}
+	_, err = io.WriteString(g.stream, out.String())
+	return err
+}
+
+// Export a slice of blocks, separated by blank lines.
+func (g *GemtextExporter) exportBlocks(blocks []ast.Block) ([]string, error) {
+	lines := []string{}
+	for i := range blocks {
+		block, err := g.exportBlock(blocks[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(block) == 0 {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, block...)
+	}
+	return lines, nil
+}
+
+// Export a block. Links are pulled out of text onto link lines following
+// it.
+func (g *GemtextExporter) exportBlock(b ast.Block) ([]string, error) {
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		content, links, err := inline(block.Content)
+		if err != nil {
+			return nil, err
+		}
+		return append(textLines(content), linkLines(links)...), nil
+	case *ast.BasicBlock:
+		return g.exportBlocks(block.Content)
+	case *ast.Quote:
+		lines, err := g.exportBlocks(block.Content)
+		if err != nil {
+			return nil, err
+		}
+		return quote(lines), nil
+	case *ast.Image:
+		// Images are links to the image.
+		label := block.Alt
+		if block.HasCaption {
+			caption, links, err := inline(block.Caption)
+			if err != nil {
+				return nil, err
+			}
+			label = caption
+			return linkLines(append([]link{{block.Source, label}}, links...)), nil
+		}
+		return linkLines([]link{{block.Source, label}}), nil
+	case *ast.Heading:
+		if block.Class < ast.Heading1Type || block.Class > ast.Heading5Type {
+			return nil, errors.New("invalid ast")
+		}
+		content, links, err := inline(block.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		// Gemtext has three heading levels.
+		level := int(block.Class-ast.Heading1Type) + 1
+		if level > 3 {
+			level = 3
+		}
+		return append([]string{strings.Repeat("#", level) + " " + oneLine(content)}, linkLines(links)...), nil
+	case *ast.HorizontalRule:
+		return []string{"---"}, nil
+	case *ast.List:
+		return g.exportList(block)
+	case *ast.Table:
+		return g.exportTable(block)
+	case *ast.Collapse:
+		// Collapse blocks are expanded.
+		summary, links, err := inline(block.Summary)
+		if err != nil {
+			return nil, err
+		}
+		content, err := g.exportBlocks(block.Content)
+		if err != nil {
+			return nil, err
+		}
+		lines := append(textLines(summary), linkLines(links)...)
+		if len(content) > 0 {
+			lines = append(append(lines, ""), content...)
+		}
+		return lines, nil
+	case *ast.PageBreak, *ast.Notes:
+		// Speaker notes are left out.
+		return nil, nil
+	}
+	return nil, errors.New("invalid ast")
+}
+
+// Export a list. Gemtext has one level of unnumbered list items, so nested
+// lists are flattened and numbered items start with their number.
+func (g *GemtextExporter) exportList(block *ast.List) ([]string, error) {
+	lines := []string{}
+	for i := range block.Items {
+		item, err := g.exportBlock(block.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		if _, nested := block.Items[i].(*ast.List); !nested && len(item) > 0 && isText(item[0]) {
+			marker := "* "
+			if block.Ordered {
+				marker += strconv.Itoa(i+1) + ". "
+			}
+			item[0] = marker + strings.TrimPrefix(item[0], " ")
+
+			// List items are single lines, so line breaks become spaces.
+			for len(item) > 1 && item[1] != "" && isText(item[1]) {
+				item[0] += " " + strings.TrimPrefix(item[1], " ")
+				item = append(item[:1], item[2:]...)
+			}
+		}
+		for _, l := range item {
+			if l != "" {
+				lines = append(lines, l)
+			}
+		}
+	}
+	return lines, nil
+}
+
+// Export a table as a preformatted block drawn by the plain text exporter.
+// The table's links are numbered and listed after it.
+func (g *GemtextExporter) exportTable(block *ast.Table) ([]string, error) {
+	out := bytes.Buffer{}
+	exporter := text.NewTextExporter(&out, text.TextSettings{Width: g.settings.TableWidth, OmitLinkReferences: true})
+	if err := exporter.Export(&ast.Document{Content: []ast.Block{block}}); err != nil {
+		return nil, err
+	}
+	if out.Len() == 0 {
+		return nil, nil
+	}
+
+	lines := []string{"```table"}
+	for _, l := range strings.Split(strings.TrimRight(out.String(), "\n"), "\n") {
+		// A line starting with the fence would end the block early.
+		if strings.HasPrefix(l, "```") {
+			l = " " + l
+		}
+		lines = append(lines, l)
+	}
+	lines = append(lines, "```")
+	for i, dest := range exporter.Links() {
+		lines = append(lines, "=> "+oneLine(dest)+" ["+strconv.Itoa(i+1)+"]")
+	}
+	return lines, nil
+}
+
+// Get the text of inline blocks and the links in it. Inline images become
+// their alternative text and a link.
+func inline(blocks []ast.InlineBlock) (string, []link, error) {
+	out := strings.Builder{}
+	links := []link{}
+	for i := range blocks {
+		switch block := blocks[i].(type) {
+		case *ast.Text:
+			out.WriteString(block.Value)
+		case *ast.HyperlinkBlock:
+			content, inner, err := inline(block.Content)
+			if err != nil {
+				return "", nil, err
+			}
+			out.WriteString(content)
+			links = append(append(links, link{block.Destination, content}), inner...)
+		case *ast.FormattingBlock, *ast.ColorBlock, *ast.SizeBlock, *ast.FontBlock:
+			content, inner, err := inline(block.Children())
+			if err != nil {
+				return "", nil, err
+			}
+			out.WriteString(content)
+			links = append(links, inner...)
+		case *ast.InlineImageBlock:
+			if block.Alt != "" {
+				out.WriteString("[" + block.Alt + "]")
+			}
+			links = append(links, link{block.Source, block.Alt})
+		default:
+			return "", nil, errors.New("invalid ast")
+		}
+	}
+	return out.String(), links, nil
+}
+
+// Get link lines. Links without a destination are dropped.
+func linkLines(links []link) []string {
+	lines := []string{}
+	for _, l := range links {
+		dest := strings.Join(strings.Fields(l.dest), "%20")
+		if dest == "" {
+			continue
+		}
+		line := "=> " + dest
+		if label := oneLine(l.label); label != "" && label != dest {
+			line += " " + label
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Split text into lines, collapsing white space. Lines that would be read as
+// another line type start with a space.
+func textLines(s string) []string {
+	lines := []string{}
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.Join(strings.Fields(l), " ")
+		if l == "" {
+			continue
+		}
+		if !isText(l) {
+			l = " " + l
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// Collapse text onto one line.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Get whether a line is a plain text line.
+func isText(l string) bool {
+	for _, prefix := range []string{"=>", "```", "#", ">", "* "} {
+		if strings.HasPrefix(l, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// Quote lines. Link lines and preformatted blocks can't be quoted and are
+// left as they are.
+func quote(lines []string) []string {
+	quoted := make([]string, len(lines))
+	preformatted := false
+	for i, l := range lines {
+		quoted[i] = l
+		if strings.HasPrefix(l, "```") {
+			preformatted = !preformatted
+			continue
+		}
+		if preformatted || l == "" || strings.HasPrefix(l, "=>") {
+			continue
+		}
+		quoted[i] = "> " + strings.TrimPrefix(strings.TrimPrefix(l, "> "), " ")
+	}
+	return quoted
+}
@@ -0,0 +1,57 @@
+// export/gemtext/gemtext_test.go
+// Gemtext export tests.
+
+package gemtext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.Block
+		want  string
+	}{
+		{"line types", &ast.Paragraph{Content: testText("=> a\n# b\n```\n* c\n> d")}, " => a\n # b\n ```\n * c\n > d\n"},
+		{
+			"nested markup",
+			&ast.Paragraph{Content: []ast.InlineBlock{&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{
+				&ast.Text{Value: "a "},
+				&ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{
+					&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("b")}, Attribute: ast.ItalicFormatting},
+				}}, Destination: "gemini://example.com/ x"},
+			}}, Attribute: ast.BoldFormatting}}},
+			"a b\n=> gemini://example.com/%20x b\n",
+		},
+		{
+			"list",
+			&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a\nb")}, &ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("c")}}}}},
+			"* a b\n* 1. c\n",
+		},
+		{
+			"table",
+			&ast.Table{Rows: []ast.TableRow{{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("```")}}, IsHeader: true}, {}}}}},
+			"```table\n+-----+---+\n| ``` |   |\n+-----+---+\n```\n",
+		},
+		{"missing image", &ast.Image{Source: "missing.png", Alt: "a"}, "=> missing.png a\n"},
+		{"inline image", &ast.Paragraph{Content: []ast.InlineBlock{&ast.Text{Value: "a "}, &ast.InlineImageBlock{Source: "b.png"}}}, "a\n=> b.png\n"},
+	}
+	for _, test := range tests {
+		out := strings.Builder{}
+		if err := NewGemtextExporter(&out, GemtextSettings{}).Export(&ast.Document{Content: []ast.Block{test.block}}); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+// export/gemtext/settings.go
+// Gemtext export settings.
+
+package gemtext
+
+import "github.com/cubeflix/cdf/export"
+
+// The default width of tables.
+const DefaultTableWidth = 72
+
+// Gemtext export settings.
+type GemtextSettings struct {
+	export.Settings
+
+	// The width tables are drawn at in preformatted blocks. Defaults to 72
+	// columns.
+	TableWidth int
+}
@@ -0,0 +1,116 @@
+// export/gopher/gopher.go
+// Package gopher provides functionality for exporting into gophermaps.
+
+package gopher
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	"github.com/cubeflix/cdf/export/text"
+)
+
+// Gophermap exporter. The document is drawn as plain text on info lines,
+// followed by a menu item for each link.
+type GopherExporter struct {
+	stream   io.Writer
+	settings GopherSettings
+}
+
+// Create a new gophermap exporter.
+func NewGopherExporter(stream io.Writer, settings GopherSettings) *GopherExporter {
+	if settings.Width <= 0 {
+		settings.Width = DefaultWidth
+	}
+	if settings.Host == "" {
+		settings.Host = DefaultHost
+	}
+	if settings.Port <= 0 {
+		settings.Port = DefaultPort
+	}
+
+	return &GopherExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to a gophermap.
+func (g *GopherExporter) Export(d *ast.Document) error {
+	out := bytes.Buffer{}
+	exporter := text.NewTextExporter(&out, text.TextSettings{
+		Settings:           g.settings.Settings,
+		Width:              g.settings.Width,
+		OmitLinkReferences: true,
+	})
+	if err := exporter.Export(d); err != nil {
+		return err
+	}
+
+	menu := strings.Builder{}
+	if out.Len() > 0 {
+		for _, l := range strings.Split(strings.TrimRight(out.String(), "\n"), "\n") {
+			menu.WriteString(g.info(l))
+		}
+	}
+
+	// List the links, labelled with their reference numbers.
+	links := exporter.Links()
+	if len(links) > 0 && out.Len() > 0 {
+		menu.WriteString(g.info(""))
+	}
+	for i, dest := range links {
+		menu.WriteString(g.item(dest, "["+strconv.Itoa(i+1)+"] "+dest))
+	}
+	menu.WriteString(".\r\n")
+
+	_, err := io.WriteString(g.stream, menu.String())
+	return err
+}
+
+// Get an info line.
+func (g *GopherExporter) info(s string) string {
+	return g.line("i", s, "", g.settings.Host, strconv.Itoa(g.settings.Port))
+}
+
+// Get a menu item for a link. Gopher links keep their item type and
+// selector, relative links are menus on this server and other links use the
+// URL: selector convention.
+func (g *GopherExporter) item(dest, label string) string {
+	u, err := url.Parse(dest)
+	if err != nil || (u.Scheme == "" && u.Host == "") {
+		selector := dest
+		if !strings.HasPrefix(selector, "/") {
+			selector = "/" + selector
+		}
+		return g.line("1", label, selector, g.settings.Host, strconv.Itoa(g.settings.Port))
+	}
+	if u.Scheme == "gopher" {
+		itemType, selector := "1", u.Path
+		if len(selector) > 1 {
+			// The path starts with the item type.
+			itemType, selector = selector[1:2], selector[2:]
+		}
+		if i := strings.Index(selector, "\t"); i >= 0 {
+			// Drop the search string.
+			selector = selector[:i]
+		}
+		port := u.Port()
+		if port == "" {
+			port = "70"
+		}
+		return g.line(itemType, label, selector, u.Hostname(), port)
+	}
+	return g.line("h", label, "URL:"+dest, g.settings.Host, strconv.Itoa(g.settings.Port))
+}
+
+// Get a menu line. Tabs and line breaks can't appear in fields.
+func (g *GopherExporter) line(itemType, display, selector, host, port string) string {
+	clean := strings.NewReplacer("\t", "    ", "\r", "", "\n", " ")
+	fields := []string{itemType + clean.Replace(display), clean.Replace(selector), clean.Replace(host), port}
+	return strings.Join(fields, "\t") + "\r\n"
+}
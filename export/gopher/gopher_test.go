@@ -0,0 +1,57 @@
+// export/gopher/gopher_test.go
+// Gophermap export tests.
+
+package gopher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.Block
+		want  string
+	}{
+		{"line breaks", &ast.Paragraph{Content: testText("a\tb\r\n.")}, "ia b\t\tlocalhost\t70\r\ni.\t\tlocalhost\t70\r\n.\r\n"},
+		{
+			"nested markup",
+			&ast.Paragraph{Content: []ast.InlineBlock{&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{
+				&ast.Text{Value: "a "},
+				&ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{
+					&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("b")}, Attribute: ast.ItalicFormatting},
+				}}, Destination: "gopher://example.com:7070/0/a%09b"},
+			}}, Attribute: ast.BoldFormatting}}},
+			"ia b [1]\t\tlocalhost\t70\r\ni\t\tlocalhost\t70\r\n0[1] gopher://example.com:7070/0/a%09b\t/a\texample.com\t7070\r\n.\r\n",
+		},
+		{
+			"list",
+			&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}},
+			"i* a\t\tlocalhost\t70\r\ni  1. b\t\tlocalhost\t70\r\n.\r\n",
+		},
+		{
+			"table",
+			&ast.Table{Rows: []ast.TableRow{{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("a")}}, IsHeader: true}, {}}}}},
+			"i+---+---+\t\tlocalhost\t70\r\ni| a |   |\t\tlocalhost\t70\r\ni+---+---+\t\tlocalhost\t70\r\n.\r\n",
+		},
+		{"missing image", &ast.Image{Source: "missing.png", Alt: "a"}, "i[a]\t\tlocalhost\t70\r\n.\r\n"},
+		{"web link", &ast.Paragraph{Content: []ast.InlineBlock{&ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("a")}, Destination: "https://example.com/"}}}, "ia [1]\t\tlocalhost\t70\r\ni\t\tlocalhost\t70\r\nh[1] https://example.com/\tURL:https://example.com/\tlocalhost\t70\r\n.\r\n"},
+	}
+	for _, test := range tests {
+		out := strings.Builder{}
+		if err := NewGopherExporter(&out, GopherSettings{}).Export(&ast.Document{Content: []ast.Block{test.block}}); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// export/gopher/settings.go
+// Gophermap export settings.
+
+package gopher
+
+import "github.com/cubeflix/cdf/export"
+
+// Default settings.
+const (
+	DefaultWidth = 70
+	DefaultHost  = "localhost"
+	DefaultPort  = 70
+)
+
+// Gophermap export settings.
+type GopherSettings struct {
+	export.Settings
+
+	// The width text is wrapped at. Defaults to 70 columns.
+	Width int
+
+	// The server relative links point to. Defaults to localhost:70.
+	Host string
+	Port int
+}
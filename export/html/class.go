@@ -0,0 +1,146 @@
+// export/html/class.go
+// Block attributes and generated style classes.
+
+package html
+
+import (
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Short class names for style properties.
+var classProperties = map[string]string{
+	"text-align":       "align",
+	"font-size":        "size",
+	"font-family":      "font",
+	"background-color": "bg",
+}
+
+// Get a block's id, style and class attributes. The given classes come
+// before the block's own, and the block's id replaces the given one.
+func (h *HTMLExporter) blockAttributes(b ast.Block, id string, classes ...string) (string, error) {
+	style, err := getHTMLAlignmentStyleParameter(b)
+	if err != nil {
+		return "", err
+	}
+	if b.GetID() != "" {
+		id = b.GetID()
+	}
+	if h.settings.ClassStyles {
+		classes = append(h.styleClasses(style), classes...)
+		style = ""
+	}
+	return wrapHTMLIDParameter(id) + wrapHTMLStyleParameter(style) + wrapHTMLClassParameter(append(classes, b.GetClasses()...)), nil
+}
+
+// Get a block's id and class attributes, for blocks without a style.
+func (h *HTMLExporter) identityAttributes(b ast.Block, id string) string {
+	if b.GetID() != "" {
+		id = b.GetID()
+	}
+	return wrapHTMLIDParameter(id) + wrapHTMLClassParameter(b.GetClasses())
+}
+
+// Get the style attribute for the style, or its class attribute in class
+// mode.
+func (h *HTMLExporter) styleAttributes(style string) string {
+	if h.settings.ClassStyles {
+		return wrapHTMLClassParameter(h.styleClasses(style))
+	}
+	return wrapHTMLStyleParameter(style)
+}
+
+// Get the classes for each declaration in the style.
+func (h *HTMLExporter) styleClasses(style string) []string {
+	classes := []string{}
+	for _, declaration := range strings.Split(style, ";") {
+		i := strings.Index(declaration, ":")
+		if i == -1 {
+			continue
+		}
+		property := strings.ToLower(strings.TrimSpace(declaration[:i]))
+		value := strings.TrimSpace(declaration[i+1:])
+		if property == "" || value == "" {
+			continue
+		}
+		classes = append(classes, h.styleClass(property, value))
+	}
+	return classes
+}
+
+// Get the class for a declaration, adding it if it is new. Each class is
+// named after its property and value, like "cdf-size-12pt".
+func (h *HTMLExporter) styleClass(property, value string) string {
+	rule := property + ": " + value + ";"
+	if name, ok := h.classNames[rule]; ok {
+		return name
+	}
+
+	if short, ok := classProperties[property]; ok {
+		property = short
+	}
+	base := h.settings.ClassPrefix + property
+	if slug := classValue(value); slug != "" {
+		base += "-" + slug
+	}
+	name := base
+	for n := 2; h.classRules[name] != ""; n++ {
+		name = base + "-" + strconv.Itoa(n)
+	}
+
+	h.classes = append(h.classes, name)
+	h.classRules[name] = rule
+	h.classNames[rule] = name
+	return name
+}
+
+// Get a class name's form of a value. Numbers are shortened, "%" is written
+// as "pct" and other characters become hyphens.
+func classValue(value string) string {
+	words := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '%')
+	})
+	for i, word := range words {
+		// Shorten a leading number, like "12.000000pt".
+		n := 0
+		for n < len(word) && (word[n] >= '0' && word[n] <= '9' || word[n] == '.') {
+			n++
+		}
+		if f, err := strconv.ParseFloat(word[:n], 64); err == nil {
+			word = strconv.FormatFloat(f, 'f', -1, 64) + word[n:]
+		}
+		words[i] = strings.NewReplacer(".", "_", "%", "pct").Replace(word)
+	}
+	return strings.Join(words, "-")
+}
+
+// Get the rules of the classes generated by the last export, or an empty
+// string if none were used.
+func (h *HTMLExporter) ClassStylesheet() string {
+	var b strings.Builder
+	for _, name := range h.classes {
+		b.WriteString("." + name + " { " + h.classRules[name] + " }\n")
+	}
+	return b.String()
+}
+
+// Wrap the identifier in " id=\"\"". Returns an empty string if no
+// identifier is provided.
+func wrapHTMLIDParameter(id string) string {
+	if id == "" {
+		return ""
+	}
+	return " id=\"" + html.EscapeString(id) + "\""
+}
+
+// Wrap the classes in " class=\"\"". Returns an empty string if no classes
+// are provided.
+func wrapHTMLClassParameter(classes []string) string {
+	if len(classes) == 0 {
+		return ""
+	}
+	return " class=\"" + html.EscapeString(strings.Join(classes, " ")) + "\""
+}
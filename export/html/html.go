@@ -4,10 +4,12 @@
 package html
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"html"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/cubeflix/cdf/ast"
@@ -17,9 +19,22 @@ import (
 type HTMLExporter struct {
 	stream   io.Writer
 	settings HTMLSettings
+
+	// The output of the current export.
+	out *errWriter
+
+	// The number of headings written.
+	headings int
+
+	// The classes generated in class mode, in order, with each class's rule
+	// and each rule's class.
+	classes    []string
+	classRules map[string]string
+	classNames map[string]string
 }
 
-// Create a new HTML exporter.
+// Create a new HTML exporter. Output is buffered, except for a
+// *bytes.Buffer or *strings.Builder, which are written to directly.
 func NewHTMLExporter(stream io.Writer, settings HTMLSettings) *HTMLExporter {
 	if !settings.UseCustomQuoteBlockClass {
 		settings.QuoteBlockClass = DefaultQuoteBlockClass
@@ -30,6 +45,21 @@ func NewHTMLExporter(stream io.Writer, settings HTMLSettings) *HTMLExporter {
 	if !settings.UseCustomImageCaptionClass {
 		settings.ImageCaptionClass = DefaultImageCaptionClass
 	}
+	if !settings.UseCustomNotesClass {
+		settings.NotesClass = DefaultNotesClass
+	}
+	if !settings.UseCustomURLSchemes {
+		settings.URLSchemes = DefaultURLSchemes
+	}
+	// Copy the schemes, so changes to the given slice don't affect the
+	// exporter.
+	settings.URLSchemes = append([]string{}, settings.URLSchemes...)
+	if !settings.UseCustomClassPrefix {
+		settings.ClassPrefix = DefaultClassPrefix
+	}
+	if settings.Language == "" {
+		settings.Language = DefaultLanguage
+	}
 
 	return &HTMLExporter{
 		stream:   stream,
@@ -37,70 +67,181 @@ func NewHTMLExporter(stream io.Writer, settings HTMLSettings) *HTMLExporter {
 	}
 }
 
-// Export the document to HTML.
+// Export the document to HTML. Stops at the first error, which is a
+// *NodeError with the path to the node being written.
 func (h *HTMLExporter) Export(d *ast.Document) error {
 	var hasTitle bool
+	h.headings = 0
+	h.classes, h.classRules, h.classNames = nil, map[string]string{}, map[string]string{}
+	out := newErrWriter(h.stream)
+
+	// In class mode, the content is written first, so the header can hold
+	// the classes it uses.
+	var content bytes.Buffer
+	if h.settings.ClassStyles {
+		h.out = newErrWriter(&content)
+		if err := h.exportContent(d.Content); err != nil {
+			return nodeError(d, err)
+		}
+	}
+	h.out = out
+
+	// Write the header.
+	if h.settings.IncludeHeader {
+		if err := h.exportHeader(d); err != nil {
+			return err
+		}
+	}
 
 	// Write the title.
 	if !h.settings.OmitTitle && d.Title != "" {
-		h.stream.Write([]byte("<h1>"))
-		h.stream.Write([]byte(html.EscapeString(d.Title)))
-		h.stream.Write([]byte("</h1>\n"))
+		h.write("<h1>")
+		h.write(html.EscapeString(d.Title))
+		h.write("</h1>\n")
 		hasTitle = true
 	}
 
 	// Write the subtitle.
 	if !h.settings.OmitSubtitle && d.Subtitle != "" {
-		h.stream.Write([]byte("<h2>"))
-		h.stream.Write([]byte(html.EscapeString(d.Subtitle)))
-		h.stream.Write([]byte("</h2>\n"))
+		h.write("<h2>")
+		h.write(html.EscapeString(d.Subtitle))
+		h.write("</h2>\n")
 		hasTitle = true
 	}
 
 	// Write the date.
 	if !h.settings.OmitDate && d.Date != "" {
-		h.stream.Write([]byte("<h3>"))
-		h.stream.Write([]byte(html.EscapeString(d.Date)))
-		h.stream.Write([]byte("</h3>\n"))
+		h.write("<h3>")
+		h.write(html.EscapeString(d.Date))
+		h.write("</h3>\n")
 		hasTitle = true
 	}
 
 	// Write the author.
 	if !h.settings.OmitAuthor && d.Author != "" {
-		h.stream.Write([]byte("<h3>"))
-		h.stream.Write([]byte(html.EscapeString(d.Author)))
-		h.stream.Write([]byte("</h3>\n"))
+		h.write("<h3>")
+		h.write(html.EscapeString(d.Author))
+		h.write("</h3>\n")
 		hasTitle = true
 	}
 
 	if hasTitle {
-		h.stream.Write([]byte("<hr>\n"))
+		h.write("<hr" + h.voidTagEnd() + "\n")
 	}
 
-	// Write the header.
-	if h.settings.IncludeHeader {
-		// TODO
+	// Write the content.
+	if h.settings.ClassStyles {
+		out.Write(content.Bytes())
+	} else if err := h.exportContent(d.Content); err != nil {
+		return nodeError(d, err)
 	}
 
-	// Write the content.
-	for i := range d.Content {
-		err := h.exportBlock(d.Content[i])
+	// Write the footer.
+	if h.settings.IncludeFooter {
+		if err := h.exportFooter(d); err != nil {
+			return err
+		}
+	}
+
+	return out.Flush()
+}
+
+// Export the document's content to HTML.
+func (h *HTMLExporter) exportContent(blocks []ast.Block) error {
+	for i := range blocks {
+		err := h.exportBlock(blocks[i])
 		if err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	// Write the footer.
-	if h.settings.IncludeFooter {
-		// TODO
+// Export the HTML inside a node's element: list items, table rows, a
+// collapse block's summary and content, or the node's children.
+func (h *HTMLExporter) exportChildren(n ast.Node) error {
+	switch n := n.(type) {
+	case *ast.List:
+		for i := range n.Items {
+			h.write("<li>")
+			if err := h.exportBlock(n.Items[i]); err != nil {
+				return err
+			}
+			h.write("</li>\n")
+		}
+		return nil
+	case *ast.Table:
+		for i := range n.Rows {
+			// Write the row.
+			h.write("<tr>\n")
+			for j := range n.Rows[i].Cells {
+				// Write the cell.
+				cell := n.Rows[i].Cells[j]
+				tag := "td"
+				if cell.IsHeader {
+					tag = "th"
+				}
+				h.write("<" + tag + ">")
+				if err := h.exportContent(cell.Content); err != nil {
+					return err
+				}
+				h.write("</" + tag + ">\n")
+			}
+			h.write("</tr>\n")
+		}
+		return nil
+	case *ast.Collapse:
+		// Write the summary.
+		h.write("<summary>")
+		for i := range n.Summary {
+			if err := h.exportInlineBlock(n.Summary[i]); err != nil {
+				return err
+			}
+		}
+		h.write("</summary>\n")
+		return h.exportContent(n.Content)
 	}
 
+	children := ast.Children(n)
+	for i := range children {
+		var err error
+		switch child := children[i].(type) {
+		case ast.Block:
+			err = h.exportBlock(child)
+		case ast.InlineBlock:
+			err = h.exportInlineBlock(child)
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Export a block to HTML.
+// Export a block to HTML, or with its render override. Errors, including
+// write errors, name the block.
 func (h *HTMLExporter) exportBlock(b ast.Block) error {
-	alignmentStyle, err := getHTMLAlignmentStyleParameter(b)
+	// Headings are counted before dispatching, so overrides keep the ids.
+	id := ""
+	if _, ok := b.(*ast.Heading); ok {
+		id = h.headingID()
+	}
+
+	var err error
+	if h.hasRenderer(b) {
+		err = h.render(b, id)
+	} else {
+		err = h.writeBlock(b, id)
+	}
+	if err != nil {
+		return nodeError(b, err)
+	}
+	return nodeError(b, h.out.err)
+}
+
+// Write a block's HTML. The id is the heading's automatic id.
+func (h *HTMLExporter) writeBlock(b ast.Block, id string) error {
+	attributes, err := h.blockAttributes(b, "")
 	if err != nil {
 		return err
 	}
@@ -109,57 +250,70 @@ func (h *HTMLExporter) exportBlock(b ast.Block) error {
 	case *ast.Paragraph:
 		// Write the inline block.
 		block := b.(*ast.Paragraph)
-		h.stream.Write([]byte("<p" + wrapHTMLStyleParameter(alignmentStyle) + ">"))
+		h.write("<p" + attributes + ">")
 		for i := range block.Content {
 			err := h.exportInlineBlock(block.Content[i])
 			if err != nil {
 				return err
 			}
 		}
-		h.stream.Write([]byte("</p>\n"))
+		h.write("</p>\n")
 		break
 	case *ast.BasicBlock:
 		// Write the basic block.
 		block := b.(*ast.BasicBlock)
-		h.stream.Write([]byte("<div" + wrapHTMLStyleParameter(alignmentStyle) + ">"))
+		h.write("<div" + attributes + ">")
 		for i := range block.Content {
 			err := h.exportBlock(block.Content[i])
 			if err != nil {
 				return err
 			}
 		}
-		h.stream.Write([]byte("</div>\n"))
+		h.write("</div>\n")
 		break
 	case *ast.Quote:
 		// Write the quote block.
 		block := b.(*ast.Quote)
-		h.stream.Write([]byte("<div" + wrapHTMLStyleParameter(alignmentStyle) + " class=\"" + h.settings.QuoteBlockClass + "\">"))
+		attributes, err := h.blockAttributes(b, "", h.settings.QuoteBlockClass)
+		if err != nil {
+			return err
+		}
+		h.write("<div" + attributes + ">")
 		for i := range block.Content {
 			err := h.exportBlock(block.Content[i])
 			if err != nil {
 				return err
 			}
 		}
-		h.stream.Write([]byte("</div>\n"))
+		h.write("</div>\n")
 		break
 	case *ast.Image:
 		// Write the image block.
 		block := b.(*ast.Image)
+		attributes, err := h.blockAttributes(b, "", h.settings.ImageBlockClass)
+		if err != nil {
+			return err
+		}
 		sizeStyle, err := getHTMLImageWidthHeightStyleParameter(block)
 		if err != nil {
 			return err
 		}
+		sizeAttributes := h.styleAttributes(sizeStyle)
+		src, err := h.urlParameter("src", block.Source, true)
+		if err != nil {
+			return err
+		}
 		if block.HasCaption {
-			h.stream.Write([]byte("<div" + wrapHTMLStyleParameter(alignmentStyle) + " class=\"" + h.settings.ImageBlockClass + "\"><img" + wrapHTMLStyleParameter(sizeStyle) + " src=\"" + block.Source + "\"><div class=\"" + h.settings.ImageCaptionClass + "\">"))
+			h.write("<div" + attributes + "><img" + sizeAttributes + src + wrapHTMLAltParameter(block.Alt) + h.voidTagEnd() + "<div class=\"" + h.settings.ImageCaptionClass + "\">")
 			for i := range block.Caption {
 				err := h.exportInlineBlock(block.Caption[i])
 				if err != nil {
 					return err
 				}
 			}
-			h.stream.Write([]byte("</div></div>\n"))
+			h.write("</div></div>\n")
 		} else {
-			h.stream.Write([]byte("<div" + wrapHTMLStyleParameter(alignmentStyle) + " class=\"" + h.settings.ImageBlockClass + "\"><img" + wrapHTMLStyleParameter(sizeStyle) + " src=\"" + block.Source + "\"></div>\n"))
+			h.write("<div" + attributes + "><img" + sizeAttributes + src + wrapHTMLAltParameter(block.Alt) + h.voidTagEnd() + "</div>\n")
 		}
 		break
 	case *ast.Heading:
@@ -169,104 +323,74 @@ func (h *HTMLExporter) exportBlock(b ast.Block) error {
 		if err != nil {
 			return err
 		}
-		h.stream.Write([]byte("<" + headingClass + wrapHTMLStyleParameter(alignmentStyle) + ">"))
+		attributes, err := h.blockAttributes(b, id)
+		if err != nil {
+			return err
+		}
+		h.write("<" + headingClass + attributes + ">")
 		for i := range block.Content {
 			err := h.exportInlineBlock(block.Content[i])
 			if err != nil {
 				return err
 			}
 		}
-		h.stream.Write([]byte("</" + headingClass + ">\n"))
+		h.write("</" + headingClass + ">\n")
 		break
 	case *ast.HorizontalRule:
 		// Write the horizontal rule.
-		h.stream.Write([]byte("<hr>\n"))
+		h.write("<hr" + h.identityAttributes(b, "") + h.voidTagEnd() + "\n")
 		break
 	case *ast.List:
 		// Write the list block.
 		block := b.(*ast.List)
+		tag := "ul"
 		if block.Ordered {
-			h.stream.Write([]byte("<ol" + wrapHTMLStyleParameter(alignmentStyle) + ">\n"))
-			for i := range block.Items {
-				h.stream.Write([]byte("<li>"))
-				err := h.exportBlock(block.Items[i])
-				if err != nil {
-					return err
-				}
-				h.stream.Write([]byte("</li>\n"))
-			}
-			h.stream.Write([]byte("</ol>\n"))
-		} else {
-			h.stream.Write([]byte("<ul" + wrapHTMLStyleParameter(alignmentStyle) + ">\n"))
-			for i := range block.Items {
-				h.stream.Write([]byte("<li>"))
-				err := h.exportBlock(block.Items[i])
-				if err != nil {
-					return err
-				}
-				h.stream.Write([]byte("</li>\n"))
-			}
-			h.stream.Write([]byte("</ul>\n"))
+			tag = "ol"
+		}
+		h.write("<" + tag + attributes + ">\n")
+		if err := h.exportChildren(block); err != nil {
+			return err
 		}
+		h.write("</" + tag + ">\n")
 		break
 	case *ast.Table:
 		// Write the table.
-		block := b.(*ast.Table)
-		h.stream.Write([]byte("<table" + wrapHTMLStyleParameter(alignmentStyle) + ">\n"))
-		for i := range block.Rows {
-			// Write the row.
-			h.stream.Write([]byte("<tr>\n"))
-			for j := range block.Rows[i].Cells {
-				// Write the cell.
-				cell := block.Rows[i].Cells[j]
-				if cell.IsHeader {
-					h.stream.Write([]byte("<th>"))
-					for cellBlock := range cell.Content {
-						err := h.exportBlock(cell.Content[cellBlock])
-						if err != nil {
-							return err
-						}
-					}
-					h.stream.Write([]byte("</th>\n"))
-				} else {
-					h.stream.Write([]byte("<td>"))
-					for cellBlock := range cell.Content {
-						err := h.exportBlock(cell.Content[cellBlock])
-						if err != nil {
-							return err
-						}
-					}
-					h.stream.Write([]byte("</td>\n"))
-				}
-			}
-			h.stream.Write([]byte("</tr>\n"))
+		h.write("<table" + attributes + ">\n")
+		if err := h.exportChildren(b); err != nil {
+			return err
 		}
-		h.stream.Write([]byte("</table>\n"))
+		h.write("</table>\n")
 		break
 	case *ast.Collapse:
 		// Write the collapseable block.
-		block := b.(*ast.Collapse)
-		h.stream.Write([]byte("<details" + wrapHTMLStyleParameter(alignmentStyle) + "><summary>"))
-
-		// Write the summary.
-		for i := range block.Summary {
-			err := h.exportInlineBlock(block.Summary[i])
-			if err != nil {
-				return err
-			}
+		h.write("<details" + attributes + ">")
+		if err := h.exportChildren(b); err != nil {
+			return err
+		}
+		h.write("</details>\n")
+		break
+	case *ast.PageBreak:
+		// Page break.
+		h.write("<br" + h.identityAttributes(b, "") + h.voidTagEnd())
+		break
+	case *ast.Notes:
+		// Write the speaker notes.
+		if !h.settings.IncludeNotes {
+			break
 		}
-		h.stream.Write([]byte("</summary>\n"))
+		block := b.(*ast.Notes)
+		attributes, err := h.blockAttributes(b, "", h.settings.NotesClass)
+		if err != nil {
+			return err
+		}
+		h.write("<aside" + attributes + ">")
 		for i := range block.Content {
 			err := h.exportBlock(block.Content[i])
 			if err != nil {
-				return nil
+				return err
 			}
 		}
-		h.stream.Write([]byte("</details>\n"))
-		break
-	case *ast.PageBreak:
-		// Page break.
-		h.stream.Write([]byte("<br>"))
+		h.write("</aside>\n")
 		break
 	default:
 		return errors.New("invalid ast")
@@ -388,92 +512,150 @@ func wrapHTMLStyleParameter(style string) string {
 	if len(strings.TrimSpace(style)) == 0 {
 		return ""
 	}
-	return " style=\"" + style + "\""
+	return " style=\"" + html.EscapeString(style) + "\""
+}
+
+// Wrap the alternate text in " alt=\"\"". Returns an empty string if no
+// alternate text is provided.
+func wrapHTMLAltParameter(alt string) string {
+	if alt == "" {
+		return ""
+	}
+	return " alt=\"" + html.EscapeString(alt) + "\""
+}
+
+// Get the next heading's id, or an empty string without heading ids.
+func (h *HTMLExporter) headingID() string {
+	if !h.settings.HeadingIDs {
+		return ""
+	}
+	h.headings++
+	return "heading-" + strconv.Itoa(h.headings)
+}
+
+// Write a string to the output. Errors are kept by the output.
+func (h *HTMLExporter) write(s string) {
+	h.out.WriteString(s)
+}
+
+// Get the end of a void element's start tag.
+func (h *HTMLExporter) voidTagEnd() string {
+	if h.settings.XHTML {
+		return " />"
+	}
+	return ">"
 }
 
-// Export an inline block to HTMl.
+// Export an inline block to HTML, or with its render override. Errors,
+// including write errors, name the inline block.
 func (h *HTMLExporter) exportInlineBlock(b ast.InlineBlock) error {
+	var err error
+	if h.hasRenderer(b) {
+		err = h.render(b, "")
+	} else {
+		err = h.writeInlineBlock(b)
+	}
+	if err != nil {
+		return nodeError(b, err)
+	}
+	return nodeError(b, h.out.err)
+}
+
+// Write an inline block's HTML.
+func (h *HTMLExporter) writeInlineBlock(b ast.InlineBlock) error {
 	switch b.(type) {
-	case string:
+	case *ast.Text:
 		// Write the content.
-		h.stream.Write([]byte(strings.ReplaceAll(html.EscapeString(b.(string)), "\n", "<br>")))
+		h.write(strings.ReplaceAll(html.EscapeString(b.(*ast.Text).Value), "\n", "<br"+h.voidTagEnd()))
 		break
-	case ast.HyperlinkBlock:
+	case *ast.HyperlinkBlock:
 		// Write the hyperlink.
-		block := b.(ast.HyperlinkBlock)
-		h.stream.Write([]byte("<a href=\"" + block.Destination + "\">"))
+		block := b.(*ast.HyperlinkBlock)
+		href, err := h.urlParameter("href", block.Destination, false)
+		if err != nil {
+			return err
+		}
+		h.write("<a" + href + ">")
 		for i := range block.Content {
 			err := h.exportInlineBlock(block.Content[i])
 			if err != nil {
 				return err
 			}
 		}
-		h.stream.Write([]byte("</a>"))
+		h.write("</a>")
 		break
-	case ast.FormattingBlock:
+	case *ast.FormattingBlock:
 		// Write the formatting.
-		block := b.(ast.FormattingBlock)
-		attrName, err := getHTMLFormattingTagName(&block)
+		block := b.(*ast.FormattingBlock)
+		attrName, err := getHTMLFormattingTagName(block)
 		if err != nil {
 			return err
 		}
-		h.stream.Write([]byte("<" + attrName + ">"))
+		h.write("<" + attrName + ">")
 		for i := range block.Content {
 			err = h.exportInlineBlock(block.Content[i])
 			if err != nil {
 				return err
 			}
 		}
-		h.stream.Write([]byte("</" + attrName + ">"))
+		h.write("</" + attrName + ">")
 		break
-	case ast.SizeBlock:
+	case *ast.SizeBlock:
 		// Write the size block.
-		block := b.(ast.SizeBlock)
-		paramValue, err := getHTMLFontSizeParameter(&block)
+		block := b.(*ast.SizeBlock)
+		paramValue, err := getHTMLFontSizeParameter(block)
 		if err != nil {
 			return err
 		}
-		h.stream.Write([]byte("<span style=\"font-size: " + paramValue + "\">"))
+		h.write("<span" + h.styleAttributes("font-size: "+paramValue) + ">")
 		for i := range block.Content {
 			err = h.exportInlineBlock(block.Content[i])
 			if err != nil {
 				return err
 			}
 		}
-		h.stream.Write([]byte("</span>"))
+		h.write("</span>")
 		break
-	case ast.FontBlock:
+	case *ast.FontBlock:
 		// Write the font block.
-		block := b.(ast.FontBlock)
-		h.stream.Write([]byte("<span style=\"font-family: " + block.Family + "\">"))
+		block := b.(*ast.FontBlock)
+		family, err := h.fontFamily(block.Family)
+		if err != nil {
+			return err
+		}
+		h.write("<span" + h.styleAttributes("font-family: "+family) + ">")
 		for i := range block.Content {
 			err := h.exportInlineBlock(block.Content[i])
 			if err != nil {
 				return err
 			}
 		}
-		h.stream.Write([]byte("</span>"))
+		h.write("</span>")
 		break
-	case ast.ColorBlock:
+	case *ast.ColorBlock:
 		// Write the color block.
-		block := b.(ast.ColorBlock)
-		h.stream.Write([]byte("<span style=\"" + getHTMLColorStyleParameter(&block) + "\">"))
+		block := b.(*ast.ColorBlock)
+		h.write("<span" + h.styleAttributes(getHTMLColorStyleParameter(block)) + ">")
 		for i := range block.Content {
 			err := h.exportInlineBlock(block.Content[i])
 			if err != nil {
 				return err
 			}
 		}
-		h.stream.Write([]byte("</span>"))
+		h.write("</span>")
 		break
-	case ast.InlineImageBlock:
+	case *ast.InlineImageBlock:
 		// Write the inline image block.
-		block := b.(ast.InlineImageBlock)
-		sizeStyle, err := getHTMLInlineImageWidthHeightStyleParameter(&block)
+		block := b.(*ast.InlineImageBlock)
+		sizeStyle, err := getHTMLInlineImageWidthHeightStyleParameter(block)
+		if err != nil {
+			return err
+		}
+		src, err := h.urlParameter("src", block.Source, true)
 		if err != nil {
 			return err
 		}
-		h.stream.Write([]byte("<img" + wrapHTMLStyleParameter(sizeStyle) + " src=\"" + block.Source + "\">"))
+		h.write("<img" + h.styleAttributes(sizeStyle) + src + wrapHTMLAltParameter(block.Alt) + h.voidTagEnd())
 		break
 	default:
 		return errors.New("invalid ast")
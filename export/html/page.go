@@ -0,0 +1,91 @@
+// export/html/page.go
+// Standalone page header and footer.
+
+package html
+
+import (
+	"html"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Header and footer template data.
+type TemplateData struct {
+	Title    string
+	Subtitle string
+	Date     string
+	Author   string
+}
+
+// Get the template data for a document.
+func templateData(d *ast.Document) TemplateData {
+	return TemplateData{
+		Title:    d.Title,
+		Subtitle: d.Subtitle,
+		Date:     d.Date,
+		Author:   d.Author,
+	}
+}
+
+// Export the page's doctype, head and the start of its body.
+func (h *HTMLExporter) exportHeader(d *ast.Document) error {
+	language := html.EscapeString(h.settings.Language)
+	h.write("<!DOCTYPE html>\n")
+	if h.settings.XHTML {
+		h.write("<html xmlns=\"http://www.w3.org/1999/xhtml\" lang=\"" + language + "\" xml:lang=\"" + language + "\">\n")
+	} else {
+		h.write("<html lang=\"" + language + "\">\n")
+	}
+	h.write("<head>\n")
+	h.write("<meta charset=\"utf-8\"" + h.voidTagEnd() + "\n")
+	h.write("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\"" + h.voidTagEnd() + "\n")
+
+	// Write the metadata.
+	if d.Title != "" {
+		h.write("<title>" + html.EscapeString(d.Title) + "</title>\n")
+	}
+	if d.Subtitle != "" {
+		h.write("<meta name=\"description\" content=\"" + html.EscapeString(d.Subtitle) + "\"" + h.voidTagEnd() + "\n")
+	}
+	if d.Author != "" {
+		h.write("<meta name=\"author\" content=\"" + html.EscapeString(d.Author) + "\"" + h.voidTagEnd() + "\n")
+	}
+	if d.Date != "" {
+		h.write("<meta name=\"dcterms.date\" content=\"" + html.EscapeString(d.Date) + "\"" + h.voidTagEnd() + "\n")
+	}
+
+	// Write the stylesheets.
+	if css := h.themeStylesheet(); css != "" {
+		h.write("<style>\n" + css + "</style>\n")
+	}
+	for i := range h.settings.Stylesheets {
+		h.write("<link rel=\"stylesheet\" href=\"" + html.EscapeString(h.settings.Stylesheets[i]) + "\"" + h.voidTagEnd() + "\n")
+	}
+	if h.settings.Stylesheet != "" {
+		h.write("<style>\n" + h.settings.Stylesheet + "\n</style>\n")
+	}
+	if h.settings.ClassStyles {
+		if h.settings.ClassStylesheetURL != "" {
+			h.write("<link rel=\"stylesheet\" href=\"" + html.EscapeString(h.settings.ClassStylesheetURL) + "\"" + h.voidTagEnd() + "\n")
+		} else if css := h.ClassStylesheet(); css != "" {
+			h.write("<style>\n" + css + "</style>\n")
+		}
+	}
+	h.write("</head>\n<body>\n")
+
+	if h.settings.HeaderTemplate != nil {
+		return h.settings.HeaderTemplate.Execute(h.out, templateData(d))
+	}
+	return nil
+}
+
+// Export the end of the page's body.
+func (h *HTMLExporter) exportFooter(d *ast.Document) error {
+	if h.settings.FooterTemplate != nil {
+		if err := h.settings.FooterTemplate.Execute(h.out, templateData(d)); err != nil {
+			return err
+		}
+	}
+	h.write("</body>\n</html>\n")
+	return nil
+}
@@ -0,0 +1,104 @@
+// export/html/page_test.go
+// Standalone page tests.
+
+package html
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+func TestExportPage(t *testing.T) {
+	d := &ast.Document{
+		Title:    "<T&>",
+		Subtitle: "\"s\"",
+		Author:   "<a>",
+		Date:     "2024 & after",
+		Content:  []ast.Block{&ast.Paragraph{Content: []ast.InlineBlock{&ast.Text{Value: "body"}}}},
+	}
+	header := template.Must(template.New("header").Parse("<header>{{.Title}}</header>\n"))
+	footer := template.Must(template.New("footer").Parse("<footer>{{.Author}}</footer>\n"))
+
+	tests := []struct {
+		name     string
+		settings HTMLSettings
+		want     []string
+		unwanted []string
+	}{
+		{
+			name:     "metadata",
+			settings: HTMLSettings{IncludeHeader: true, IncludeFooter: true},
+			want: []string{
+				"<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n",
+				"<title>&lt;T&amp;&gt;</title>\n",
+				"<meta name=\"description\" content=\"&#34;s&#34;\">\n",
+				"<meta name=\"author\" content=\"&lt;a&gt;\">\n",
+				"<meta name=\"dcterms.date\" content=\"2024 &amp; after\">\n",
+				"</head>\n<body>\n",
+				"<p>body</p>\n</body>\n</html>\n",
+			},
+			unwanted: []string{"<style>", "<link"},
+		},
+		{
+			name:     "xhtml",
+			settings: HTMLSettings{IncludeHeader: true, XHTML: true, Language: "de\""},
+			want: []string{
+				"<html xmlns=\"http://www.w3.org/1999/xhtml\" lang=\"de&#34;\" xml:lang=\"de&#34;\">\n",
+				"<meta charset=\"utf-8\" />\n",
+				"<meta name=\"author\" content=\"&lt;a&gt;\" />\n",
+			},
+			unwanted: []string{"</html>"},
+		},
+		{
+			name: "stylesheets",
+			settings: HTMLSettings{
+				IncludeHeader: true,
+				Theme:         DarkTheme,
+				Stylesheets:   []string{"a.css", "b.css?x=1&y=\"2\""},
+				Stylesheet:    "p { color: red; }",
+			},
+			want: []string{
+				"<style>\nbody { max-width: 42em;",
+				"#181a1b",
+				"</style>\n<link rel=\"stylesheet\" href=\"a.css\">\n<link rel=\"stylesheet\" href=\"b.css?x=1&amp;y=&#34;2&#34;\">\n<style>\np { color: red; }\n</style>\n</head>",
+			},
+		},
+		{
+			name:     "templates",
+			settings: HTMLSettings{IncludeHeader: true, IncludeFooter: true, HeaderTemplate: header, FooterTemplate: footer},
+			want: []string{
+				"<body>\n<header>&lt;T&amp;&gt;</header>\n<h1>&lt;T&amp;&gt;</h1>\n",
+				"<p>body</p>\n<footer>&lt;a&gt;</footer>\n</body>\n</html>\n",
+			},
+		},
+		{
+			name:     "templates without header",
+			settings: HTMLSettings{HeaderTemplate: header, FooterTemplate: footer},
+			want:     []string{"<p>body</p>\n"},
+			unwanted: []string{"<header>", "<footer>", "<head>"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := bytes.Buffer{}
+			if err := NewHTMLExporter(&out, test.settings).Export(d); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("got:\n%s\nwant it to contain:\n%s", out.String(), want)
+				}
+			}
+			for _, unwanted := range test.unwanted {
+				if strings.Contains(out.String(), unwanted) {
+					t.Errorf("got:\n%s\nwant it not to contain:\n%s", out.String(), unwanted)
+				}
+			}
+		})
+	}
+}
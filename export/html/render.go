@@ -0,0 +1,90 @@
+// export/html/render.go
+// Render overrides for node types.
+
+package html
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// A render override for a node type. It writes the node's HTML to w, and can
+// call children to write the HTML the exporter writes inside the node's
+// element. The attributes are the block's id, style and class attributes,
+// like RenderData's.
+type RenderFunc func(w io.Writer, n ast.Node, attributes string, children func() error) error
+
+// The data render templates are executed with.
+type RenderData struct {
+	// The node being written.
+	Node ast.Node
+
+	// The block's id, style and class attributes, as the exporter writes
+	// them, starting with a space, like `<div{{.Attributes}}>`. Empty for
+	// inline blocks.
+	Attributes template.HTMLAttr
+
+	children func() (template.HTML, error)
+}
+
+// Get the HTML the exporter writes inside the node's element.
+func (d RenderData) Children() (template.HTML, error) {
+	return d.children()
+}
+
+// Check if a node has a render override.
+func (h *HTMLExporter) hasRenderer(n ast.Node) bool {
+	name := ast.TypeName(n)
+	return h.settings.RenderFuncs[name] != nil || h.settings.RenderTemplates[name] != nil
+}
+
+// Write a node with its render override. The id is a heading's automatic
+// id.
+func (h *HTMLExporter) render(n ast.Node, id string) error {
+	attributes, err := h.renderAttributes(n, id)
+	if err != nil {
+		return err
+	}
+
+	name := ast.TypeName(n)
+	if f := h.settings.RenderFuncs[name]; f != nil {
+		return f(h.out, n, attributes, func() error {
+			return h.exportChildren(n)
+		})
+	}
+	return h.settings.RenderTemplates[name].Execute(h.out, RenderData{
+		Node:       n,
+		Attributes: template.HTMLAttr(attributes),
+		children: func() (template.HTML, error) {
+			// Write the children into a buffer, to give them to the template.
+			var buf bytes.Buffer
+			out := h.out
+			h.out = newErrWriter(&buf)
+			err := h.exportChildren(n)
+			h.out = out
+			return template.HTML(buf.String()), err
+		},
+	})
+}
+
+// Get a block's attributes for its render override.
+func (h *HTMLExporter) renderAttributes(n ast.Node, id string) (string, error) {
+	switch b := n.(type) {
+	case *ast.Quote:
+		return h.blockAttributes(b, "", h.settings.QuoteBlockClass)
+	case *ast.Image:
+		return h.blockAttributes(b, "", h.settings.ImageBlockClass)
+	case *ast.Notes:
+		return h.blockAttributes(b, "", h.settings.NotesClass)
+	case *ast.Heading:
+		return h.blockAttributes(b, id)
+	case *ast.HorizontalRule, *ast.PageBreak:
+		return h.identityAttributes(b.(ast.Block), ""), nil
+	case ast.Block:
+		return h.blockAttributes(b, "")
+	}
+	return "", nil
+}
@@ -0,0 +1,91 @@
+// export/html/render_test.go
+// Render override tests.
+
+package html
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create a heading holding text.
+func testHeading(text string) *ast.Heading {
+	return &ast.Heading{Class: ast.Heading2Type, Content: []ast.InlineBlock{&ast.Text{Value: text}}}
+}
+
+func TestRenderOverrides(t *testing.T) {
+	d := &ast.Document{Content: []ast.Block{
+		testHeading("a"),
+		testHeading("b"),
+		&ast.Quote{Content: []ast.Block{&ast.Paragraph{Content: []ast.InlineBlock{&ast.Text{Value: "q"}}}}},
+		testHeading("c"),
+	}}
+
+	tests := []struct {
+		name     string
+		settings HTMLSettings
+		want     string
+	}{
+		{
+			name:     "none",
+			settings: HTMLSettings{HeadingIDs: true},
+			want:     "<h2 id=\"heading-1\">a</h2>\n<h2 id=\"heading-2\">b</h2>\n<div class=\"quote\"><p>q</p>\n</div>\n<h2 id=\"heading-3\">c</h2>\n",
+		},
+		{
+			name: "function",
+			settings: HTMLSettings{HeadingIDs: true, RenderFuncs: map[string]RenderFunc{
+				"heading": func(w io.Writer, n ast.Node, attributes string, children func() error) error {
+					io.WriteString(w, "<h2"+attributes+"><a href=\"#\">#</a>")
+					if err := children(); err != nil {
+						return err
+					}
+					_, err := io.WriteString(w, "</h2>\n")
+					return err
+				},
+			}},
+			want: "<h2 id=\"heading-1\"><a href=\"#\">#</a>a</h2>\n<h2 id=\"heading-2\"><a href=\"#\">#</a>b</h2>\n<div class=\"quote\"><p>q</p>\n</div>\n<h2 id=\"heading-3\"><a href=\"#\">#</a>c</h2>\n",
+		},
+		{
+			name: "template",
+			settings: HTMLSettings{HeadingIDs: true, RenderTemplates: map[string]*template.Template{
+				"quote": template.Must(template.New("quote").Parse("<blockquote{{.Attributes}}>{{.Children}}</blockquote>\n")),
+			}},
+			want: "<h2 id=\"heading-1\">a</h2>\n<h2 id=\"heading-2\">b</h2>\n<blockquote class=\"quote\"><p>q</p>\n</blockquote>\n<h2 id=\"heading-3\">c</h2>\n",
+		},
+		{
+			name: "only some headings",
+			settings: HTMLSettings{HeadingIDs: true, RenderTemplates: map[string]*template.Template{
+				"heading": template.Must(template.New("heading").Parse(`{{if eq (printf "%v" .Attributes) " id=\"heading-2\""}}<h2{{.Attributes}} class="x">{{.Children}}</h2>` + "\n" + `{{else}}<h2{{.Attributes}}>{{.Children}}</h2>` + "\n{{end}}")),
+			}},
+			want: "<h2 id=\"heading-1\">a</h2>\n<h2 id=\"heading-2\" class=\"x\">b</h2>\n<div class=\"quote\"><p>q</p>\n</div>\n<h2 id=\"heading-3\">c</h2>\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := bytes.Buffer{}
+			if err := NewHTMLExporter(&out, test.settings).Export(d); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestRenderOverrideError(t *testing.T) {
+	d := &ast.Document{Content: []ast.Block{testHeading("a")}}
+	settings := HTMLSettings{RenderTemplates: map[string]*template.Template{
+		"heading": template.Must(template.New("heading").Parse("{{.Missing}}")),
+	}}
+	err := NewHTMLExporter(io.Discard, settings).Export(d)
+	if err == nil || !strings.Contains(err.Error(), "heading") {
+		t.Errorf("got %v, want a heading error", err)
+	}
+}
@@ -0,0 +1,74 @@
+// export/html/safe.go
+// URL and style sanitization.
+
+package html
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// The URL schemes allowed by default.
+var DefaultURLSchemes = []string{"http", "https", "mailto", "tel", "ftp"}
+
+// Get a URL attribute, like " href=\"\"". Returns an empty string if the
+// URL's scheme isn't allowed, or an error in strict mode. Images may also be
+// data URLs holding an image.
+func (h *HTMLExporter) urlParameter(name, value string, image bool) (string, error) {
+	if !h.allowedURL(value, image) {
+		if h.settings.Strict {
+			return "", errors.New("unsafe url '" + value + "'")
+		}
+		return "", nil
+	}
+	return " " + name + "=\"" + html.EscapeString(value) + "\"", nil
+}
+
+// Check if a URL is relative or has an allowed scheme.
+func (h *HTMLExporter) allowedURL(value string, image bool) bool {
+	// Browsers skip whitespace and control characters, so they are removed
+	// before looking for the scheme.
+	url := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value)
+	end := strings.IndexAny(url, ":/?#")
+	if end == -1 || url[end] != ':' {
+		return true
+	}
+	scheme := strings.ToLower(url[:end])
+	if image && scheme == "data" && strings.HasPrefix(strings.ToLower(url[end+1:]), "image/") {
+		return true
+	}
+	for _, allowed := range h.settings.URLSchemes {
+		if scheme == strings.ToLower(allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get a font family with only letters, digits, spaces, balanced quotes and
+// ",-_." kept, so it can't end its declaration. Returns an error in strict
+// mode if anything was removed.
+func (h *HTMLExporter) fontFamily(family string) (string, error) {
+	safe := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(" ,-_.'\"", r) {
+			return r
+		}
+		return -1
+	}, family)
+	for _, quote := range []string{"'", "\""} {
+		if strings.Count(safe, quote)%2 != 0 {
+			safe = strings.Replace(safe, quote, "", -1)
+		}
+	}
+	if safe != family && h.settings.Strict {
+		return "", errors.New("unsafe font family '" + family + "'")
+	}
+	return safe, nil
+}
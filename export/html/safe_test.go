@@ -0,0 +1,251 @@
+// export/html/safe_test.go
+// URL and style sanitization tests.
+
+package html
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+func TestURLParameter(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		image bool
+		want  string
+	}{
+		{"http", "http://example.com/a?b=1&c=2", false, ` href="http://example.com/a?b=1&amp;c=2"`},
+		{"mailto", "mailto:a@example.com", false, ` href="mailto:a@example.com"`},
+		{"relative", "/a/b.html#c", false, ` href="/a/b.html#c"`},
+		{"relative colon", "a/b:c", false, ` href="a/b:c"`},
+		{"javascript", "javascript:alert(1)", false, ""},
+		{"javascript mixed case", "JaVaScRiPt:alert(1)", false, ""},
+		{"javascript leading whitespace", " \t\njavascript:alert(1)", false, ""},
+		{"javascript tab", "java\tscript:alert(1)", false, ""},
+		{"javascript newline", "java\nscript:alert(1)", false, ""},
+		{"javascript control character", "java\x00script:alert(1)", false, ""},
+		{"javascript escape character", "\x1bjavascript:alert(1)", false, ""},
+		{"vbscript", "vbscript:msgbox(1)", false, ""},
+		{"data link", "data:text/html,<script>alert(1)</script>", false, ""},
+		{"data html image", "data:text/html,<script>alert(1)</script>", true, ""},
+		{"data image link", "data:image/png;base64,AAAA", false, ""},
+		{"data image", "data:image/png;base64,AAAA", true, ` href="data:image/png;base64,AAAA"`},
+		{"data image mixed case", "DATA:IMAGE/png;base64,AAAA", true, ` href="DATA:IMAGE/png;base64,AAAA"`},
+		{"quote breakout", `x" onmouseover="alert(1)`, false, ` href="x&#34; onmouseover=&#34;alert(1)"`},
+		{"tag breakout", `x"><script>alert(1)</script>`, false, ` href="x&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;"`},
+	}
+
+	h := NewHTMLExporter(nil, HTMLSettings{})
+	strict := NewHTMLExporter(nil, HTMLSettings{Strict: true})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := h.urlParameter("href", test.url, test.image)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+
+			// Strict mode fails on the URLs that are left out otherwise.
+			got, err = strict.urlParameter("href", test.url, test.image)
+			if test.want == "" && err == nil {
+				t.Errorf("strict: got %q, want an error", got)
+			}
+			if test.want != "" && (err != nil || got != test.want) {
+				t.Errorf("strict: got %q, %v, want %q", got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestCustomURLSchemes(t *testing.T) {
+	h := NewHTMLExporter(nil, HTMLSettings{UseCustomURLSchemes: true, URLSchemes: []string{"HTTPS", "gemini"}})
+	tests := map[string]bool{
+		"https://a.b":   true,
+		"gemini://a.b":  true,
+		"http://a.b":    false,
+		"mailto:a@b.c":  false,
+		"relative/path": true,
+	}
+	for url, want := range tests {
+		if got := h.allowedURL(url, false); got != want {
+			t.Errorf("%q: got %v, want %v", url, got, want)
+		}
+	}
+}
+
+func TestFontFamily(t *testing.T) {
+	tests := []struct {
+		family string
+		want   string
+	}{
+		{`serif`, `serif`},
+		{`"Times New Roman", serif`, `"Times New Roman", serif`},
+		{`'Noto Sans JP', sans-serif`, `'Noto Sans JP', sans-serif`},
+		{`x;}</style><script>`, `xstylescript`},
+		{`x; background: url(javascript:alert(1))`, `x background urljavascriptalert1`},
+		{`a"b`, `ab`},
+		{`a\"b`, `ab`},
+		{`x</span><script>`, `xspanscript`},
+	}
+
+	h := NewHTMLExporter(nil, HTMLSettings{})
+	strict := NewHTMLExporter(nil, HTMLSettings{Strict: true})
+	for _, test := range tests {
+		t.Run(test.family, func(t *testing.T) {
+			got, err := h.fontFamily(test.family)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+
+			_, err = strict.fontFamily(test.family)
+			if (err != nil) != (test.want != test.family) {
+				t.Errorf("strict: got error %v for %q", err, test.family)
+			}
+		})
+	}
+}
+
+func TestExportInjection(t *testing.T) {
+	text := func(s string) []ast.InlineBlock {
+		return []ast.InlineBlock{&ast.Text{Value: s}}
+	}
+	tests := []struct {
+		name     string
+		block    ast.Block
+		settings HTMLSettings
+		want     string
+		unsafe   []string
+	}{
+		{
+			name:   "href",
+			block:  testParagraph(&ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: text("a")}, Destination: `" onclick="alert(1)`}),
+			want:   `<p><a href="&#34; onclick=&#34;alert(1)">a</a></p>`,
+			unsafe: []string{`" onclick`},
+		},
+		{
+			name:   "javascript href",
+			block:  testParagraph(&ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: text("a")}, Destination: " JavaScript:alert(1)"}),
+			want:   `<p><a>a</a></p>`,
+			unsafe: []string{"alert"},
+		},
+		{
+			name:   "image src",
+			block:  &ast.Image{Source: `x" onerror="alert(1)`, Alt: "a"},
+			want:   `<div class="image-block"><img src="x&#34; onerror=&#34;alert(1)" alt="a"></div>`,
+			unsafe: []string{`" onerror`},
+		},
+		{
+			name:   "inline image src",
+			block:  testParagraph(&ast.InlineImageBlock{Source: `x" onerror="alert(1)`}),
+			want:   `<p><img src="x&#34; onerror=&#34;alert(1)"></p>`,
+			unsafe: []string{`" onerror`},
+		},
+		{
+			name:   "data image src",
+			block:  &ast.Image{Source: "data:text/html,<script>alert(1)</script>"},
+			want:   `<div class="image-block"><img></div>`,
+			unsafe: []string{"<script>"},
+		},
+		{
+			name:   "id",
+			block:  &ast.Paragraph{BaseBlock: ast.BaseBlock{ID: `x" onclick="alert(1)`}, Content: text("a")},
+			want:   `<p id="x&#34; onclick=&#34;alert(1)">a</p>`,
+			unsafe: []string{`" onclick`},
+		},
+		{
+			name:   "class",
+			block:  &ast.Paragraph{BaseBlock: ast.BaseBlock{Classes: []string{`x"`, `onclick="alert(1)`}}, Content: text("a")},
+			want:   `<p class="x&#34; onclick=&#34;alert(1)">a</p>`,
+			unsafe: []string{`" onclick`},
+		},
+		{
+			name:   "font family style",
+			block:  testParagraph(&ast.FontBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: text("a")}, Family: `x;}</style><script>`}),
+			want:   `<p><span style="font-family: xstylescript">a</span></p>`,
+			unsafe: []string{"<script>", ";}"},
+		},
+		{
+			name:     "font family class",
+			block:    testParagraph(&ast.FontBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: text("a")}, Family: `x;}</style><script>`}),
+			settings: HTMLSettings{IncludeHeader: true, ClassStyles: true},
+			want:     ".cdf-font-xstylescript { font-family: xstylescript; }",
+			unsafe:   []string{"<script>", "</style><"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := &ast.Document{Content: []ast.Block{test.block}}
+			out := strings.Builder{}
+			if err := NewHTMLExporter(&out, test.settings).Export(d); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), test.want) {
+				t.Errorf("got:\n%s\nwant it to contain:\n%s", out.String(), test.want)
+			}
+			for _, s := range test.unsafe {
+				if strings.Contains(out.String(), s) {
+					t.Errorf("output contains %q:\n%s", s, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestExportStrict(t *testing.T) {
+	blocks := []ast.Block{
+		testParagraph(&ast.HyperlinkBlock{Destination: "javascript:alert(1)"}),
+		&ast.Image{Source: "vbscript:x"},
+		testParagraph(&ast.InlineImageBlock{Source: "data:text/html,x"}),
+		testParagraph(&ast.FontBlock{Family: "x;}"}),
+	}
+	for _, b := range blocks {
+		d := &ast.Document{Content: []ast.Block{b}}
+		if err := NewHTMLExporter(&strings.Builder{}, HTMLSettings{}).Export(d); err != nil {
+			t.Errorf("%s: unexpected error: %v", ast.TypeName(b), err)
+		}
+		if err := NewHTMLExporter(&strings.Builder{}, HTMLSettings{Strict: true}).Export(d); err == nil {
+			t.Errorf("%s: expected an error in strict mode", ast.TypeName(b))
+		}
+	}
+}
+
+func TestURLSchemesCopied(t *testing.T) {
+	custom := []string{"gemini"}
+	defaults := NewHTMLExporter(nil, HTMLSettings{})
+	h := NewHTMLExporter(nil, HTMLSettings{UseCustomURLSchemes: true, URLSchemes: custom})
+
+	// Changing the schemes after creating the exporters doesn't affect them.
+	custom[0] = "https"
+	previous := DefaultURLSchemes[0]
+	DefaultURLSchemes[0] = "javascript"
+	defer func() { DefaultURLSchemes[0] = previous }()
+
+	tests := []struct {
+		exporter *HTMLExporter
+		url      string
+		want     string
+	}{
+		{defaults, "http://example.com/", ` href="http://example.com/"`},
+		{defaults, "javascript:alert(1)", ""},
+		{h, "gemini://example.com/", ` href="gemini://example.com/"`},
+		{h, "https://example.com/", ""},
+	}
+	for _, test := range tests {
+		got, err := test.exporter.urlParameter("href", test.url, false)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.url, err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.url, got, test.want)
+		}
+	}
+}
@@ -3,21 +3,102 @@
 
 package html
 
-import "github.com/cubeflix/cdf/export"
+import (
+	"html/template"
+
+	"github.com/cubeflix/cdf/export"
+)
 
 const (
 	DefaultQuoteBlockClass   = "quote"
 	DefaultImageBlockClass   = "image-block"
 	DefaultImageCaptionClass = "image-caption"
+	DefaultNotesClass        = "notes"
+	DefaultClassPrefix       = "cdf-"
+	DefaultLanguage          = "en"
+)
+
+// Built-in page theme.
+type Theme int64
+
+const (
+	// No stylesheet.
+	NoTheme Theme = iota
+
+	// Sans-serif text in a narrow column.
+	PlainTheme
+
+	// Serif text, like a printed article.
+	SerifTheme
+
+	// Light text on a dark background.
+	DarkTheme
 )
 
 // HTML export settings.
 type HTMLSettings struct {
 	export.Settings
 
+	// Write a complete page, starting with the doctype and a head holding
+	// the document's metadata, the theme and the stylesheets.
 	IncludeHeader bool
+
+	// Close the page's body and html elements.
 	IncludeFooter bool
 
+	// The page's language, as a BCP 47 tag. Defaults to "en".
+	Language string
+
+	// The page's built-in theme, and stylesheets linked after it. CSS in
+	// Stylesheet is added last, so it can override both.
+	Theme       Theme
+	Stylesheets []string
+	Stylesheet  string
+
+	// Templates written at the start and end of the page's body, executed
+	// with TemplateData. They are only written with the header and footer.
+	HeaderTemplate *template.Template
+	FooterTemplate *template.Template
+
+	// Render overrides, keyed by node type name, like "image" or "heading"
+	// (see ast.TypeName). Templates are executed with RenderData. A function
+	// is used before a template for the same type, and the override writes
+	// the whole node.
+	RenderFuncs     map[string]RenderFunc
+	RenderTemplates map[string]*template.Template
+
+	// Write XHTML, with self-closing void elements.
+	XHTML bool
+
+	// The URL schemes allowed in links and image sources. Defaults to
+	// DefaultURLSchemes. Relative URLs are always allowed, and images may be
+	// data URLs. Other URLs are left out.
+	UseCustomURLSchemes bool
+	URLSchemes          []string
+
+	// Fail on URLs with other schemes and on font families with unsafe
+	// characters, instead of leaving them out.
+	Strict bool
+
+	// Give each heading a sequential id, like "heading-1".
+	HeadingIDs bool
+
+	// Write speaker notes as aside elements. Notes are left out otherwise.
+	IncludeNotes bool
+
+	// Write alignment, sizes, fonts and colors as generated classes, like
+	// "cdf-align-center", instead of style attributes. The classes' rules
+	// are written in a style element in the header, or linked from
+	// ClassStylesheetURL. Use ClassStylesheet to get them otherwise. A
+	// Content Security Policy without 'unsafe-inline' styles also blocks the
+	// style element, so such pages need ClassStylesheetURL.
+	ClassStyles        bool
+	ClassStylesheetURL string
+
+	// The prefix of generated classes. Defaults to "cdf-".
+	UseCustomClassPrefix bool
+	ClassPrefix          string
+
 	UseCustomQuoteBlockClass bool
 	QuoteBlockClass          string
 
@@ -26,4 +107,7 @@ type HTMLSettings struct {
 
 	UseCustomImageCaptionClass bool
 	ImageCaptionClass          string
+
+	UseCustomNotesClass bool
+	NotesClass          string
 }
@@ -0,0 +1,72 @@
+// export/html/theme.go
+// Built-in page themes.
+
+package html
+
+import "strings"
+
+// The layout shared by the themes. Class selectors are written as
+// placeholders, like "{quote}", and replaced with the exporter's classes.
+const baseTheme = `body { max-width: 42em; margin: 0 auto; padding: 2em 1em; line-height: 1.6; }
+img { max-width: 100%; height: auto; }
+h1, h2, h3, h4, h5 { line-height: 1.25; margin: 1.4em 0 .5em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { padding: .3em .7em; text-align: left; vertical-align: top; }
+th p, td p { margin: 0; }
+code { font-family: ui-monospace, Menlo, Consolas, "Liberation Mono", monospace; font-size: .9em; padding: 0 .2em; }
+hr { border: none; margin: 2em 0; }
+details { margin: 1em 0; }
+summary { cursor: pointer; }
+.{quote} { margin: 1em 0; padding: 0 1em; }
+.{image-block} { margin: 1em 0; text-align: center; }
+.{image-caption} { font-size: .9em; margin-top: .3em; }
+.{notes} { display: none; }
+@media print { body { max-width: none; padding: 0; } a { color: inherit; } }
+`
+
+// The themes' colors and fonts.
+var themes = map[Theme]string{
+	PlainTheme: `body { font-family: system-ui, -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; color: #222; background: #fff; }
+a { color: #0b57d0; }
+th, td { border: 1px solid #ddd; }
+th { background: #f5f5f5; }
+code { background: #f2f2f2; border-radius: 3px; }
+hr { border-top: 1px solid #ddd; }
+.{quote} { border-left: 4px solid #ddd; color: #555; }
+.{image-caption} { color: #666; }
+`,
+	SerifTheme: `body { font-family: Charter, "Bitstream Charter", Georgia, Cambria, "Times New Roman", serif; font-size: 1.1em; color: #1a1a1a; background: #fffdf8; }
+h1, h2, h3, h4, h5 { font-weight: normal; }
+h1 { text-align: center; font-size: 2.2em; }
+a { color: #7a2e0e; }
+th, td { border-top: 1px solid #bbb; border-bottom: 1px solid #bbb; }
+th { font-weight: bold; }
+hr { border-top: 1px solid #bbb; width: 30%; }
+.{quote} { font-style: italic; border-left: 2px solid #bbb; }
+.{image-caption} { font-style: italic; color: #555; }
+`,
+	DarkTheme: `body { font-family: system-ui, -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; color: #ddd; background: #181a1b; }
+a { color: #8ab4f8; }
+th, td { border: 1px solid #3c4043; }
+th { background: #232629; }
+code { background: #2a2d30; border-radius: 3px; }
+hr { border-top: 1px solid #3c4043; }
+.{quote} { border-left: 4px solid #3c4043; color: #aaa; }
+.{image-caption} { color: #999; }
+@media print { body { color: #000; background: #fff; } }
+`,
+}
+
+// Get the theme's stylesheet, or an empty string for no theme.
+func (h *HTMLExporter) themeStylesheet() string {
+	theme, ok := themes[h.settings.Theme]
+	if !ok {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{quote}", h.settings.QuoteBlockClass,
+		"{image-block}", h.settings.ImageBlockClass,
+		"{image-caption}", h.settings.ImageCaptionClass,
+		"{notes}", h.settings.NotesClass,
+	).Replace(baseTheme + theme)
+}
@@ -0,0 +1,142 @@
+// export/html/writer.go
+// Buffered, error-tracking output.
+
+package html
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// A writer that keeps the first error, and ignores writes after it.
+type errWriter struct {
+	w interface {
+		io.Writer
+		io.StringWriter
+	}
+	buf *bufio.Writer
+	err error
+}
+
+// Create a writer for a stream. In-memory builders are written to directly,
+// and other streams are buffered.
+func newErrWriter(stream io.Writer) *errWriter {
+	switch s := stream.(type) {
+	case *bytes.Buffer:
+		return &errWriter{w: s}
+	case *strings.Builder:
+		return &errWriter{w: s}
+	}
+	buf := bufio.NewWriter(stream)
+	return &errWriter{w: buf, buf: buf}
+}
+
+// Write bytes.
+func (w *errWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.err = err
+	return n, err
+}
+
+// Write a string.
+func (w *errWriter) WriteString(s string) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.WriteString(s)
+	w.err = err
+	return n, err
+}
+
+// Flush the buffered output. Returns the first error.
+func (w *errWriter) Flush() error {
+	if w.err == nil && w.buf != nil {
+		w.err = w.buf.Flush()
+	}
+	return w.err
+}
+
+// An error exporting a node.
+type NodeError struct {
+	// The node that failed.
+	Node ast.Node
+
+	// The indices of the node and its parents in their parents' children
+	// (see ast.Children), starting from the document. Table rows and cells
+	// are included.
+	Path []int
+
+	// The underlying error.
+	Err error
+
+	// The type names of the nodes in the path, and the outermost node
+	// reached so far.
+	names []string
+	outer ast.Node
+}
+
+// Get the error's message, starting with the path to the node, like
+// "quote[2] > paragraph[0] > text[1]".
+func (e *NodeError) Error() string {
+	if len(e.Path) == 0 {
+		return ast.TypeName(e.Node) + ": " + e.Err.Error()
+	}
+	parts := make([]string, len(e.Path))
+	for i := range e.Path {
+		parts[i] = e.names[i] + "[" + strconv.Itoa(e.Path[i]) + "]"
+	}
+	return strings.Join(parts, " > ") + ": " + e.Err.Error()
+}
+
+// Get the underlying error.
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap an error with the node that failed. If the error is from one of the
+// node's children, the child's position is added to the error's path.
+func nodeError(n ast.Node, err error) error {
+	if err == nil {
+		return nil
+	}
+	e, ok := err.(*NodeError)
+	if !ok {
+		return &NodeError{Node: n, Path: []int{}, Err: err, names: []string{}, outer: n}
+	}
+
+	// Add the path to the outermost node so far.
+	path, names := childPath(n, e.outer)
+	if path == nil {
+		return err
+	}
+	e.Path = append(path, e.Path...)
+	e.names = append(names, e.names...)
+	e.outer = n
+	return e
+}
+
+// Get the indices and type names leading from a node to one of its
+// children, through table rows and cells. Returns nil if it is not a child.
+func childPath(parent, child ast.Node) ([]int, []string) {
+	children := ast.Children(parent)
+	for i := range children {
+		if children[i] == child {
+			return []int{i}, []string{ast.TypeName(child)}
+		}
+		switch children[i].(type) {
+		case *ast.TableRow, *ast.TableCell:
+			if path, names := childPath(children[i], child); path != nil {
+				return append([]int{i}, path...), append([]string{ast.TypeName(children[i])}, names...)
+			}
+		}
+	}
+	return nil, nil
+}
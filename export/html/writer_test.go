@@ -0,0 +1,122 @@
+// export/html/writer_test.go
+// Output and export error tests.
+
+package html
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// A writer that fails once a number of bytes are written.
+type failingWriter struct {
+	left int
+}
+
+var errTestWrite = errors.New("disk full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.left {
+		return 0, errTestWrite
+	}
+	w.left -= len(p)
+	return len(p), nil
+}
+
+// Create a paragraph holding inline blocks.
+func testParagraph(content ...ast.InlineBlock) *ast.Paragraph {
+	return &ast.Paragraph{Content: content}
+}
+
+// Create an unsafe link.
+func testUnsafeLink() *ast.HyperlinkBlock {
+	return &ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{&ast.Text{Value: "x"}}}, Destination: "javascript:x"}
+}
+
+func TestNodeErrorPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []ast.Block
+		path    []int
+		message string
+	}{
+		{
+			name:    "paragraph",
+			content: []ast.Block{testParagraph(), testParagraph(&ast.Text{Value: "a"}, testUnsafeLink())},
+			path:    []int{1, 1},
+			message: "paragraph[1] > link[1]: unsafe url 'javascript:x'",
+		},
+		{
+			name:    "quote",
+			content: []ast.Block{&ast.Quote{Content: []ast.Block{testParagraph(testUnsafeLink())}}},
+			path:    []int{0, 0, 0},
+			message: "quote[0] > paragraph[0] > link[0]: unsafe url 'javascript:x'",
+		},
+		{
+			name: "collapse",
+			content: []ast.Block{&ast.Collapse{
+				Summary: []ast.InlineBlock{&ast.Text{Value: "s"}},
+				Content: []ast.Block{testParagraph(), testParagraph(testUnsafeLink())},
+			}, testParagraph(&ast.Text{Value: "after"})},
+			path:    []int{0, 2, 0},
+			message: "collapse[0] > paragraph[2] > link[0]: unsafe url 'javascript:x'",
+		},
+		{
+			name: "table",
+			content: []ast.Block{&ast.Table{Rows: []ast.TableRow{
+				{Cells: []ast.TableCell{{Content: []ast.Block{testParagraph()}}}},
+				{Cells: []ast.TableCell{{}, {Content: []ast.Block{testParagraph(testUnsafeLink())}}}},
+			}}},
+			path:    []int{0, 1, 1, 0, 0},
+			message: "table[0] > table-row[1] > table-cell[1] > paragraph[0] > link[0]: unsafe url 'javascript:x'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := strings.Builder{}
+			err := NewHTMLExporter(&out, HTMLSettings{Strict: true}).Export(&ast.Document{Content: test.content})
+			var e *NodeError
+			if !errors.As(err, &e) {
+				t.Fatalf("got %v, want a *NodeError", err)
+			}
+			if _, ok := e.Node.(*ast.HyperlinkBlock); !ok {
+				t.Errorf("got node %T, want the link", e.Node)
+			}
+			if !reflect.DeepEqual(e.Path, test.path) {
+				t.Errorf("got path %v, want %v", e.Path, test.path)
+			}
+			if err.Error() != test.message {
+				t.Errorf("got %q, want %q", err.Error(), test.message)
+			}
+			if strings.Contains(out.String(), "after") {
+				t.Errorf("export continued after the error:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	content := []ast.Block{}
+	for i := 0; i < 200; i++ {
+		content = append(content, &ast.Quote{Content: []ast.Block{testParagraph(&ast.Text{Value: "Some text in a paragraph."})}})
+	}
+	d := &ast.Document{Content: content}
+
+	err := NewHTMLExporter(&failingWriter{left: 5000}, HTMLSettings{}).Export(d)
+	if !errors.Is(err, errTestWrite) {
+		t.Fatalf("got %v, want the write error", err)
+	}
+	var e *NodeError
+	if !errors.As(err, &e) || len(e.Path) == 0 {
+		t.Errorf("got %v, want a *NodeError with a path", err)
+	}
+
+	if err := NewHTMLExporter(&failingWriter{left: 1 << 20}, HTMLSettings{}).Export(d); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
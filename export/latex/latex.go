@@ -0,0 +1,592 @@
+// export/latex/latex.go
+// Package latex provides functionality for exporting into LaTeX.
+
+package latex
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	"gopkg.in/go-playground/colors.v1"
+)
+
+// Packages in load order, with their options. Hyperref must be loaded last.
+var packages = [][2]string{
+	{"graphicx", ""},
+	{"xcolor", ""},
+	{"ulem", "normalem"},
+	{"relsize", ""},
+	{"wrapfig", ""},
+	{"hyperref", ""},
+}
+
+// Font family commands for common family names.
+var fontFamilies = map[string]string{
+	"serif":           `\rmfamily`,
+	"sans-serif":      `\sffamily`,
+	"sans":            `\sffamily`,
+	"monospace":       `\ttfamily`,
+	"computer modern": `\fontfamily{cmr}\selectfont`,
+	"times":           `\fontfamily{ptm}\selectfont`,
+	"times new roman": `\fontfamily{ptm}\selectfont`,
+	"helvetica":       `\fontfamily{phv}\selectfont`,
+	"arial":           `\fontfamily{phv}\selectfont`,
+	"courier":         `\fontfamily{pcr}\selectfont`,
+	"courier new":     `\fontfamily{pcr}\selectfont`,
+	"palatino":        `\fontfamily{ppl}\selectfont`,
+	"bookman":         `\fontfamily{pbk}\selectfont`,
+	"avant garde":     `\fontfamily{pag}\selectfont`,
+	"charter":         `\fontfamily{bch}\selectfont`,
+}
+
+// Escapes text for LaTeX.
+var textEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`^`, `\textasciicircum{}`,
+	`_`, `\_`,
+	`%`, `\%`,
+	`~`, `\textasciitilde{}`,
+	"\n", "\\newline\n",
+)
+
+// Escapes URLs for hyperref.
+var urlEscaper = strings.NewReplacer(
+	`\`, `%5C`,
+	`{`, `%7B`,
+	`}`, `%7D`,
+	`%`, `\%`,
+	`#`, `\#`,
+	`&`, `\&`,
+)
+
+// LaTeX exporter.
+type LaTeXExporter struct {
+	stream   io.Writer
+	settings LaTeXSettings
+
+	// The packages used by the document.
+	used map[string]bool
+
+	// The list and table nesting, which limits floats.
+	listDepth, tableDepth int
+}
+
+// Create a new LaTeX exporter.
+func NewLaTeXExporter(stream io.Writer, settings LaTeXSettings) *LaTeXExporter {
+	if settings.DocumentClass == "" {
+		settings.DocumentClass = DefaultDocumentClass
+	}
+	if settings.FigurePlacement == "" {
+		settings.FigurePlacement = DefaultFigurePlacement
+	}
+
+	return &LaTeXExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to LaTeX.
+func (l *LaTeXExporter) Export(d *ast.Document) error {
+	l.used = map[string]bool{}
+	body, err := l.exportBlocks(d.Content)
+	if err != nil {
+		return err
+	}
+
+	out := strings.Builder{}
+	if l.settings.BodyOnly {
+		if body != "" {
+			out.WriteString(body + "\n")
+		}
+		_, err = io.WriteString(l.stream, out.String())
+		return err
+	}
+
+	// Write the preamble.
+	out.WriteString("\\documentclass")
+	if l.settings.ClassOptions != "" {
+		out.WriteString("[" + l.settings.ClassOptions + "]")
+	}
+	out.WriteString("{" + l.settings.DocumentClass + "}\n")
+	if l.settings.Unicode {
+		out.WriteString("\\usepackage{fontspec}\n")
+	} else {
+		out.WriteString("\\usepackage[utf8]{inputenc}\n\\usepackage[T1]{fontenc}\n")
+	}
+	for _, p := range packages {
+		if !l.used[p[0]] {
+			continue
+		}
+		out.WriteString("\\usepackage")
+		if p[1] != "" {
+			out.WriteString("[" + p[1] + "]")
+		}
+		out.WriteString("{" + p[0] + "}\n")
+	}
+	if l.settings.Preamble != "" {
+		out.WriteString(strings.TrimRight(l.settings.Preamble, "\n") + "\n")
+	}
+
+	// Write the document information.
+	title := ""
+	if !l.settings.OmitTitle {
+		title = escapeLine(d.Title)
+	}
+	if !l.settings.OmitSubtitle && d.Subtitle != "" {
+		if title != "" {
+			title += "\\\\\n"
+		}
+		title += "\\large " + escapeLine(d.Subtitle)
+	}
+	date := ""
+	if !l.settings.OmitDate {
+		date = escapeLine(d.Date)
+	}
+	author := ""
+	if !l.settings.OmitAuthor {
+		author = escapeLine(d.Author)
+	}
+
+	// LaTeX requires a title for \maketitle, so one is always written with it.
+	hasTitle := title != "" || author != "" || date != ""
+	if hasTitle {
+		out.WriteString("\\title{" + title + "}\n")
+		if author != "" {
+			out.WriteString("\\author{" + author + "}\n")
+		}
+		out.WriteString("\\date{" + date + "}\n")
+	}
+
+	// Write the body.
+	out.WriteString("\n\\begin{document}\n")
+	if hasTitle {
+		out.WriteString("\\maketitle\n")
+	}
+	if body != "" {
+		out.WriteString("\n" + body + "\n")
+	}
+	out.WriteString("\n\\end{document}\n")
+
+	_, err = io.WriteString(l.stream, out.String())
+	return err
+}
+
+// Export a slice of blocks, separated by blank lines.
+func (l *LaTeXExporter) exportBlocks(blocks []ast.Block) (string, error) {
+	parts := make([]string, 0, len(blocks))
+	for i := range blocks {
+		part, err := l.exportBlock(blocks[i])
+		if err != nil {
+			return "", err
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// Export a block to LaTeX.
+func (l *LaTeXExporter) exportBlock(b ast.Block) (string, error) {
+	var out string
+	var err error
+
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		out, err = l.exportInlineBlocks(block.Content)
+		out = trimTrailingNewlines(out)
+		if strings.HasPrefix(out, "\\newline") {
+			out = "\\mbox{}" + out
+		}
+	case *ast.BasicBlock:
+		out, err = l.exportBlocks(block.Content)
+	case *ast.Quote:
+		out, err = l.exportBlocks(block.Content)
+		out = "\\begin{quote}\n" + out + "\n\\end{quote}"
+	case *ast.Image:
+		// Images align themselves.
+		return l.exportImage(block)
+	case *ast.Heading:
+		// Headings are not aligned.
+		return l.exportHeading(block)
+	case *ast.HorizontalRule:
+		out = "\\noindent\\rule{\\linewidth}{0.4pt}"
+	case *ast.List:
+		out, err = l.exportList(block)
+	case *ast.Table:
+		out, err = l.exportTable(block)
+	case *ast.Collapse:
+		// Collapse blocks are expanded.
+		var summary, content string
+		summary, err = l.exportInlineBlocks(block.Summary)
+		if err != nil {
+			return "", err
+		}
+		summary = trimTrailingNewlines(summary)
+		content, err = l.exportBlocks(block.Content)
+		out = "\\noindent\\textbf{" + summary + "}"
+		if content != "" {
+			out += "\n\n" + content
+		}
+	case *ast.PageBreak:
+		return "\\newpage", nil
+	case *ast.Notes:
+		// Speaker notes are left out.
+		return "", nil
+	default:
+		return "", errors.New("invalid ast")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if environment := alignmentEnvironment(b.GetAlignment()); environment != "" && out != "" {
+		out = "\\begin{" + environment + "}\n" + out + "\n\\end{" + environment + "}"
+	}
+	return out, nil
+}
+
+// Export a heading to a sectioning command.
+func (l *LaTeXExporter) exportHeading(block *ast.Heading) (string, error) {
+	commands := []string{"section", "subsection", "subsubsection", "paragraph", "subparagraph"}
+	if l.settings.DocumentClass == "report" || l.settings.DocumentClass == "book" {
+		commands = []string{"chapter", "section", "subsection", "subsubsection", "paragraph"}
+	}
+	if block.Class < ast.Heading1Type || block.Class > ast.Heading5Type {
+		return "", errors.New("invalid ast")
+	}
+	command := commands[block.Class-ast.Heading1Type]
+	if l.settings.UnnumberedSections {
+		command += "*"
+	}
+
+	content, err := l.exportInlineBlocks(block.Content)
+	if err != nil {
+		return "", err
+	}
+	return "\\" + command + "{" + strings.ReplaceAll(content, "\\newline\n", " ") + "}", nil
+}
+
+// Export an image block. Images are figures, wrapped figures when they are
+// wrapped, or plain graphics inside tables.
+func (l *LaTeXExporter) exportImage(block *ast.Image) (string, error) {
+	l.used["graphicx"] = true
+	options, err := graphicsOptions(block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+	if err != nil {
+		return "", err
+	}
+	source, err := graphicsSource(block.Source)
+	if err != nil {
+		return "", err
+	}
+	graphic := "\\includegraphics" + options + "{" + source + "}"
+
+	caption := ""
+	if block.HasCaption && len(block.Caption) != 0 {
+		caption, err = l.exportInlineBlocks(block.Caption)
+		if err != nil {
+			return "", err
+		}
+		caption = trimTrailingNewlines(caption)
+	}
+
+	// Floats are not allowed in tables.
+	if l.tableDepth > 0 {
+		if caption != "" {
+			graphic += "\\newline\n{\\small " + caption + "}"
+		}
+		return graphic, nil
+	}
+
+	var out string
+	side := map[ast.AlignmentType]string{ast.LeftAlign: "l", ast.RightAlign: "r"}[block.Alignment]
+	if block.Wrap && side != "" && l.listDepth == 0 {
+		l.used["wrapfig"] = true
+		width := "0.5\\linewidth"
+		if block.HasWidthParameter {
+			width, _ = length(block.WidthValue, block.WidthType, "\\linewidth")
+		}
+		out = "\\begin{wrapfigure}{" + side + "}{" + width + "}\n\\centering\n" + graphic
+		if caption != "" {
+			out += "\n\\caption{" + caption + "}"
+		}
+		return out + "\n\\end{wrapfigure}", nil
+	}
+
+	out = "\\begin{figure}[" + l.settings.FigurePlacement + "]\n"
+	switch block.Alignment {
+	case ast.LeftAlign:
+		out += "\\raggedright\n"
+	case ast.RightAlign:
+		out += "\\raggedleft\n"
+	default:
+		out += "\\centering\n"
+	}
+	out += graphic
+	if caption != "" {
+		out += "\n\\caption{" + caption + "}"
+	}
+	return out + "\n\\end{figure}", nil
+}
+
+// Export a list.
+func (l *LaTeXExporter) exportList(block *ast.List) (string, error) {
+	environment := "itemize"
+	if block.Ordered {
+		environment = "enumerate"
+	}
+
+	l.listDepth++
+	defer func() { l.listDepth-- }()
+	out := "\\begin{" + environment + "}"
+	for i := range block.Items {
+		item, err := l.exportBlock(block.Items[i])
+		if err != nil {
+			return "", err
+		}
+		out += "\n\\item"
+		if item != "" {
+			out += " " + item
+		}
+	}
+	return out + "\n\\end{" + environment + "}", nil
+}
+
+// Export a table to a tabular environment with equal paragraph columns.
+func (l *LaTeXExporter) exportTable(block *ast.Table) (string, error) {
+	columns := 0
+	for i := range block.Rows {
+		if len(block.Rows[i].Cells) > columns {
+			columns = len(block.Rows[i].Cells)
+		}
+	}
+	if columns == 0 {
+		return "", nil
+	}
+
+	l.tableDepth++
+	defer func() { l.tableDepth-- }()
+	column := "p{\\dimexpr\\linewidth/" + strconv.Itoa(columns) + "-2\\tabcolsep\\relax}"
+	out := "\\begin{tabular}{|" + strings.Repeat(column+"|", columns) + "}\n\\hline"
+	for i := range block.Rows {
+		cells := make([]string, columns)
+		for j, cell := range block.Rows[i].Cells {
+			content, err := l.exportBlocks(cell.Content)
+			if err != nil {
+				return "", err
+			}
+			if cell.IsHeader && content != "" {
+				content = "\\bfseries " + content
+			}
+			cells[j] = content
+		}
+		out += "\n" + strings.Join(cells, " & ") + " \\\\\n\\hline"
+	}
+	return out + "\n\\end{tabular}", nil
+}
+
+// Export a slice of inline blocks.
+func (l *LaTeXExporter) exportInlineBlocks(blocks []ast.InlineBlock) (string, error) {
+	out := strings.Builder{}
+	for i := range blocks {
+		part, err := l.exportInlineBlock(blocks[i])
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(part)
+	}
+	return out.String(), nil
+}
+
+// Export an inline block to LaTeX.
+func (l *LaTeXExporter) exportInlineBlock(b ast.InlineBlock) (string, error) {
+	if text, ok := b.(*ast.Text); ok {
+		return textEscaper.Replace(text.Value), nil
+	}
+	if image, ok := b.(*ast.InlineImageBlock); ok {
+		l.used["graphicx"] = true
+		options, err := graphicsOptions(image.HasWidthParameter, image.WidthValue, image.WidthType, image.HasHeightParameter, image.HeightValue, image.HeightType)
+		if err != nil {
+			return "", err
+		}
+		source, err := graphicsSource(image.Source)
+		if err != nil {
+			return "", err
+		}
+		return "\\includegraphics" + options + "{" + source + "}", nil
+	}
+
+	content, err := l.exportInlineBlocks(b.Children())
+	if err != nil {
+		return "", err
+	}
+	switch block := b.(type) {
+	case *ast.HyperlinkBlock:
+		l.used["hyperref"] = true
+		if content == "" {
+			return "\\url{" + urlEscaper.Replace(block.Destination) + "}", nil
+		}
+		return "\\href{" + urlEscaper.Replace(block.Destination) + "}{" + content + "}", nil
+	case *ast.FormattingBlock:
+		switch block.Attribute {
+		case ast.BoldFormatting:
+			return "\\textbf{" + content + "}", nil
+		case ast.ItalicFormatting:
+			return "\\textit{" + content + "}", nil
+		case ast.StrikethroughFormatting:
+			l.used["ulem"] = true
+			return "\\sout{" + content + "}", nil
+		case ast.UnderlineFormatting:
+			l.used["ulem"] = true
+			return "\\uline{" + content + "}", nil
+		case ast.TeletypeFormatting:
+			return "\\texttt{" + content + "}", nil
+		}
+	case *ast.ColorBlock:
+		l.used["xcolor"] = true
+		if block.ForegroundValue != nil {
+			content = "\\textcolor[HTML]{" + hexColor(block.ForegroundValue) + "}{" + content + "}"
+		}
+		if block.BackgroundValue != nil {
+			content = "\\colorbox[HTML]{" + hexColor(block.BackgroundValue) + "}{" + content + "}"
+		}
+		return content, nil
+	case *ast.SizeBlock:
+		if block.Type == ast.PercentageSizeType {
+			l.used["relsize"] = true
+			return "{\\relscale{" + formatFloat(block.Value/100) + "}" + content + "}", nil
+		}
+		size, err := length(block.Value, block.Type, "")
+		if err != nil {
+			return "", err
+		}
+		skip, _ := length(block.Value*1.2, block.Type, "")
+		return "{\\fontsize{" + size + "}{" + skip + "}\\selectfont " + content + "}", nil
+	case *ast.FontBlock:
+		command := l.fontCommand(block.Family)
+		if command == "" {
+			return content, nil
+		}
+		return "{" + command + " " + content + "}", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Get the command that selects a font family. Families are comma-separated
+// lists, as in CSS. Unknown families are ignored, unless fontspec is used.
+func (l *LaTeXExporter) fontCommand(family string) string {
+	names := strings.Split(family, ",")
+	for i := range names {
+		name := strings.Trim(strings.TrimSpace(names[i]), "\"'")
+		if command, ok := fontFamilies[strings.ToLower(name)]; ok {
+			return command
+		}
+	}
+	if l.settings.Unicode && len(names) > 0 {
+		return "\\fontspec{" + textEscaper.Replace(strings.Trim(strings.TrimSpace(names[0]), "\"'")) + "}"
+	}
+	return ""
+}
+
+// Get the environment for an alignment. Returns an empty string for no
+// alignment.
+func alignmentEnvironment(a ast.AlignmentType) string {
+	switch a {
+	case ast.LeftAlign:
+		return "flushleft"
+	case ast.RightAlign:
+		return "flushright"
+	case ast.CenterAlign:
+		return "center"
+	}
+	return ""
+}
+
+// Check an image source for includegraphics. File names can't be escaped,
+// so sources with braces, "%", "#" or "\\" are an error.
+func graphicsSource(src string) (string, error) {
+	if strings.ContainsAny(src, "{}%#\\") {
+		return "", errors.New("image '" + src + "': source contains characters that can't be used in latex")
+	}
+	return src, nil
+}
+
+// Get the includegraphics options for an image size.
+func graphicsOptions(hasWidth bool, width float32, widthType ast.SizeType, hasHeight bool, height float32, heightType ast.SizeType) (string, error) {
+	options := []string{}
+	if hasWidth {
+		w, err := length(width, widthType, "\\linewidth")
+		if err != nil {
+			return "", err
+		}
+		options = append(options, "width="+w)
+	}
+	if hasHeight {
+		h, err := length(height, heightType, "\\textheight")
+		if err != nil {
+			return "", err
+		}
+		options = append(options, "height="+h)
+	}
+	if len(options) == 0 {
+		return "", nil
+	}
+	return "[" + strings.Join(options, ",") + "]", nil
+}
+
+// Convert a size to a LaTeX length. Percentages are relative to a length.
+// CSS pixels are 0.75 big points and CSS points are big points.
+func length(value float32, t ast.SizeType, relative string) (string, error) {
+	switch t {
+	case ast.PercentageSizeType:
+		return formatFloat(value/100) + relative, nil
+	case ast.PixelSizeType:
+		return formatFloat(value*0.75) + "bp", nil
+	case ast.PointSizeType:
+		return formatFloat(value) + "bp", nil
+	case ast.CentimeterSizeType:
+		return formatFloat(value) + "cm", nil
+	case ast.MillimeterSizeType:
+		return formatFloat(value) + "mm", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Format a float to at most four decimal places, without trailing zeros.
+func formatFloat(f float32) string {
+	return strconv.FormatFloat(math.Round(float64(f)*10000)/10000, 'f', -1, 64)
+}
+
+// Get the hexadecimal value of a color for xcolor's HTML model.
+func hexColor(c colors.Color) string {
+	rgb := c.ToRGB()
+	return fmt.Sprintf("%02X%02X%02X", rgb.R, rgb.G, rgb.B)
+}
+
+// Escape a single line of text, like a title.
+func escapeLine(s string) string {
+	return strings.ReplaceAll(textEscaper.Replace(s), "\\newline\n", " ")
+}
+
+// Remove the line breaks at the end of exported text, including those inside
+// closing braces. LaTeX rejects a \newline with no line to end.
+func trimTrailingNewlines(s string) string {
+	for {
+		body := strings.TrimRight(s, "}")
+		if !strings.HasSuffix(body, "\\newline\n") {
+			return s
+		}
+		s = strings.TrimSuffix(body, "\\newline\n") + s[len(body):]
+	}
+}
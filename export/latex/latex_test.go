@@ -0,0 +1,162 @@
+// export/latex/latex_test.go
+// LaTeX exporter tests.
+
+package latex
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+	"github.com/cubeflix/cdf/export"
+)
+
+func TestExportTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		document ast.Document
+		settings export.Settings
+		want     string
+	}{
+		{
+			name:     "author only",
+			document: ast.Document{Author: "Jane"},
+			want:     "\\title{}\n\\author{Jane}\n\\date{}\n\n\\begin{document}\n\\maketitle\n",
+		},
+		{
+			name:     "date only",
+			document: ast.Document{Date: "2024"},
+			want:     "\\title{}\n\\date{2024}\n\n\\begin{document}\n\\maketitle\n",
+		},
+		{
+			name:     "omitted title",
+			document: ast.Document{Title: "T", Author: "Jane"},
+			settings: export.Settings{OmitTitle: true},
+			want:     "\\title{}\n\\author{Jane}\n\\date{}\n\n\\begin{document}\n\\maketitle\n",
+		},
+		{
+			name:     "title and author",
+			document: ast.Document{Title: "T", Author: "Jane", Date: "2024"},
+			want:     "\\title{T}\n\\author{Jane}\n\\date{2024}\n\n\\begin{document}\n\\maketitle\n",
+		},
+		{
+			name:     "no title",
+			document: ast.Document{},
+			want:     "\n\\begin{document}\n\n\\end{document}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := bytes.Buffer{}
+			if err := NewLaTeXExporter(&out, LaTeXSettings{Settings: test.settings}).Export(&test.document); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), test.want) {
+				t.Errorf("got:\n%s\nwant it to contain:\n%s", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestExportImageSource(t *testing.T) {
+	tests := []struct {
+		source string
+		ok     bool
+	}{
+		{"images/a.png", true},
+		{"a b.png", true},
+		{"a}.png", false},
+		{"a{.png", false},
+		{"a%.png", false},
+		{"a#.png", false},
+		{"a\\b.png", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			for _, d := range []*ast.Document{
+				{Content: []ast.Block{&ast.Image{Source: test.source}}},
+				{Content: []ast.Block{&ast.Paragraph{Content: []ast.InlineBlock{&ast.InlineImageBlock{Source: test.source}}}}},
+			} {
+				out := bytes.Buffer{}
+				err := NewLaTeXExporter(&out, LaTeXSettings{}).Export(d)
+				if test.ok && err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if !test.ok && err == nil {
+					t.Errorf("expected an error, got:\n%s", out.String())
+				}
+				if test.ok && !strings.Contains(out.String(), "\\includegraphics{"+test.source+"}") {
+					t.Errorf("missing graphic in:\n%s", out.String())
+				}
+			}
+		})
+	}
+}
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.Block
+		want  string
+	}{
+		{"escaping", &ast.Paragraph{Content: testText(`\{}$&#^_%~`)}, `\textbackslash{}\{\}\$\&\#\textasciicircum{}\_\%\textasciitilde{}`},
+		{"line break", &ast.Paragraph{Content: testText("a\nb")}, "a\\newline\nb"},
+		{"leading line break", &ast.Paragraph{Content: testText("\na")}, "\\mbox{}\\newline\na"},
+		{"trailing line break", &ast.Paragraph{Content: testText("a\n\n")}, "\n\na\n\n\\end{document}"},
+		{
+			"trailing line break in markup",
+			&ast.Paragraph{Content: []ast.InlineBlock{&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("a\n")}, Attribute: ast.BoldFormatting}}},
+			"\n\\textbf{a}\n\n\\end{document}",
+		},
+		{
+			"nested markup",
+			&ast.Paragraph{Content: []ast.InlineBlock{&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{
+				&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("a_b")}, Attribute: ast.ItalicFormatting},
+			}}, Attribute: ast.BoldFormatting}}},
+			`\textbf{\textit{a\_b}}`,
+		},
+		{
+			"link",
+			&ast.Paragraph{Content: []ast.InlineBlock{&ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("a&b")}, Destination: "https://example.com/?a=1&b=50%#c"}}},
+			`\href{https://example.com/?a=1\&b=50\%\#c}{a\&b}`,
+		},
+		{
+			"list",
+			&ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}},
+			"\\begin{enumerate}\n\\item a\n\\item \\begin{itemize}\n\\item b\n\\end{itemize}\n\\end{enumerate}",
+		},
+		{
+			"table",
+			&ast.Table{Rows: []ast.TableRow{
+				{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("a&b")}}, IsHeader: true}, {}}},
+				{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("c\n")}}}}},
+			}},
+			"\\hline\n\\bfseries a\\&b &  \\\\\n\\hline\nc &  \\\\\n\\hline",
+		},
+		{
+			"image caption",
+			&ast.Image{Source: "missing.png", HasCaption: true, Caption: testText("a%\n")},
+			"\\includegraphics{missing.png}\n\\caption{a\\%}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := bytes.Buffer{}
+			if err := NewLaTeXExporter(&out, LaTeXSettings{}).Export(&ast.Document{Content: []ast.Block{test.block}}); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), test.want) {
+				t.Errorf("got:\n%s\nwant it to contain:\n%s", out.String(), test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// export/latex/settings.go
+// LaTeX export settings.
+
+package latex
+
+import "github.com/cubeflix/cdf/export"
+
+const (
+	DefaultDocumentClass   = "article"
+	DefaultFigurePlacement = "htbp"
+)
+
+// LaTeX export settings.
+type LaTeXSettings struct {
+	export.Settings
+
+	// The document class and its options, like "11pt,a4paper". The class
+	// defaults to "article". With "report" or "book", level 1 headings are
+	// chapters.
+	DocumentClass string
+	ClassOptions  string
+
+	// LaTeX inserted into the preamble after the packages.
+	Preamble string
+
+	// Write only the document body, without the preamble and document
+	// environment, for including into another file.
+	BodyOnly bool
+
+	// Use unnumbered sectioning commands.
+	UnnumberedSections bool
+
+	// Load fontspec instead of inputenc and fontenc, for XeLaTeX and
+	// LuaLaTeX.
+	Unicode bool
+
+	// The placement of image figures. Defaults to "htbp".
+	FigurePlacement string
+}
@@ -0,0 +1,549 @@
+// export/man/man.go
+// Package man provides functionality for exporting into roff man pages.
+
+package man
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Escapes text for roff. Hyphens are escaped so options can be copied from
+// the page.
+var textEscaper = strings.NewReplacer(
+	`\`, `\e`,
+	`-`, `\-`,
+	"\t", " ",
+)
+
+// Runs of spaces, which roff would keep.
+var spaces = regexp.MustCompile(`  +`)
+
+// Matches lines of font changes only.
+var fontEscapes = regexp.MustCompile(`^(\\f(\[[A-Z]*\]|[A-Z]))+$`)
+
+// Layout properties inherited by nested blocks.
+type context struct {
+	// The request starting a paragraph: ".PP", or ".IP" to keep the
+	// indentation of a list item. Empty in table cells.
+	paragraph string
+
+	// Whether the block is inside a list.
+	list bool
+
+	// Whether the block is indented, where sections can't start.
+	indented bool
+}
+
+// The current font.
+type font struct {
+	bold, italic, teletype bool
+}
+
+// Man page exporter.
+type ManExporter struct {
+	stream   io.Writer
+	settings ManSettings
+
+	// The font of inline text being exported.
+	font font
+
+	// Whether the page needs the tbl preprocessor.
+	tables bool
+}
+
+// Create a new man page exporter.
+func NewManExporter(stream io.Writer, settings ManSettings) *ManExporter {
+	if settings.Section == "" {
+		settings.Section = DefaultSection
+	}
+
+	return &ManExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to a man page.
+func (m *ManExporter) Export(d *ast.Document) error {
+	m.font = font{}
+	m.tables = false
+	body, err := m.exportBlocks(d.Content, context{paragraph: ".PP"})
+	if err != nil {
+		return err
+	}
+
+	out := strings.Builder{}
+	if m.tables {
+		// Tell man to run tbl.
+		out.WriteString("'\\\" t\n")
+	}
+
+	// Write the title line.
+	title, date, manual := "", "", m.settings.Manual
+	if !m.settings.OmitTitle {
+		title = d.Title
+	}
+	if !m.settings.OmitDate {
+		date = d.Date
+	}
+	if manual == "" && !m.settings.OmitSubtitle {
+		manual = d.Subtitle
+	}
+	out.WriteString(".TH")
+	for _, field := range []string{title, m.settings.Section, date, m.settings.Source, manual} {
+		out.WriteString(" " + argument(field))
+	}
+	out.WriteString("\n")
+
+	if body != "" {
+		out.WriteString(body + "\n")
+	}
+	if !m.settings.OmitAuthor && d.Author != "" {
+		out.WriteString(".SH AUTHOR\n" + escapeLine(d.Author) + "\n")
+	}
+
+	_, err = io.WriteString(m.stream, out.String())
+	return err
+}
+
+// Export a slice of blocks.
+func (m *ManExporter) exportBlocks(blocks []ast.Block, ctx context) (string, error) {
+	parts := make([]string, 0, len(blocks))
+	for i := range blocks {
+		part, err := m.exportBlock(blocks[i], ctx)
+		if err != nil {
+			return "", err
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	// Table cells have no paragraphs, so their blocks are broken apart.
+	if ctx.paragraph == "" {
+		return strings.Join(parts, "\n.br\n"), nil
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// Export a block to roff.
+func (m *ManExporter) exportBlock(b ast.Block, ctx context) (string, error) {
+	var out string
+	var err error
+
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		out, err = m.inline(block.Content)
+		if out != "" {
+			out = paragraph(ctx, out)
+		}
+	case *ast.BasicBlock:
+		out, err = m.exportBlocks(block.Content, ctx)
+	case *ast.Quote:
+		out, err = m.exportBlocks(block.Content, context{paragraph: ".PP", indented: true})
+		if out != "" {
+			out = ".RS 4\n" + out + "\n.RE"
+		}
+	case *ast.Image:
+		// Images are replaced by their caption or alternate text.
+		label := escapeLine(block.Alt)
+		if block.HasCaption && len(block.Caption) != 0 {
+			label, err = m.inline(block.Caption)
+			if err != nil {
+				return "", err
+			}
+		}
+		if label == "" {
+			label = escapeLine(block.Source)
+		}
+		out = paragraph(ctx, "["+label+"]")
+	case *ast.Heading:
+		// Headings are not aligned.
+		return m.exportHeading(block, ctx)
+	case *ast.HorizontalRule:
+		out = paragraph(ctx, `\l'\n(.lu-\n(.iu'`)
+	case *ast.List:
+		out, err = m.exportList(block, ctx)
+	case *ast.Table:
+		out, err = m.exportTable(block, ctx)
+	case *ast.Collapse:
+		// Collapse blocks are expanded.
+		var summary, content string
+		summary, err = m.styled(block.Summary, func(f *font) { f.bold = true })
+		if err != nil {
+			return "", err
+		}
+		content, err = m.exportBlocks(block.Content, ctx)
+		out = paragraph(ctx, summary)
+		if content != "" {
+			out += "\n" + content
+		}
+	case *ast.PageBreak:
+		return ".bp", nil
+	case *ast.Notes:
+		// Speaker notes are left out.
+		return "", nil
+	default:
+		return "", errors.New("invalid ast")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if adjust := adjustment(b.GetAlignment()); adjust != "" && out != "" && ctx.paragraph != "" {
+		out = ".ad " + adjust + "\n" + out + "\n.br\n.ad"
+	}
+	return out, nil
+}
+
+// Export a heading. Level 1 and 2 headings are sections and subsections,
+// and deeper headings are bold paragraphs.
+func (m *ManExporter) exportHeading(block *ast.Heading, ctx context) (string, error) {
+	if block.Class < ast.Heading1Type || block.Class > ast.Heading5Type {
+		return "", errors.New("invalid ast")
+	}
+	if block.Class > ast.Heading2Type || ctx.indented || ctx.paragraph == "" {
+		content, err := m.styled(block.Content, func(f *font) { f.bold = true })
+		if err != nil || content == "" {
+			return "", err
+		}
+		return paragraph(ctx, oneLine(content)), nil
+	}
+
+	// Empty headings would start empty sections.
+	content, err := m.inline(block.Content)
+	if err != nil || content == "" {
+		return "", err
+	}
+	request := ".SH"
+	if block.Class == ast.Heading2Type {
+		request = ".SS"
+	}
+	return request + "\n" + oneLine(content), nil
+}
+
+// Export a list. Items are indented paragraphs tagged with a bullet or
+// number. Items of unordered lists that are blocks starting with a
+// paragraph use the paragraph as the tag, for lists of options.
+func (m *ManExporter) exportList(block *ast.List, ctx context) (string, error) {
+	items := make([]string, 0, len(block.Items))
+	width := "2"
+	if block.Ordered {
+		width = strconv.Itoa(len(strconv.Itoa(len(block.Items))) + 2)
+	}
+	number := 0
+	inner := context{paragraph: ".IP", list: true, indented: true}
+
+	for i := range block.Items {
+		// Nested lists are indented under the previous item.
+		if nested, ok := block.Items[i].(*ast.List); ok {
+			item, err := m.exportList(nested, inner)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, item)
+			continue
+		}
+
+		if basic, ok := block.Items[i].(*ast.BasicBlock); ok && !block.Ordered && len(basic.Content) > 1 {
+			if term, ok := basic.Content[0].(*ast.Paragraph); ok {
+				tag, err := m.inline(term.Content)
+				if err != nil {
+					return "", err
+				}
+				content, err := m.exportBlocks(basic.Content[1:], inner)
+				if err != nil {
+					return "", err
+				}
+				items = append(items, ".TP\n"+oneLine(tag)+"\n"+strings.TrimPrefix(content, ".IP\n"))
+				continue
+			}
+		}
+
+		item, err := m.exportBlock(block.Items[i], inner)
+		if err != nil {
+			return "", err
+		}
+		number++
+		tag := `\(bu`
+		if block.Ordered {
+			tag = strconv.Itoa(number) + "."
+		}
+		items = append(items, ".IP "+tag+" "+width+"\n"+strings.TrimPrefix(item, ".IP\n"))
+	}
+
+	out := strings.Join(items, "\n")
+	if ctx.list {
+		out = ".RS\n" + out + "\n.RE"
+	}
+	return out, nil
+}
+
+// Export a table for tbl. Every cell is boxed, and header cells are bold.
+func (m *ManExporter) exportTable(block *ast.Table, ctx context) (string, error) {
+	columns := 0
+	for i := range block.Rows {
+		if len(block.Rows[i].Cells) > columns {
+			columns = len(block.Rows[i].Cells)
+		}
+	}
+	if columns == 0 {
+		return "", nil
+	}
+	m.tables = true
+
+	options := "allbox"
+	if block.Alignment == ast.CenterAlign {
+		options += " center"
+	}
+	formats := make([]string, len(block.Rows))
+	rows := make([]string, len(block.Rows))
+	for i := range block.Rows {
+		format := make([]string, columns)
+		cells := make([]string, columns)
+		for j := range format {
+			format[j] = "l"
+			if j >= len(block.Rows[i].Cells) {
+				continue
+			}
+			cell := block.Rows[i].Cells[j]
+			if cell.IsHeader {
+				format[j] = "lB"
+			}
+			previous := m.font
+			m.font.bold = cell.IsHeader
+			content, err := m.exportBlocks(cell.Content, context{})
+			m.font = previous
+			if err != nil {
+				return "", err
+			}
+
+			// Multi-line cells are text blocks, and cells holding only a
+			// line character would draw a line.
+			if strings.Contains(content, "\n") {
+				content = "T{\n" + content + "\nT}"
+			} else if content == "_" || content == "=" {
+				content = `\&` + content
+			}
+			cells[j] = content
+		}
+		formats[i] = strings.Join(format, " ")
+		rows[i] = strings.Join(cells, "\t")
+	}
+
+	out := ".TS\n" + options + ";\n" + strings.Join(formats, "\n") + ".\n" + strings.Join(rows, "\n") + "\n.TE"
+	if ctx.paragraph != "" {
+		out = ctx.paragraph + "\n" + out
+	}
+	return out, nil
+}
+
+// Export inline blocks in a changed font.
+func (m *ManExporter) styled(blocks []ast.InlineBlock, change func(*font)) (string, error) {
+	previous := m.font
+	change(&m.font)
+	current := m.font
+	content, err := m.inline(blocks)
+	m.font = previous
+	if err != nil || content == "" {
+		return content, err
+	}
+	return m.fontEscape(current) + content + m.fontEscape(previous), nil
+}
+
+// Export inline blocks as lines of text and breaks.
+func (m *ManExporter) inline(blocks []ast.InlineBlock) (string, error) {
+	content, err := m.exportInlineBlocks(blocks)
+	if err != nil {
+		return "", err
+	}
+
+	// Spaces at the start of a line would break it, and empty lines would
+	// leave a blank line. Breaks with no text before them are dropped, so
+	// consecutive line breaks collapse into one.
+	lines := []string{}
+	text := false
+	for _, l := range strings.Split(spaces.ReplaceAllString(content, " "), "\n") {
+		l = strings.TrimSpace(l)
+		switch {
+		case l == "":
+			continue
+		case l == ".br" && !text:
+			continue
+		case l == ".br":
+			text = false
+		case !fontEscapes.MatchString(l):
+			text = true
+		}
+		lines = append(lines, l)
+	}
+
+	// Drop a break at the end, which the next paragraph makes.
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i] == ".br" {
+			lines = append(lines[:i], lines[i+1:]...)
+			break
+		}
+		if !fontEscapes.MatchString(lines[i]) {
+			break
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Export a slice of inline blocks.
+func (m *ManExporter) exportInlineBlocks(blocks []ast.InlineBlock) (string, error) {
+	out := strings.Builder{}
+	for i := range blocks {
+		part, err := m.exportInlineBlock(blocks[i])
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(part)
+	}
+	return out.String(), nil
+}
+
+// Export an inline block to roff.
+func (m *ManExporter) exportInlineBlock(b ast.InlineBlock) (string, error) {
+	switch block := b.(type) {
+	case *ast.Text:
+		return escapeText(block.Value), nil
+	case *ast.InlineImageBlock:
+		if block.Alt == "" {
+			return "", nil
+		}
+		return "[" + escapeText(block.Alt) + "]", nil
+	case *ast.HyperlinkBlock:
+		// Links are followed by their destination.
+		content, err := m.exportInlineBlocks(block.Content)
+		if err != nil {
+			return "", err
+		}
+		dest := escapeLine(block.Destination)
+		if content == "" || content == dest {
+			return dest, nil
+		}
+		return content + " <" + dest + ">", nil
+	case *ast.FormattingBlock:
+		previous := m.font
+		switch block.Attribute {
+		case ast.BoldFormatting:
+			m.font.bold = true
+		case ast.ItalicFormatting, ast.UnderlineFormatting:
+			// Italics are underlined on terminals.
+			m.font.italic = true
+		case ast.TeletypeFormatting:
+			m.font.teletype = true
+		case ast.StrikethroughFormatting:
+		default:
+			return "", errors.New("invalid ast")
+		}
+		current := m.font
+		content, err := m.exportInlineBlocks(block.Content)
+		m.font = previous
+		if err != nil || content == "" {
+			return "", err
+		}
+		if m.fontEscape(current) == m.fontEscape(previous) {
+			return content, nil
+		}
+		return m.fontEscape(current) + content + m.fontEscape(previous), nil
+	case *ast.ColorBlock, *ast.SizeBlock, *ast.FontBlock:
+		// Colors, sizes and fonts are dropped.
+		return m.exportInlineBlocks(block.Children())
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Get the escape selecting a font. Teletype text is bold unless the
+// constant width font is used.
+func (m *ManExporter) fontEscape(f font) string {
+	name := ""
+	if f.teletype {
+		if m.settings.Teletype == ConstantWidthTeletype {
+			name = "C"
+		} else {
+			f.bold = true
+		}
+	}
+	if f.bold {
+		name += "B"
+	}
+	if f.italic {
+		name += "I"
+	}
+	switch name {
+	case "":
+		return `\fR`
+	case "C":
+		return `\f[CR]`
+	case "B", "I":
+		return `\f` + name
+	}
+	return `\f[` + name + `]`
+}
+
+// Start a paragraph, unless the block is in a table cell.
+func paragraph(ctx context, content string) string {
+	if ctx.paragraph == "" {
+		return content
+	}
+	return ctx.paragraph + "\n" + content
+}
+
+// Get the adjustment mode for an alignment. Returns an empty string for no
+// alignment.
+func adjustment(a ast.AlignmentType) string {
+	switch a {
+	case ast.LeftAlign:
+		return "l"
+	case ast.RightAlign:
+		return "r"
+	case ast.CenterAlign:
+		return "c"
+	}
+	return ""
+}
+
+// Escape text. Line breaks become breaks.
+func escapeText(s string) string {
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		lines[i] = textEscaper.Replace(lines[i])
+
+		// A period or apostrophe at the start of a line would be read as a
+		// request.
+		if strings.HasPrefix(strings.TrimLeft(lines[i], " "), ".") || strings.HasPrefix(strings.TrimLeft(lines[i], " "), "'") {
+			lines[i] = `\&` + strings.TrimLeft(lines[i], " ")
+		}
+	}
+	return strings.Join(lines, "\n.br\n")
+}
+
+// Escape a single line of text, like the author.
+func escapeLine(s string) string {
+	return oneLine(escapeText(s))
+}
+
+// Join exported lines onto one line, dropping breaks.
+func oneLine(s string) string {
+	lines := []string{}
+	for _, l := range strings.Split(s, "\n") {
+		if l != ".br" && l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+// Quote a macro argument.
+func argument(s string) string {
+	return `"` + strings.ReplaceAll(escapeLine(s), `"`, `\(dq`) + `"`
+}
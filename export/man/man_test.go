@@ -0,0 +1,68 @@
+// export/man/man_test.go
+// Man page export tests.
+
+package man
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+// Create a formatting block.
+func testFormat(attribute ast.FormattingType, content ...ast.InlineBlock) ast.InlineBlock {
+	return &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: attribute}
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		blocks []ast.Block
+		want   string
+	}{
+		{"escaping", []ast.Block{&ast.Paragraph{Content: testText(".a\n'b \\c -d")}}, ".PP\n\\&.a\n.br\n\\&'b \\ec \\-d\n"},
+		{"line breaks", []ast.Block{&ast.Paragraph{Content: testText("\na\n\n\nb\n")}}, ".PP\na\n.br\nb\n"},
+		{"line breaks in markup", []ast.Block{&ast.Paragraph{Content: []ast.InlineBlock{&ast.Text{Value: "a\n"}, testFormat(ast.BoldFormatting, &ast.Text{Value: "\nb\n"}), &ast.Text{Value: "\n"}}}}, ".PP\na\n.br\n\\fB\nb\n\\fR\n"},
+		{"nested markup", []ast.Block{&ast.Paragraph{Content: []ast.InlineBlock{testFormat(ast.BoldFormatting, &ast.Text{Value: "a"}, testFormat(ast.ItalicFormatting, &ast.Text{Value: "b"}))}}}, ".PP\n\\fBa\\f[BI]b\\fB\\fR\n"},
+		{
+			"empty headings",
+			[]ast.Block{
+				&ast.Heading{Class: ast.Heading1Type},
+				&ast.Heading{Class: ast.Heading2Type, Content: []ast.InlineBlock{testFormat(ast.BoldFormatting), &ast.Text{Value: "\n"}}},
+				&ast.Heading{Class: ast.Heading3Type},
+				&ast.Heading{Class: ast.Heading2Type, Content: testText("a")},
+			},
+			".SS\na\n",
+		},
+		{
+			"list",
+			[]ast.Block{&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}}},
+			".IP \\(bu 2\na\n.RS\n.IP 1. 3\nb\n.RE\n",
+		},
+		{
+			"table",
+			[]ast.Block{&ast.Table{Rows: []ast.TableRow{{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("a\n\nb")}}, IsHeader: true}, {}}}}}},
+			".PP\n.TS\nallbox;\nlB l.\nT{\na\n.br\nb\nT}\t\n.TE\n",
+		},
+		{"missing image", []ast.Block{&ast.Image{Source: "missing.png", Alt: "a"}}, ".PP\n[a]\n"},
+	}
+	for _, test := range tests {
+		out := strings.Builder{}
+		if err := NewManExporter(&out, ManSettings{}).Export(&ast.Document{Content: test.blocks}); err != nil {
+			t.Fatal(err)
+		}
+		// Skip the title line.
+		got := out.String()
+		got = got[strings.Index(got, ".TH "):]
+		got = got[strings.Index(got, "\n")+1:]
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// export/man/settings.go
+// Man page export settings.
+
+package man
+
+import "github.com/cubeflix/cdf/export"
+
+// The default manual section.
+const DefaultSection = "1"
+
+// Man page export settings.
+type ManSettings struct {
+	export.Settings
+
+	// The manual section, like "1" for commands. Defaults to 1.
+	Section string
+
+	// The source of the page, like "cdf 1.0", shown in the footer.
+	Source string
+
+	// The title of the manual, shown in the header. Defaults to the
+	// document's subtitle.
+	Manual string
+
+	// The font for teletype text.
+	Teletype TeletypeStyle
+}
+
+// Teletype text style.
+type TeletypeStyle int64
+
+const (
+	// Bold, the usual style for literal text in man pages read on a
+	// terminal.
+	BoldTeletype TeletypeStyle = iota
+
+	// The constant width font, for typeset output.
+	ConstantWidthTeletype
+)
@@ -0,0 +1,552 @@
+// export/markdown/markdown.go
+// Package markdown provides functionality for exporting into Markdown.
+
+package markdown
+
+import (
+	"errors"
+	gohtml "html"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	"github.com/cubeflix/cdf/export"
+	"github.com/cubeflix/cdf/export/html"
+)
+
+// Markdown exporter.
+type MarkdownExporter struct {
+	stream   io.Writer
+	settings MarkdownSettings
+
+	// If the exporter is writing a pipe table's cell, where '|' ends the
+	// cell.
+	inTable bool
+}
+
+// Create a new Markdown exporter.
+func NewMarkdownExporter(stream io.Writer, settings MarkdownSettings) *MarkdownExporter {
+	return &MarkdownExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to Markdown.
+func (m *MarkdownExporter) Export(d *ast.Document) error {
+	out := strings.Builder{}
+
+	// Write the document information.
+	fields := [][2]string{}
+	if !m.settings.OmitTitle && d.Title != "" {
+		fields = append(fields, [2]string{"title", d.Title})
+	}
+	if !m.settings.OmitSubtitle && d.Subtitle != "" {
+		fields = append(fields, [2]string{"subtitle", d.Subtitle})
+	}
+	if !m.settings.OmitDate && d.Date != "" {
+		fields = append(fields, [2]string{"date", d.Date})
+	}
+	if !m.settings.OmitAuthor && d.Author != "" {
+		fields = append(fields, [2]string{"author", d.Author})
+	}
+	if len(fields) != 0 {
+		if m.settings.FrontMatter {
+			out.WriteString("---\n")
+			for i := range fields {
+				out.WriteString(fields[i][0] + ": " + strconv.Quote(fields[i][1]) + "\n")
+			}
+			out.WriteString("---\n\n")
+		} else {
+			for i := range fields {
+				switch fields[i][0] {
+				case "title":
+					out.WriteString("# ")
+				case "subtitle":
+					out.WriteString("## ")
+				default:
+					out.WriteString("### ")
+				}
+				out.WriteString(escapeText(fields[i][1]) + "\n\n")
+			}
+			out.WriteString("---\n\n")
+		}
+	}
+
+	// Write the content.
+	content, err := m.exportBlocks(d.Content)
+	if err != nil {
+		return err
+	}
+	if content != "" {
+		out.WriteString(content + "\n")
+	}
+
+	_, err = io.WriteString(m.stream, out.String())
+	return err
+}
+
+// Export a slice of blocks, separated by blank lines.
+func (m *MarkdownExporter) exportBlocks(blocks []ast.Block) (string, error) {
+	parts := make([]string, 0, len(blocks))
+	for i := range blocks {
+		part, err := m.exportBlock(blocks[i])
+		if err != nil {
+			return "", err
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// Export a block to Markdown.
+func (m *MarkdownExporter) exportBlock(b ast.Block) (string, error) {
+	var out string
+	var err error
+
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		out, err = m.exportInlineBlocks(block.Content)
+		out = escapeLineStarts(trimHardBreaks(out))
+	case *ast.BasicBlock:
+		out, err = m.exportBlocks(block.Content)
+	case *ast.Quote:
+		out, err = m.exportBlocks(block.Content)
+		out = prefixLines(out, "> ", "> ")
+	case *ast.Image:
+		out, err = m.exportImage(block.Source, block.Alt, block.Caption, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+		if err != nil {
+			return "", err
+		}
+		if block.HasCaption && len(block.Caption) != 0 {
+			caption, err := m.exportInlineBlocks(block.Caption)
+			if err != nil {
+				return "", err
+			}
+			caption = strings.TrimSpace(caption)
+			if caption != "" {
+				out += "\\\n*" + caption + "*"
+			}
+		}
+	case *ast.Heading:
+		if block.Class < ast.Heading1Type || block.Class > ast.Heading5Type {
+			return "", errors.New("invalid ast")
+		}
+		out, err = m.exportInlineBlocks(block.Content)
+		out = strings.Repeat("#", int(block.Class-ast.Heading1Type)+1) + " " + strings.ReplaceAll(strings.ReplaceAll(out, "\\\n", " "), "\n", " ")
+	case *ast.HorizontalRule:
+		out = "---"
+	case *ast.List:
+		out, err = m.exportList(block)
+	case *ast.Table:
+		out, err = m.exportTable(block)
+	case *ast.Collapse:
+		var content string
+		content, err = m.exportBlocks(block.Content)
+		out = "<details>\n<summary>" + gohtml.EscapeString(ast.PlainText(block.Summary)) + "</summary>\n\n" + content + "\n\n</details>"
+	case *ast.PageBreak:
+		if m.settings.Degrade == HTMLDegrade {
+			out = "<div style=\"page-break-after: always;\"></div>"
+		}
+	case *ast.Notes:
+		// Speaker notes are left out.
+		return "", nil
+	default:
+		return "", errors.New("invalid ast")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Degrade the alignment.
+	if m.settings.Degrade == HTMLDegrade && out != "" {
+		switch b.GetAlignment() {
+		case ast.LeftAlign:
+			out = "<div align=\"left\">\n\n" + out + "\n\n</div>"
+		case ast.RightAlign:
+			out = "<div align=\"right\">\n\n" + out + "\n\n</div>"
+		case ast.CenterAlign:
+			out = "<div align=\"center\">\n\n" + out + "\n\n</div>"
+		}
+	}
+	return out, nil
+}
+
+// Export a list.
+func (m *MarkdownExporter) exportList(block *ast.List) (string, error) {
+	items := make([]string, 0, len(block.Items))
+	loose := false
+	for i := range block.Items {
+		item, err := m.exportBlock(block.Items[i])
+		if err != nil {
+			return "", err
+		}
+		marker := "- "
+		if block.Ordered {
+			marker = strconv.Itoa(i+1) + ". "
+		}
+		if strings.Contains(item, "\n\n") {
+			loose = true
+		}
+		items = append(items, prefixLines(item, marker, strings.Repeat(" ", len(marker))))
+	}
+	if loose {
+		return strings.Join(items, "\n\n"), nil
+	}
+	return strings.Join(items, "\n"), nil
+}
+
+// Export a table. Tables of simple cells are written as GFM pipe tables,
+// other tables are written as HTML.
+func (m *MarkdownExporter) exportTable(block *ast.Table) (string, error) {
+	if m.settings.Flavor != GFMFlavor || !isSimpleTable(block) {
+		return m.exportHTMLBlock(block)
+	}
+
+	// Get the cell content.
+	columns := 0
+	rows := make([][]string, 0, len(block.Rows))
+	for i := range block.Rows {
+		row := make([]string, 0, len(block.Rows[i].Cells))
+		for j := range block.Rows[i].Cells {
+			var cell string
+			if len(block.Rows[i].Cells[j].Content) != 0 {
+				var err error
+				m.inTable = true
+				cell, err = m.exportInlineBlocks(block.Rows[i].Cells[j].Content[0].(*ast.Paragraph).Content)
+				m.inTable = false
+				if err != nil {
+					return "", err
+				}
+				cell = trimHardBreaks(cell)
+				if m.settings.Degrade == HTMLDegrade {
+					cell = strings.ReplaceAll(cell, "\\\n", "<br>")
+				} else {
+					cell = strings.ReplaceAll(cell, "\\\n", " ")
+				}
+			}
+			row = append(row, strings.TrimSpace(cell))
+		}
+		if len(row) > columns {
+			columns = len(row)
+		}
+		rows = append(rows, row)
+	}
+	if columns == 0 {
+		return "", nil
+	}
+
+	// Write the header. Tables without a header row get an empty header.
+	out := strings.Builder{}
+	writeRow := func(row []string) {
+		out.WriteString("|")
+		for i := 0; i < columns; i++ {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			out.WriteString(" " + cell + " |")
+		}
+		out.WriteString("\n")
+	}
+	hasHeader := len(block.Rows[0].Cells) != 0
+	for i := range block.Rows[0].Cells {
+		if !block.Rows[0].Cells[i].IsHeader {
+			hasHeader = false
+		}
+	}
+	if hasHeader {
+		writeRow(rows[0])
+		rows = rows[1:]
+	} else {
+		writeRow(nil)
+	}
+	out.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+	for i := range rows {
+		writeRow(rows[i])
+	}
+	return strings.TrimRight(out.String(), "\n"), nil
+}
+
+// Check if a table's cells each contain at most one paragraph.
+func isSimpleTable(block *ast.Table) bool {
+	if len(block.Rows) == 0 {
+		return false
+	}
+	for i := range block.Rows {
+		for j := range block.Rows[i].Cells {
+			content := block.Rows[i].Cells[j].Content
+			if len(content) > 1 {
+				return false
+			}
+			if len(content) == 1 {
+				if _, ok := content[0].(*ast.Paragraph); !ok {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Export a block as HTML, using the HTML exporter.
+func (m *MarkdownExporter) exportHTMLBlock(b ast.Block) (string, error) {
+	out := strings.Builder{}
+	h := html.NewHTMLExporter(&out, html.HTMLSettings{
+		Settings: export.Settings{OmitTitle: true, OmitSubtitle: true, OmitDate: true, OmitAuthor: true},
+	})
+	if err := h.Export(&ast.Document{Content: []ast.Block{b}}); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(out.String(), "\n"), nil
+}
+
+// Export an image.
+func (m *MarkdownExporter) exportImage(src, alt string, caption []ast.InlineBlock, hasWidth bool, widthValue float32, widthType ast.SizeType, hasHeight bool, heightValue float32, heightType ast.SizeType) (string, error) {
+	if alt == "" {
+		alt = ast.PlainText(caption)
+	}
+	if m.settings.Degrade == HTMLDegrade && (hasWidth || hasHeight) {
+		// Write the sized image as HTML.
+		var style string
+		if hasWidth {
+			size, err := cssSize(widthValue, widthType)
+			if err != nil {
+				return "", err
+			}
+			style += "width: " + size + ";"
+		}
+		if hasHeight {
+			size, err := cssSize(heightValue, heightType)
+			if err != nil {
+				return "", err
+			}
+			style += "height: " + size + ";"
+		}
+		return "<img src=\"" + m.escapeAttribute(src) + "\" alt=\"" + m.escapeAttribute(alt) + "\" style=\"" + style + "\">", nil
+	}
+	return "![" + escapeText(alt) + "](" + m.formatDestination(src) + ")", nil
+}
+
+// Export a slice of inline blocks.
+func (m *MarkdownExporter) exportInlineBlocks(blocks []ast.InlineBlock) (string, error) {
+	out := strings.Builder{}
+	for i := range blocks {
+		s, err := m.exportInlineBlock(blocks[i])
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(s)
+	}
+	return out.String(), nil
+}
+
+// Export an inline block to Markdown.
+func (m *MarkdownExporter) exportInlineBlock(b ast.InlineBlock) (string, error) {
+	switch block := b.(type) {
+	case *ast.Text:
+		return strings.ReplaceAll(escapeText(block.Value), "\n", "\\\n"), nil
+	case *ast.InlineImageBlock:
+		return m.exportImage(block.Source, block.Alt, nil, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+	}
+
+	if f, ok := b.(*ast.FormattingBlock); ok && f.Attribute == ast.TeletypeFormatting {
+		// Code spans cannot contain formatting. GFM allows escaped pipes in
+		// code spans in tables.
+		span := codeSpan(ast.PlainText(f.Content))
+		if m.inTable {
+			span = strings.ReplaceAll(span, "|", "\\|")
+		}
+		return span, nil
+	}
+
+	content, err := m.exportInlineBlocks(b.Children())
+	if err != nil {
+		return "", err
+	}
+
+	switch block := b.(type) {
+	case *ast.HyperlinkBlock:
+		return "[" + content + "](" + m.formatDestination(block.Destination) + ")", nil
+	case *ast.FormattingBlock:
+		switch block.Attribute {
+		case ast.BoldFormatting:
+			return wrapDelimiter(content, "**"), nil
+		case ast.ItalicFormatting:
+			return wrapDelimiter(content, "*"), nil
+		case ast.StrikethroughFormatting:
+			if m.settings.Flavor == GFMFlavor {
+				return wrapDelimiter(content, "~~"), nil
+			}
+			return m.degradeTag(content, "s", ""), nil
+		case ast.UnderlineFormatting:
+			return m.degradeTag(content, "u", ""), nil
+		}
+		return "", errors.New("invalid ast")
+	case *ast.ColorBlock:
+		var style string
+		if block.ForegroundValue != nil {
+			style += "color: " + block.ForegroundValue.String() + ";"
+		}
+		if block.BackgroundValue != nil {
+			style += "background-color: " + block.BackgroundValue.String() + ";"
+		}
+		return m.degradeTag(content, "span", style), nil
+	case *ast.SizeBlock:
+		size, err := cssSize(block.Value, block.Type)
+		if err != nil {
+			return "", err
+		}
+		return m.degradeTag(content, "span", "font-size: "+size+";"), nil
+	case *ast.FontBlock:
+		return m.degradeTag(content, "span", "font-family: "+block.Family+";"), nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Degrade content wrapped in an HTML tag with an optional style.
+func (m *MarkdownExporter) degradeTag(content, tag, style string) string {
+	if m.settings.Degrade == StripDegrade || content == "" {
+		return content
+	}
+	if style != "" {
+		return "<" + tag + " style=\"" + m.escapeAttribute(style) + "\">" + content + "</" + tag + ">"
+	}
+	return "<" + tag + ">" + content + "</" + tag + ">"
+}
+
+// Wrap content in an emphasis delimiter. Surrounding whitespace is moved
+// outside of the delimiters, since CommonMark does not allow it inside.
+func wrapDelimiter(content, delimiter string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return content
+	}
+	start := strings.Index(content, trimmed)
+	return content[:start] + delimiter + trimmed + delimiter + content[start+len(trimmed):]
+}
+
+// Write text as a code span, with a fence longer than any backtick run.
+func codeSpan(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", longest+1)
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+// Escape an HTML attribute value.
+func (m *MarkdownExporter) escapeAttribute(s string) string {
+	s = gohtml.EscapeString(s)
+	if m.inTable {
+		s = strings.ReplaceAll(s, "|", "&#124;")
+	}
+	return s
+}
+
+// Format a link destination. Pipes in tables are percent-encoded.
+func (m *MarkdownExporter) formatDestination(dest string) string {
+	if m.inTable {
+		dest = strings.ReplaceAll(dest, "|", "%7C")
+	}
+	if strings.ContainsAny(dest, " ()<>") {
+		return "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(dest) + ">"
+	}
+	return dest
+}
+
+// Get a CSS size.
+func cssSize(value float32, t ast.SizeType) (string, error) {
+	v := strconv.FormatFloat(float64(value), 'f', -1, 32)
+	switch t {
+	case ast.PercentageSizeType:
+		return v + "%", nil
+	case ast.PixelSizeType:
+		return v + "px", nil
+	case ast.PointSizeType:
+		return v + "pt", nil
+	case ast.CentimeterSizeType:
+		return v + "cm", nil
+	case ast.MillimeterSizeType:
+		return v + "mm", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Trim trailing hard line breaks.
+func trimHardBreaks(s string) string {
+	for strings.HasSuffix(s, "\\\n") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// Escape Markdown punctuation in text.
+func escapeText(s string) string {
+	out := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte("\\`*_[]<>|~&", s[i]) != -1 {
+			out.WriteByte('\\')
+		}
+		out.WriteByte(s[i])
+	}
+	return out.String()
+}
+
+// Escape characters at the start of lines that would start a block, like
+// headings, list items and block quotes.
+func escapeLineStarts(s string) string {
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		line := strings.TrimLeft(lines[i], " ")
+		indent := lines[i][:len(lines[i])-len(line)]
+		if line == "" {
+			continue
+		}
+		if strings.IndexByte("#-+=", line[0]) != -1 {
+			lines[i] = indent + "\\" + line
+			continue
+		}
+		digits := 0
+		for digits < len(line) && line[digits] >= '0' && line[digits] <= '9' {
+			digits++
+		}
+		if digits != 0 && digits < len(line) && (line[digits] == '.' || line[digits] == ')') {
+			lines[i] = indent + line[:digits] + "\\" + line[digits:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Prefix the first line of a block with one prefix and the rest of its lines
+// with another. Blank lines are prefixed without trailing whitespace.
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if lines[i] == "" {
+			lines[i] = strings.TrimRight(prefix, " ")
+		} else {
+			lines[i] = prefix + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,142 @@
+// export/markdown/markdown_test.go
+// Markdown export tests.
+
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+// Create a formatting block.
+func testFormat(attribute ast.FormattingType, content ...ast.InlineBlock) ast.InlineBlock {
+	return &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: attribute}
+}
+
+// Create a table with a header row and a row of inline content.
+func testTable(cells ...ast.InlineBlock) *ast.Table {
+	header := ast.TableRow{}
+	row := ast.TableRow{}
+	for i := range cells {
+		header.Cells = append(header.Cells, ast.TableCell{Content: []ast.Block{&ast.Paragraph{Content: testText("h")}}, IsHeader: true})
+		row.Cells = append(row.Cells, ast.TableCell{Content: []ast.Block{&ast.Paragraph{Content: []ast.InlineBlock{cells[i]}}}})
+	}
+	return &ast.Table{Rows: []ast.TableRow{header, row}}
+}
+
+// Export a block as GFM.
+func testExport(t *testing.T, b ast.Block) string {
+	out := strings.Builder{}
+	if err := NewMarkdownExporter(&out, MarkdownSettings{Flavor: GFMFlavor}).Export(&ast.Document{Content: []ast.Block{b}}); err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(out.String())
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"a*b_c", "a\\*b\\_c"},
+		{"[x](y)", "\\[x\\](y)"},
+		{"<b>", "\\<b\\>"},
+		{"&amp; &#42;", "\\&amp; \\&#42;"},
+		{"a|b", "a\\|b"},
+	}
+	for _, test := range tests {
+		if got := escapeText(test.text); got != test.want {
+			t.Errorf("escapeText(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestExportEntities(t *testing.T) {
+	got := testExport(t, &ast.Paragraph{Content: testText("&copy; & &#169;")})
+	if want := "\\&copy; \\& \\&#169;"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestExportTablePipes(t *testing.T) {
+	tests := []struct {
+		name string
+		cell ast.InlineBlock
+		want string
+	}{
+		{"text", &ast.Text{Value: "a|b"}, "a\\|b"},
+		{"link", &ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("x")}, Destination: "https://example.com/?q=a|b"}, "[x](https://example.com/?q=a%7Cb)"},
+		{"link with space", &ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("x")}, Destination: "a b|c"}, "[x](<a b%7Cc>)"},
+		{"image", &ast.InlineImageBlock{Source: "a|b.png", Alt: "c|d"}, "![c\\|d](a%7Cb.png)"},
+		{"sized image", &ast.InlineImageBlock{Source: "a|b.png", Alt: "c", HasWidthParameter: true, WidthValue: 10, WidthType: ast.PixelSizeType}, "<img src=\"a&#124;b.png\" alt=\"c\" style=\"width: 10px;\">"},
+		{"code", &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("a|b")}, Attribute: ast.TeletypeFormatting}, "`a\\|b`"},
+		{"font", &ast.FontBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("x")}, Family: "a|b"}, "<span style=\"font-family: a&#124;b;\">x</span>"},
+	}
+	for _, test := range tests {
+		got := testExport(t, testTable(test.cell))
+		want := "| h |\n| --- |\n| " + test.want + " |"
+		if got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, want)
+		}
+	}
+}
+
+func TestExportPipesOutsideTables(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.InlineBlock
+		want  string
+	}{
+		{"link", &ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("x")}, Destination: "a|b"}, "[x](a|b)"},
+		{"code", &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("a|b")}, Attribute: ast.TeletypeFormatting}, "`a|b`"},
+	}
+	for _, test := range tests {
+		if got := testExport(t, &ast.Paragraph{Content: []ast.InlineBlock{test.block}}); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExportNestedMarkup(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.InlineBlock
+		want  string
+	}{
+		{"bold italic", testFormat(ast.BoldFormatting, testFormat(ast.ItalicFormatting, &ast.Text{Value: "a"})), "***a***"},
+		{"italic link", testFormat(ast.ItalicFormatting, &ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("a*b")}, Destination: "x"}), "*[a\\*b](x)*"},
+		{"spaces", testFormat(ast.ItalicFormatting, &ast.Text{Value: "a"}, testFormat(ast.BoldFormatting, &ast.Text{Value: " b "}), &ast.Text{Value: "c"}), "*a **b** c*"},
+		{"code in bold", testFormat(ast.BoldFormatting, testFormat(ast.TeletypeFormatting, testFormat(ast.ItalicFormatting, &ast.Text{Value: "a`b"}))), "**``a`b``**"},
+		{"missing image", &ast.InlineImageBlock{Source: "missing.png", Alt: "a]b"}, "![a\\]b](missing.png)"},
+	}
+	for _, test := range tests {
+		if got := testExport(t, &ast.Paragraph{Content: []ast.InlineBlock{test.block}}); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExportLists(t *testing.T) {
+	tests := []struct {
+		name string
+		list *ast.List
+		want string
+	}{
+		{"unordered", &ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.Paragraph{Content: testText("b")}}}, "- a\n- b"},
+		{"ordered", &ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.Paragraph{Content: testText("b")}}}, "1. a\n2. b"},
+		{"nested", &ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}}, "- a\n- - b"},
+		{"marker text", &ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("- a")}, &ast.Paragraph{Content: testText("1. b")}}}, "- \\- a\n- 1\\. b"},
+	}
+	for _, test := range tests {
+		if got := testExport(t, test.list); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// export/markdown/settings.go
+// Markdown export settings.
+
+package markdown
+
+import "github.com/cubeflix/cdf/export"
+
+// Markdown flavor.
+type Flavor int64
+
+const (
+	// CommonMark. Tables and strikethrough are written as HTML.
+	CommonMarkFlavor Flavor = iota
+	// GitHub Flavored Markdown, with pipe tables and strikethrough.
+	GFMFlavor
+)
+
+// How constructs that Markdown cannot express, like color, size, font,
+// underline and alignment, are degraded.
+type DegradeMode int64
+
+const (
+	// Write the constructs as inline HTML.
+	HTMLDegrade DegradeMode = iota
+	// Drop the styling and keep the content.
+	StripDegrade
+)
+
+// Markdown export settings.
+type MarkdownSettings struct {
+	export.Settings
+
+	Flavor  Flavor
+	Degrade DegradeMode
+
+	// Write the title, subtitle, date and author as YAML front matter instead
+	// of headings.
+	FrontMatter bool
+}
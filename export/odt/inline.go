@@ -0,0 +1,278 @@
+// export/odt/inline.go
+// Text spans, hyperlinks and images.
+
+package odt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// A generated text style.
+type textStyle struct {
+	bold, italic, strike, underline bool
+
+	// The font, and the text and background colors as hexadecimal.
+	font, color, background string
+
+	// The font size in points, or zero.
+	size float64
+}
+
+// Text properties.
+type run struct {
+	textStyle
+
+	// The font size in points, for relative sizes.
+	base float64
+
+	// Whether the text is in a hyperlink.
+	link bool
+}
+
+// Get the properties of body text.
+func (o *ODTExporter) baseRun() run {
+	return run{base: o.settings.FontSize}
+}
+
+// Export the inline blocks of a paragraph.
+func (o *ODTExporter) runs(blocks []ast.InlineBlock, r run) (string, error) {
+	o.space = true
+	if r.size != 0 {
+		r.base, r.size = r.size, 0
+	}
+	return o.exportInlineBlocks(blocks, r)
+}
+
+// Export a slice of inline blocks.
+func (o *ODTExporter) exportInlineBlocks(blocks []ast.InlineBlock, r run) (string, error) {
+	out := strings.Builder{}
+	for i := range blocks {
+		s, err := o.exportInlineBlock(blocks[i], r)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(s)
+	}
+	return out.String(), nil
+}
+
+// Export an inline block.
+func (o *ODTExporter) exportInlineBlock(b ast.InlineBlock, r run) (string, error) {
+	switch block := b.(type) {
+	case *ast.Text:
+		return o.span(o.text(block.Value), r), nil
+	case *ast.HyperlinkBlock:
+		// Hyperlinks can't be nested.
+		if r.link {
+			return o.exportInlineBlocks(block.Content, r)
+		}
+		r.link = true
+		content, err := o.exportInlineBlocks(block.Content, r)
+		if err != nil {
+			return "", err
+		}
+		return hyperlink(block.Destination, content), nil
+	case *ast.FormattingBlock:
+		switch block.Attribute {
+		case ast.BoldFormatting:
+			r.bold = true
+		case ast.ItalicFormatting:
+			r.italic = true
+		case ast.StrikethroughFormatting:
+			r.strike = true
+		case ast.UnderlineFormatting:
+			r.underline = true
+		case ast.TeletypeFormatting:
+			r.font = "Liberation Mono"
+		default:
+			return "", errors.New("invalid ast")
+		}
+		return o.exportInlineBlocks(block.Content, r)
+	case *ast.ColorBlock:
+		if block.ForegroundValue != nil {
+			rgb := block.ForegroundValue.ToRGB()
+			r.color = hexColor(rgb.R, rgb.G, rgb.B)
+		}
+		if block.BackgroundValue != nil {
+			rgb := block.BackgroundValue.ToRGB()
+			r.background = hexColor(rgb.R, rgb.G, rgb.B)
+		}
+		return o.exportInlineBlocks(block.Content, r)
+	case *ast.SizeBlock:
+		size, err := points(block.Value, block.Type, r.base)
+		if err != nil {
+			return "", err
+		}
+		r.base, r.size = size, size
+		return o.exportInlineBlocks(block.Content, r)
+	case *ast.FontBlock:
+		if family := strings.TrimSpace(block.Family); family != "" {
+			r.font = family
+		}
+		return o.exportInlineBlocks(block.Content, r)
+	case *ast.InlineImageBlock:
+		return o.image(block.Source, block.Alt, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType, r)
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Wrap text in a span with the run's style, if it has one.
+func (o *ODTExporter) span(text string, r run) string {
+	if text == "" || r.textStyle == (textStyle{}) {
+		return text
+	}
+	name, ok := o.textStyles[r.textStyle]
+	if !ok {
+		name = "T" + strconv.Itoa(len(o.textOrder)+1)
+		o.textStyles[r.textStyle] = name
+		o.textOrder = append(o.textOrder, r.textStyle)
+	}
+	return "<text:span text:style-name=\"" + name + "\">" + text + "</text:span>"
+}
+
+// Write text. White space that would be collapsed is kept with space
+// elements, and newlines become line breaks.
+func (o *ODTExporter) text(s string) string {
+	out := strings.Builder{}
+	for _, c := range s {
+		switch {
+		case c == ' ':
+			if o.space {
+				out.WriteString("<text:s/>")
+			} else {
+				out.WriteByte(' ')
+			}
+			o.space = true
+			continue
+		case c == '\t':
+			out.WriteString("<text:tab/>")
+		case c == '\n':
+			out.WriteString("<text:line-break/>")
+		case c < 0x20 || c == 0xfffe || c == 0xffff:
+			continue
+		default:
+			out.WriteString(html.EscapeString(string(c)))
+			o.space = false
+			continue
+		}
+		o.space = true
+	}
+	return out.String()
+}
+
+// Wrap content in a hyperlink.
+func hyperlink(dest, content string) string {
+	return "<text:a xlink:type=\"simple\" xlink:href=\"" + escape(dest) + "\" text:style-name=\"Internet_20_link\" text:visited-style-name=\"Visited_20_Internet_20_Link\">" + content + "</text:a>"
+}
+
+// Get an image by its source, adding it to the package. Returns nil for
+// remote images, which are not embedded.
+func (o *ODTExporter) picture(src string) (*picture, error) {
+	if p, ok := o.pictures[src]; ok {
+		return p, nil
+	}
+	if strings.Contains(src, "://") || strings.HasPrefix(src, "data:") {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(o.settings.ImageDirectory, filepath.FromSlash(src)))
+	if err != nil {
+		return nil, errors.New("image '" + src + "': " + err.Error())
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("image '" + src + "': unsupported image format")
+	}
+
+	name := "image" + strconv.Itoa(len(o.mediaNames)+1) + "." + format
+	o.media[name] = data
+	o.mediaNames = append(o.mediaNames, name)
+	p := &picture{file: "Pictures/" + name, width: config.Width, height: config.Height}
+	o.pictures[src] = p
+	return p, nil
+}
+
+// Write an image as a frame in the text. Images keep their aspect ratio when
+// one dimension is given and are shrunk to fit the page. Remote images are
+// written as a hyperlink labeled with their alternative text.
+func (o *ODTExporter) image(src, alt string, hasWidth bool, widthValue float32, widthType ast.SizeType, hasHeight bool, heightValue float32, heightType ast.SizeType, r run) (string, error) {
+	p, err := o.picture(src)
+	if err != nil {
+		return "", err
+	}
+	if p == nil {
+		if alt == "" {
+			alt = src
+		}
+		text := o.span(o.text(alt), r)
+		if r.link {
+			return text, nil
+		}
+		return hyperlink(src, text), nil
+	}
+
+	maxWidth := o.textWidth()
+	maxHeight := o.settings.PageHeight - 2*o.settings.Margin
+	w, h := float64(p.width)*0.75, float64(p.height)*0.75
+	switch {
+	case hasWidth && hasHeight:
+		if w, err = points(widthValue, widthType, maxWidth); err == nil {
+			h, err = points(heightValue, heightType, maxHeight)
+		}
+	case hasWidth:
+		natural := w
+		if w, err = points(widthValue, widthType, maxWidth); err == nil && natural > 0 {
+			h *= w / natural
+		}
+	case hasHeight:
+		natural := h
+		if h, err = points(heightValue, heightType, maxHeight); err == nil && natural > 0 {
+			w *= h / natural
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if w > maxWidth {
+		h *= maxWidth / w
+		w = maxWidth
+	}
+	if h > maxHeight {
+		w *= maxHeight / h
+		h = maxHeight
+	}
+
+	o.frames++
+	o.space = false
+	return "<draw:frame draw:style-name=\"Graphics\" draw:name=\"Image " + strconv.Itoa(o.frames) + "\" text:anchor-type=\"as-char\" svg:width=\"" + length(w) + "\" svg:height=\"" + length(h) + "\" draw:z-index=\"0\">" +
+		"<draw:image xlink:href=\"" + p.file + "\" xlink:type=\"simple\" xlink:show=\"embed\" xlink:actuate=\"onLoad\"/>" +
+		"<svg:desc>" + escape(alt) + "</svg:desc></draw:frame>", nil
+}
+
+// Format a color for text properties.
+func hexColor(r, g, b uint8) string {
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
+
+// Escape text for XML, dropping characters XML doesn't allow.
+func escape(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xfffe || r == 0xffff {
+			return -1
+		}
+		return r
+	}, s)
+	return html.EscapeString(s)
+}
@@ -0,0 +1,407 @@
+// export/odt/odt.go
+// Package odt provides functionality for exporting into OpenDocument text
+// documents.
+
+package odt
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Heading font sizes in points.
+var headingSizes = []float64{18, 15, 13, 12, 11}
+
+// The left margins of the named paragraph styles, in points.
+var styleMargins = map[string]float64{
+	"Quotations": 36,
+}
+
+// Paragraph properties inherited by nested blocks.
+type context struct {
+	// The paragraph style and alignment.
+	style string
+	align ast.AlignmentType
+
+	// The left margin and the available width in points.
+	margin, width float64
+
+	// Whether blocks are in a list item, which can only contain paragraphs,
+	// headings and lists.
+	list bool
+}
+
+// A generated paragraph style.
+type paragraphStyle struct {
+	parent      string
+	align       ast.AlignmentType
+	margin      float64
+	breakBefore bool
+}
+
+// A generated table style.
+type tableStyle struct {
+	width, margin float64
+	columns       int
+	breakBefore   bool
+}
+
+// An image in the package.
+type picture struct {
+	file          string
+	width, height int
+}
+
+// ODT exporter.
+type ODTExporter struct {
+	stream   io.Writer
+	settings ODTSettings
+
+	paragraphStyles map[paragraphStyle]string
+	paragraphOrder  []paragraphStyle
+	textStyles      map[textStyle]string
+	textOrder       []textStyle
+	tableStyles     []tableStyle
+
+	pictures   map[string]*picture
+	media      map[string][]byte
+	mediaNames []string
+	frames     int
+
+	// Whether the next paragraph or table starts a new page.
+	pendingBreak bool
+
+	// Whether the last character written was white space, so following
+	// spaces must be kept explicitly.
+	space bool
+}
+
+// Create a new ODT exporter.
+func NewODTExporter(stream io.Writer, settings ODTSettings) *ODTExporter {
+	if settings.PageWidth <= 0 || settings.PageHeight <= 0 {
+		settings.PageWidth, settings.PageHeight = DefaultPageWidth, DefaultPageHeight
+	}
+	if settings.Margin <= 0 {
+		settings.Margin = DefaultMargin
+	}
+	if settings.Font == "" {
+		settings.Font = DefaultFont
+	}
+	if settings.FontSize <= 0 {
+		settings.FontSize = DefaultFontSize
+	}
+
+	return &ODTExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to ODT.
+func (o *ODTExporter) Export(d *ast.Document) error {
+	o.paragraphStyles, o.paragraphOrder = map[paragraphStyle]string{}, nil
+	o.textStyles, o.textOrder = map[textStyle]string{}, nil
+	o.tableStyles = nil
+	o.pictures, o.media, o.mediaNames = map[string]*picture{}, map[string][]byte{}, nil
+	o.frames = 0
+	o.pendingBreak = false
+
+	body := strings.Builder{}
+	ctx := context{width: o.textWidth()}
+
+	// Write the title block.
+	info := []struct {
+		omit        bool
+		text, style string
+	}{
+		{o.settings.OmitTitle, d.Title, "Title"},
+		{o.settings.OmitSubtitle, d.Subtitle, "Subtitle"},
+		{o.settings.OmitDate, d.Date, "Subtitle"},
+		{o.settings.OmitAuthor, d.Author, "Subtitle"},
+	}
+	for _, i := range info {
+		if !i.omit && i.text != "" {
+			o.space = true
+			body.WriteString("<text:p text:style-name=\"" + i.style + "\">" + o.text(i.text) + "</text:p>\n")
+		}
+	}
+
+	content, err := o.exportBlocks(d.Content, ctx)
+	if err != nil {
+		return err
+	}
+	body.WriteString(content)
+
+	return o.write(d, body.String())
+}
+
+// Get the width of the text area in points.
+func (o *ODTExporter) textWidth() float64 {
+	return o.settings.PageWidth - 2*o.settings.Margin
+}
+
+// Export a slice of blocks.
+func (o *ODTExporter) exportBlocks(blocks []ast.Block, ctx context) (string, error) {
+	out := strings.Builder{}
+	for i := range blocks {
+		s, err := o.exportBlock(blocks[i], ctx)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(s)
+	}
+	return out.String(), nil
+}
+
+// Export a block. Blocks without an alignment inherit their parent's.
+func (o *ODTExporter) exportBlock(b ast.Block, ctx context) (string, error) {
+	if b.GetAlignment() != ast.NoAlign {
+		ctx.align = b.GetAlignment()
+	}
+
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		runs, err := o.runs(block.Content, o.baseRun())
+		if err != nil {
+			return "", err
+		}
+		return "<text:p text:style-name=\"" + o.paragraphStyle(ctx, "") + "\">" + runs + "</text:p>\n", nil
+	case *ast.BasicBlock:
+		return o.exportBlocks(block.Content, ctx)
+	case *ast.Quote:
+		ctx.margin += styleMargins["Quotations"]
+		ctx.style = "Quotations"
+		ctx.width -= styleMargins["Quotations"]
+		return o.exportBlocks(block.Content, ctx)
+	case *ast.Image:
+		return o.exportImage(block, ctx)
+	case *ast.Heading:
+		if block.Class < ast.Heading1Type || block.Class > ast.Heading5Type {
+			return "", errors.New("invalid ast")
+		}
+		level := int(block.Class - ast.Heading1Type)
+		r := o.baseRun()
+		r.size = headingSizes[level]
+		runs, err := o.runs(block.Content, r)
+		if err != nil {
+			return "", err
+		}
+		style := o.paragraphStyle(ctx, "Heading_20_"+strconv.Itoa(level+1))
+		return "<text:h text:style-name=\"" + style + "\" text:outline-level=\"" + strconv.Itoa(level+1) + "\">" + runs + "</text:h>\n", nil
+	case *ast.HorizontalRule:
+		return "<text:p text:style-name=\"" + o.paragraphStyle(ctx, "Horizontal_20_Line") + "\"/>\n", nil
+	case *ast.List:
+		return o.exportList(block, ctx)
+	case *ast.Table:
+		return o.exportTable(block, ctx)
+	case *ast.Collapse:
+		// Collapse blocks are expanded.
+		r := o.baseRun()
+		r.bold = true
+		summary, err := o.runs(block.Summary, r)
+		if err != nil {
+			return "", err
+		}
+		content, err := o.exportBlocks(block.Content, ctx)
+		if err != nil {
+			return "", err
+		}
+		return "<text:p text:style-name=\"" + o.paragraphStyle(ctx, "") + "\">" + summary + "</text:p>\n" + content, nil
+	case *ast.PageBreak:
+		// The next paragraph or table starts on a new page.
+		o.pendingBreak = true
+		return "", nil
+	case *ast.Notes:
+		// Speaker notes are left out.
+		return "", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Get the style of a paragraph, generating one if the context needs it. The
+// style defaults to the context's. A pending page break is applied and
+// cleared.
+func (o *ODTExporter) paragraphStyle(ctx context, style string) string {
+	if style == "" {
+		style = ctx.style
+	}
+	if style == "" {
+		style = "Text_20_body"
+	}
+
+	// Lists indent their own paragraphs.
+	margin := styleMargins[style]
+	if !ctx.list {
+		margin = ctx.margin
+	}
+	key := paragraphStyle{parent: style, align: ctx.align, margin: margin, breakBefore: o.pendingBreak}
+	o.pendingBreak = false
+	if key.align == ast.NoAlign && key.margin == styleMargins[style] && !key.breakBefore {
+		return style
+	}
+	if name, ok := o.paragraphStyles[key]; ok {
+		return name
+	}
+	name := "P" + strconv.Itoa(len(o.paragraphOrder)+1)
+	o.paragraphStyles[key] = name
+	o.paragraphOrder = append(o.paragraphOrder, key)
+	return name
+}
+
+// Export a list. List items that are lists themselves are unnumbered.
+func (o *ODTExporter) exportList(block *ast.List, ctx context) (string, error) {
+	style := "L1"
+	if block.Ordered {
+		style = "L2"
+	}
+	ctx.list = true
+
+	out := strings.Builder{}
+	out.WriteString("<text:list text:style-name=\"" + style + "\">\n")
+	for i := range block.Items {
+		s, err := o.exportBlock(block.Items[i], ctx)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString("<text:list-item>" + s + "</text:list-item>\n")
+	}
+	out.WriteString("</text:list>\n")
+	return out.String(), nil
+}
+
+// Export a table with equal columns. Leading header rows repeat on each
+// page. List items can't contain tables, so tables in lists are written as
+// their cells' content.
+func (o *ODTExporter) exportTable(block *ast.Table, ctx context) (string, error) {
+	if ctx.list {
+		out := strings.Builder{}
+		for i := range block.Rows {
+			for j := range block.Rows[i].Cells {
+				cell := block.Rows[i].Cells[j]
+				cellCtx := ctx
+				if cell.IsHeader {
+					cellCtx.style = "Table_20_Heading"
+				}
+				s, err := o.exportBlocks(cell.Content, cellCtx)
+				if err != nil {
+					return "", err
+				}
+				out.WriteString(s)
+			}
+		}
+		return out.String(), nil
+	}
+
+	columns := 0
+	for i := range block.Rows {
+		if len(block.Rows[i].Cells) > columns {
+			columns = len(block.Rows[i].Cells)
+		}
+	}
+	if columns == 0 {
+		return "", nil
+	}
+	o.tableStyles = append(o.tableStyles, tableStyle{
+		width:       ctx.width,
+		margin:      ctx.margin,
+		columns:     columns,
+		breakBefore: o.pendingBreak,
+	})
+	o.pendingBreak = false
+	name := "Table" + strconv.Itoa(len(o.tableStyles))
+
+	cellCtx := context{align: ctx.align, width: ctx.width/float64(columns) - 8}
+	out := strings.Builder{}
+	out.WriteString("<table:table table:name=\"" + name + "\" table:style-name=\"" + name + "\">\n")
+	out.WriteString("<table:table-column table:style-name=\"" + name + ".A\" table:number-columns-repeated=\"" + strconv.Itoa(columns) + "\"/>\n")
+	headerRows := 0
+	for headerRows < len(block.Rows) && isHeaderRow(block.Rows[headerRows]) {
+		headerRows++
+	}
+	for i := range block.Rows {
+		row := block.Rows[i]
+		if i == 0 && headerRows > 0 {
+			out.WriteString("<table:table-header-rows>\n")
+		}
+		out.WriteString("<table:table-row>")
+		for j := 0; j < columns; j++ {
+			var cell ast.TableCell
+			if j < len(row.Cells) {
+				cell = row.Cells[j]
+			}
+			c, style := cellCtx, "TableCell"
+			c.style = "Table_20_Contents"
+			if cell.IsHeader {
+				c.style, style = "Table_20_Heading", "TableHeaderCell"
+			}
+			content, err := o.exportBlocks(cell.Content, c)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString("<table:table-cell table:style-name=\"" + style + "\" office:value-type=\"string\">" + content + "</table:table-cell>")
+		}
+		out.WriteString("</table:table-row>\n")
+		if i == headerRows-1 {
+			out.WriteString("</table:table-header-rows>\n")
+		}
+	}
+	out.WriteString("</table:table>\n")
+	return out.String(), nil
+}
+
+// Get whether all of a row's cells are headers.
+func isHeaderRow(row ast.TableRow) bool {
+	for i := range row.Cells {
+		if !row.Cells[i].IsHeader {
+			return false
+		}
+	}
+	return len(row.Cells) > 0
+}
+
+// Export an image block and its caption.
+func (o *ODTExporter) exportImage(block *ast.Image, ctx context) (string, error) {
+	o.space = true
+	content, err := o.image(block.Source, block.Alt, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType, o.baseRun())
+	if err != nil {
+		return "", err
+	}
+	out := "<text:p text:style-name=\"" + o.paragraphStyle(ctx, "") + "\">" + content + "</text:p>\n"
+
+	if block.HasCaption {
+		runs, err := o.runs(block.Caption, o.baseRun())
+		if err != nil {
+			return "", err
+		}
+		out += "<text:p text:style-name=\"" + o.paragraphStyle(ctx, "Caption") + "\">" + runs + "</text:p>\n"
+	}
+	return out, nil
+}
+
+// Convert a size to points. Percentages are relative to a length. CSS
+// pixels are three quarters of a point.
+func points(value float32, t ast.SizeType, relative float64) (float64, error) {
+	switch t {
+	case ast.PercentageSizeType:
+		return float64(value) / 100 * relative, nil
+	case ast.PixelSizeType:
+		return float64(value) * 0.75, nil
+	case ast.PointSizeType:
+		return float64(value), nil
+	case ast.CentimeterSizeType:
+		return float64(value) * 72 / 2.54, nil
+	case ast.MillimeterSizeType:
+		return float64(value) * 72 / 25.4, nil
+	}
+	return 0, errors.New("invalid ast")
+}
+
+// Format a length in points.
+func length(points float64) string {
+	return strconv.FormatFloat(math.Round(points*100)/100, 'f', -1, 64) + "pt"
+}
@@ -0,0 +1,111 @@
+// export/odt/odt_test.go
+// ODT export tests.
+
+package odt
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+// Export blocks and get the content document.
+func testExport(t *testing.T, settings ODTSettings, blocks ...ast.Block) (string, error) {
+	out := bytes.Buffer{}
+	if err := NewODTExporter(&out, settings).Export(&ast.Document{Content: blocks}); err != nil {
+		return "", err
+	}
+	z, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range z.File {
+		if f.Name != "content.xml" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data), nil
+	}
+	t.Fatal("missing content.xml")
+	return "", nil
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.Block
+		want  []string
+	}{
+		{"escaping", &ast.Paragraph{Content: testText(`<a & "b">`)}, []string{`<text:p text:style-name="Text_20_body">&lt;a &amp; &#34;b&#34;&gt;</text:p>`}},
+		{
+			"nested markup",
+			&ast.Paragraph{Content: []ast.InlineBlock{&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{
+				&ast.Text{Value: "a"},
+				&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("b")}, Attribute: ast.ItalicFormatting},
+			}}, Attribute: ast.BoldFormatting}}},
+			[]string{
+				`<text:span text:style-name="T1">a</text:span><text:span text:style-name="T2">b</text:span>`,
+				`<style:style style:name="T1" style:family="text"><style:text-properties fo:font-weight="bold"/></style:style>`,
+				`<style:style style:name="T2" style:family="text"><style:text-properties fo:font-weight="bold" fo:font-style="italic"/></style:style>`,
+			},
+		},
+		{
+			"list",
+			&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}},
+			[]string{"<text:list text:style-name=\"L1\">\n<text:list-item><text:p text:style-name=\"Text_20_body\">a</text:p>\n</text:list-item>\n" +
+				"<text:list-item><text:list text:style-name=\"L2\">\n<text:list-item><text:p text:style-name=\"Text_20_body\">b</text:p>\n</text:list-item>\n</text:list>\n</text:list-item>\n</text:list>"},
+		},
+		{
+			"table",
+			&ast.Table{Rows: []ast.TableRow{{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("a")}}, IsHeader: true}, {}}}}},
+			[]string{
+				`<table:table-column table:style-name="Table1.A" table:number-columns-repeated="2"/>`,
+				`<table:table-cell table:style-name="TableHeaderCell" office:value-type="string"><text:p text:style-name="Table_20_Heading">a</text:p>`,
+				`<table:table-cell table:style-name="TableCell" office:value-type="string"></table:table-cell>`,
+			},
+		},
+		{"remote image", &ast.Image{Source: "https://example.com/a.png", Alt: "a"}, []string{`<text:a xlink:type="simple" xlink:href="https://example.com/a.png" text:style-name="Internet_20_link" text:visited-style-name="Visited_20_Internet_20_Link">a</text:a>`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := testExport(t, ODTSettings{}, test.block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("got:\n%s\nwant it to contain:\n%s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExportMissingImage(t *testing.T) {
+	for _, b := range []ast.Block{
+		&ast.Image{Source: "missing.png"},
+		&ast.Paragraph{Content: []ast.InlineBlock{&ast.InlineImageBlock{Source: "missing.png"}}},
+	} {
+		if _, err := testExport(t, ODTSettings{ImageDirectory: t.TempDir()}, b); err == nil || !strings.Contains(err.Error(), "image 'missing.png'") {
+			t.Errorf("got error %v, want a missing image error", err)
+		}
+	}
+}
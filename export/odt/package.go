@@ -0,0 +1,249 @@
+// export/odt/package.go
+// ODT package, styles and generated automatic styles.
+
+package odt
+
+import (
+	"archive/zip"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// The namespaces of the document parts.
+const namespaces = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:draw="urn:oasis:names:tc:opendocument:xmlns:drawing:1.0" xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0" xmlns:xlink="http://www.w3.org/1999/xlink" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0" xmlns:svg="urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0"`
+
+// Bullet characters for each list level.
+var bullets = []string{"•", "◦", "▪"}
+
+// Write the ODT package.
+func (o *ODTExporter) write(d *ast.Document, body string) error {
+	z := zip.NewWriter(o.stream)
+
+	// The mimetype must come first and be stored uncompressed.
+	w, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("application/vnd.oasis.opendocument.text")); err != nil {
+		return err
+	}
+
+	type file struct {
+		name string
+		data []byte
+	}
+	files := []file{
+		{"META-INF/manifest.xml", []byte(o.manifest())},
+		{"meta.xml", []byte(meta(d))},
+		{"styles.xml", []byte(o.styles())},
+		{"content.xml", []byte(o.content(body))},
+	}
+	for _, name := range o.mediaNames {
+		files = append(files, file{"Pictures/" + name, o.media[name]})
+	}
+	for _, f := range files {
+		w, err := z.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+// Generate the manifest.
+func (o *ODTExporter) manifest() string {
+	out := strings.Builder{}
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">
+<manifest:file-entry manifest:full-path="/" manifest:version="1.2" manifest:media-type="application/vnd.oasis.opendocument.text"/>
+<manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>
+<manifest:file-entry manifest:full-path="styles.xml" manifest:media-type="text/xml"/>
+<manifest:file-entry manifest:full-path="meta.xml" manifest:media-type="text/xml"/>
+`)
+	for _, name := range o.mediaNames {
+		out.WriteString("<manifest:file-entry manifest:full-path=\"Pictures/" + name + "\" manifest:media-type=\"image/" + name[strings.LastIndex(name, ".")+1:] + "\"/>\n")
+	}
+	out.WriteString("</manifest:manifest>\n")
+	return out.String()
+}
+
+// Generate the document metadata.
+func meta(d *ast.Document) string {
+	out := strings.Builder{}
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<office:document-meta ` + namespaces + ` office:version="1.2">
+<office:meta>
+<meta:generator>cdf</meta:generator>
+`)
+	if d.Title != "" {
+		out.WriteString("<dc:title>" + escape(d.Title) + "</dc:title>\n")
+	}
+	if d.Subtitle != "" {
+		out.WriteString("<dc:subject>" + escape(d.Subtitle) + "</dc:subject>\n")
+	}
+	if d.Author != "" {
+		out.WriteString("<meta:initial-creator>" + escape(d.Author) + "</meta:initial-creator>\n")
+		out.WriteString("<dc:creator>" + escape(d.Author) + "</dc:creator>\n")
+	}
+	out.WriteString("</office:meta>\n</office:document-meta>\n")
+	return out.String()
+}
+
+// Get a paragraph style element with a display name and extra attributes.
+func namedParagraphStyle(name, parent, attributes, paragraph, text string) string {
+	s := "<style:style style:name=\"" + name + "\" style:display-name=\"" + strings.ReplaceAll(name, "_20_", " ") + "\" style:family=\"paragraph\""
+	if parent != "" {
+		s += " style:parent-style-name=\"" + parent + "\""
+	}
+	s += " " + attributes + ">"
+	if paragraph != "" {
+		s += "<style:paragraph-properties " + paragraph + "/>"
+	}
+	if text != "" {
+		s += "<style:text-properties " + text + "/>"
+	}
+	return s + "</style:style>\n"
+}
+
+// Generate the common styles and the page layout.
+func (o *ODTExporter) styles() string {
+	s := o.settings
+	font := escape(s.Font)
+	out := strings.Builder{}
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<office:document-styles ` + namespaces + ` office:version="1.2">
+<office:styles>
+<style:default-style style:family="paragraph"><style:paragraph-properties fo:line-height="115%"/><style:text-properties fo:font-family="` + font + `" fo:font-size="` + length(s.FontSize) + `"/></style:default-style>
+<style:default-style style:family="graphic"><style:graphic-properties style:vertical-pos="top" style:vertical-rel="baseline"/></style:default-style>
+`)
+	out.WriteString(namedParagraphStyle("Standard", "", `style:class="text"`, "", ""))
+	out.WriteString(namedParagraphStyle("Text_20_body", "Standard", `style:class="text"`, `fo:margin-top="0pt" fo:margin-bottom="6pt"`, ""))
+	out.WriteString(namedParagraphStyle("Heading", "Standard", `style:class="text"`, `fo:margin-top="12pt" fo:margin-bottom="4pt" fo:keep-with-next="always"`, `fo:font-weight="bold"`))
+	for i, size := range headingSizes {
+		level := strconv.Itoa(i + 1)
+		out.WriteString(namedParagraphStyle("Heading_20_"+level, "Heading", `style:next-style-name="Text_20_body" style:default-outline-level="`+level+`" style:class="text"`, "", `fo:font-size="`+length(size)+`"`))
+	}
+	out.WriteString(namedParagraphStyle("Title", "Heading", `style:class="chapter"`, `fo:margin-top="0pt" fo:margin-bottom="4pt"`, `fo:font-size="28pt"`))
+	out.WriteString(namedParagraphStyle("Subtitle", "Standard", `style:class="chapter"`, `fo:margin-top="0pt" fo:margin-bottom="4pt"`, `fo:font-size="14pt" fo:color="#595959"`))
+	out.WriteString(namedParagraphStyle("Quotations", "Text_20_body", `style:class="html"`, `fo:margin-left="`+length(styleMargins["Quotations"])+`" fo:margin-right="0pt"`, `fo:font-style="italic" fo:color="#404040"`))
+	out.WriteString(namedParagraphStyle("Caption", "Standard", `style:class="extra"`, `fo:margin-top="3pt" fo:margin-bottom="6pt"`, `fo:font-size="9pt" fo:font-style="italic"`))
+	out.WriteString(namedParagraphStyle("Table_20_Contents", "Standard", `style:class="extra"`, "", ""))
+	out.WriteString(namedParagraphStyle("Table_20_Heading", "Table_20_Contents", `style:class="extra"`, "", `fo:font-weight="bold"`))
+	out.WriteString(namedParagraphStyle("Horizontal_20_Line", "Standard", `style:class="html"`, `fo:margin-top="0pt" fo:margin-bottom="6pt" fo:border-bottom="0.5pt solid #808080" fo:padding="0pt"`, `fo:font-size="6pt"`))
+	out.WriteString(`<style:style style:name="Internet_20_link" style:display-name="Internet link" style:family="text"><style:text-properties fo:color="#000080" style:text-underline-style="solid" style:text-underline-width="auto" style:text-underline-color="font-color"/></style:style>
+<style:style style:name="Visited_20_Internet_20_Link" style:display-name="Visited Internet Link" style:family="text"><style:text-properties fo:color="#800000" style:text-underline-style="solid" style:text-underline-width="auto" style:text-underline-color="font-color"/></style:style>
+<style:style style:name="Graphics" style:family="graphic"><style:graphic-properties text:anchor-type="as-char" style:vertical-pos="top" style:vertical-rel="baseline"/></style:style>
+</office:styles>
+<office:automatic-styles>
+<style:page-layout style:name="pm1"><style:page-layout-properties fo:page-width="` + length(s.PageWidth) + `" fo:page-height="` + length(s.PageHeight) + `" style:print-orientation="portrait" fo:margin-top="` + length(s.Margin) + `" fo:margin-bottom="` + length(s.Margin) + `" fo:margin-left="` + length(s.Margin) + `" fo:margin-right="` + length(s.Margin) + `"/></style:page-layout>
+</office:automatic-styles>
+<office:master-styles>
+<style:master-page style:name="Standard" style:page-layout-name="pm1"/>
+</office:master-styles>
+</office:document-styles>
+`)
+	return out.String()
+}
+
+// Generate the content, with the automatic styles it uses.
+func (o *ODTExporter) content(body string) string {
+	out := strings.Builder{}
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content ` + namespaces + ` office:version="1.2">
+<office:automatic-styles>
+`)
+	for i, p := range o.paragraphOrder {
+		props := ""
+		switch p.align {
+		case ast.LeftAlign:
+			props += ` fo:text-align="start"`
+		case ast.RightAlign:
+			props += ` fo:text-align="end"`
+		case ast.CenterAlign:
+			props += ` fo:text-align="center"`
+		}
+		if p.margin != styleMargins[p.parent] {
+			props += ` fo:margin-left="` + length(p.margin) + `"`
+		}
+		if p.breakBefore {
+			props += ` fo:break-before="page"`
+		}
+		out.WriteString("<style:style style:name=\"P" + strconv.Itoa(i+1) + "\" style:family=\"paragraph\" style:parent-style-name=\"" + p.parent + "\"><style:paragraph-properties" + props + "/></style:style>\n")
+	}
+	for i, t := range o.textOrder {
+		out.WriteString("<style:style style:name=\"T" + strconv.Itoa(i+1) + "\" style:family=\"text\"><style:text-properties" + textProperties(t) + "/></style:style>\n")
+	}
+	for i, t := range o.tableStyles {
+		name := "Table" + strconv.Itoa(i+1)
+		align := "margins"
+		if t.margin > 0 {
+			align = "left"
+		}
+		props := ` style:width="` + length(t.width) + `" table:align="` + align + `"`
+		if t.margin > 0 {
+			props += ` fo:margin-left="` + length(t.margin) + `"`
+		}
+		if t.breakBefore {
+			props += ` fo:break-before="page"`
+		}
+		out.WriteString("<style:style style:name=\"" + name + "\" style:family=\"table\"><style:table-properties" + props + "/></style:style>\n")
+		out.WriteString("<style:style style:name=\"" + name + ".A\" style:family=\"table-column\"><style:table-column-properties style:column-width=\"" + length(t.width/float64(t.columns)) + "\"/></style:style>\n")
+	}
+	out.WriteString(`<style:style style:name="TableCell" style:family="table-cell"><style:table-cell-properties fo:padding="4pt" fo:border="0.5pt solid #808080"/></style:style>
+<style:style style:name="TableHeaderCell" style:family="table-cell"><style:table-cell-properties fo:padding="4pt" fo:border="0.5pt solid #808080" fo:background-color="#eeeeee"/></style:style>
+`)
+
+	// Bullet and numbered list styles, indented half an inch per level.
+	for i, numbered := range []bool{false, true} {
+		out.WriteString("<text:list-style style:name=\"L" + strconv.Itoa(i+1) + "\">")
+		for level := 1; level <= 10; level++ {
+			alignment := "<style:list-level-properties text:list-level-position-and-space-mode=\"label-alignment\"><style:list-level-label-alignment text:label-followed-by=\"listtab\" text:list-tab-stop-position=\"" + length(float64(36*level)) + "\" fo:text-indent=\"-18pt\" fo:margin-left=\"" + length(float64(36*level)) + "\"/></style:list-level-properties>"
+			if numbered {
+				out.WriteString("<text:list-level-style-number text:level=\"" + strconv.Itoa(level) + "\" style:num-suffix=\".\" style:num-format=\"1\">" + alignment + "</text:list-level-style-number>")
+			} else {
+				out.WriteString("<text:list-level-style-bullet text:level=\"" + strconv.Itoa(level) + "\" text:bullet-char=\"" + bullets[(level-1)%len(bullets)] + "\">" + alignment + "</text:list-level-style-bullet>")
+			}
+		}
+		out.WriteString("</text:list-style>\n")
+	}
+
+	out.WriteString("</office:automatic-styles>\n<office:body>\n<office:text>\n")
+	out.WriteString(body)
+	out.WriteString("</office:text>\n</office:body>\n</office:document-content>\n")
+	return out.String()
+}
+
+// Get the attributes of a text style.
+func textProperties(t textStyle) string {
+	props := ""
+	if t.font != "" {
+		props += ` fo:font-family="` + escape(t.font) + `"`
+	}
+	if t.size != 0 {
+		props += ` fo:font-size="` + length(t.size) + `"`
+	}
+	if t.bold {
+		props += ` fo:font-weight="bold"`
+	}
+	if t.italic {
+		props += ` fo:font-style="italic"`
+	}
+	if t.underline {
+		props += ` style:text-underline-style="solid" style:text-underline-width="auto" style:text-underline-color="font-color"`
+	}
+	if t.strike {
+		props += ` style:text-line-through-style="solid"`
+	}
+	if t.color != "" {
+		props += ` fo:color="` + t.color + `"`
+	}
+	if t.background != "" {
+		props += ` fo:background-color="` + t.background + `"`
+	}
+	return props
+}
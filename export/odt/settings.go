@@ -0,0 +1,33 @@
+// export/odt/settings.go
+// ODT export settings.
+
+package odt
+
+import "github.com/cubeflix/cdf/export"
+
+const (
+	DefaultPageWidth  = 595.3
+	DefaultPageHeight = 841.9
+	DefaultMargin     = 72
+	DefaultFont       = "Liberation Sans"
+	DefaultFontSize   = 11
+)
+
+// ODT export settings.
+type ODTSettings struct {
+	export.Settings
+
+	// The page size and margins in points. Defaults to A4 with one inch
+	// margins.
+	PageWidth  float64
+	PageHeight float64
+	Margin     float64
+
+	// The body font and its size in points. Defaults to Liberation Sans at
+	// 11 points.
+	Font     string
+	FontSize float64
+
+	// The directory relative image sources are read from.
+	ImageDirectory string
+}
@@ -0,0 +1,511 @@
+// export/pandoc/pandoc.go
+// Package pandoc provides functionality for exporting into Pandoc's JSON
+// AST, for converting with Pandoc through a pipe.
+
+package pandoc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	"gopkg.in/go-playground/colors.v1"
+)
+
+// The version of the Pandoc types written, from pandoc-types 1.23.
+var APIVersion = []int{1, 23, 1}
+
+// Classes marking CDF constructs Pandoc has no element for.
+const (
+	// A basic block.
+	BlockClass = "block"
+
+	// A collapse block, and its summary.
+	CollapseClass = "details"
+	SummaryClass  = "summary"
+
+	// A page break.
+	PageBreakClass = "page-break"
+
+	// Speaker notes, which Pandoc's slide show writers also read.
+	NotesClass = "notes"
+
+	// Teletype text holding formatting, which a code element can't.
+	TeletypeClass = "teletype"
+
+	// A table cell that is a header outside of the table head.
+	HeaderCellClass = "header"
+)
+
+// A Pandoc element, like a block or inline.
+type element struct {
+	T string      `json:"t"`
+	C interface{} `json:"c,omitempty"`
+}
+
+// A Pandoc document.
+type document struct {
+	APIVersion []int                  `json:"pandoc-api-version"`
+	Meta       map[string]interface{} `json:"meta"`
+	Blocks     []interface{}          `json:"blocks"`
+}
+
+// Pandoc JSON exporter.
+type PandocExporter struct {
+	stream   io.Writer
+	settings PandocSettings
+}
+
+// Create a new Pandoc JSON exporter.
+func NewPandocExporter(stream io.Writer, settings PandocSettings) *PandocExporter {
+	return &PandocExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to Pandoc JSON.
+func (p *PandocExporter) Export(d *ast.Document) error {
+	out := document{APIVersion: APIVersion, Meta: map[string]interface{}{}}
+
+	// Write the document information.
+	if !p.settings.OmitTitle && d.Title != "" {
+		out.Meta["title"] = element{"MetaInlines", text(d.Title)}
+	}
+	if !p.settings.OmitSubtitle && d.Subtitle != "" {
+		out.Meta["subtitle"] = element{"MetaInlines", text(d.Subtitle)}
+	}
+	if !p.settings.OmitDate && d.Date != "" {
+		out.Meta["date"] = element{"MetaInlines", text(d.Date)}
+	}
+	if !p.settings.OmitAuthor && d.Author != "" {
+		out.Meta["author"] = element{"MetaList", []interface{}{element{"MetaInlines", text(d.Author)}}}
+	}
+
+	blocks, err := p.exportBlocks(d.Content, false)
+	if err != nil {
+		return err
+	}
+	out.Blocks = blocks
+
+	e := json.NewEncoder(p.stream)
+	if p.settings.Indent {
+		e.SetIndent("", "\t")
+	}
+	return e.Encode(out)
+}
+
+// Export a slice of blocks. Paragraphs are plain text in tight lists and
+// table cells.
+func (p *PandocExporter) exportBlocks(blocks []ast.Block, plain bool) ([]interface{}, error) {
+	out := make([]interface{}, 0, len(blocks))
+	for i := range blocks {
+		block, err := p.exportBlock(blocks[i], plain)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, block)
+	}
+	return out, nil
+}
+
+// Export a block. Aligned blocks are wrapped in a div with align and wrap
+// attributes.
+func (p *PandocExporter) exportBlock(b ast.Block, plain bool) (interface{}, error) {
+	var out interface{}
+	var err error
+
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		var content []interface{}
+		content, err = p.exportInlineBlocks(block.Content)
+		content = trim(content)
+		if plain {
+			out = element{"Plain", content}
+		} else {
+			out = element{"Para", content}
+		}
+	case *ast.BasicBlock:
+		var content []interface{}
+		content, err = p.exportBlocks(block.Content, plain)
+		if err != nil {
+			return nil, err
+		}
+		return element{"Div", []interface{}{attr([]string{BlockClass}, blockAttributes(block)...), content}}, nil
+	case *ast.Quote:
+		var content []interface{}
+		content, err = p.exportBlocks(block.Content, false)
+		out = element{"BlockQuote", content}
+	case *ast.Image:
+		// Images are figures, so they are told apart from inline images.
+		var image, caption []interface{}
+		image, err = p.exportImage(block.Source, block.Alt, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+		if err != nil {
+			return nil, err
+		}
+		captionBlocks := []interface{}{}
+		if block.HasCaption {
+			caption, err = p.exportInlineBlocks(block.Caption)
+			captionBlocks = append(captionBlocks, element{"Plain", trim(caption)})
+		}
+		out = element{"Figure", []interface{}{attr(nil), []interface{}{nil, captionBlocks}, []interface{}{element{"Plain", image}}}}
+	case *ast.Heading:
+		if block.Class < ast.Heading1Type || block.Class > ast.Heading5Type {
+			return nil, errors.New("invalid ast")
+		}
+		var content []interface{}
+		content, err = p.exportInlineBlocks(block.Content)
+		out = element{"Header", []interface{}{int(block.Class-ast.Heading1Type) + 1, attr(nil), trim(content)}}
+	case *ast.HorizontalRule:
+		out = element{T: "HorizontalRule"}
+	case *ast.List:
+		out, err = p.exportList(block)
+	case *ast.Table:
+		out, err = p.exportTable(block)
+	case *ast.Collapse:
+		var summary, content []interface{}
+		summary, err = p.exportInlineBlocks(block.Summary)
+		if err != nil {
+			return nil, err
+		}
+		content, err = p.exportBlocks(block.Content, false)
+		summaryDiv := element{"Div", []interface{}{attr([]string{SummaryClass}), []interface{}{element{"Plain", trim(summary)}}}}
+		out = element{"Div", []interface{}{attr([]string{CollapseClass}), append([]interface{}{summaryDiv}, content...)}}
+	case *ast.PageBreak:
+		out = element{"Div", []interface{}{attr([]string{PageBreakClass}), []interface{}{}}}
+	case *ast.Notes:
+		var content []interface{}
+		content, err = p.exportBlocks(block.Content, false)
+		out = element{"Div", []interface{}{attr([]string{NotesClass}), content}}
+	default:
+		return nil, errors.New("invalid ast")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if attributes := blockAttributes(b); len(attributes) > 0 {
+		out = element{"Div", []interface{}{attr(nil, attributes...), []interface{}{out}}}
+	}
+	return out, nil
+}
+
+// Export a list. Nested lists are added to the previous item, and lists of
+// single paragraphs are tight.
+func (p *PandocExporter) exportList(block *ast.List) (interface{}, error) {
+	tight := true
+	for i := range block.Items {
+		switch block.Items[i].(type) {
+		case *ast.Paragraph, *ast.List:
+		default:
+			tight = false
+		}
+	}
+
+	items := []interface{}{}
+	for i := range block.Items {
+		item, err := p.exportBlock(block.Items[i], tight)
+		if err != nil {
+			return nil, err
+		}
+		if _, nested := block.Items[i].(*ast.List); nested && len(items) > 0 {
+			items[len(items)-1] = append(items[len(items)-1].([]interface{}), item)
+			continue
+		}
+
+		// Basic blocks are the item's content.
+		if basic, ok := block.Items[i].(*ast.BasicBlock); ok && len(blockAttributes(basic)) == 0 {
+			content, err := p.exportBlocks(basic.Content, tight)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, content)
+			continue
+		}
+		items = append(items, []interface{}{item})
+	}
+
+	if block.Ordered {
+		return element{"OrderedList", []interface{}{[]interface{}{1, element{T: "Decimal"}, element{T: "Period"}}, items}}, nil
+	}
+	return element{"BulletList", items}, nil
+}
+
+// Export a table. Leading rows of header cells are the table head, and
+// other header cells have the header class.
+func (p *PandocExporter) exportTable(block *ast.Table) (interface{}, error) {
+	columns := 0
+	for i := range block.Rows {
+		if len(block.Rows[i].Cells) > columns {
+			columns = len(block.Rows[i].Cells)
+		}
+	}
+
+	heads := 0
+	for heads < len(block.Rows) && len(block.Rows[heads].Cells) > 0 {
+		header := true
+		for _, cell := range block.Rows[heads].Cells {
+			header = header && cell.IsHeader
+		}
+		if !header {
+			break
+		}
+		heads++
+	}
+
+	rows := make([]interface{}, len(block.Rows))
+	for i := range block.Rows {
+		cells := make([]interface{}, columns)
+		for j := range cells {
+			var classes []string
+			content := []interface{}{}
+			if j < len(block.Rows[i].Cells) {
+				cell := block.Rows[i].Cells[j]
+				if cell.IsHeader && i >= heads {
+					classes = []string{HeaderCellClass}
+				}
+				var err error
+				content, err = p.exportBlocks(cell.Content, true)
+				if err != nil {
+					return nil, err
+				}
+			}
+			cells[j] = []interface{}{attr(classes), element{T: "AlignDefault"}, 1, 1, content}
+		}
+		rows[i] = []interface{}{attr(nil), cells}
+	}
+
+	specs := make([]interface{}, columns)
+	for i := range specs {
+		specs[i] = []interface{}{element{T: "AlignDefault"}, element{T: "ColWidthDefault"}}
+	}
+	return element{"Table", []interface{}{
+		attr(nil),
+		[]interface{}{nil, []interface{}{}},
+		specs,
+		[]interface{}{attr(nil), rows[:heads]},
+		[]interface{}{[]interface{}{attr(nil), 0, []interface{}{}, rows[heads:]}},
+		[]interface{}{attr(nil), []interface{}{}},
+	}}, nil
+}
+
+// Export an image, as the content of a paragraph.
+func (p *PandocExporter) exportImage(src, alt string, hasWidth bool, widthValue float32, widthType ast.SizeType, hasHeight bool, heightValue float32, heightType ast.SizeType) ([]interface{}, error) {
+	attributes := [][2]string{}
+	if hasWidth {
+		size, err := cssSize(widthValue, widthType)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, [2]string{"width", size})
+	}
+	if hasHeight {
+		size, err := cssSize(heightValue, heightType)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, [2]string{"height", size})
+	}
+	return []interface{}{element{"Image", []interface{}{attr(nil, attributes...), text(alt), []interface{}{src, ""}}}}, nil
+}
+
+// Export a slice of inline blocks.
+func (p *PandocExporter) exportInlineBlocks(blocks []ast.InlineBlock) ([]interface{}, error) {
+	out := []interface{}{}
+	for i := range blocks {
+		inlines, err := p.exportInlineBlock(blocks[i])
+		if err != nil {
+			return nil, err
+		}
+
+		// Text next to text can leave two spaces in a row.
+		for _, inline := range inlines {
+			if e, ok := inline.(element); ok && e.T == "Space" && len(out) > 0 {
+				if last, ok := out[len(out)-1].(element); ok && last.T == "Space" {
+					continue
+				}
+			}
+			out = append(out, inline)
+		}
+	}
+	return out, nil
+}
+
+// Export an inline block. Colors, sizes and fonts are spans with a style
+// attribute, which Pandoc keeps in HTML output.
+func (p *PandocExporter) exportInlineBlock(b ast.InlineBlock) ([]interface{}, error) {
+	switch block := b.(type) {
+	case *ast.Text:
+		return text(block.Value), nil
+	case *ast.InlineImageBlock:
+		return p.exportImage(block.Source, block.Alt, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+	}
+
+	content, err := p.exportInlineBlocks(b.Children())
+	if err != nil {
+		return nil, err
+	}
+
+	switch block := b.(type) {
+	case *ast.HyperlinkBlock:
+		return []interface{}{element{"Link", []interface{}{attr(nil), content, []interface{}{block.Destination, ""}}}}, nil
+	case *ast.FormattingBlock:
+		switch block.Attribute {
+		case ast.BoldFormatting:
+			return []interface{}{element{"Strong", content}}, nil
+		case ast.ItalicFormatting:
+			return []interface{}{element{"Emph", content}}, nil
+		case ast.StrikethroughFormatting:
+			return []interface{}{element{"Strikeout", content}}, nil
+		case ast.UnderlineFormatting:
+			return []interface{}{element{"Underline", content}}, nil
+		case ast.TeletypeFormatting:
+			// Code holds only text.
+			for i := range block.Content {
+				if _, ok := block.Content[i].(*ast.Text); !ok {
+					return []interface{}{element{"Span", []interface{}{attr([]string{TeletypeClass}), content}}}, nil
+				}
+			}
+			return []interface{}{element{"Code", []interface{}{attr(nil), ast.PlainText(block.Content)}}}, nil
+		}
+	case *ast.ColorBlock:
+		style := ""
+		if block.ForegroundValue != nil {
+			style += "color: " + hexColor(block.ForegroundValue) + ";"
+		}
+		if block.BackgroundValue != nil {
+			if style != "" {
+				style += " "
+			}
+			style += "background-color: " + hexColor(block.BackgroundValue) + ";"
+		}
+		return span(style, content), nil
+	case *ast.SizeBlock:
+		size, err := cssSize(block.Value, block.Type)
+		if err != nil {
+			return nil, err
+		}
+		return span("font-size: "+size+";", content), nil
+	case *ast.FontBlock:
+		return span("font-family: "+block.Family+";", content), nil
+	}
+	return nil, errors.New("invalid ast")
+}
+
+// Get a span with a style.
+func span(style string, content []interface{}) []interface{} {
+	if style == "" {
+		return content
+	}
+	return []interface{}{element{"Span", []interface{}{attr(nil, [2]string{"style", style}), content}}}
+}
+
+// Get an attribute triple, of an identifier, classes and key-value pairs.
+func attr(classes []string, attributes ...[2]string) []interface{} {
+	if classes == nil {
+		classes = []string{}
+	}
+	pairs := make([][]string, len(attributes))
+	for i := range attributes {
+		pairs[i] = []string{attributes[i][0], attributes[i][1]}
+	}
+	return []interface{}{"", classes, pairs}
+}
+
+// Get the align and wrap attributes of a block.
+func blockAttributes(b ast.Block) [][2]string {
+	attributes := [][2]string{}
+	switch b.GetAlignment() {
+	case ast.LeftAlign:
+		attributes = append(attributes, [2]string{"align", "left"})
+	case ast.RightAlign:
+		attributes = append(attributes, [2]string{"align", "right"})
+	case ast.CenterAlign:
+		attributes = append(attributes, [2]string{"align", "center"})
+	}
+	if b.GetWrap() {
+		attributes = append(attributes, [2]string{"wrap", "true"})
+	}
+	return attributes
+}
+
+// Split text into strings, spaces and line breaks. Spaces around line
+// breaks are dropped.
+func text(s string) []interface{} {
+	out := []interface{}{}
+	word := strings.Builder{}
+	space := false
+	flush := func() {
+		if word.Len() > 0 {
+			out = append(out, element{"Str", word.String()})
+			word.Reset()
+		}
+		if space && (len(out) == 0 || out[len(out)-1].(element).T != "LineBreak") {
+			out = append(out, element{T: "Space"})
+		}
+		space = false
+	}
+	for _, c := range s {
+		switch c {
+		case ' ', '\t', '\r':
+			if word.Len() > 0 {
+				flush()
+			}
+			space = true
+		case '\n':
+			space = false
+			flush()
+			out = append(out, element{T: "LineBreak"})
+		default:
+			if space {
+				flush()
+			}
+			word.WriteRune(c)
+		}
+	}
+	flush()
+	return out
+}
+
+// Trim spaces and line breaks from the ends of a block's inlines.
+func trim(inlines []interface{}) []interface{} {
+	blank := func(inline interface{}) bool {
+		e, ok := inline.(element)
+		return ok && (e.T == "Space" || e.T == "LineBreak")
+	}
+	for len(inlines) > 0 && blank(inlines[0]) {
+		inlines = inlines[1:]
+	}
+	for len(inlines) > 0 && blank(inlines[len(inlines)-1]) {
+		inlines = inlines[:len(inlines)-1]
+	}
+	return inlines
+}
+
+// Get a CSS size.
+func cssSize(value float32, t ast.SizeType) (string, error) {
+	v := strconv.FormatFloat(float64(value), 'f', -1, 32)
+	switch t {
+	case ast.PercentageSizeType:
+		return v + "%", nil
+	case ast.PixelSizeType:
+		return v + "px", nil
+	case ast.PointSizeType:
+		return v + "pt", nil
+	case ast.CentimeterSizeType:
+		return v + "cm", nil
+	case ast.MillimeterSizeType:
+		return v + "mm", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Get the hexadecimal value of a color.
+func hexColor(c colors.Color) string {
+	rgb := c.ToRGB()
+	return fmt.Sprintf("#%02x%02x%02x", rgb.R, rgb.G, rgb.B)
+}
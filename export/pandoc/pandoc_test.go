@@ -0,0 +1,64 @@
+// export/pandoc/pandoc_test.go
+// Pandoc JSON export tests.
+
+package pandoc
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+// Create a formatting block.
+func testFormat(attribute ast.FormattingType, content ...ast.InlineBlock) ast.InlineBlock {
+	return &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: attribute}
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.Block
+		want  string
+	}{
+		{"text", &ast.Paragraph{Content: testText("a  <b>\n\"c\"")}, `[{"t":"Para","c":[{"t":"Str","c":"a"},{"t":"Space"},{"t":"Str","c":"\u003cb\u003e"},{"t":"LineBreak"},{"t":"Str","c":"\"c\""}]}]`},
+		{"nested markup", &ast.Paragraph{Content: []ast.InlineBlock{testFormat(ast.BoldFormatting, &ast.Text{Value: "a"}, testFormat(ast.ItalicFormatting, testFormat(ast.TeletypeFormatting, &ast.Text{Value: "b"})))}}, `[{"t":"Para","c":[{"t":"Strong","c":[{"t":"Str","c":"a"},{"t":"Emph","c":[{"t":"Code","c":[["",[],[]],"b"]}]}]}]}]`},
+		{
+			"list",
+			&ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}},
+			`[{"t":"OrderedList","c":[[1,{"t":"Decimal"},{"t":"Period"}],[[{"t":"Plain","c":[{"t":"Str","c":"a"}]},{"t":"BulletList","c":[[{"t":"Plain","c":[{"t":"Str","c":"b"}]}]]}]]]}]`,
+		},
+		{
+			"table",
+			&ast.Table{Rows: []ast.TableRow{
+				{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("a")}}, IsHeader: true}, {}}},
+				{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}},
+			}},
+			`[{"t":"Table","c":[["",[],[]],[null,[]],[[{"t":"AlignDefault"},{"t":"ColWidthDefault"}],[{"t":"AlignDefault"},{"t":"ColWidthDefault"}]],[["",[],[]],[]],` +
+				`[[["",[],[]],0,[],[[["",[],[]],[[["",["header"],[]],{"t":"AlignDefault"},1,1,[{"t":"Plain","c":[{"t":"Str","c":"a"}]}]],[["",[],[]],{"t":"AlignDefault"},1,1,[]]]],` +
+				`[["",[],[]],[[["",[],[]],{"t":"AlignDefault"},1,1,[{"t":"Plain","c":[{"t":"Str","c":"b"}]}]],[["",[],[]],{"t":"AlignDefault"},1,1,[]]]]]]],[["",[],[]],[]]]}]`,
+		},
+		{"missing image", &ast.Image{Source: "missing.png", Alt: "a"}, `[{"t":"Figure","c":[["",[],[]],[null,[]],[{"t":"Plain","c":[{"t":"Image","c":[["",[],[]],[{"t":"Str","c":"a"}],["missing.png",""]]}]}]]}]`},
+	}
+	for _, test := range tests {
+		out := strings.Builder{}
+		if err := NewPandocExporter(&out, PandocSettings{}).Export(&ast.Document{Content: []ast.Block{test.block}}); err != nil {
+			t.Fatal(err)
+		}
+		var doc struct {
+			Blocks json.RawMessage `json:"blocks"`
+		}
+		if err := json.Unmarshal([]byte(out.String()), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(doc.Blocks); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
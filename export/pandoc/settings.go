@@ -0,0 +1,14 @@
+// export/pandoc/settings.go
+// Pandoc JSON export settings.
+
+package pandoc
+
+import "github.com/cubeflix/cdf/export"
+
+// Pandoc JSON export settings.
+type PandocSettings struct {
+	export.Settings
+
+	// Indent the JSON output.
+	Indent bool
+}
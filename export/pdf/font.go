@@ -0,0 +1,201 @@
+// export/pdf/font.go
+// Font selection, measurement and encoding.
+
+package pdf
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Standard 14 font metrics.
+type standardFont struct {
+	// The ascent and descent in thousandths of an em.
+	ascent, descent int
+
+	// Whether the font uses its built-in encoding instead of WinAnsi.
+	builtin bool
+
+	widths [256]uint16
+}
+
+// Font variants.
+const (
+	regularVariant = iota
+	boldVariant
+	italicVariant
+	boldItalicVariant
+)
+
+// Standard font names for common family names, by variant.
+var standardFamilies = map[string][4]string{
+	"helvetica":       {"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Helvetica-BoldOblique"},
+	"arial":           {"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Helvetica-BoldOblique"},
+	"sans-serif":      {"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Helvetica-BoldOblique"},
+	"sans":            {"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Helvetica-BoldOblique"},
+	"times":           {"Times-Roman", "Times-Bold", "Times-Italic", "Times-BoldItalic"},
+	"times-roman":     {"Times-Roman", "Times-Bold", "Times-Italic", "Times-BoldItalic"},
+	"times new roman": {"Times-Roman", "Times-Bold", "Times-Italic", "Times-BoldItalic"},
+	"serif":           {"Times-Roman", "Times-Bold", "Times-Italic", "Times-BoldItalic"},
+	"courier":         {"Courier", "Courier-Bold", "Courier-Oblique", "Courier-BoldOblique"},
+	"courier new":     {"Courier", "Courier-Bold", "Courier-Oblique", "Courier-BoldOblique"},
+	"monospace":       {"Courier", "Courier-Bold", "Courier-Oblique", "Courier-BoldOblique"},
+	"symbol":          {"Symbol", "Symbol", "Symbol", "Symbol"},
+	"zapfdingbats":    {"ZapfDingbats", "ZapfDingbats", "ZapfDingbats", "ZapfDingbats"},
+	"zapf dingbats":   {"ZapfDingbats", "ZapfDingbats", "ZapfDingbats", "ZapfDingbats"},
+}
+
+// WinAnsiEncoding codes for characters outside of Latin-1.
+var winAnsiCodes = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86,
+	'‡': 0x87, 'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c,
+	'Ž': 0x8e, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95,
+	'–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b,
+	'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// A font used by the document.
+type fontResource struct {
+	// The resource name, like "F1", and the base font name.
+	id       string
+	baseFont string
+
+	// Either standard font metrics or an embedded TrueType font.
+	standard *standardFont
+	trueType *trueTypeFont
+
+	// The glyphs used from a TrueType font, and their characters.
+	used map[uint16]rune
+}
+
+// Get the advance width of a string at a font size.
+func (f *fontResource) measure(s string, size float64) float64 {
+	total := 0.0
+	if f.standard != nil {
+		for _, c := range f.standardBytes(s) {
+			total += float64(f.standard.widths[c])
+		}
+	} else {
+		for _, r := range s {
+			total += f.trueType.width(f.trueType.glyph(r))
+		}
+	}
+	return total * size / 1000
+}
+
+// Get the ascent and descent at a font size. The descent is positive.
+func (f *fontResource) metrics(size float64) (float64, float64) {
+	if f.standard != nil {
+		return float64(f.standard.ascent) * size / 1000, -float64(f.standard.descent) * size / 1000
+	}
+	return f.trueType.ascent * size / 1000, -f.trueType.descent * size / 1000
+}
+
+// Encode text as a string operand for the Tj operator.
+func (f *fontResource) encode(s string) string {
+	if f.standard != nil {
+		return literal(f.standardBytes(s))
+	}
+	b := make([]byte, 0, len(s)*2)
+	for _, r := range s {
+		g := f.trueType.glyph(r)
+		if _, ok := f.used[g]; !ok {
+			f.used[g] = r
+		}
+		b = append(b, byte(g>>8), byte(g))
+	}
+	return "<" + hex.EncodeToString(b) + ">"
+}
+
+// Encode text as single-byte codes for a standard font. Characters outside
+// of the encoding become question marks.
+func (f *fontResource) standardBytes(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			b = append(b, ' ')
+		case f.standard.builtin && r < 256:
+			b = append(b, byte(r))
+		case f.standard.builtin:
+			b = append(b, '?')
+		case r >= 0x20 && r < 0x7f || r >= 0xa0 && r < 0x100:
+			b = append(b, byte(r))
+		case winAnsiCodes[r] != 0:
+			b = append(b, winAnsiCodes[r])
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// Resolve a comma-separated list of family names to the first available
+// family. Embedded families are prefixed with "ttf:". Returns an empty
+// string if none is available.
+func (p *PDFExporter) resolveFamily(list string) string {
+	names := strings.Split(list, ",")
+	for i := range names {
+		name := strings.ToLower(strings.Trim(strings.TrimSpace(names[i]), "\"'"))
+		if _, ok := p.families[name]; ok {
+			return "ttf:" + name
+		}
+		if _, ok := standardFamilies[name]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// Get the font for a style, adding it to the document if necessary.
+func (p *PDFExporter) font(st style) *fontResource {
+	family := st.family
+	if st.teletype {
+		family = p.monospace
+	}
+	variant := regularVariant
+	if st.bold && st.italic {
+		variant = boldItalicVariant
+	} else if st.bold {
+		variant = boldVariant
+	} else if st.italic {
+		variant = italicVariant
+	}
+
+	key := family + "/" + strconv.Itoa(variant)
+	if f, ok := p.fonts[key]; ok {
+		return f
+	}
+	f := &fontResource{id: "F" + strconv.Itoa(len(p.fontList)+1)}
+	if strings.HasPrefix(family, "ttf:") {
+		variants := p.families[strings.TrimPrefix(family, "ttf:")]
+		if variants[variant] == nil {
+			variant = regularVariant
+		}
+		f.trueType = variants[variant]
+		f.baseFont = f.trueType.name
+		f.used = map[uint16]rune{}
+	} else {
+		f.baseFont = standardFamilies[family][variant]
+		f.standard = standardFonts[f.baseFont]
+	}
+	p.fonts[key] = f
+	p.fontList = append(p.fontList, f)
+	return f
+}
+
+// Write a font's objects and return its object number.
+func (p *PDFExporter) writeFont(w *writer, f *fontResource) int {
+	n := w.reserve()
+	if f.standard != nil {
+		encoding := ""
+		if !f.standard.builtin {
+			encoding = "/Encoding/WinAnsiEncoding"
+		}
+		w.object(n, "<</Type/Font/Subtype/Type1/BaseFont"+name(f.baseFont)+encoding+">>")
+		return n
+	}
+	p.writeTrueType(w, n, f)
+	return n
+}
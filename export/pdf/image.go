@@ -0,0 +1,149 @@
+// export/pdf/image.go
+// PNG and JPEG image embedding.
+
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The error for image sources that are only loaded by OpenImage.
+var errRemoteImage = errors.New("remote image")
+
+// An image used by the document.
+type imageResource struct {
+	// The resource name, like "Im1".
+	id string
+
+	width, height int
+	colorSpace    string
+	decode        string
+
+	// JPEG data is embedded as is. Other images are stored as raw samples,
+	// with an optional alpha channel.
+	jpeg  bool
+	data  []byte
+	alpha []byte
+}
+
+// Get an image by its source, loading it if necessary. Returns nil for
+// remote images, and for images that cannot be loaded if ImagePlaceholders
+// is set. These are drawn as placeholders.
+func (p *PDFExporter) image(src string) (*imageResource, error) {
+	if img, ok := p.images[src]; ok {
+		return img, nil
+	}
+	img, err := p.loadImage(src)
+	if err == errRemoteImage || (err != nil && p.settings.ImagePlaceholders) {
+		img = nil
+	} else if err != nil {
+		return nil, errors.New("image '" + src + "': " + err.Error())
+	} else {
+		img.id = "Im" + strconv.Itoa(len(p.imageList)+1)
+		p.imageList = append(p.imageList, img)
+	}
+	p.images[src] = img
+	return img, nil
+}
+
+// Open and decode an image.
+func (p *PDFExporter) loadImage(src string) (*imageResource, error) {
+	var r io.ReadCloser
+	var err error
+	if p.settings.OpenImage != nil {
+		r, err = p.settings.OpenImage(src)
+	} else if strings.Contains(src, ":") && !filepath.IsAbs(src) {
+		// Remote and data URLs are not loaded.
+		err = errRemoteImage
+	} else {
+		r, err = os.Open(filepath.Join(p.settings.ImageDirectory, filepath.FromSlash(src)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(data, []byte{0xff, 0xd8}) {
+		config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		img := &imageResource{width: config.Width, height: config.Height, jpeg: true, data: data, colorSpace: "/DeviceRGB"}
+		switch config.ColorModel {
+		case color.GrayModel:
+			img.colorSpace = "/DeviceGray"
+		case color.CMYKModel:
+			img.colorSpace = "/DeviceCMYK"
+			img.decode = "/Decode[1 0 1 0 1 0 1 0]"
+		}
+		return img, nil
+	}
+
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("unsupported image format")
+	}
+	bounds := decoded.Bounds()
+	img := &imageResource{width: bounds.Dx(), height: bounds.Dy()}
+	if gray, ok := decoded.(*image.Gray); ok {
+		img.colorSpace = "/DeviceGray"
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			i := gray.PixOffset(bounds.Min.X, y)
+			img.data = append(img.data, gray.Pix[i:i+bounds.Dx()]...)
+		}
+		return img, nil
+	}
+
+	img.colorSpace = "/DeviceRGB"
+	img.data = make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+			img.data = append(img.data, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			if c.A != 0xff {
+				opaque = false
+			}
+		}
+	}
+	if !opaque {
+		img.alpha = alpha
+	}
+	return img, nil
+}
+
+// Write an image's objects and return its object number.
+func (p *PDFExporter) writeImage(w *writer, img *imageResource) int {
+	n := w.reserve()
+	dict := "/Type/XObject/Subtype/Image/Width " + strconv.Itoa(img.width) + "/Height " + strconv.Itoa(img.height) +
+		"/ColorSpace" + img.colorSpace + "/BitsPerComponent 8" + img.decode
+	if img.jpeg {
+		w.stream(n, dict+"/Filter/DCTDecode", img.data, false)
+		return n
+	}
+	if img.alpha != nil {
+		mask := w.reserve()
+		w.stream(mask, "/Type/XObject/Subtype/Image/Width "+strconv.Itoa(img.width)+"/Height "+strconv.Itoa(img.height)+
+			"/ColorSpace/DeviceGray/BitsPerComponent 8", img.alpha, true)
+		dict += "/SMask " + ref(mask)
+	}
+	w.stream(n, dict, img.data, true)
+	return n
+}
@@ -0,0 +1,420 @@
+// export/pdf/layout.go
+// Block layout into boxes.
+
+package pdf
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Heading font sizes, relative to the body font size.
+var headingScales = []float64{2, 1.65, 1.35, 1.15, 1}
+
+// A laid out piece of vertical content, positioned horizontally.
+type box struct {
+	height float64
+
+	// The distance from the top to the first baseline, if any.
+	baseline float64
+
+	// Gaps are dropped at the top of a page. Page breaks start a new page.
+	// Boxes that keep with the next box are moved to the next page with it.
+	gap, pageBreak, keep bool
+
+	draw func(pg *page, top float64)
+}
+
+// Create a vertical gap.
+func gap(height float64) box {
+	return box{height: height, gap: true}
+}
+
+// Lay out blocks, separated by gaps.
+func (p *PDFExporter) blocks(blocks []ast.Block, st style, x, width float64, align ast.AlignmentType) ([]box, error) {
+	out := []box{}
+	for i := range blocks {
+		boxes, err := p.block(blocks[i], st, x, width, align)
+		if err != nil {
+			return nil, err
+		}
+		if len(boxes) == 0 {
+			continue
+		}
+		if len(out) > 0 {
+			out = append(out, gap(st.size*0.75))
+		}
+		out = append(out, boxes...)
+	}
+	return out, nil
+}
+
+// Lay out a block. Blocks without an alignment inherit their parent's.
+func (p *PDFExporter) block(b ast.Block, st style, x, width float64, align ast.AlignmentType) ([]box, error) {
+	if b.GetAlignment() != ast.NoAlign {
+		align = b.GetAlignment()
+	}
+
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		return p.paragraph(block.Content, st, x, width, align)
+	case *ast.BasicBlock:
+		return p.blocks(block.Content, st, x, width, align)
+	case *ast.Quote:
+		indent := st.size * 1.5
+		boxes, err := p.blocks(block.Content, st, x+indent, width-indent, align)
+		if err != nil {
+			return nil, err
+		}
+		for i := range boxes {
+			boxes[i] = withBar(boxes[i], x+st.size*0.3, st.size*0.2)
+		}
+		return boxes, nil
+	case *ast.Image:
+		return p.imageBlock(block, st, x, width, align)
+	case *ast.Heading:
+		if block.Class < ast.Heading1Type || block.Class > ast.Heading5Type {
+			return nil, errors.New("invalid ast")
+		}
+		st.bold = true
+		st.size *= headingScales[block.Class-ast.Heading1Type]
+		boxes, err := p.paragraph(block.Content, st, x, width, align)
+		if err != nil || len(boxes) == 0 {
+			return boxes, err
+		}
+		for i := range boxes {
+			boxes[i].keep = true
+		}
+		return append([]box{gap(st.size * 0.4)}, boxes...), nil
+	case *ast.HorizontalRule:
+		return []box{{height: st.size, draw: func(pg *page, top float64) {
+			pg.content.WriteString("0.6 0.6 0.6 rg " + num(x) + " " + num(top-st.size/2) + " " + num(width) + " 0.75 re f\n")
+		}}}, nil
+	case *ast.List:
+		return p.list(block, st, x, width, align)
+	case *ast.Table:
+		return p.table(block, st, x, width, align)
+	case *ast.Collapse:
+		// Collapse blocks are expanded.
+		summary := st
+		summary.bold = true
+		boxes, err := p.paragraph(block.Summary, summary, x, width, align)
+		if err != nil {
+			return nil, err
+		}
+		content, err := p.blocks(block.Content, st, x, width, align)
+		if err != nil {
+			return nil, err
+		}
+		if len(boxes) > 0 && len(content) > 0 {
+			boxes = append(boxes, gap(st.size*0.5))
+		}
+		return append(boxes, content...), nil
+	case *ast.PageBreak:
+		return []box{{pageBreak: true}}, nil
+	case *ast.Notes:
+		// Speaker notes are left out.
+		return nil, nil
+	}
+	return nil, errors.New("invalid ast")
+}
+
+// Lay out inline content into one box per line.
+func (p *PDFExporter) paragraph(content []ast.InlineBlock, st style, x, width float64, align ast.AlignmentType) ([]box, error) {
+	pieces, err := p.inline(content, st, width, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(pieces) == 0 {
+		return nil, nil
+	}
+
+	lines := p.breakLines(pieces, width, st)
+	boxes := make([]box, len(lines))
+	for i := range lines {
+		l := lines[i]
+		left := x
+		switch align {
+		case ast.RightAlign:
+			left += width - l.width
+		case ast.CenterAlign:
+			left += (width - l.width) / 2
+		}
+		boxes[i] = box{height: l.above + l.below, baseline: l.above, draw: func(pg *page, top float64) {
+			p.drawLine(pg, l, left, top-l.above)
+		}}
+	}
+	return boxes, nil
+}
+
+// Add a vertical bar to the left of a box, for quotes.
+func withBar(b box, x, width float64) box {
+	draw := b.draw
+	b.draw = func(pg *page, top float64) {
+		pg.content.WriteString("0.8 0.8 0.8 rg " + num(x) + " " + num(top-b.height) + " " + num(width) + " " + num(b.height) + " re f\n")
+		if draw != nil {
+			draw(pg, top)
+		}
+	}
+	return b
+}
+
+// Lay out a list, with markers to the left of each item.
+func (p *PDFExporter) list(block *ast.List, st style, x, width float64, align ast.AlignmentType) ([]box, error) {
+	indent := st.size * 1.8
+	out := []box{}
+	number := 0
+	for i := range block.Items {
+		boxes, err := p.block(block.Items[i], st, x+indent, width-indent, align)
+		if err != nil {
+			return nil, err
+		}
+		if len(boxes) == 0 {
+			continue
+		}
+		if len(out) > 0 {
+			out = append(out, gap(st.size*0.3))
+		}
+
+		// Nested lists have their own markers.
+		if _, nested := block.Items[i].(*ast.List); !nested {
+			number++
+			marker := "•"
+			if block.Ordered {
+				marker = strconv.Itoa(number) + "."
+			}
+			first := 0
+			for first < len(boxes)-1 && boxes[first].gap {
+				first++
+			}
+			boxes[first] = p.withMarker(boxes[first], marker, st, x+indent-st.size*0.5)
+		}
+		out = append(out, boxes...)
+	}
+	return out, nil
+}
+
+// Add a list marker to a box, right-aligned to a position on its first
+// baseline.
+func (p *PDFExporter) withMarker(b box, marker string, st style, right float64) box {
+	draw := b.draw
+	pieces := p.text(marker, st, nil)
+	l := p.measureLine(pieces, st)
+	baseline := b.baseline
+	if baseline == 0 {
+		baseline = l.above
+	}
+	b.draw = func(pg *page, top float64) {
+		p.drawLine(pg, l, right-l.width, top-baseline)
+		if draw != nil {
+			draw(pg, top)
+		}
+	}
+	return b
+}
+
+// Lay out a table with equal columns and one box per row.
+func (p *PDFExporter) table(block *ast.Table, st style, x, width float64, align ast.AlignmentType) ([]box, error) {
+	columns := 0
+	for i := range block.Rows {
+		if len(block.Rows[i].Cells) > columns {
+			columns = len(block.Rows[i].Cells)
+		}
+	}
+	if columns == 0 {
+		return nil, nil
+	}
+	columnWidth := width / float64(columns)
+	padding := st.size * 0.35
+
+	out := []box{}
+	for i := range block.Rows {
+		row := block.Rows[i]
+		cells := make([][]box, len(row.Cells))
+		height := st.size * lineHeight
+		for j := range row.Cells {
+			cst := st
+			if row.Cells[j].IsHeader {
+				cst.bold = true
+			}
+			boxes, err := p.blocks(row.Cells[j].Content, cst, x+float64(j)*columnWidth+padding, columnWidth-2*padding, align)
+			if err != nil {
+				return nil, err
+			}
+
+			// Page breaks and leading gaps are dropped in cells.
+			cellHeight := 0.0
+			for _, b := range boxes {
+				if b.pageBreak || b.gap && len(cells[j]) == 0 {
+					continue
+				}
+				cells[j] = append(cells[j], b)
+				cellHeight += b.height
+			}
+			if cellHeight > height {
+				height = cellHeight
+			}
+		}
+		height += 2 * padding
+
+		header := make([]bool, len(row.Cells))
+		for j := range row.Cells {
+			header[j] = row.Cells[j].IsHeader
+		}
+		out = append(out, box{height: height, draw: func(pg *page, top float64) {
+			for j := range cells {
+				left := x + float64(j)*columnWidth
+				if header[j] {
+					pg.content.WriteString("0.93 0.93 0.93 rg " + num(left) + " " + num(top-height) + " " + num(columnWidth) + " " + num(height) + " re f\n")
+				}
+				y := top - padding
+				for _, b := range cells[j] {
+					if b.draw != nil {
+						b.draw(pg, y)
+					}
+					y -= b.height
+				}
+				pg.content.WriteString("0.5 0.5 0.5 RG 0.5 w " + num(left) + " " + num(top-height) + " " + num(columnWidth) + " " + num(height) + " re S\n")
+			}
+		}})
+	}
+	return out, nil
+}
+
+// Lay out an image block and its caption. Placeholder images are drawn as a
+// frame around their alternative text.
+func (p *PDFExporter) imageBlock(block *ast.Image, st style, x, width float64, align ast.AlignmentType) ([]box, error) {
+	img, err := p.image(block.Source)
+	if err != nil {
+		return nil, err
+	}
+	var w, h float64
+	if img != nil {
+		w, h, err = p.imageSize(img, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType, width)
+	} else {
+		w, h, err = p.placeholderSize(block, st, width)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	left := x
+	switch align {
+	case ast.RightAlign:
+		left += width - w
+	case ast.CenterAlign:
+		left += (width - w) / 2
+	}
+	boxes := []box{{height: h, draw: func(pg *page, top float64) {
+		if img != nil {
+			pg.content.WriteString("q " + num(w) + " 0 0 " + num(h) + " " + num(left) + " " + num(top-h) + " cm /" + img.id + " Do Q\n")
+			return
+		}
+		pg.content.WriteString("0.6 0.6 0.6 RG 0.75 w " + num(left) + " " + num(top-h) + " " + num(w) + " " + num(h) + " re S\n")
+		text := block.Alt
+		if text == "" {
+			text = block.Source
+		}
+		lines := p.breakLines(p.text(text, st, nil), w-st.size, st)
+		if len(lines) > 0 {
+			l := lines[0]
+			p.drawLine(pg, l, left+(w-l.width)/2, top-(h+l.above-l.below)/2)
+		}
+	}}}
+
+	if block.HasCaption {
+		caption := st
+		caption.italic = true
+		caption.size *= 0.9
+		lines, err := p.paragraph(block.Caption, caption, x, width, align)
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) > 0 {
+			boxes[0].keep = true
+			boxes = append(boxes, gap(st.size*0.3))
+			boxes = append(boxes, lines...)
+		}
+	}
+	return boxes, nil
+}
+
+// Get the size of an image in points. Images are shrunk to fit the width
+// and the page.
+func (p *PDFExporter) imageSize(img *imageResource, hasWidth bool, widthValue float32, widthType ast.SizeType, hasHeight bool, heightValue float32, heightType ast.SizeType, maxWidth float64) (float64, float64, error) {
+	w, h := float64(img.width)*0.75, float64(img.height)*0.75
+	var err error
+	switch {
+	case hasWidth && hasHeight:
+		if w, err = length(widthValue, widthType, maxWidth); err != nil {
+			return 0, 0, err
+		}
+		h, err = length(heightValue, heightType, p.top-p.bottom)
+	case hasWidth:
+		natural := w
+		if w, err = length(widthValue, widthType, maxWidth); err == nil && natural > 0 {
+			h *= w / natural
+		}
+	case hasHeight:
+		natural := h
+		if h, err = length(heightValue, heightType, p.top-p.bottom); err == nil && natural > 0 {
+			w *= h / natural
+		}
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if w > maxWidth && w > 0 {
+		h *= maxWidth / w
+		w = maxWidth
+	}
+	if h > p.top-p.bottom && h > 0 {
+		w *= (p.top - p.bottom) / h
+		h = p.top - p.bottom
+	}
+	return w, h, nil
+}
+
+// Get the size of an image placeholder.
+func (p *PDFExporter) placeholderSize(block *ast.Image, st style, maxWidth float64) (float64, float64, error) {
+	w, h := maxWidth, st.size*4
+	var err error
+	if block.HasWidthParameter {
+		if w, err = length(block.WidthValue, block.WidthType, maxWidth); err != nil {
+			return 0, 0, err
+		}
+	}
+	if block.HasHeightParameter {
+		if h, err = length(block.HeightValue, block.HeightType, p.top-p.bottom); err != nil {
+			return 0, 0, err
+		}
+	}
+	if w > maxWidth {
+		w = maxWidth
+	}
+	if h > p.top-p.bottom {
+		h = p.top - p.bottom
+	}
+	return w, h, nil
+}
+
+// Convert a size to points. Percentages are relative to a length. CSS
+// pixels are 0.75 points.
+func length(value float32, t ast.SizeType, relative float64) (float64, error) {
+	switch t {
+	case ast.PercentageSizeType:
+		return float64(value) / 100 * relative, nil
+	case ast.PixelSizeType:
+		return float64(value) * 0.75, nil
+	case ast.PointSizeType:
+		return float64(value), nil
+	case ast.CentimeterSizeType:
+		return float64(value) * 72 / 2.54, nil
+	case ast.MillimeterSizeType:
+		return float64(value) * 72 / 25.4, nil
+	}
+	return 0, errors.New("invalid ast")
+}
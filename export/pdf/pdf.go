@@ -0,0 +1,280 @@
+// export/pdf/pdf.go
+// Package pdf provides functionality for exporting into paged PDF, without
+// external programs.
+
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// A page of the document.
+type page struct {
+	content bytes.Buffer
+	links   []link
+}
+
+// A link annotation.
+type link struct {
+	rect [4]float64
+	dest string
+}
+
+// PDF exporter.
+type PDFExporter struct {
+	stream   io.Writer
+	settings PDFSettings
+
+	// Embedded font families by lowercase name and variant, and the
+	// resolved body and teletype families.
+	families        map[string][4]*trueTypeFont
+	body, monospace string
+
+	// The fonts and images used, by key and in order.
+	fonts     map[string]*fontResource
+	fontList  []*fontResource
+	images    map[string]*imageResource
+	imageList []*imageResource
+
+	pages []*page
+
+	// The content area.
+	left, width, top, bottom float64
+}
+
+// Create a new PDF exporter.
+func NewPDFExporter(stream io.Writer, settings PDFSettings) *PDFExporter {
+	if settings.PageSize.Width <= 0 || settings.PageSize.Height <= 0 {
+		settings.PageSize = A4
+	}
+	if settings.Margins == (Margins{}) {
+		settings.Margins = Margins{DefaultMargin, DefaultMargin, DefaultMargin, DefaultMargin}
+	}
+	if settings.FontSize <= 0 {
+		settings.FontSize = DefaultFontSize
+	}
+	if settings.DefaultFont == "" {
+		settings.DefaultFont = DefaultFont
+	}
+	if settings.MonospaceFont == "" {
+		settings.MonospaceFont = DefaultMonospaceFont
+	}
+
+	return &PDFExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to PDF.
+func (p *PDFExporter) Export(d *ast.Document) error {
+	if err := p.reset(); err != nil {
+		return err
+	}
+	st := style{family: p.body, size: p.settings.FontSize}
+
+	// Lay out the title block and the content.
+	boxes, err := p.titleBlock(d, st)
+	if err != nil {
+		return err
+	}
+	content, err := p.blocks(d.Content, st, p.left, p.width, ast.NoAlign)
+	if err != nil {
+		return err
+	}
+	if len(boxes) > 0 && len(content) > 0 {
+		boxes = append(boxes, gap(st.size*0.75))
+	}
+	p.paginate(append(boxes, content...))
+
+	_, err = p.stream.Write(p.write(d))
+	return err
+}
+
+// Reset the exporter and load the embedded fonts.
+func (p *PDFExporter) reset() error {
+	p.families = map[string][4]*trueTypeFont{}
+	for family, fonts := range p.settings.Fonts {
+		if fonts.Regular == nil {
+			return errors.New("font family '" + family + "' has no regular font")
+		}
+		variants := [4]*trueTypeFont{}
+		for i, data := range [][]byte{fonts.Regular, fonts.Bold, fonts.Italic, fonts.BoldItalic} {
+			if data == nil {
+				continue
+			}
+			f, err := parseTrueType(data)
+			if err != nil {
+				return errors.New("font family '" + family + "': " + err.Error())
+			}
+			variants[i] = f
+		}
+		p.families[strings.ToLower(family)] = variants
+	}
+	if p.body = p.resolveFamily(p.settings.DefaultFont); p.body == "" {
+		p.body = "helvetica"
+	}
+	if p.monospace = p.resolveFamily(p.settings.MonospaceFont); p.monospace == "" {
+		p.monospace = "courier"
+	}
+
+	p.fonts, p.fontList = map[string]*fontResource{}, nil
+	p.images, p.imageList = map[string]*imageResource{}, nil
+	p.pages = nil
+
+	m := p.settings.Margins
+	p.left = m.Left
+	p.width = p.settings.PageSize.Width - m.Left - m.Right
+	p.top = p.settings.PageSize.Height - m.Top
+	p.bottom = m.Bottom
+	if p.width <= 0 || p.top <= p.bottom {
+		return errors.New("margins are larger than the page")
+	}
+	return nil
+}
+
+// Lay out the document title, subtitle, date and author, followed by a rule.
+func (p *PDFExporter) titleBlock(d *ast.Document, st style) ([]box, error) {
+	texts := []string{d.Title, d.Subtitle, d.Date, d.Author}
+	omit := []bool{p.settings.OmitTitle, p.settings.OmitSubtitle, p.settings.OmitDate, p.settings.OmitAuthor}
+	scales := []float64{2, 1.5, 1.15, 1.15}
+
+	out := []box{}
+	for i := range texts {
+		if omit[i] || texts[i] == "" {
+			continue
+		}
+		lst := st
+		lst.bold = true
+		lst.size *= scales[i]
+		boxes, err := p.paragraph([]ast.InlineBlock{&ast.Text{Value: texts[i]}}, lst, p.left, p.width, ast.NoAlign)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) > 0 {
+			out = append(out, gap(st.size*0.4))
+		}
+		out = append(out, boxes...)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	rule, err := p.block(&ast.HorizontalRule{}, st, p.left, p.width, ast.NoAlign)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, rule...), nil
+}
+
+// Distribute boxes onto pages.
+func (p *PDFExporter) paginate(boxes []box) {
+	pg := p.newPage()
+	y, atTop := p.top, true
+	for i, b := range boxes {
+		if b.pageBreak {
+			pg = p.newPage()
+			y, atTop = p.top, true
+			continue
+		}
+		if b.gap {
+			if atTop {
+				continue
+			}
+			if y-b.height < p.bottom {
+				pg = p.newPage()
+				y, atTop = p.top, true
+				continue
+			}
+			if b.draw != nil {
+				b.draw(pg, y)
+			}
+			y -= b.height
+			continue
+		}
+
+		// Boxes that keep with the next box need room for both.
+		needed := b.height
+		for j := i + 1; b.keep && j < len(boxes) && !boxes[j].pageBreak; j++ {
+			needed += boxes[j].height
+			if !boxes[j].gap && !boxes[j].keep {
+				break
+			}
+		}
+		if y-needed < p.bottom-0.01 && !atTop {
+			if y-b.height < p.bottom-0.01 || needed <= p.top-p.bottom {
+				pg = p.newPage()
+				y = p.top
+			}
+		}
+		if b.draw != nil {
+			b.draw(pg, y)
+		}
+		y -= b.height
+		atTop = false
+	}
+}
+
+// Start a new page.
+func (p *PDFExporter) newPage() *page {
+	pg := &page{}
+	p.pages = append(p.pages, pg)
+	return pg
+}
+
+// Write the PDF file.
+func (p *PDFExporter) write(d *ast.Document) []byte {
+	w := newWriter()
+	catalog, pages, resources, info := w.reserve(), w.reserve(), w.reserve(), w.reserve()
+	size := p.settings.PageSize
+
+	kids := make([]string, len(p.pages))
+	for i, pg := range p.pages {
+		n, content := w.reserve(), w.reserve()
+		kids[i] = ref(n)
+		w.stream(content, "", pg.content.Bytes(), true)
+
+		annots := ""
+		for _, l := range pg.links {
+			a := w.reserve()
+			w.object(a, "<</Type/Annot/Subtype/Link/Rect["+num(l.rect[0])+" "+num(l.rect[1])+" "+num(l.rect[2])+" "+num(l.rect[3])+
+				"]/Border[0 0 0]/A<</S/URI/URI"+literal([]byte(l.dest))+">>>>")
+			annots += ref(a) + " "
+		}
+		if annots != "" {
+			annots = "/Annots[" + strings.TrimSpace(annots) + "]"
+		}
+		w.object(n, "<</Type/Page/Parent "+ref(pages)+"/MediaBox[0 0 "+num(size.Width)+" "+num(size.Height)+"]/Resources "+ref(resources)+"/Contents "+ref(content)+annots+">>")
+	}
+	w.object(pages, "<</Type/Pages/Kids["+strings.Join(kids, " ")+"]/Count "+strconv.Itoa(len(kids))+">>")
+
+	// Fonts are written after the pages, once their used glyphs are known.
+	fonts := ""
+	for _, f := range p.fontList {
+		fonts += "/" + f.id + " " + ref(p.writeFont(w, f))
+	}
+	images := ""
+	for _, img := range p.imageList {
+		images += "/" + img.id + " " + ref(p.writeImage(w, img))
+	}
+	w.object(resources, "<</ProcSet[/PDF/Text/ImageB/ImageC]/Font<<"+fonts+">>/XObject<<"+images+">>>>")
+	w.object(catalog, "<</Type/Catalog/Pages "+ref(pages)+">>")
+
+	metadata := "/Producer" + textString("cdf")
+	if !p.settings.OmitTitle && d.Title != "" {
+		metadata += "/Title" + textString(d.Title)
+	}
+	if !p.settings.OmitSubtitle && d.Subtitle != "" {
+		metadata += "/Subject" + textString(d.Subtitle)
+	}
+	if !p.settings.OmitAuthor && d.Author != "" {
+		metadata += "/Author" + textString(d.Author)
+	}
+	w.object(info, "<<"+metadata+">>")
+	return w.finish(catalog, info)
+}
@@ -0,0 +1,141 @@
+// export/pdf/pdf_test.go
+// PDF export tests.
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Matches the start of a compressed stream.
+var streamPattern = regexp.MustCompile(`<</Filter/FlateDecode/Length (\d+)>>\nstream\n`)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+// Export blocks and get the file and its decompressed page contents.
+func testExport(t *testing.T, settings PDFSettings, blocks ...ast.Block) (string, string, error) {
+	out := bytes.Buffer{}
+	if err := NewPDFExporter(&out, settings).Export(&ast.Document{Content: blocks}); err != nil {
+		return "", "", err
+	}
+	data := out.Bytes()
+	contents := strings.Builder{}
+	for _, m := range streamPattern.FindAllSubmatchIndex(data, -1) {
+		length, err := strconv.Atoi(string(data[m[2]:m[3]]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := zlib.NewReader(bytes.NewReader(data[m[1] : m[1]+length]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents.Write(s)
+	}
+	return string(data), contents.String(), nil
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		block    ast.Block
+		contents []string
+		fonts    []string
+	}{
+		{
+			name:     "escaping",
+			block:    &ast.Paragraph{Content: testText(`(a)\b`)},
+			contents: []string{`(\(a\)\\b) Tj`},
+		},
+		{
+			name: "nested markup",
+			block: &ast.Paragraph{Content: []ast.InlineBlock{&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{
+				&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("a")}, Attribute: ast.ItalicFormatting},
+			}}, Attribute: ast.BoldFormatting}}},
+			contents: []string{"(a) Tj"},
+			fonts:    []string{"/BaseFont/Helvetica-BoldOblique"},
+		},
+		{
+			name:     "list",
+			block:    &ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}, &ast.Paragraph{Content: testText("c")}}},
+			contents: []string{"(1.) Tj", "(a) Tj", "(\x95) Tj", "(b) Tj", "(2.) Tj", "(c) Tj"},
+		},
+		{
+			name: "table",
+			block: &ast.Table{Rows: []ast.TableRow{{Cells: []ast.TableCell{
+				{Content: []ast.Block{&ast.Paragraph{Content: testText("a")}}, IsHeader: true},
+				{Content: []ast.Block{&ast.Paragraph{Content: testText("b")}}},
+			}}}},
+			contents: []string{"0.93 0.93 0.93 rg", "(a) Tj", "(b) Tj", "re S"},
+			fonts:    []string{"/BaseFont/Helvetica-Bold"},
+		},
+		{
+			name:     "remote image",
+			block:    &ast.Image{Source: "https://example.com/a.png", Alt: "alt"},
+			contents: []string{"0.6 0.6 0.6 RG", "(alt) Tj"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, contents, err := testExport(t, PDFSettings{}, test.block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := 0
+			for _, want := range test.contents {
+				i := strings.Index(contents[last:], want)
+				if i == -1 {
+					t.Fatalf("got:\n%s\nwant %q after offset %d", contents, want, last)
+				}
+				last += i + len(want)
+			}
+			for _, want := range test.fonts {
+				if !strings.Contains(data, want) {
+					t.Errorf("missing font %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestExportMissingImage(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.Block
+		alt   string
+	}{
+		{"image", &ast.Image{Source: "missing.png", Alt: "a"}, "(a) Tj"},
+		{"inline image", &ast.Paragraph{Content: []ast.InlineBlock{&ast.InlineImageBlock{Source: "missing.png", Alt: "a"}}}, "([a]) Tj"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if _, _, err := testExport(t, PDFSettings{ImageDirectory: dir}, test.block); err == nil || !strings.Contains(err.Error(), "image 'missing.png'") {
+				t.Errorf("got error %v, want a missing image error", err)
+			}
+			_, contents, err := testExport(t, PDFSettings{ImageDirectory: dir, ImagePlaceholders: true}, test.block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(contents, test.alt) {
+				t.Errorf("got:\n%s\nwant it to contain %q", contents, test.alt)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// export/pdf/settings.go
+// PDF export settings.
+
+package pdf
+
+import (
+	"io"
+
+	"github.com/cubeflix/cdf/export"
+)
+
+const (
+	DefaultMargin        = 72
+	DefaultFontSize      = 11
+	DefaultFont          = "Helvetica"
+	DefaultMonospaceFont = "Courier"
+)
+
+// A page size in points.
+type PageSize struct {
+	Width, Height float64
+}
+
+// Common page sizes.
+var (
+	A4     = PageSize{595.28, 841.89}
+	A5     = PageSize{419.53, 595.28}
+	Letter = PageSize{612, 792}
+	Legal  = PageSize{612, 1008}
+)
+
+// Page margins in points.
+type Margins struct {
+	Top, Right, Bottom, Left float64
+}
+
+// A TrueType font family. Missing styles fall back to the regular font.
+type FontFamily struct {
+	Regular    []byte
+	Bold       []byte
+	Italic     []byte
+	BoldItalic []byte
+}
+
+// PDF export settings.
+type PDFSettings struct {
+	export.Settings
+
+	// The page size. Defaults to A4.
+	PageSize PageSize
+
+	// The page margins. Zero margins default to one inch.
+	Margins Margins
+
+	// The body font size in points. Defaults to 11.
+	FontSize float64
+
+	// The body and teletype font families. These may be standard font
+	// names or families in Fonts. They default to Helvetica and Courier.
+	DefaultFont   string
+	MonospaceFont string
+
+	// TrueType fonts to embed, by family name. Font blocks naming a family
+	// use it.
+	Fonts map[string]FontFamily
+
+	// The directory relative image sources are read from.
+	ImageDirectory string
+
+	// Opens image sources. If nil, local files are read relative to
+	// ImageDirectory and remote images are not loaded.
+	OpenImage func(src string) (io.ReadCloser, error)
+
+	// Draw images that cannot be loaded as placeholders instead of failing.
+	// Remote images are always placeholders, unless OpenImage loads them.
+	ImagePlaceholders bool
+}
@@ -0,0 +1,301 @@
+// export/pdf/text.go
+// Inline content, line breaking and text drawing.
+
+package pdf
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// The line height as a multiple of the font size.
+const lineHeight = 1.25
+
+// An inline text style.
+type style struct {
+	family string
+	size   float64
+
+	bold, italic, underline, strikethrough, teletype bool
+
+	color         [3]uint8
+	background    [3]uint8
+	hasBackground bool
+
+	link string
+}
+
+// A piece of inline content that is laid out as a unit: a word, a space, a
+// hard line break or an inline image.
+type piece struct {
+	text  string
+	style style
+	font  *fontResource
+	width float64
+
+	space, newline bool
+
+	image  *imageResource
+	height float64
+}
+
+// A line of laid out pieces. The line extends above and below its baseline.
+type line struct {
+	pieces       []piece
+	width        float64
+	above, below float64
+}
+
+// Collect the pieces of inline content.
+func (p *PDFExporter) inline(blocks []ast.InlineBlock, st style, width float64, out []piece) ([]piece, error) {
+	var err error
+	for i := range blocks {
+		switch block := blocks[i].(type) {
+		case *ast.Text:
+			out = p.text(block.Value, st, out)
+			continue
+		case *ast.InlineImageBlock:
+			img, err := p.image(block.Source)
+			if err != nil {
+				return nil, err
+			}
+			if img == nil {
+				if block.Alt != "" {
+					out = p.text("["+block.Alt+"]", st, out)
+				}
+				continue
+			}
+			w, h, err := p.imageSize(img, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType, width)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, piece{style: st, image: img, width: w, height: h})
+			continue
+		case *ast.HyperlinkBlock:
+			st := st
+			st.link = block.Destination
+			st.color = [3]uint8{0x00, 0x00, 0xee}
+			st.underline = true
+			out, err = p.inline(block.Content, st, width, out)
+		case *ast.FormattingBlock:
+			st := st
+			switch block.Attribute {
+			case ast.BoldFormatting:
+				st.bold = true
+			case ast.ItalicFormatting:
+				st.italic = true
+			case ast.StrikethroughFormatting:
+				st.strikethrough = true
+			case ast.UnderlineFormatting:
+				st.underline = true
+			case ast.TeletypeFormatting:
+				st.teletype = true
+			default:
+				return nil, errors.New("invalid ast")
+			}
+			out, err = p.inline(block.Content, st, width, out)
+		case *ast.ColorBlock:
+			st := st
+			if block.ForegroundValue != nil {
+				rgb := block.ForegroundValue.ToRGB()
+				st.color = [3]uint8{rgb.R, rgb.G, rgb.B}
+			}
+			if block.BackgroundValue != nil {
+				rgb := block.BackgroundValue.ToRGB()
+				st.background = [3]uint8{rgb.R, rgb.G, rgb.B}
+				st.hasBackground = true
+			}
+			out, err = p.inline(block.Content, st, width, out)
+		case *ast.SizeBlock:
+			st := st
+			st.size, err = length(block.Value, block.Type, st.size)
+			if err != nil {
+				return nil, err
+			}
+			out, err = p.inline(block.Content, st, width, out)
+		case *ast.FontBlock:
+			st := st
+			if family := p.resolveFamily(block.Family); family != "" {
+				st.family = family
+			}
+			out, err = p.inline(block.Content, st, width, out)
+		default:
+			return nil, errors.New("invalid ast")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Split text into pieces. Spaces are collapsed, except in teletype text.
+func (p *PDFExporter) text(s string, st style, out []piece) []piece {
+	f := p.font(st)
+	word := strings.Builder{}
+	flush := func() {
+		if word.Len() > 0 {
+			out = append(out, piece{text: word.String(), style: st, font: f, width: f.measure(word.String(), st.size)})
+			word.Reset()
+		}
+	}
+	for i, part := range strings.Split(s, "\n") {
+		if i > 0 {
+			out = append(out, piece{style: st, font: f, newline: true})
+		}
+		for _, r := range part {
+			if r != ' ' && r != '\t' && r != '\r' {
+				word.WriteRune(r)
+				continue
+			}
+			flush()
+			if !st.teletype && len(out) > 0 && out[len(out)-1].space {
+				continue
+			}
+			out = append(out, piece{text: " ", style: st, font: f, width: f.measure(" ", st.size), space: true})
+		}
+		flush()
+	}
+	return out
+}
+
+// Break pieces into lines that fit a width. Words wider than a line are
+// broken between characters.
+func (p *PDFExporter) breakLines(pieces []piece, width float64, base style) []line {
+	lines := []line{}
+	current := []piece{}
+	used := 0.0
+	finish := func() {
+		for len(current) > 0 && current[len(current)-1].space {
+			current = current[:len(current)-1]
+		}
+		lines = append(lines, p.measureLine(current, base))
+		current, used = []piece{}, 0
+	}
+
+	for i := 0; i < len(pieces); i++ {
+		pc := pieces[i]
+		switch {
+		case pc.newline:
+			finish()
+			continue
+		case pc.space && len(current) == 0:
+			continue
+		case pc.space:
+			current = append(current, pc)
+			used += pc.width
+			continue
+		}
+
+		if used+pc.width > width && len(current) > 0 {
+			finish()
+		}
+		if pc.width > width && pc.image == nil {
+			// Break the word, keeping at least one character per line.
+			runes := []rune(pc.text)
+			n := 1
+			for n < len(runes) && pc.font.measure(string(runes[:n+1]), pc.style.size) <= width {
+				n++
+			}
+			if n < len(runes) {
+				rest := pc
+				rest.text = string(runes[n:])
+				rest.width = pc.font.measure(rest.text, pc.style.size)
+				pc.text = string(runes[:n])
+				pc.width = pc.font.measure(pc.text, pc.style.size)
+				current = append(current, pc)
+				finish()
+				pieces[i] = rest
+				i--
+				continue
+			}
+		}
+		current = append(current, pc)
+		used += pc.width
+	}
+	if len(current) > 0 {
+		finish()
+	}
+	return lines
+}
+
+// Measure a line. Empty lines take the height of the base style.
+func (p *PDFExporter) measureLine(pieces []piece, base style) line {
+	l := line{pieces: pieces}
+	if len(pieces) == 0 {
+		l.above, l.below = textExtent(p.font(base), base.size)
+	}
+	for i := range pieces {
+		l.width += pieces[i].width
+		above, below := pieces[i].height, 0.0
+		if pieces[i].image == nil {
+			above, below = textExtent(pieces[i].font, pieces[i].style.size)
+		}
+		if above > l.above {
+			l.above = above
+		}
+		if below > l.below {
+			l.below = below
+		}
+	}
+	return l
+}
+
+// Get the extent of text above and below the baseline, with half of the
+// leading on each side.
+func textExtent(f *fontResource, size float64) (float64, float64) {
+	ascent, descent := f.metrics(size)
+	leading := (size*lineHeight - ascent - descent) / 2
+	if leading < 0 {
+		leading = 0
+	}
+	return ascent + leading, descent + leading
+}
+
+// Draw a line with its left edge at x.
+func (p *PDFExporter) drawLine(pg *page, l line, x, baseline float64) {
+	for i := 0; i < len(l.pieces); {
+		pc := l.pieces[i]
+		if pc.image != nil {
+			pg.content.WriteString("q " + num(pc.width) + " 0 0 " + num(pc.height) + " " + num(x) + " " + num(baseline) + " cm /" + pc.image.id + " Do Q\n")
+			if pc.style.link != "" {
+				pg.links = append(pg.links, link{[4]float64{x, baseline, x + pc.width, baseline + pc.height}, pc.style.link})
+			}
+			x += pc.width
+			i++
+			continue
+		}
+
+		// Merge pieces of the same style into a run.
+		text, width := pc.text, pc.width
+		i++
+		for i < len(l.pieces) && l.pieces[i].image == nil && l.pieces[i].style == pc.style && l.pieces[i].font == pc.font {
+			text += l.pieces[i].text
+			width += l.pieces[i].width
+			i++
+		}
+		st, size := pc.style, pc.style.size
+		ascent, descent := pc.font.metrics(size)
+		if st.hasBackground {
+			pg.content.WriteString(fillColor(st.background) + " " + num(x) + " " + num(baseline-descent) + " " + num(width) + " " + num(ascent+descent) + " re f\n")
+		}
+		pg.content.WriteString("BT " + fillColor(st.color) + " /" + pc.font.id + " " + num(size) + " Tf " + num(x) + " " + num(baseline) + " Td " + pc.font.encode(text) + " Tj ET\n")
+		if st.underline {
+			pg.content.WriteString(num(x) + " " + num(baseline-size*0.15) + " " + num(width) + " " + num(size*0.06) + " re f\n")
+		}
+		if st.strikethrough {
+			pg.content.WriteString(num(x) + " " + num(baseline+size*0.28) + " " + num(width) + " " + num(size*0.06) + " re f\n")
+		}
+		if st.link != "" {
+			pg.links = append(pg.links, link{[4]float64{x, baseline - descent, x + width, baseline + ascent}, st.link})
+		}
+		x += width
+	}
+}
+
+// Format a fill color operator.
+func fillColor(c [3]uint8) string {
+	return num(float64(c[0])/255) + " " + num(float64(c[1])/255) + " " + num(float64(c[2])/255) + " rg"
+}
@@ -0,0 +1,304 @@
+// export/pdf/truetype.go
+// TrueType font parsing and embedding.
+
+package pdf
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+var errInvalidFont = errors.New("invalid truetype font")
+
+// A parsed TrueType font. Metrics are in thousandths of an em.
+type trueTypeFont struct {
+	data []byte
+	name string
+
+	unitsPerEm                 float64
+	ascent, descent, capHeight float64
+	bbox                       [4]float64
+	italicAngle                float64
+	fixedPitch                 bool
+
+	advances []uint16
+	cmap     map[rune]uint16
+}
+
+// Parse a TrueType font.
+func parseTrueType(data []byte) (*trueTypeFont, error) {
+	if len(data) < 12 {
+		return nil, errInvalidFont
+	}
+	if string(data[:4]) == "OTTO" {
+		return nil, errors.New("opentype fonts with cff outlines are not supported")
+	}
+
+	// Read the table directory.
+	tables := map[string][]byte{}
+	count := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+count*16 {
+		return nil, errInvalidFont
+	}
+	for i := 0; i < count; i++ {
+		record := data[12+i*16:]
+		offset := int(binary.BigEndian.Uint32(record[8:]))
+		length := int(binary.BigEndian.Uint32(record[12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) || offset+length < offset {
+			return nil, errInvalidFont
+		}
+		tables[string(record[:4])] = data[offset : offset+length]
+	}
+	head, hhea, hmtx, cmap := tables["head"], tables["hhea"], tables["hmtx"], tables["cmap"]
+	if len(head) < 54 || len(hhea) < 36 || cmap == nil || tables["glyf"] == nil {
+		return nil, errInvalidFont
+	}
+
+	f := &trueTypeFont{data: data}
+	f.unitsPerEm = float64(binary.BigEndian.Uint16(head[18:]))
+	if f.unitsPerEm == 0 {
+		return nil, errInvalidFont
+	}
+	for i := range f.bbox {
+		f.bbox[i] = f.scale(int16(binary.BigEndian.Uint16(head[36+i*2:])))
+	}
+	f.ascent = f.scale(int16(binary.BigEndian.Uint16(hhea[4:])))
+	f.descent = f.scale(int16(binary.BigEndian.Uint16(hhea[6:])))
+	f.capHeight = f.ascent
+	if os2 := tables["OS/2"]; len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		f.capHeight = f.scale(int16(binary.BigEndian.Uint16(os2[88:])))
+	}
+	if post := tables["post"]; len(post) >= 16 {
+		f.italicAngle = float64(int32(binary.BigEndian.Uint32(post[4:]))) / 65536
+		f.fixedPitch = binary.BigEndian.Uint32(post[12:]) != 0
+	}
+
+	// Read the horizontal metrics.
+	metrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	if metrics == 0 || len(hmtx) < metrics*4 {
+		return nil, errInvalidFont
+	}
+	f.advances = make([]uint16, metrics)
+	for i := range f.advances {
+		f.advances[i] = binary.BigEndian.Uint16(hmtx[i*4:])
+	}
+
+	var err error
+	if f.cmap, err = parseCmap(cmap); err != nil {
+		return nil, err
+	}
+	f.name = fontName(tables["name"])
+	return f, nil
+}
+
+// Scale a value in font units to thousandths of an em.
+func (f *trueTypeFont) scale(v int16) float64 {
+	return float64(v) * 1000 / f.unitsPerEm
+}
+
+// Get the glyph for a character. Missing characters map to glyph 0.
+func (f *trueTypeFont) glyph(r rune) uint16 {
+	if r == '\t' {
+		r = ' '
+	}
+	return f.cmap[r]
+}
+
+// Get the advance width of a glyph.
+func (f *trueTypeFont) width(g uint16) float64 {
+	if int(g) >= len(f.advances) {
+		g = uint16(len(f.advances) - 1)
+	}
+	return float64(f.advances[g]) * 1000 / f.unitsPerEm
+}
+
+// Parse the best Unicode subtable of a cmap table.
+func parseCmap(table []byte) (map[rune]uint16, error) {
+	if len(table) < 4 {
+		return nil, errInvalidFont
+	}
+	count := int(binary.BigEndian.Uint16(table[2:]))
+	if len(table) < 4+count*8 {
+		return nil, errInvalidFont
+	}
+	best, bestScore := []byte(nil), 0
+	for i := 0; i < count; i++ {
+		record := table[4+i*8:]
+		platform, encoding := binary.BigEndian.Uint16(record), binary.BigEndian.Uint16(record[2:])
+		offset := int(binary.BigEndian.Uint32(record[4:]))
+		if offset+4 > len(table) {
+			continue
+		}
+		sub := table[offset:]
+		unicode := platform == 0 || platform == 3 && (encoding == 1 || encoding == 10)
+		score := 0
+		switch format := binary.BigEndian.Uint16(sub); {
+		case unicode && format == 12:
+			score = 2
+		case unicode && format == 4:
+			score = 1
+		}
+		if score > bestScore {
+			best, bestScore = sub, score
+		}
+	}
+
+	m := map[rune]uint16{}
+	switch bestScore {
+	case 2:
+		if len(best) < 16 {
+			return nil, errInvalidFont
+		}
+		groups := int(binary.BigEndian.Uint32(best[12:]))
+		if groups < 0 || len(best) < 16+groups*12 {
+			return nil, errInvalidFont
+		}
+		for i := 0; i < groups; i++ {
+			group := best[16+i*12:]
+			start, end := binary.BigEndian.Uint32(group), binary.BigEndian.Uint32(group[4:])
+			g := binary.BigEndian.Uint32(group[8:])
+			for c := start; c <= end && c <= 0x10ffff; c++ {
+				m[rune(c)] = uint16(g + c - start)
+			}
+		}
+	case 1:
+		if len(best) < 14 {
+			return nil, errInvalidFont
+		}
+		segments := int(binary.BigEndian.Uint16(best[6:])) / 2
+		if len(best) < 16+segments*8 {
+			return nil, errInvalidFont
+		}
+		for i := 0; i < segments; i++ {
+			end := binary.BigEndian.Uint16(best[14+i*2:])
+			start := binary.BigEndian.Uint16(best[16+segments*2+i*2:])
+			delta := binary.BigEndian.Uint16(best[16+segments*4+i*2:])
+			rangePos := 16 + segments*6 + i*2
+			rangeOffset := int(binary.BigEndian.Uint16(best[rangePos:]))
+			for c := int(start); c <= int(end) && c != 0xffff; c++ {
+				if rangeOffset == 0 {
+					m[rune(c)] = uint16(c) + delta
+					continue
+				}
+				pos := rangePos + rangeOffset + (c-int(start))*2
+				if pos+2 > len(best) {
+					break
+				}
+				if g := binary.BigEndian.Uint16(best[pos:]); g != 0 {
+					m[rune(c)] = g + delta
+				}
+			}
+		}
+	default:
+		return nil, errors.New("truetype font has no unicode character map")
+	}
+	return m, nil
+}
+
+// Get the PostScript name of a font from its name table.
+func fontName(table []byte) string {
+	if len(table) >= 6 {
+		count := int(binary.BigEndian.Uint16(table[2:]))
+		storage := int(binary.BigEndian.Uint16(table[4:]))
+		for i := 0; i < count && 6+i*12+12 <= len(table); i++ {
+			record := table[6+i*12:]
+			platform := binary.BigEndian.Uint16(record)
+			if binary.BigEndian.Uint16(record[6:]) != 6 {
+				continue
+			}
+			length := int(binary.BigEndian.Uint16(record[8:]))
+			offset := storage + int(binary.BigEndian.Uint16(record[10:]))
+			if offset+length > len(table) {
+				continue
+			}
+			raw := table[offset : offset+length]
+			name := ""
+			switch platform {
+			case 0, 3:
+				units := make([]uint16, len(raw)/2)
+				for j := range units {
+					units[j] = binary.BigEndian.Uint16(raw[j*2:])
+				}
+				name = string(utf16.Decode(units))
+			case 1:
+				name = string(raw)
+			}
+			name = strings.Map(func(r rune) rune {
+				if r <= ' ' || r > '~' {
+					return -1
+				}
+				return r
+			}, name)
+			if name != "" {
+				return name
+			}
+		}
+	}
+	return "EmbeddedFont"
+}
+
+// Write an embedded TrueType font as a composite font with Identity-H
+// encoding.
+func (p *PDFExporter) writeTrueType(w *writer, n int, f *fontResource) {
+	t := f.trueType
+	descendant, descriptor, file, toUnicode := w.reserve(), w.reserve(), w.reserve(), w.reserve()
+
+	glyphs := make([]int, 0, len(f.used))
+	for g := range f.used {
+		glyphs = append(glyphs, int(g))
+	}
+	sort.Ints(glyphs)
+
+	w.object(n, "<</Type/Font/Subtype/Type0/BaseFont"+name(t.name)+"/Encoding/Identity-H/DescendantFonts["+ref(descendant)+"]/ToUnicode "+ref(toUnicode)+">>")
+
+	widths := strings.Builder{}
+	for _, g := range glyphs {
+		widths.WriteString(strconv.Itoa(g) + "[" + strconv.Itoa(int(math.Round(t.width(uint16(g))))) + "]")
+	}
+	w.object(descendant, "<</Type/Font/Subtype/CIDFontType2/BaseFont"+name(t.name)+
+		"/CIDSystemInfo<</Registry(Adobe)/Ordering(Identity)/Supplement 0>>/FontDescriptor "+ref(descriptor)+
+		"/DW "+strconv.Itoa(int(math.Round(t.width(0))))+"/W["+widths.String()+"]/CIDToGIDMap/Identity>>")
+
+	flags := 32
+	if t.fixedPitch {
+		flags |= 1
+	}
+	if t.italicAngle != 0 {
+		flags |= 64
+	}
+	w.object(descriptor, "<</Type/FontDescriptor/FontName"+name(t.name)+"/Flags "+strconv.Itoa(flags)+
+		"/FontBBox["+num(t.bbox[0])+" "+num(t.bbox[1])+" "+num(t.bbox[2])+" "+num(t.bbox[3])+"]"+
+		"/ItalicAngle "+num(t.italicAngle)+"/Ascent "+num(t.ascent)+"/Descent "+num(t.descent)+
+		"/CapHeight "+num(t.capHeight)+"/StemV 80/FontFile2 "+ref(file)+">>")
+	w.stream(file, "/Length1 "+strconv.Itoa(len(t.data)), t.data, true)
+
+	// Map glyphs back to text, at most 100 mappings per block.
+	cmap := strings.Builder{}
+	cmap.WriteString("/CIDInit/ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo<</Registry(Adobe)/Ordering(UCS)/Supplement 0>>def\n" +
+		"/CMapName/Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000><FFFF>\nendcodespacerange\n")
+	for i := 0; i < len(glyphs); i += 100 {
+		block := glyphs[i:]
+		if len(block) > 100 {
+			block = block[:100]
+		}
+		cmap.WriteString(strconv.Itoa(len(block)) + " beginbfchar\n")
+		for _, g := range block {
+			text := []byte{}
+			for _, u := range utf16.Encode([]rune{f.used[uint16(g)]}) {
+				text = append(text, byte(u>>8), byte(u))
+			}
+			cmap.WriteString("<" + hex.EncodeToString([]byte{byte(g >> 8), byte(g)}) + "><" + hex.EncodeToString(text) + ">\n")
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict/CMap defineresource pop\nend\nend\n")
+	w.stream(toUnicode, "", []byte(cmap.String()), true)
+}
@@ -0,0 +1,133 @@
+// export/pdf/writer.go
+// Low-level PDF object writing.
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// A PDF file under construction. Objects may be written in any order once
+// their numbers are reserved.
+type writer struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// Create a new PDF file writer.
+func newWriter() *writer {
+	w := &writer{}
+	w.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	return w
+}
+
+// Reserve an object number.
+func (w *writer) reserve() int {
+	w.offsets = append(w.offsets, -1)
+	return len(w.offsets)
+}
+
+// Write an object.
+func (w *writer) object(n int, body string) {
+	w.offsets[n-1] = w.buf.Len()
+	w.buf.WriteString(strconv.Itoa(n) + " 0 obj\n" + body + "\nendobj\n")
+}
+
+// Write a stream object. The dictionary's entries are written without the
+// enclosing brackets.
+func (w *writer) stream(n int, dict string, data []byte, compress bool) {
+	if compress {
+		var b bytes.Buffer
+		z := zlib.NewWriter(&b)
+		z.Write(data)
+		z.Close()
+		data = b.Bytes()
+		dict += "/Filter/FlateDecode"
+	}
+	w.offsets[n-1] = w.buf.Len()
+	w.buf.WriteString(strconv.Itoa(n) + " 0 obj\n<<" + dict + "/Length " + strconv.Itoa(len(data)) + ">>\nstream\n")
+	w.buf.Write(data)
+	w.buf.WriteString("\nendstream\nendobj\n")
+}
+
+// Finish the file with the cross-reference table and trailer.
+func (w *writer) finish(root, info int) []byte {
+	start := w.buf.Len()
+	w.buf.WriteString("xref\n0 " + strconv.Itoa(len(w.offsets)+1) + "\n0000000000 65535 f \n")
+	for _, offset := range w.offsets {
+		w.buf.WriteString(pad10(offset) + " 00000 n \n")
+	}
+	w.buf.WriteString("trailer\n<</Size " + strconv.Itoa(len(w.offsets)+1) + "/Root " + ref(root) + "/Info " + ref(info) + ">>\nstartxref\n" + strconv.Itoa(start) + "\n%%EOF\n")
+	return w.buf.Bytes()
+}
+
+// Pad an offset to ten digits.
+func pad10(n int) string {
+	s := strconv.Itoa(n)
+	return strings.Repeat("0", 10-len(s)) + s
+}
+
+// Format an indirect reference.
+func ref(n int) string {
+	return strconv.Itoa(n) + " 0 R"
+}
+
+// Format a number with at most three decimal places.
+func num(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 3, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+// Format a literal string of bytes.
+func literal(b []byte) string {
+	var s strings.Builder
+	s.WriteByte('(')
+	for _, c := range b {
+		switch c {
+		case '\\', '(', ')':
+			s.WriteByte('\\')
+			s.WriteByte(c)
+		case '\r':
+			s.WriteString("\\r")
+		case '\n':
+			s.WriteString("\\n")
+		default:
+			s.WriteByte(c)
+		}
+	}
+	s.WriteByte(')')
+	return s.String()
+}
+
+// Format a text string, like a document title, as UTF-16.
+func textString(s string) string {
+	b := []byte{0xfe, 0xff}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return "<" + hex.EncodeToString(b) + ">"
+}
+
+// Format a name, escaping delimiters and other special characters.
+func name(s string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || strings.IndexByte("#()<>[]{}/%", c) != -1 {
+			b.WriteString("#" + hex.EncodeToString([]byte{c}))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,677 @@
+// export/rst/rst.go
+// Package rst provides functionality for exporting into reStructuredText.
+
+package rst
+
+import (
+	"errors"
+	gohtml "html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cubeflix/cdf/ast"
+	"github.com/cubeflix/cdf/export"
+)
+
+// Heading underline characters by level. The document title and subtitle
+// are overlined and underlined, so they are distinct from headings.
+var headingUnderlines = []string{"=", "-", "~", "^", "\""}
+
+// Lines that would start an enumerated list item.
+var enumerator = regexp.MustCompile(`^(\d+|[a-zA-Z]|[ivxlcdmIVXLCDM]+)([.)])(\s|$)`)
+
+// A piece of inline output. Inline markup must be separated from the text
+// around it by white space or punctuation.
+type piece struct {
+	text   string
+	markup bool
+
+	// Whether the piece is a link or a substitution, which formatting
+	// can't hold.
+	reference bool
+}
+
+// reStructuredText exporter.
+type RSTExporter struct {
+	stream   io.Writer
+	settings RSTSettings
+
+	// Substitution definitions for inline images.
+	substitutions []string
+
+	// The nesting of blocks in lists, quotes, tables and collapse blocks.
+	depth int
+}
+
+// Create a new reStructuredText exporter.
+func NewRSTExporter(stream io.Writer, settings RSTSettings) *RSTExporter {
+	return &RSTExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to reStructuredText.
+func (r *RSTExporter) Export(d *ast.Document) error {
+	r.substitutions = nil
+	r.depth = 0
+	parts := []string{}
+
+	// Write the document information. The title and subtitle become the
+	// document's title and subtitle, and the author and date become its
+	// bibliographic fields.
+	if !r.settings.OmitTitle && d.Title != "" {
+		parts = append(parts, overline(escapeLine(d.Title), "="))
+	}
+	if !r.settings.OmitSubtitle && d.Subtitle != "" {
+		parts = append(parts, overline(escapeLine(d.Subtitle), "-"))
+	}
+	fields := []string{}
+	if !r.settings.OmitAuthor && d.Author != "" {
+		fields = append(fields, ":Author: "+escapeLine(d.Author))
+	}
+	if !r.settings.OmitDate && d.Date != "" {
+		fields = append(fields, ":Date: "+escapeLine(d.Date))
+	}
+	if len(fields) > 0 {
+		parts = append(parts, strings.Join(fields, "\n"))
+	}
+
+	content, err := r.exportBlocks(d.Content)
+	if err != nil {
+		return err
+	}
+	if content != "" {
+		parts = append(parts, content)
+	}
+	parts = append(parts, r.substitutions...)
+
+	out := strings.Join(parts, "\n\n")
+	if out != "" {
+		out += "\n"
+	}
+	_, err = io.WriteString(r.stream, out)
+	return err
+}
+
+// Export a slice of blocks, separated by blank lines.
+func (r *RSTExporter) exportBlocks(blocks []ast.Block) (string, error) {
+	parts := make([]string, 0, len(blocks))
+	separate := false
+	for i := range blocks {
+		part, err := r.exportBlock(blocks[i])
+		if err != nil {
+			return "", err
+		}
+		if part == "" {
+			continue
+		}
+
+		// A block quote following a list or another block quote would be
+		// read as part of it, so they are separated by an empty comment.
+		if separate && strings.HasPrefix(part, "    ") {
+			parts = append(parts, "..")
+		}
+		_, isList := blocks[i].(*ast.List)
+		separate = isList || strings.HasPrefix(part, "    ")
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// Export a block to reStructuredText.
+func (r *RSTExporter) exportBlock(b ast.Block) (string, error) {
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		return r.exportParagraph(block.Content)
+	case *ast.BasicBlock:
+		return r.exportBlocks(block.Content)
+	case *ast.Quote:
+		r.depth++
+		defer func() { r.depth-- }()
+
+		// Quotes starting with a label like "Note:" are admonitions.
+		if kind, content := export.Admonition(block); kind != "" {
+			out, err := r.exportBlocks(content)
+			if err != nil {
+				return "", err
+			}
+			return directive(kind, "", nil, out), nil
+		}
+		out, err := r.exportBlocks(block.Content)
+		if err != nil || out == "" {
+			return "", err
+		}
+		return indent(out, "    "), nil
+	case *ast.Image:
+		return r.exportImage(block)
+	case *ast.Heading:
+		if block.Class < ast.Heading1Type || block.Class > ast.Heading5Type {
+			return "", errors.New("invalid ast")
+		}
+		pieces, err := r.exportInlineBlocks(block.Content)
+		if err != nil {
+			return "", err
+		}
+		content := strings.ReplaceAll(join(pieces), "\n", " ")
+		if content == "" {
+			return "", nil
+		}
+
+		// Sections can't be nested in other blocks.
+		if r.depth > 0 {
+			return "**" + escapeLine(ast.PlainText(block.Content)) + "**", nil
+		}
+		if !startsWithMarkup(pieces) {
+			content = escapeLineStart(content)
+		}
+		return content + "\n" + strings.Repeat(headingUnderlines[block.Class-ast.Heading1Type], width(content)), nil
+	case *ast.HorizontalRule:
+		// Transitions can only appear between sections' paragraphs.
+		if r.depth > 0 {
+			return "", nil
+		}
+		return "----", nil
+	case *ast.List:
+		r.depth++
+		defer func() { r.depth-- }()
+		return r.exportList(block)
+	case *ast.Table:
+		r.depth++
+		defer func() { r.depth-- }()
+		if r.settings.Tables == GridTableStyle {
+			return r.exportGridTable(block)
+		}
+		return r.exportListTable(block)
+	case *ast.Collapse:
+		r.depth++
+		defer func() { r.depth-- }()
+		return r.exportCollapse(block)
+	case *ast.PageBreak:
+		return directive("raw", "latex", nil, "\\newpage"), nil
+	case *ast.Notes:
+		// Speaker notes are left out.
+		return "", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Export a paragraph. Paragraphs with line breaks are line blocks.
+func (r *RSTExporter) exportParagraph(content []ast.InlineBlock) (string, error) {
+	pieces, err := r.exportInlineBlocks(content)
+	if err != nil {
+		return "", err
+	}
+	out := strings.TrimSpace(join(pieces))
+	if out == "" {
+		return "", nil
+	}
+	if !strings.Contains(out, "\n") {
+		if startsWithMarkup(pieces) {
+			return out, nil
+		}
+		return escapeLineStart(out), nil
+	}
+
+	lines := strings.Split(out, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight("| "+strings.TrimSpace(lines[i]), " ")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Export an image block. Images with captions are figures.
+func (r *RSTExporter) exportImage(block *ast.Image) (string, error) {
+	options, err := imageOptions(block.Alt, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+	if err != nil {
+		return "", err
+	}
+	if align := alignment(block.Alignment); align != "" {
+		options = append(options, ":align: "+align)
+	}
+
+	if block.HasCaption && len(block.Caption) != 0 {
+		caption, err := r.exportParagraph(block.Caption)
+		if err != nil {
+			return "", err
+		}
+		if caption != "" {
+			return directive("figure", destination(block.Source), options, caption), nil
+		}
+	}
+	return directive("image", destination(block.Source), options, ""), nil
+}
+
+// Export a list. Nested lists are items of their own, as in the other
+// exporters.
+func (r *RSTExporter) exportList(block *ast.List) (string, error) {
+	items := []string{}
+	compact := true
+	for i := range block.Items {
+		item, err := r.exportBlock(block.Items[i])
+		if err != nil {
+			return "", err
+		}
+		marker := "- "
+		if block.Ordered {
+			marker = strconv.Itoa(len(items)+1) + ". "
+		}
+		if item == "" {
+			items = append(items, strings.TrimRight(marker, " "))
+			continue
+		}
+		if strings.Contains(item, "\n") {
+			compact = false
+		}
+		items = append(items, marker+indent(item, strings.Repeat(" ", len(marker)))[len(marker):])
+	}
+	if compact {
+		return strings.Join(items, "\n"), nil
+	}
+	return strings.Join(items, "\n\n"), nil
+}
+
+// Export a table as a list-table directive.
+func (r *RSTExporter) exportListTable(block *ast.Table) (string, error) {
+	columns := countColumns(block)
+	if columns == 0 {
+		return "", nil
+	}
+
+	options := []string{}
+	if headers := headerRows(block); headers > 0 {
+		options = append(options, ":header-rows: "+strconv.Itoa(headers))
+	}
+	if align := alignment(block.Alignment); align != "" {
+		options = append(options, ":align: "+align)
+	}
+
+	rows := make([]string, len(block.Rows))
+	for i := range block.Rows {
+		cells := make([]string, columns)
+		for j := range cells {
+			cells[j] = "-"
+			if j >= len(block.Rows[i].Cells) {
+				continue
+			}
+			content, err := r.exportBlocks(block.Rows[i].Cells[j].Content)
+			if err != nil {
+				return "", err
+			}
+			if content != "" {
+				cells[j] = "- " + indent(content, "  ")[2:]
+			}
+		}
+		rows[i] = "* " + indent(strings.Join(cells, "\n"), "  ")[2:]
+	}
+	return directive("list-table", "", options, strings.Join(rows, "\n")), nil
+}
+
+// Export a table as a grid table.
+func (r *RSTExporter) exportGridTable(block *ast.Table) (string, error) {
+	columns := countColumns(block)
+	if columns == 0 {
+		return "", nil
+	}
+
+	// Get the lines of each cell and the width of each column.
+	widths := make([]int, columns)
+	cells := make([][][]string, len(block.Rows))
+	for i := range block.Rows {
+		cells[i] = make([][]string, columns)
+		for j := range block.Rows[i].Cells {
+			content, err := r.exportBlocks(block.Rows[i].Cells[j].Content)
+			if err != nil {
+				return "", err
+			}
+			if content != "" {
+				cells[i][j] = strings.Split(content, "\n")
+			}
+			for _, l := range cells[i][j] {
+				if width(l) > widths[j] {
+					widths[j] = width(l)
+				}
+			}
+		}
+	}
+
+	border := func(c string) string {
+		line := "+"
+		for _, w := range widths {
+			line += strings.Repeat(c, w+2) + "+"
+		}
+		return line
+	}
+	headers := headerRows(block)
+	lines := []string{border("-")}
+	for i := range cells {
+		height := 1
+		for j := range cells[i] {
+			if len(cells[i][j]) > height {
+				height = len(cells[i][j])
+			}
+		}
+		for k := 0; k < height; k++ {
+			line := "|"
+			for j := range cells[i] {
+				cell := ""
+				if k < len(cells[i][j]) {
+					cell = cells[i][j][k]
+				}
+				line += " " + cell + strings.Repeat(" ", widths[j]-width(cell)) + " |"
+			}
+			lines = append(lines, line)
+		}
+		if i == headers-1 && headers < len(cells) {
+			lines = append(lines, border("="))
+		} else {
+			lines = append(lines, border("-"))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Export a collapse block, as the collapse directive or as HTML.
+func (r *RSTExporter) exportCollapse(block *ast.Collapse) (string, error) {
+	content, err := r.exportBlocks(block.Content)
+	if err != nil {
+		return "", err
+	}
+	if r.settings.CollapseDirective != "" {
+		summary, err := r.exportInlineBlocks(block.Summary)
+		if err != nil {
+			return "", err
+		}
+		return directive(r.settings.CollapseDirective, strings.ReplaceAll(join(summary), "\n", " "), nil, content), nil
+	}
+
+	out := directive("raw", "html", nil, "<details>\n<summary>"+gohtml.EscapeString(ast.PlainText(block.Summary))+"</summary>")
+	if content != "" {
+		out += "\n\n" + content
+	}
+	return out + "\n\n" + directive("raw", "html", nil, "</details>"), nil
+}
+
+// Export a slice of inline blocks.
+func (r *RSTExporter) exportInlineBlocks(blocks []ast.InlineBlock) ([]piece, error) {
+	pieces := []piece{}
+	for i := range blocks {
+		part, err := r.exportInlineBlock(blocks[i])
+		if err != nil {
+			return nil, err
+		}
+		pieces = append(pieces, part...)
+	}
+	return pieces, nil
+}
+
+// Export an inline block. Inline markup can't be nested, so formatting
+// around links and images is dropped, and formatting inside other formatting
+// gives way to the outer formatting.
+func (r *RSTExporter) exportInlineBlock(b ast.InlineBlock) ([]piece, error) {
+	switch block := b.(type) {
+	case *ast.Text:
+		return []piece{{text: escapeText(block.Value)}}, nil
+	case *ast.InlineImageBlock:
+		// Inline images are substitutions, defined at the end.
+		options, err := imageOptions(block.Alt, block.HasWidthParameter, block.WidthValue, block.WidthType, block.HasHeightParameter, block.HeightValue, block.HeightType)
+		if err != nil {
+			return nil, err
+		}
+		name := "image" + strconv.Itoa(len(r.substitutions)+1)
+		r.substitutions = append(r.substitutions, ".. |"+name+"| "+directive("image", destination(block.Source), options, "")[3:])
+		return []piece{{text: "|" + name + "|", markup: true, reference: true}}, nil
+	case *ast.HyperlinkBlock:
+		label := escapeLine(ast.PlainText(block.Content))
+		dest := destination(block.Destination)
+		if label == "" {
+			label = dest
+		}
+		label = strings.NewReplacer("<", `\<`, ">", `\>`).Replace(label)
+		return []piece{{text: "`" + label + " <" + dest + ">`__", markup: true, reference: true}}, nil
+	case *ast.FormattingBlock:
+		pieces, err := r.exportInlineBlocks(block.Content)
+		if err != nil {
+			return nil, err
+		}
+		for i := range pieces {
+			if pieces[i].reference {
+				return pieces, nil
+			}
+		}
+
+		// Inner formatting is dropped, keeping the outer formatting.
+		text := escapeText(ast.PlainText(block.Content))
+		switch block.Attribute {
+		case ast.BoldFormatting:
+			return markup(text, "**", "**"), nil
+		case ast.ItalicFormatting:
+			return markup(text, "*", "*"), nil
+		case ast.TeletypeFormatting:
+			literal := strings.ReplaceAll(ast.PlainText(block.Content), "\n", " ")
+			if strings.Contains(literal, "``") || strings.HasPrefix(strings.TrimSpace(literal), "`") || strings.HasSuffix(strings.TrimSpace(literal), "`") {
+				// Inline literals can't hold these backticks, but the code
+				// role's escaped text can.
+				return markup(text, ":code:`", "`"), nil
+			}
+			return markup(literal, "``", "``"), nil
+		case ast.StrikethroughFormatting, ast.UnderlineFormatting:
+			// There is no strikethrough or underline markup.
+			return pieces, nil
+		}
+		return nil, errors.New("invalid ast")
+	case *ast.ColorBlock, *ast.SizeBlock, *ast.FontBlock:
+		// Colors, sizes and fonts are dropped.
+		return r.exportInlineBlocks(block.Children())
+	}
+	return nil, errors.New("invalid ast")
+}
+
+// Wrap text in inline markup. Surrounding white space is moved outside of
+// the markup, which can't start or end with white space.
+func markup(text, start, end string) []piece {
+	text = strings.ReplaceAll(text, "\n", " ")
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return []piece{{text: text}}
+	}
+	i := strings.Index(text, trimmed)
+	pieces := []piece{}
+	if i > 0 {
+		pieces = append(pieces, piece{text: text[:i]})
+	}
+	pieces = append(pieces, piece{text: start + trimmed + end, markup: true})
+	if j := i + len(trimmed); j < len(text) {
+		pieces = append(pieces, piece{text: text[j:]})
+	}
+	return pieces
+}
+
+// Join pieces of inline output. Markup next to text is separated by an
+// escaped space, which is removed when read.
+func join(pieces []piece) string {
+	out := strings.Builder{}
+	previous := ""
+	for i, p := range pieces {
+		if p.text == "" {
+			continue
+		}
+		before, _ := utf8.DecodeLastRuneInString(previous)
+		after, _ := utf8.DecodeRuneInString(p.text)
+		if previous != "" && ((p.markup && !unicode.IsSpace(before) && !strings.ContainsRune(`-:/'"<([{`, before)) ||
+			(pieces[i-1].markup && !unicode.IsSpace(after) && !strings.ContainsRune(`-.,:;!?\/'")]}>`, after))) {
+			out.WriteString(`\ `)
+		}
+		out.WriteString(p.text)
+		previous = p.text
+	}
+	return out.String()
+}
+
+// Get whether inline output starts with markup, rather than text.
+func startsWithMarkup(pieces []piece) bool {
+	for _, p := range pieces {
+		if strings.TrimSpace(p.text) != "" {
+			return p.markup
+		}
+	}
+	return false
+}
+
+// Write a directive. The content is indented under the options.
+func directive(name, argument string, options []string, content string) string {
+	out := ".. " + name + "::"
+	if argument != "" {
+		out += " " + argument
+	}
+	for _, option := range options {
+		out += "\n   " + option
+	}
+	if content != "" {
+		out += "\n\n" + indent(content, "   ")
+	}
+	return out
+}
+
+// Get the options of an image directive.
+func imageOptions(alt string, hasWidth bool, widthValue float32, widthType ast.SizeType, hasHeight bool, heightValue float32, heightType ast.SizeType) ([]string, error) {
+	options := []string{}
+	if alt = strings.Join(strings.Fields(alt), " "); alt != "" {
+		options = append(options, ":alt: "+alt)
+	}
+	if hasWidth {
+		size, err := length(widthValue, widthType)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, ":width: "+size)
+	}
+
+	// Heights can't be percentages.
+	if hasHeight && heightType != ast.PercentageSizeType {
+		size, err := length(heightValue, heightType)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, ":height: "+size)
+	}
+	return options, nil
+}
+
+// Get a reStructuredText length.
+func length(value float32, t ast.SizeType) (string, error) {
+	v := strconv.FormatFloat(float64(value), 'f', -1, 32)
+	switch t {
+	case ast.PercentageSizeType:
+		return v + "%", nil
+	case ast.PixelSizeType:
+		return v + "px", nil
+	case ast.PointSizeType:
+		return v + "pt", nil
+	case ast.CentimeterSizeType:
+		return v + "cm", nil
+	case ast.MillimeterSizeType:
+		return v + "mm", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Get the alignment option of an image or table. Returns an empty string for no
+// alignment.
+func alignment(a ast.AlignmentType) string {
+	switch a {
+	case ast.LeftAlign:
+		return "left"
+	case ast.RightAlign:
+		return "right"
+	case ast.CenterAlign:
+		return "center"
+	}
+	return ""
+}
+
+// Get the number of columns in a table.
+func countColumns(block *ast.Table) int {
+	columns := 0
+	for i := range block.Rows {
+		if len(block.Rows[i].Cells) > columns {
+			columns = len(block.Rows[i].Cells)
+		}
+	}
+	return columns
+}
+
+// Get the number of leading rows of header cells.
+func headerRows(block *ast.Table) int {
+	for i := range block.Rows {
+		if len(block.Rows[i].Cells) == 0 {
+			return i
+		}
+		for _, cell := range block.Rows[i].Cells {
+			if !cell.IsHeader {
+				return i
+			}
+		}
+	}
+	return len(block.Rows)
+}
+
+// Format a link or image destination. White space would be removed.
+func destination(dest string) string {
+	return strings.NewReplacer(" ", "%20", "\t", "%09", "\n", "%0A", "<", "%3C", ">", "%3E", "`", "%60").Replace(dest)
+}
+
+// Escape inline markup characters in text.
+func escapeText(s string) string {
+	out := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte("\\*`_|", s[i]) != -1 {
+			out.WriteByte('\\')
+		}
+		out.WriteByte(s[i])
+	}
+	return out.String()
+}
+
+// Escape a single line of text, like the title.
+func escapeLine(s string) string {
+	return strings.Join(strings.Fields(escapeText(s)), " ")
+}
+
+// Escape the start of a line that would start another block, like a list
+// item, a comment or a section underline.
+func escapeLineStart(s string) string {
+	if m := enumerator.FindStringSubmatchIndex(s); m != nil {
+		return s[:m[4]] + `\` + s[m[4]:]
+	}
+	if c, _ := utf8.DecodeRuneInString(s); c < utf8.RuneSelf && c != '\\' && (unicode.IsPunct(c) || unicode.IsSymbol(c)) {
+		return `\` + s
+	}
+	return s
+}
+
+// Write a title with an overline and underline.
+func overline(s, c string) string {
+	line := strings.Repeat(c, width(s))
+	return line + "\n" + s + "\n" + line
+}
+
+// Indent each non-empty line.
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		if lines[i] != "" {
+			lines[i] = prefix + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Get the width of a line, in characters.
+func width(s string) int {
+	return utf8.RuneCountInString(s)
+}
@@ -0,0 +1,92 @@
+// export/rst/rst_test.go
+// reStructuredText export tests.
+
+package rst
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+// Create a formatting block.
+func testFormat(attribute ast.FormattingType, content ...ast.InlineBlock) ast.InlineBlock {
+	return &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: attribute}
+}
+
+// Export blocks.
+func testExport(t *testing.T, blocks ...ast.Block) string {
+	out := strings.Builder{}
+	if err := NewRSTExporter(&out, RSTSettings{}).Export(&ast.Document{Content: blocks}); err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(out.String())
+}
+
+func TestExportInline(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []ast.InlineBlock
+		want    string
+	}{
+		{"escaping", testText("*a* `b` _c |d| \\"), "\\*a\\* \\`b\\` \\_c \\|d\\| \\\\"},
+		{"bold italic", []ast.InlineBlock{testFormat(ast.BoldFormatting, &ast.Text{Value: "a "}, testFormat(ast.ItalicFormatting, &ast.Text{Value: "b*"}))}, "**a b\\***"},
+		{"italic bold", []ast.InlineBlock{&ast.Text{Value: "x"}, testFormat(ast.ItalicFormatting, testFormat(ast.BoldFormatting, &ast.Text{Value: " a "})), &ast.Text{Value: "y"}}, "x *a* y"},
+		{"code in bold", []ast.InlineBlock{testFormat(ast.BoldFormatting, testFormat(ast.TeletypeFormatting, &ast.Text{Value: "a"}))}, "**a**"},
+		{"bold in code", []ast.InlineBlock{testFormat(ast.TeletypeFormatting, &ast.Text{Value: "a "}, testFormat(ast.BoldFormatting, &ast.Text{Value: "b"}))}, "``a b``"},
+		{"code", []ast.InlineBlock{testFormat(ast.TeletypeFormatting, &ast.Text{Value: "a*b"})}, "``a*b``"},
+		{"code with backtick", []ast.InlineBlock{testFormat(ast.TeletypeFormatting, &ast.Text{Value: "a`"})}, ":code:`a\\``"},
+		{"code with backticks", []ast.InlineBlock{testFormat(ast.TeletypeFormatting, &ast.Text{Value: "``a\\b``"})}, ":code:`\\`\\`a\\\\b\\`\\``"},
+		{
+			"link in bold",
+			[]ast.InlineBlock{testFormat(ast.BoldFormatting, &ast.Text{Value: "a "}, &ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("b")}, Destination: "https://example.com"})},
+			"a `b <https://example.com>`__",
+		},
+	}
+	for _, test := range tests {
+		if got := testExport(t, &ast.Paragraph{Content: test.content}); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.Block
+		want  string
+	}{
+		{
+			"nested list",
+			&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}},
+			"- a\n- - b",
+		},
+		{
+			"nested ordered list",
+			&ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("b")}, &ast.Paragraph{Content: testText("c")}}}, &ast.Paragraph{Content: testText("d")}}},
+			"1. a\n\n2. - b\n   - c\n\n3. d",
+		},
+		{
+			"first nested list",
+			&ast.List{Items: []ast.Block{&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}}}}},
+			"- - a",
+		},
+		{
+			"table",
+			&ast.Table{Rows: []ast.TableRow{{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("a|b")}}, IsHeader: true}, {}}}}},
+			".. list-table::\n\n   * - a\\|b\n     -",
+		},
+		{"missing image", &ast.Image{Source: "missing.png", Alt: "a"}, ".. image:: missing.png\n   :alt: a"},
+	}
+	for _, test := range tests {
+		if got := testExport(t, test.block); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
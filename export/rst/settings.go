@@ -0,0 +1,28 @@
+// export/rst/settings.go
+// reStructuredText export settings.
+
+package rst
+
+import "github.com/cubeflix/cdf/export"
+
+// Table markup.
+type TableStyle int64
+
+const (
+	// The list-table directive, which holds any content.
+	ListTableStyle TableStyle = iota
+	// Grid tables, drawn with ASCII art.
+	GridTableStyle
+)
+
+// reStructuredText export settings.
+type RSTSettings struct {
+	export.Settings
+
+	Tables TableStyle
+
+	// The directive collapse blocks are written as, with the summary as its
+	// argument, like "dropdown" from sphinx-design. Without a directive,
+	// collapse blocks are passed through as HTML details elements.
+	CollapseDirective string
+}
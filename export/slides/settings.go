@@ -0,0 +1,44 @@
+// export/slides/settings.go
+// Slide deck export settings.
+
+package slides
+
+import (
+	"github.com/cubeflix/cdf/export"
+	"github.com/cubeflix/cdf/export/html"
+)
+
+const DefaultHeadingLevel = 2
+
+// Where slides start.
+type SplitType int64
+
+const (
+	// Slides start at top-level page breaks and headings.
+	HeadingSplit SplitType = iota
+
+	// Slides start at top-level page breaks only.
+	PageBreakSplit
+)
+
+// Slide deck export settings.
+type SlidesSettings struct {
+	export.Settings
+
+	Split SplitType
+
+	// The deepest heading level that starts a slide, from 1 to 5. Defaults
+	// to 2.
+	HeadingLevel int
+
+	// The directory relative image sources are read from. Local images are
+	// embedded as data URLs, so the deck is a single file. Images are
+	// referenced as they are if empty.
+	ImageDirectory string
+
+	// CSS appended to the deck's stylesheet.
+	Stylesheet string
+
+	// Settings for the slides' HTML. Notes are written by the deck.
+	HTML html.HTMLSettings
+}
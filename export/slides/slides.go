@@ -0,0 +1,231 @@
+// export/slides/slides.go
+// Package slides provides functionality for exporting into a slide deck, as
+// a single HTML file.
+
+package slides
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	gohtml "html"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	"github.com/cubeflix/cdf/export/html"
+)
+
+// Slide deck exporter.
+type SlidesExporter struct {
+	stream   io.Writer
+	settings SlidesSettings
+}
+
+// Create a new slide deck exporter.
+func NewSlidesExporter(stream io.Writer, settings SlidesSettings) *SlidesExporter {
+	if settings.HeadingLevel < 1 || settings.HeadingLevel > 5 {
+		settings.HeadingLevel = DefaultHeadingLevel
+	}
+	settings.HTML.OmitTitle, settings.HTML.OmitSubtitle, settings.HTML.OmitDate, settings.HTML.OmitAuthor = true, true, true, true
+	settings.HTML.IncludeHeader, settings.HTML.IncludeFooter = false, false
+	settings.HTML.ClassStyles = false
+	settings.HTML.IncludeNotes = false
+
+	return &SlidesExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// A slide, and its speaker notes.
+type slide struct {
+	content []ast.Block
+	notes   []ast.Block
+}
+
+// Export the document to a slide deck. The title, subtitle, date and author
+// are the first slide.
+func (s *SlidesExporter) Export(d *ast.Document) error {
+	// Work on a copy, so image sources can be rewritten and notes removed.
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	d = &ast.Document{}
+	if err := json.Unmarshal(data, d); err != nil {
+		return err
+	}
+	if s.settings.ImageDirectory != "" {
+		if err := s.embedImages(d); err != nil {
+			return err
+		}
+	}
+
+	slides := s.split(d.Content)
+	title := s.titleSlide(d)
+	total := len(slides)
+	if title != "" {
+		total++
+	}
+
+	out := bytes.Buffer{}
+	out.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	out.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	out.WriteString("<title>" + gohtml.EscapeString(d.Title) + "</title>\n")
+	if d.Author != "" {
+		out.WriteString("<meta name=\"author\" content=\"" + gohtml.EscapeString(d.Author) + "\">\n")
+	}
+	out.WriteString("<style>\n" + stylesheet)
+	if s.settings.Stylesheet != "" {
+		out.WriteString(s.settings.Stylesheet + "\n")
+	}
+	out.WriteString("</style>\n</head>\n<body>\n")
+
+	number := 0
+	if title != "" {
+		number++
+		out.WriteString("<section class=\"slide title-slide\" id=\"slide-1\">\n<div class=\"slide-content\">\n" + title + "</div>\n")
+		out.WriteString(slideNumber(number, total) + "</section>\n")
+	}
+	for i := range slides {
+		number++
+		out.WriteString("<section class=\"slide\" id=\"slide-" + strconv.Itoa(number) + "\">\n<div class=\"slide-content\">\n")
+		if err := html.NewHTMLExporter(&out, s.settings.HTML).Export(&ast.Document{Content: slides[i].content}); err != nil {
+			return err
+		}
+		out.WriteString("</div>\n")
+		if len(slides[i].notes) > 0 {
+			out.WriteString("<aside class=\"notes\">\n")
+			if err := html.NewHTMLExporter(&out, s.settings.HTML).Export(&ast.Document{Content: slides[i].notes}); err != nil {
+				return err
+			}
+			out.WriteString("</aside>\n")
+		}
+		out.WriteString(slideNumber(number, total) + "</section>\n")
+	}
+
+	out.WriteString("<script>\n" + script + "</script>\n</body>\n</html>\n")
+	_, err = s.stream.Write(out.Bytes())
+	return err
+}
+
+// Split blocks into slides. Notes anywhere in a slide are moved to its
+// notes. Slides without content are dropped, and their notes are added to
+// the previous slide.
+func (s *SlidesExporter) split(blocks []ast.Block) []slide {
+	parts := [][]ast.Block{}
+	current := []ast.Block{}
+	for i := range blocks {
+		switch block := blocks[i].(type) {
+		case *ast.PageBreak:
+			parts = append(parts, current)
+			current = []ast.Block{}
+			continue
+		case *ast.Heading:
+			if s.settings.Split == HeadingSplit && int(block.Class-ast.Heading1Type)+1 <= s.settings.HeadingLevel && hasContent(current) {
+				parts = append(parts, current)
+				current = []ast.Block{}
+			}
+		}
+		current = append(current, blocks[i])
+	}
+	parts = append(parts, current)
+
+	slides := []slide{}
+	for i := range parts {
+		part := &ast.Document{Content: parts[i]}
+		notes := []ast.Block{}
+		ast.Transform(part, func(c *ast.Cursor) bool {
+			if n, ok := c.Node().(*ast.Notes); ok {
+				notes = append(notes, n.Content...)
+				c.Delete()
+				return false
+			}
+			return true
+		}, nil)
+
+		if len(part.Content) == 0 {
+			if len(slides) > 0 {
+				slides[len(slides)-1].notes = append(slides[len(slides)-1].notes, notes...)
+			}
+			continue
+		}
+		slides = append(slides, slide{content: part.Content, notes: notes})
+	}
+	return slides
+}
+
+// Check if blocks have content other than notes.
+func hasContent(blocks []ast.Block) bool {
+	for i := range blocks {
+		if _, ok := blocks[i].(*ast.Notes); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the HTML of the title slide, or an empty string if there is nothing
+// to show.
+func (s *SlidesExporter) titleSlide(d *ast.Document) string {
+	out := ""
+	if !s.settings.OmitTitle && d.Title != "" {
+		out += "<h1>" + gohtml.EscapeString(d.Title) + "</h1>\n"
+	}
+	if !s.settings.OmitSubtitle && d.Subtitle != "" {
+		out += "<p class=\"subtitle\">" + gohtml.EscapeString(d.Subtitle) + "</p>\n"
+	}
+	if !s.settings.OmitAuthor && d.Author != "" {
+		out += "<p class=\"author\">" + gohtml.EscapeString(d.Author) + "</p>\n"
+	}
+	if !s.settings.OmitDate && d.Date != "" {
+		out += "<p class=\"date\">" + gohtml.EscapeString(d.Date) + "</p>\n"
+	}
+	return out
+}
+
+// Get a slide's number label.
+func slideNumber(number, total int) string {
+	return "<div class=\"slide-number\">" + strconv.Itoa(number) + " / " + strconv.Itoa(total) + "</div>\n"
+}
+
+// Embed the document's local images as data URLs.
+func (s *SlidesExporter) embedImages(d *ast.Document) error {
+	var err error
+	ast.Inspect(d, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.Image:
+			node.Source, err = s.image(node.Source)
+		case *ast.InlineImageBlock:
+			node.Source, err = s.image(node.Source)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// Get an image's data URL. Remote images and data URLs are left in place.
+func (s *SlidesExporter) image(src string) (string, error) {
+	if src == "" || strings.HasPrefix(src, "data:") || strings.Contains(src, "://") {
+		return src, nil
+	}
+	file := src
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(s.settings.ImageDirectory, filepath.FromSlash(src))
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", errors.New("image '" + src + "': " + err.Error())
+	}
+	mediaType := http.DetectContentType(data)
+	if strings.EqualFold(filepath.Ext(file), ".svg") {
+		mediaType = "image/svg+xml"
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,131 @@
+// export/slides/slides_test.go
+// Slide deck export tests.
+
+package slides
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Matches the content of a slide and its notes.
+var slidePattern = regexp.MustCompile(`(?s)<section class="slide[^"]*" id="slide-\d+">\n<div class="slide-content">\n(.*?)</div>\n(?:<aside class="notes">\n(.*?)</aside>\n)?<div class="slide-number">`)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+// Export a document and get the content and notes of its slides.
+func testExport(t *testing.T, settings SlidesSettings, d *ast.Document) [][2]string {
+	out := strings.Builder{}
+	if err := NewSlidesExporter(&out, settings).Export(d); err != nil {
+		t.Fatal(err)
+	}
+	slides := [][2]string{}
+	for _, m := range slidePattern.FindAllStringSubmatch(out.String(), -1) {
+		slides = append(slides, [2]string{m[1], m[2]})
+	}
+	return slides
+}
+
+func TestExportSlides(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings SlidesSettings
+		document ast.Document
+		want     [][2]string
+	}{
+		{
+			name:     "title",
+			document: ast.Document{Title: "<a> & b", Author: "c", Content: []ast.Block{}},
+			want:     [][2]string{{"<h1>&lt;a&gt; &amp; b</h1>\n<p class=\"author\">c</p>\n", ""}},
+		},
+		{
+			name: "headings",
+			document: ast.Document{Content: []ast.Block{
+				&ast.Heading{Class: ast.Heading1Type, Content: testText("a")},
+				&ast.Heading{Class: ast.Heading2Type, Content: testText("b")},
+				&ast.Paragraph{Content: testText("c")},
+				&ast.Heading{Class: ast.Heading3Type, Content: testText("d")},
+			}},
+			want: [][2]string{{"<h1>a</h1>\n", ""}, {"<h2>b</h2>\n<p>c</p>\n<h3>d</h3>\n", ""}},
+		},
+		{
+			name:     "page breaks",
+			settings: SlidesSettings{Split: PageBreakSplit},
+			document: ast.Document{Content: []ast.Block{
+				&ast.Heading{Class: ast.Heading1Type, Content: testText("a")},
+				&ast.Heading{Class: ast.Heading2Type, Content: testText("b")},
+				&ast.PageBreak{},
+				&ast.PageBreak{},
+				&ast.Paragraph{Content: testText("c")},
+			}},
+			want: [][2]string{{"<h1>a</h1>\n<h2>b</h2>\n", ""}, {"<p>c</p>\n", ""}},
+		},
+		{
+			name: "notes",
+			document: ast.Document{Content: []ast.Block{
+				&ast.Paragraph{Content: testText("a")},
+				&ast.Quote{Content: []ast.Block{&ast.Paragraph{Content: testText("d")}, &ast.Notes{Content: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}},
+				&ast.PageBreak{},
+				&ast.Notes{Content: []ast.Block{&ast.Paragraph{Content: testText("c")}}},
+			}},
+			want: [][2]string{{"<p>a</p>\n<div class=\"quote\"><p>d</p>\n</div>\n", "<p>b</p>\n<p>c</p>\n"}},
+		},
+		{
+			name: "markup, lists and tables",
+			document: ast.Document{Content: []ast.Block{
+				&ast.Paragraph{Content: []ast.InlineBlock{&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{
+					&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("a")}, Attribute: ast.ItalicFormatting},
+				}}, Attribute: ast.BoldFormatting}}},
+				&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}},
+				&ast.Table{Rows: []ast.TableRow{{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("c")}}}}}}},
+			}},
+			want: [][2]string{{"<p><b><i>a</i></b></p>\n<ul>\n<li><p>b</p>\n</li>\n</ul>\n<table>\n<tr>\n<td><p>c</p>\n</td>\n</tr>\n</table>\n", ""}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := testExport(t, test.settings, &test.document)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d slides %q, want %d", len(got), got, len(test.want))
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("slide %d: got %q, want %q", i+1, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExportImages(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.svg"), []byte("<svg></svg>"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	d := &ast.Document{Content: []ast.Block{
+		&ast.Image{Source: "a.svg"},
+		&ast.Image{Source: "https://example.com/b.png"},
+	}}
+	got := testExport(t, SlidesSettings{ImageDirectory: dir}, d)
+	if len(got) != 1 || !strings.Contains(got[0][0], `src="data:image/svg+xml;base64,PHN2Zz48L3N2Zz4="`) || !strings.Contains(got[0][0], `src="https://example.com/b.png"`) {
+		t.Errorf("got %q", got)
+	}
+	if d.Content[0].(*ast.Image).Source != "a.svg" {
+		t.Errorf("the document was changed")
+	}
+
+	d = &ast.Document{Content: []ast.Block{&ast.Image{Source: "missing.png"}}}
+	err := NewSlidesExporter(&strings.Builder{}, SlidesSettings{ImageDirectory: dir}).Export(d)
+	if err == nil || !strings.HasPrefix(err.Error(), "image 'missing.png': ") {
+		t.Errorf("got error %v, want a missing image error", err)
+	}
+}
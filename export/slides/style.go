@@ -0,0 +1,127 @@
+// export/slides/style.go
+// The deck's stylesheet and navigation script.
+
+package slides
+
+// The deck's stylesheet. Slides are 16:9, and printed one per page. Without
+// the script, all slides are shown in a column.
+const stylesheet = `* { box-sizing: border-box; }
+html, body { margin: 0; background: #222; }
+body { font-family: system-ui, -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; color: #222; line-height: 1.4; }
+.slide { position: relative; display: flex; flex-direction: column; width: 100vw; height: 56.25vw; max-width: 177.78vh; max-height: 100vh; margin: 0 auto 2vh; background: #fff; overflow: hidden; font-size: min(2.5vw, 4.44vh); }
+.deck .slide { display: none; position: fixed; top: 0; right: 0; bottom: 0; left: 0; margin: auto; }
+.deck .slide.current { display: flex; }
+.slide-content { flex: 1; padding: 5% 7%; overflow: hidden; }
+.slide h1 { font-size: 2em; margin: 0 0 .5em; }
+.slide h2 { font-size: 1.6em; margin: 0 0 .5em; }
+.slide h3 { font-size: 1.3em; margin: 0 0 .4em; }
+.slide h4, .slide h5 { font-size: 1.1em; margin: 0 0 .4em; }
+.slide p { margin: 0 0 .6em; }
+.slide img { max-width: 100%; }
+.slide code { font-family: ui-monospace, Menlo, Consolas, monospace; font-size: .9em; background: #f2f2f2; padding: 0 .2em; }
+.slide table { border-collapse: collapse; margin-bottom: .6em; }
+.slide th, .slide td { border: 1px solid #ccc; padding: .2em .6em; text-align: left; vertical-align: top; }
+.slide th { background: #f2f2f2; }
+.slide .quote { border-left: .2em solid #ccc; padding-left: 1em; color: #555; }
+.slide .image-block { text-align: center; }
+.slide .image-caption { font-size: .7em; color: #666; }
+.title-slide .slide-content { display: flex; flex-direction: column; justify-content: center; text-align: center; }
+.title-slide h1 { font-size: 2.6em; }
+.title-slide .subtitle { font-size: 1.4em; color: #555; }
+.title-slide .author, .title-slide .date { color: #777; }
+.slide-number { position: absolute; right: 2%; bottom: 2%; font-size: .6em; color: #999; }
+.notes { display: none; }
+.show-notes .slide.current .notes { display: block; position: fixed; left: 0; right: 0; bottom: 0; max-height: 35vh; overflow: auto; padding: 1em 2em; background: #111; color: #eee; font-size: 16px; z-index: 1; }
+@page { size: 254mm 142.875mm; margin: 0; }
+@media print {
+	html, body { background: none; }
+	.slide, .deck .slide { display: flex; position: relative; width: 254mm; height: 142.875mm; max-width: none; max-height: none; margin: 0; font-size: 18pt; break-after: page; page-break-after: always; }
+	.show-notes .slide.current .notes { display: none; }
+}
+`
+
+// The deck's navigation script. The arrow keys, page keys, space and
+// backspace move between slides, Home and End jump to the first and last
+// slides, S shows the speaker notes and F toggles full screen. The current
+// slide is kept in the URL's fragment.
+const script = `(function () {
+	var slides = document.querySelectorAll(".slide");
+	if (slides.length == 0) {
+		return;
+	}
+	var current = 0;
+
+	function fromHash() {
+		var target = document.getElementById(location.hash.slice(1));
+		for (var i = 0; i < slides.length; i++) {
+			if (slides[i] === target) {
+				return i;
+			}
+		}
+		return 0;
+	}
+
+	function show(i) {
+		if (i < 0 || i >= slides.length) {
+			return;
+		}
+		slides[current].classList.remove("current");
+		current = i;
+		slides[current].classList.add("current");
+		if (location.hash != "#" + slides[current].id) {
+			history.replaceState(null, "", "#" + slides[current].id);
+		}
+	}
+
+	document.body.classList.add("deck");
+	current = fromHash();
+	show(current);
+
+	document.addEventListener("keydown", function (e) {
+		if (e.altKey || e.ctrlKey || e.metaKey) {
+			return;
+		}
+		switch (e.key) {
+		case "ArrowRight":
+		case "ArrowDown":
+		case "PageDown":
+		case "Enter":
+			show(current + 1);
+			break;
+		case " ":
+			show(e.shiftKey ? current - 1 : current + 1);
+			break;
+		case "ArrowLeft":
+		case "ArrowUp":
+		case "PageUp":
+		case "Backspace":
+			show(current - 1);
+			break;
+		case "Home":
+			show(0);
+			break;
+		case "End":
+			show(slides.length - 1);
+			break;
+		case "s":
+		case "S":
+			document.body.classList.toggle("show-notes");
+			break;
+		case "f":
+		case "F":
+			if (document.fullscreenElement) {
+				document.exitFullscreen();
+			} else if (document.documentElement.requestFullscreen) {
+				document.documentElement.requestFullscreen();
+			}
+			break;
+		default:
+			return;
+		}
+		e.preventDefault();
+	});
+	window.addEventListener("hashchange", function () {
+		show(fromHash());
+	});
+})();
+`
@@ -0,0 +1,21 @@
+// export/text/settings.go
+// Plain text export settings.
+
+package text
+
+import "github.com/cubeflix/cdf/export"
+
+// The default line width.
+const DefaultWidth = 72
+
+// Plain text export settings.
+type TextSettings struct {
+	export.Settings
+
+	// The width text is wrapped at. Defaults to 72 columns.
+	Width int
+
+	// Leave out the list of link references at the end. The references are
+	// still numbered, and the destinations are available from Links.
+	OmitLinkReferences bool
+}
@@ -0,0 +1,513 @@
+// export/text/text.go
+// Package text provides functionality for exporting into plain text.
+
+package text
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Heading underline characters by level.
+var headingUnderlines = []string{"=", "-", "~", "^", "\""}
+
+// Bullets by list depth.
+var bullets = []string{"*", "-", "+"}
+
+// The indentation of quotes.
+const quoteIndent = "    "
+
+// Layout properties inherited by nested blocks.
+type context struct {
+	// The width available to the block.
+	width int
+
+	align ast.AlignmentType
+
+	// The list nesting depth, for choosing bullets.
+	depth int
+}
+
+// Plain text exporter.
+type TextExporter struct {
+	stream   io.Writer
+	settings TextSettings
+
+	// Link destinations in order of their reference numbers.
+	links     []string
+	linkIndex map[string]int
+}
+
+// Create a new plain text exporter.
+func NewTextExporter(stream io.Writer, settings TextSettings) *TextExporter {
+	if settings.Width <= 0 {
+		settings.Width = DefaultWidth
+	}
+
+	return &TextExporter{
+		stream:   stream,
+		settings: settings,
+	}
+}
+
+// Export the document to plain text.
+func (t *TextExporter) Export(d *ast.Document) error {
+	t.links, t.linkIndex = nil, map[string]int{}
+	ctx := context{width: t.settings.Width}
+	groups := [][]string{}
+
+	// Write the title block.
+	title := []string{}
+	if !t.settings.OmitTitle && d.Title != "" {
+		lines := wrap(d.Title, ctx.width)
+		rule := strings.Repeat("=", maxWidth(lines))
+		title = append(append(append(title, rule), lines...), rule)
+	}
+	for _, field := range []struct {
+		omit bool
+		text string
+	}{
+		{t.settings.OmitSubtitle, d.Subtitle},
+		{t.settings.OmitDate, d.Date},
+		{t.settings.OmitAuthor, d.Author},
+	} {
+		if !field.omit && field.text != "" {
+			title = append(title, wrap(field.text, ctx.width)...)
+		}
+	}
+	if len(title) > 0 {
+		groups = append(groups, title)
+	}
+
+	content, err := t.exportBlocks(d.Content, ctx)
+	if err != nil {
+		return err
+	}
+	if len(content) > 0 {
+		groups = append(groups, content)
+	}
+
+	// List the link references.
+	if len(t.links) > 0 && !t.settings.OmitLinkReferences {
+		references := []string{}
+		for i, link := range t.links {
+			references = append(references, "["+strconv.Itoa(i+1)+"] "+link)
+		}
+		groups = append(groups, references)
+	}
+
+	out := strings.Builder{}
+	for i, lines := range groups {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		for _, l := range lines {
+			out.WriteString(strings.TrimRight(l, " ") + "\n")
+		}
+	}
+	_, err = io.WriteString(t.stream, out.String())
+	return err
+}
+
+// Get the link destinations of the last export, in order of their reference
+// numbers.
+func (t *TextExporter) Links() []string {
+	return t.links
+}
+
+// Export a slice of blocks, separated by blank lines.
+func (t *TextExporter) exportBlocks(blocks []ast.Block, ctx context) ([]string, error) {
+	lines := []string{}
+	for i := range blocks {
+		block, err := t.exportBlock(blocks[i], ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(block) == 0 {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, block...)
+	}
+	return lines, nil
+}
+
+// Export a block. Blocks without an alignment inherit their parent's.
+func (t *TextExporter) exportBlock(b ast.Block, ctx context) ([]string, error) {
+	if b.GetAlignment() != ast.NoAlign {
+		ctx.align = b.GetAlignment()
+	}
+	if ctx.width < 1 {
+		ctx.width = 1
+	}
+
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		text, err := t.inline(block.Content)
+		if err != nil {
+			return nil, err
+		}
+		return align(wrap(text, ctx.width), ctx), nil
+	case *ast.BasicBlock:
+		return t.exportBlocks(block.Content, ctx)
+	case *ast.Quote:
+		inner := ctx
+		inner.width -= len(quoteIndent)
+		lines, err := t.exportBlocks(block.Content, inner)
+		if err != nil {
+			return nil, err
+		}
+		return indent(lines, quoteIndent, quoteIndent), nil
+	case *ast.Image:
+		// Images are replaced by their caption or alternative text.
+		text := block.Alt
+		if block.HasCaption {
+			caption, err := t.inline(block.Caption)
+			if err != nil {
+				return nil, err
+			}
+			text = caption
+		}
+		if text == "" {
+			text = block.Source
+		}
+		return align(wrap("["+text+"]", ctx.width), ctx), nil
+	case *ast.Heading:
+		if block.Class < ast.Heading1Type || block.Class > ast.Heading5Type {
+			return nil, errors.New("invalid ast")
+		}
+		text, err := t.inline(block.Content)
+		if err != nil {
+			return nil, err
+		}
+		lines := wrap(text, ctx.width)
+		lines = append(lines, strings.Repeat(headingUnderlines[block.Class-ast.Heading1Type], maxWidth(lines)))
+		return align(lines, ctx), nil
+	case *ast.HorizontalRule:
+		return []string{strings.Repeat("-", ctx.width)}, nil
+	case *ast.List:
+		return t.exportList(block, ctx)
+	case *ast.Table:
+		return t.exportTable(block, ctx)
+	case *ast.Collapse:
+		// Collapse blocks are expanded.
+		summary, err := t.inline(block.Summary)
+		if err != nil {
+			return nil, err
+		}
+		content, err := t.exportBlocks(block.Content, ctx)
+		if err != nil {
+			return nil, err
+		}
+		lines := align(wrap(summary, ctx.width), ctx)
+		if len(content) > 0 {
+			lines = append(append(lines, ""), content...)
+		}
+		return lines, nil
+	case *ast.PageBreak, *ast.Notes:
+		// Speaker notes are left out.
+		return nil, nil
+	}
+	return nil, errors.New("invalid ast")
+}
+
+// Get the text of inline blocks. Hyperlinks are followed by a reference
+// number, unless their text is the destination.
+func (t *TextExporter) inline(blocks []ast.InlineBlock) (string, error) {
+	out := strings.Builder{}
+	for i := range blocks {
+		switch block := blocks[i].(type) {
+		case *ast.Text:
+			out.WriteString(block.Value)
+		case *ast.HyperlinkBlock:
+			content, err := t.inline(block.Content)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(content)
+			if block.Destination != "" && !strings.HasPrefix(block.Destination, "#") && block.Destination != strings.TrimSpace(content) {
+				out.WriteString(" [" + strconv.Itoa(t.reference(block.Destination)) + "]")
+			}
+		case *ast.FormattingBlock:
+			content, err := t.inline(block.Content)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(content)
+		case *ast.ColorBlock:
+			content, err := t.inline(block.Content)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(content)
+		case *ast.SizeBlock:
+			content, err := t.inline(block.Content)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(content)
+		case *ast.FontBlock:
+			content, err := t.inline(block.Content)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(content)
+		case *ast.InlineImageBlock:
+			if block.Alt != "" {
+				out.WriteString("[" + block.Alt + "]")
+			}
+		default:
+			return "", errors.New("invalid ast")
+		}
+	}
+	return out.String(), nil
+}
+
+// Get the reference number of a link destination.
+func (t *TextExporter) reference(dest string) int {
+	if i, ok := t.linkIndex[dest]; ok {
+		return i
+	}
+	t.links = append(t.links, dest)
+	t.linkIndex[dest] = len(t.links)
+	return len(t.links)
+}
+
+// Export a list. Items are marked with bullets or numbers, and their
+// following lines are indented to the text.
+func (t *TextExporter) exportList(block *ast.List, ctx context) ([]string, error) {
+	markers := make([]string, len(block.Items))
+	width := 0
+	for i := range block.Items {
+		if block.Ordered {
+			markers[i] = strconv.Itoa(i+1) + ". "
+		} else {
+			markers[i] = bullets[ctx.depth%len(bullets)] + " "
+		}
+		if len(markers[i]) > width {
+			width = len(markers[i])
+		}
+	}
+
+	inner := ctx
+	inner.width -= width
+	inner.depth++
+	lines := []string{}
+	for i := range block.Items {
+		item, err := t.exportBlock(block.Items[i], inner)
+		if err != nil {
+			return nil, err
+		}
+
+		// Nested lists aren't marked.
+		marker := strings.Repeat(" ", width)
+		if _, nested := block.Items[i].(*ast.List); !nested {
+			marker = strings.Repeat(" ", width-len(markers[i])) + markers[i]
+		}
+		lines = append(lines, indent(item, marker, strings.Repeat(" ", width))...)
+	}
+	return lines, nil
+}
+
+// Export a table with ASCII borders. Columns get their natural widths, and
+// the widest columns are narrowed until the table fits. Header rows are
+// separated by a double rule.
+func (t *TextExporter) exportTable(block *ast.Table, ctx context) ([]string, error) {
+	columns := 0
+	for i := range block.Rows {
+		if len(block.Rows[i].Cells) > columns {
+			columns = len(block.Rows[i].Cells)
+		}
+	}
+	if columns == 0 {
+		return nil, nil
+	}
+
+	available := ctx.width - 3*columns - 1
+	if available < columns {
+		available = columns
+	}
+
+	// Render each cell once at the available width to find the natural
+	// column widths. Cells are rendered again once the widths are known, so
+	// the references are reset in between.
+	links, linkIndex := append([]string{}, t.links...), map[string]int{}
+	for k, v := range t.linkIndex {
+		linkIndex[k] = v
+	}
+	widths := make([]int, columns)
+	for i := range block.Rows {
+		for j, cell := range block.Rows[i].Cells {
+			lines, err := t.exportBlocks(cell.Content, context{width: available})
+			if err != nil {
+				return nil, err
+			}
+			if w := maxWidth(lines); w > widths[j] {
+				widths[j] = w
+			}
+		}
+	}
+	t.links, t.linkIndex = links, linkIndex
+	total := 0
+	for j := range widths {
+		if widths[j] < 1 {
+			widths[j] = 1
+		}
+		total += widths[j]
+	}
+	for total > available {
+		widest := 0
+		for j := range widths {
+			if widths[j] > widths[widest] {
+				widest = j
+			}
+		}
+		if widths[widest] == 1 {
+			break
+		}
+		widths[widest]--
+		total--
+	}
+
+	border := func(c string) string {
+		s := "+"
+		for j := range widths {
+			s += strings.Repeat(c, widths[j]+2) + "+"
+		}
+		return s
+	}
+
+	lines := []string{border("-")}
+	for i, row := range block.Rows {
+		cells := make([][]string, columns)
+		height := 1
+		header := len(row.Cells) > 0
+		for j := range cells {
+			if j >= len(row.Cells) {
+				continue
+			}
+			header = header && row.Cells[j].IsHeader
+			var err error
+			cells[j], err = t.exportBlocks(row.Cells[j].Content, context{width: widths[j], align: ctx.align})
+			if err != nil {
+				return nil, err
+			}
+			if len(cells[j]) > height {
+				height = len(cells[j])
+			}
+		}
+		for k := 0; k < height; k++ {
+			s := "|"
+			for j := range cells {
+				content := ""
+				if k < len(cells[j]) {
+					content = cells[j][k]
+				}
+				padding := widths[j] - width(content) + 1
+				if padding < 1 {
+					padding = 1
+				}
+				s += " " + content + strings.Repeat(" ", padding) + "|"
+			}
+			lines = append(lines, s)
+		}
+		if header && i < len(block.Rows)-1 {
+			lines = append(lines, border("="))
+		} else {
+			lines = append(lines, border("-"))
+		}
+	}
+	return align(lines, ctx), nil
+}
+
+// Wrap text into lines of at most a width. Runs of white space collapse to
+// a single space and newlines break lines. Words longer than the width,
+// like long URLs, are kept whole.
+func wrap(text string, limit int) []string {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	lines := []string{}
+	for _, paragraph := range strings.Split(text, "\n") {
+		current := ""
+		for _, word := range strings.FieldsFunc(paragraph, unicode.IsSpace) {
+			word = strings.Map(func(c rune) rune {
+				if unicode.IsControl(c) {
+					return -1
+				}
+				return c
+			}, word)
+			switch {
+			case word == "":
+				// Words of control characters leave no gap behind.
+			case current == "":
+				current = word
+			case width(current)+1+width(word) > limit:
+				lines = append(lines, current)
+				current = word
+			default:
+				current += " " + word
+			}
+		}
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// Indent lines, with a different indent for the first line. Blank lines
+// aren't indented.
+func indent(lines []string, first, rest string) []string {
+	indented := make([]string, len(lines))
+	for i, l := range lines {
+		p := rest
+		if i == 0 {
+			p = first
+		}
+		if l == "" && strings.TrimSpace(p) == "" {
+			continue
+		}
+		indented[i] = p + l
+	}
+	return indented
+}
+
+// Align lines within the context's width.
+func align(lines []string, ctx context) []string {
+	for i, l := range lines {
+		padding := 0
+		switch ctx.align {
+		case ast.CenterAlign:
+			padding = (ctx.width - width(l)) / 2
+		case ast.RightAlign:
+			padding = ctx.width - width(l)
+		}
+		if padding > 0 && l != "" {
+			lines[i] = strings.Repeat(" ", padding) + l
+		}
+	}
+	return lines
+}
+
+// Get the width of a line.
+func width(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// Get the width of the widest line.
+func maxWidth(lines []string) int {
+	w := 0
+	for _, l := range lines {
+		if width(l) > w {
+			w = width(l)
+		}
+	}
+	return w
+}
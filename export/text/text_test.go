@@ -0,0 +1,83 @@
+// export/text/text_test.go
+// Plain text export tests.
+
+package text
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  []string
+	}{
+		{"aaaa bbbb cccc", 10, []string{"aaaa bbbb", "cccc"}},
+		{"aaaa   \t bbbbbb  ", 10, []string{"aaaa", "bbbbbb"}},
+		{"aaaa \x01 \x02 bbbb", 9, []string{"aaaa bbbb"}},
+		{"aaaa \x01 bbbbb", 9, []string{"aaaa", "bbbbb"}},
+		{"\x01 aaaa", 9, []string{"aaaa"}},
+		{"aaaa \n  bbbb", 10, []string{"aaaa", "bbbb"}},
+		{"aa bbbbbbbbbbbb cc", 10, []string{"aa", "bbbbbbbbbbbb", "cc"}},
+		{" \n ", 10, nil},
+	}
+	for _, test := range tests {
+		if got := wrap(test.text, test.limit); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("wrap(%q, %d) = %q, want %q", test.text, test.limit, got, test.want)
+		}
+	}
+}
+
+func TestExportBlocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ast.Block
+		want  string
+	}{
+		{"control characters", &ast.Paragraph{Content: testText("\x1b[31mred\x07 <&>")}, "[31mred <&>\n"},
+		{
+			"nested markup",
+			&ast.Paragraph{Content: []ast.InlineBlock{&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{
+				&ast.Text{Value: "a "},
+				&ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{
+					&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("b")}, Attribute: ast.ItalicFormatting},
+				}}, Destination: "https://example.com"},
+			}}, Attribute: ast.BoldFormatting}}},
+			"a b [1]\n\n[1] https://example.com\n",
+		},
+		{
+			"list",
+			&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("a")}, &ast.List{Ordered: true, Items: []ast.Block{&ast.Paragraph{Content: testText("b")}}}}},
+			"* a\n  1. b\n",
+		},
+		{
+			"table",
+			&ast.Table{Rows: []ast.TableRow{
+				{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("a")}}, IsHeader: true}, {Content: []ast.Block{&ast.Paragraph{Content: testText("bb")}}, IsHeader: true}}},
+				{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("c")}}}}},
+			}},
+			"+---+----+\n| a | bb |\n+===+====+\n| c |    |\n+---+----+\n",
+		},
+		{"missing image", &ast.Image{Source: "missing.png", Alt: "a"}, "[a]\n"},
+		{"image caption", &ast.Image{Source: "missing.png", Alt: "a", HasCaption: true, Caption: testText("b")}, "[b]\n"},
+	}
+	for _, test := range tests {
+		out := strings.Builder{}
+		if err := NewTextExporter(&out, TextSettings{}).Export(&ast.Document{Content: []ast.Block{test.block}}); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
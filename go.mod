@@ -2,9 +2,12 @@ module github.com/cubeflix/cdf
 
 go 1.17
 
+require (
+	github.com/spf13/cobra v1.6.1
+	gopkg.in/go-playground/colors.v1 v1.2.0
+)
+
 require (
 	github.com/inconshreveable/mousetrap v1.0.1 // indirect
-	github.com/spf13/cobra v1.6.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	gopkg.in/go-playground/colors.v1 v1.2.0 // indirect
 )
@@ -0,0 +1,485 @@
+// importer/html/html.go
+// Package html provides functionality for importing HTML.
+
+package html
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	exporthtml "github.com/cubeflix/cdf/export/html"
+	"github.com/cubeflix/cdf/importer"
+)
+
+// HTML importer.
+type HTMLImporter struct {
+	settings HTMLSettings
+	warnings []importer.Warning
+}
+
+// Create a new HTML importer.
+func NewHTMLImporter(settings HTMLSettings) *HTMLImporter {
+	if !settings.UseCustomQuoteBlockClass {
+		settings.QuoteBlockClass = exporthtml.DefaultQuoteBlockClass
+	}
+	if !settings.UseCustomImageBlockClass {
+		settings.ImageBlockClass = exporthtml.DefaultImageBlockClass
+	}
+	if !settings.UseCustomImageCaptionClass {
+		settings.ImageCaptionClass = exporthtml.DefaultImageCaptionClass
+	}
+	if !settings.UseCustomNotesClass {
+		settings.NotesClass = exporthtml.DefaultNotesClass
+	}
+
+	return &HTMLImporter{
+		settings: settings,
+	}
+}
+
+// Get the constructs that could not be mapped by the last import.
+func (h *HTMLImporter) Warnings() []importer.Warning {
+	return h.warnings
+}
+
+// Import an HTML document or fragment. In strict mode, the first construct
+// that cannot be mapped is returned as an error.
+func (h *HTMLImporter) Import(data []byte) (*ast.Document, error) {
+	h.warnings = nil
+	root := h.parseTree(string(data))
+
+	d := &ast.Document{}
+	b := &blockBuilder{h: h}
+	for _, n := range root.children {
+		b.add(h.readHead(d, n))
+	}
+	d.Content = b.finish()
+	if h.settings.TitleHeadings {
+		readTitleHeadings(d)
+	}
+	sort.SliceStable(h.warnings, func(i, j int) bool {
+		return h.warnings[i].Line < h.warnings[j].Line
+	})
+
+	if h.settings.Strict && len(h.warnings) > 0 {
+		return nil, errors.New(h.warnings[0].String())
+	}
+	return d, nil
+}
+
+// Record a construct that could not be mapped.
+func (h *HTMLImporter) warn(line int, construct, message string) {
+	h.warnings = append(h.warnings, importer.Warning{
+		Line:      line,
+		Construct: construct,
+		Message:   message,
+	})
+}
+
+// Read the metadata from the document's head, returning the node with the
+// head removed.
+func (h *HTMLImporter) readHead(d *ast.Document, n *node) *node {
+	switch n.tag {
+	case "html":
+		body := &node{tag: "body", line: n.line}
+		for _, c := range n.children {
+			if c = h.readHead(d, c); c != nil {
+				body.children = append(body.children, c)
+			}
+		}
+		return body
+	case "head":
+		for _, c := range n.children {
+			h.readHead(d, c)
+		}
+		return nil
+	case "title":
+		d.Title = strings.TrimSpace(textContent(n))
+		return nil
+	case "meta":
+		content := n.attributes["content"]
+		switch strings.ToLower(n.attributes["name"]) {
+		case "author":
+			d.Author = content
+		case "date", "dcterms.date":
+			d.Date = content
+		case "description", "subtitle":
+			d.Subtitle = content
+		}
+		return nil
+	case "link", "base", "script", "style":
+		return nil
+	}
+	return n
+}
+
+// Read the title headings written by the HTML exporter.
+func readTitleHeadings(d *ast.Document) {
+	i := 0
+	next := func(class ast.HeadingType) *ast.Heading {
+		if i < len(d.Content) {
+			if heading, ok := d.Content[i].(*ast.Heading); ok && heading.Class == class {
+				i++
+				return heading
+			}
+		}
+		return nil
+	}
+
+	title, subtitle := "", ""
+	var dates []string
+	if heading := next(ast.Heading1Type); heading != nil {
+		title = ast.PlainText(heading.Content)
+	}
+	if heading := next(ast.Heading2Type); heading != nil {
+		subtitle = ast.PlainText(heading.Content)
+	}
+	for len(dates) < 2 {
+		heading := next(ast.Heading3Type)
+		if heading == nil {
+			break
+		}
+		dates = append(dates, ast.PlainText(heading.Content))
+	}
+	if i == 0 || i >= len(d.Content) {
+		return
+	}
+	if _, ok := d.Content[i].(*ast.HorizontalRule); !ok {
+		return
+	}
+
+	if title != "" {
+		d.Title = title
+	}
+	if subtitle != "" {
+		d.Subtitle = subtitle
+	}
+	if len(dates) > 0 {
+		d.Date = dates[0]
+	}
+	if len(dates) > 1 {
+		d.Author = dates[1]
+	}
+	d.Content = d.Content[i+1:]
+}
+
+// Builds blocks from a sequence of nodes, grouping runs of inline content
+// into paragraphs.
+type blockBuilder struct {
+	h      *HTMLImporter
+	blocks []ast.Block
+	run    []*node
+}
+
+// Block-level elements.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "blockquote": true, "figure": true, "ul": true,
+	"ol": true, "table": true, "details": true, "hr": true, "pre": true,
+	"body": true, "main": true, "article": true, "section": true,
+	"header": true, "footer": true, "nav": true, "aside": true,
+	"address": true, "center": true, "dl": true, "form": true,
+	"fieldset": true, "li": true, "tr": true, "td": true, "th": true,
+	"thead": true, "tbody": true, "tfoot": true, "caption": true,
+	"figcaption": true, "summary": true, "dt": true, "dd": true,
+	"noscript": true, "script": true, "style": true, "template": true,
+}
+
+// Add a node in block context.
+func (b *blockBuilder) add(n *node) {
+	if n == nil {
+		return
+	}
+	if n.tag == "br" && !hasContentNodes(b.run) {
+		b.run = nil
+		base := b.h.blockStyle(n)
+		b.blocks = append(b.blocks, &ast.PageBreak{BaseBlock: ast.BaseBlock{Classes: base.Classes, ID: base.ID}})
+		return
+	}
+	if !blockElements[n.tag] {
+		b.run = append(b.run, n)
+		return
+	}
+	b.flush()
+
+	h := b.h
+	switch n.tag {
+	case "body", "main":
+		for _, c := range n.children {
+			b.add(c)
+		}
+	case "script", "style", "template", "noscript":
+		h.warn(n.line, "element '"+n.tag+"'", "dropped")
+	default:
+		if block := h.block(n); block != nil {
+			b.blocks = append(b.blocks, block)
+			return
+		}
+		// Unwrap the element.
+		for _, c := range n.children {
+			b.add(c)
+		}
+		b.flush()
+	}
+}
+
+// Turn the current inline run into a paragraph, or an image block if it is
+// a lone image.
+func (b *blockBuilder) flush() {
+	run := b.run
+	b.run = nil
+	if !hasContentNodes(run) {
+		return
+	}
+
+	var content []*node
+	for _, n := range run {
+		if !isWhitespace(n) {
+			content = append(content, n)
+		}
+	}
+	if len(content) == 1 && content[0].tag == "img" {
+		b.blocks = append(b.blocks, b.h.image(content[0], nil))
+		return
+	}
+	b.blocks = append(b.blocks, &ast.Paragraph{Content: b.h.paragraphInlines(run)})
+}
+
+// Finish building, returning the blocks.
+func (b *blockBuilder) finish() []ast.Block {
+	b.flush()
+	if b.blocks == nil {
+		return []ast.Block{}
+	}
+	return b.blocks
+}
+
+// Check if nodes have non-whitespace content.
+func hasContentNodes(nodes []*node) bool {
+	for _, n := range nodes {
+		if !isWhitespace(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert the children of an element to blocks.
+func (h *HTMLImporter) blocks(children []*node) []ast.Block {
+	b := &blockBuilder{h: h}
+	for _, c := range children {
+		b.add(c)
+	}
+	return b.finish()
+}
+
+// Convert the children of an element to a single block.
+func (h *HTMLImporter) singleBlock(children []*node) ast.Block {
+	blocks := h.blocks(children)
+	switch len(blocks) {
+	case 0:
+		return &ast.Paragraph{Content: []ast.InlineBlock{}}
+	case 1:
+		return blocks[0]
+	}
+	return &ast.BasicBlock{Content: blocks}
+}
+
+// Convert a block-level element. Returns nil if the element should be
+// unwrapped.
+func (h *HTMLImporter) block(n *node) ast.Block {
+	base := h.blockStyle(n)
+	switch n.tag {
+	case "p":
+		return &ast.Paragraph{BaseBlock: base, Content: h.paragraphInlines(n.children)}
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(n.tag[1] - '1')
+		if level > int(ast.Heading5Type) {
+			h.warn(n.line, "element 'h6'", "mapped to h5")
+			level = int(ast.Heading5Type)
+		}
+		return &ast.Heading{BaseBlock: base, Class: ast.HeadingType(level), Content: h.paragraphInlines(n.children)}
+	case "div":
+		switch {
+		case hasClass(n, h.settings.QuoteBlockClass):
+			base.Classes = removeString(base.Classes, h.settings.QuoteBlockClass)
+			return &ast.Quote{BaseBlock: base, Content: h.blocks(n.children)}
+		case hasClass(n, h.settings.ImageBlockClass):
+			return h.imageBlock(n, base)
+		}
+		return &ast.BasicBlock{BaseBlock: base, Content: h.blocks(n.children)}
+	case "aside":
+		if hasClass(n, h.settings.NotesClass) {
+			base.Classes = removeString(base.Classes, h.settings.NotesClass)
+			return &ast.Notes{BaseBlock: base, Content: h.blocks(n.children)}
+		}
+		return &ast.BasicBlock{BaseBlock: base, Content: h.blocks(n.children)}
+	case "article", "section", "header", "footer", "nav", "address", "form", "fieldset":
+		return &ast.BasicBlock{BaseBlock: base, Content: h.blocks(n.children)}
+	case "center":
+		base.Alignment = ast.CenterAlign
+		return &ast.BasicBlock{BaseBlock: base, Content: h.blocks(n.children)}
+	case "blockquote":
+		return &ast.Quote{BaseBlock: base, Content: h.blocks(n.children)}
+	case "figure":
+		return h.imageBlock(n, base)
+	case "ul", "ol":
+		return h.list(n, base)
+	case "table":
+		return h.table(n, base)
+	case "details":
+		return h.collapse(n, base)
+	case "hr":
+		return &ast.HorizontalRule{BaseBlock: base}
+	case "pre":
+		code := strings.TrimSuffix(strings.TrimPrefix(textContent(n), "\n"), "\n")
+		return &ast.Paragraph{BaseBlock: base, Content: []ast.InlineBlock{&ast.FormattingBlock{
+			BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{&ast.Text{Value: code}}},
+			Attribute:       ast.TeletypeFormatting,
+		}}}
+	}
+	h.warn(n.line, "element '"+n.tag+"'", "unwrapped")
+	return nil
+}
+
+// Convert an image block: an image block div or a figure.
+func (h *HTMLImporter) imageBlock(n *node, base ast.BaseBlock) ast.Block {
+	var img, caption *node
+	var visit func(n *node)
+	visit = func(n *node) {
+		for _, c := range n.children {
+			switch {
+			case c.tag == "img" && img == nil:
+				img = c
+			case c.tag == "figcaption" || (c.tag == "div" && hasClass(c, h.settings.ImageCaptionClass)):
+				caption = c
+			case c.tag == "a" || c.tag == "picture" || c.tag == "p":
+				visit(c)
+			case !isWhitespace(c):
+				h.warn(c.line, "image content", "dropped")
+			}
+		}
+	}
+	visit(n)
+	if img == nil {
+		h.warn(n.line, "element '"+n.tag+"'", "has no image")
+		return &ast.BasicBlock{BaseBlock: base, Content: h.blocks(n.children)}
+	}
+	image := h.image(img, caption)
+	image.BaseBlock = base
+	image.Classes = removeString(image.Classes, h.settings.ImageBlockClass)
+	return image
+}
+
+// Convert an image element, with an optional caption element.
+func (h *HTMLImporter) image(img, caption *node) *ast.Image {
+	inline := h.inlineImage(img)
+	image := &ast.Image{
+		Source:             inline.Source,
+		Alt:                inline.Alt,
+		HasWidthParameter:  inline.HasWidthParameter,
+		WidthValue:         inline.WidthValue,
+		WidthType:          inline.WidthType,
+		HasHeightParameter: inline.HasHeightParameter,
+		HeightValue:        inline.HeightValue,
+		HeightType:         inline.HeightType,
+	}
+	if caption != nil {
+		image.HasCaption = true
+		image.Caption = h.paragraphInlines(caption.children)
+	}
+	return image
+}
+
+// Convert a list.
+func (h *HTMLImporter) list(n *node, base ast.BaseBlock) ast.Block {
+	list := &ast.List{BaseBlock: base, Ordered: n.tag == "ol", Items: []ast.Block{}}
+	if start, ok := n.attributes["start"]; ok && start != "1" {
+		h.warn(n.line, "list start number", "numbering restarts at 1")
+	}
+	if _, ok := n.attributes["type"]; ok {
+		h.warn(n.line, "list type", "dropped")
+	}
+	for _, c := range n.children {
+		switch {
+		case c.tag == "li":
+			list.Items = append(list.Items, h.singleBlock(c.children))
+		case !isWhitespace(c):
+			h.warn(c.line, "list content", "'"+nodeName(c)+"' outside of a list item treated as an item")
+			list.Items = append(list.Items, h.singleBlock([]*node{c}))
+		}
+	}
+	return list
+}
+
+// Convert a table.
+func (h *HTMLImporter) table(n *node, base ast.BaseBlock) ast.Block {
+	table := &ast.Table{BaseBlock: base, Rows: []ast.TableRow{}}
+	var visit func(n *node)
+	visit = func(n *node) {
+		for _, c := range n.children {
+			switch c.tag {
+			case "thead", "tbody", "tfoot":
+				visit(c)
+			case "tr":
+				table.Rows = append(table.Rows, h.tableRow(c))
+			case "caption":
+				h.warn(c.line, "table caption", "dropped")
+			case "colgroup", "col":
+			default:
+				if !isWhitespace(c) {
+					h.warn(c.line, "table content", "'"+nodeName(c)+"' outside of a row dropped")
+				}
+			}
+		}
+	}
+	visit(n)
+	return table
+}
+
+// Convert a table row.
+func (h *HTMLImporter) tableRow(n *node) ast.TableRow {
+	row := ast.TableRow{Cells: []ast.TableCell{}}
+	for _, c := range n.children {
+		switch c.tag {
+		case "td", "th":
+			if c.attributes["colspan"] != "" || c.attributes["rowspan"] != "" {
+				h.warn(c.line, "table cell span", "dropped")
+			}
+			row.Cells = append(row.Cells, ast.TableCell{
+				Content:  h.blocks(c.children),
+				IsHeader: c.tag == "th",
+			})
+		default:
+			if !isWhitespace(c) {
+				h.warn(c.line, "table content", "'"+nodeName(c)+"' outside of a cell dropped")
+			}
+		}
+	}
+	return row
+}
+
+// Convert a details element.
+func (h *HTMLImporter) collapse(n *node, base ast.BaseBlock) ast.Block {
+	collapse := &ast.Collapse{BaseBlock: base, Summary: []ast.InlineBlock{}}
+	var content []*node
+	for _, c := range n.children {
+		if c.tag == "summary" && len(collapse.Summary) == 0 {
+			collapse.Summary = h.paragraphInlines(c.children)
+			continue
+		}
+		content = append(content, c)
+	}
+	collapse.Content = h.blocks(content)
+	return collapse
+}
+
+// Get a name for a node in messages.
+func nodeName(n *node) string {
+	if n.tag == "" {
+		return "text"
+	}
+	return n.tag
+}
@@ -0,0 +1,161 @@
+// importer/html/html_test.go
+// HTML import tests.
+
+package html
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/export/html"
+	"github.com/cubeflix/cdf/parser"
+)
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		want     string
+		warnings []string
+	}{
+		{
+			name: "inline",
+			html: "<p>a <b>b</b> <i>i</i> <s>s</s> <u>u</u> <code>c</code> <a href=\"/x\">l</a></p>",
+			want: "[[cdf]]\n\t[[p]]a [[b]]b[[/]] [[i]]i[[/]] [[s]]s[[/]] [[u]]u[[/]] [[t]]c[[/]] [[link dest=/x]]l[[/]][[/]]\n[[/]]\n",
+		},
+		{
+			name:     "headings",
+			html:     "<h1>1</h1><h5>5</h5><h6>6</h6>",
+			want:     "[[cdf]]\n\t[[h c=1]]1[[/]]\n\t[[h c=5]]5[[/]]\n\t[[h c=5]]6[[/]]\n[[/]]\n",
+			warnings: []string{"1: element 'h6': mapped to h5"},
+		},
+		{
+			name: "quote and rule",
+			html: "<blockquote><p>q</p></blockquote><hr>",
+			want: "[[cdf]]\n\t[[quote]]\n\t\t[[p]]q[[/]]\n\t[[/]]\n\t[[hr]][[/]]\n[[/]]\n",
+		},
+		{
+			name: "figure",
+			html: "<figure><img src=\"a.png\" alt=\"x\"><figcaption>cap</figcaption></figure>",
+			want: "[[cdf]]\n\t[[image src=a.png|alt=x|has-caption=]]cap[[/]]\n[[/]]\n",
+		},
+		{
+			name:     "lists",
+			html:     "<ul><li>a</li><li>b</li></ul><ol start=\"3\"><li>x</li></ol>",
+			want:     "[[cdf]]\n\t[[list]]\n\t\t[[p]]a[[/]]\n\t\t[[p]]b[[/]]\n\t[[/]]\n\t[[list ordered=]]\n\t\t[[p]]x[[/]]\n\t[[/]]\n[[/]]\n",
+			warnings: []string{"1: list start number: numbering restarts at 1"},
+		},
+		{
+			name: "table",
+			html: "<table><tr><th>h</th></tr><tr><td>c</td></tr></table>",
+			want: "[[cdf]]\n\t[[table]]\n" +
+				"\t\t[[row]]\n\t\t\t[[cell is-header=]]\n\t\t\t\t[[p]]h[[/]]\n\t\t\t[[/]]\n\t\t[[/]]\n" +
+				"\t\t[[row]]\n\t\t\t[[cell]]\n\t\t\t\t[[p]]c[[/]]\n\t\t\t[[/]]\n\t\t[[/]]\n" +
+				"\t[[/]]\n[[/]]\n",
+		},
+		{
+			name: "details",
+			html: "<details><summary>s</summary><p>in</p></details>",
+			want: "[[cdf]]\n\t[[collapse]]\n\t\t[[summary]]s[[/]]\n\t\t[[content]]\n\t\t\t[[p]]in[[/]]\n\t\t[[/]]\n\t[[/]]\n[[/]]\n",
+		},
+		{
+			name:     "inline styles",
+			html:     "<p><span style=\"color: red; background-color: #00f\">c</span><span style=\"font-size: 12px\">s</span><span style=\"font-family: Georgia\">f</span><span style=\"float: left\">x</span></p>",
+			want:     "[[cdf]]\n\t[[p]][[color fg=#ff0000|bg=#00f]]c[[/]][[size px=12]]s[[/]][[font family=Georgia]]f[[/]]x[[/]]\n[[/]]\n",
+			warnings: []string{"1: style property 'float': dropped"},
+		},
+		{
+			name: "block attributes",
+			html: "<p class=\"a b\" id=\"i\" style=\"text-align: center\">x</p>",
+			want: "[[cdf]]\n\t[[p align=center|class=a b|id=i]]x[[/]]\n[[/]]\n",
+		},
+		{
+			name: "inline image",
+			html: "<p><img src=\"i.png\" alt=\"a\" width=\"10\"></p>",
+			want: "[[cdf]]\n\t[[p]][[inline-image src=i.png|alt=a|width-px=10]][[/]][[/]]\n[[/]]\n",
+		},
+		{
+			name:     "unknown element",
+			html:     "<marquee>m</marquee>",
+			want:     "[[cdf]]\n\t[[p]]m[[/]]\n[[/]]\n",
+			warnings: []string{"1: element 'marquee': unwrapped"},
+		},
+		{
+			name: "head",
+			html: "<html><head><title>T</title><meta name=\"author\" content=\"A\"></head><body><p>x</p></body></html>",
+			want: "[[cdf title=T|author=A]]\n\t[[p]]x[[/]]\n[[/]]\n",
+		},
+	}
+	for _, test := range tests {
+		i := NewHTMLImporter(HTMLSettings{})
+		d, err := i.Import([]byte(test.html))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		got, err := parser.FormatBytes(d)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+		warnings := []string{}
+		for _, w := range i.Warnings() {
+			warnings = append(warnings, w.String())
+		}
+		if test.warnings == nil {
+			test.warnings = []string{}
+		}
+		if !reflect.DeepEqual(warnings, test.warnings) {
+			t.Errorf("%s: got warnings %q, want %q", test.name, warnings, test.warnings)
+		}
+
+		// Strict mode fails on the first warning.
+		_, err = NewHTMLImporter(HTMLSettings{Strict: true}).Import([]byte(test.html))
+		if len(test.warnings) == 0 && err != nil {
+			t.Errorf("%s: strict: %v", test.name, err)
+		} else if len(test.warnings) != 0 && (err == nil || err.Error() != test.warnings[0]) {
+			t.Errorf("%s: strict: got error %v, want %q", test.name, err, test.warnings[0])
+		}
+	}
+}
+
+func TestImportExported(t *testing.T) {
+	tests := []string{
+		"[[cdf title=T|subtitle=S|date=D|author=A]]\n\t[[h c=2]]x[[/]]\n\t[[p]]a [[b]]b[[/]][[/]]\n[[/]]\n",
+		"[[cdf title=T]]\n\t[[quote]]\n\t\t[[p]]q[[/]]\n\t[[/]]\n\t[[image src=a.png|alt=x|has-caption=]]cap[[/]]\n\t[[hr]][[/]]\n[[/]]\n",
+		"[[cdf]]\n\t[[list ordered=]]\n\t\t[[p]]a[[/]]\n\t\t[[list]]\n\t\t\t[[p]]b[[/]]\n\t\t[[/]]\n\t[[/]]\n[[/]]\n",
+		"[[cdf]]\n\t[[collapse]]\n\t\t[[summary]]s[[/]]\n\t\t[[content]]\n\t\t\t[[p align=right]][[i]]in[[/]][[/]]\n\t\t[[/]]\n\t[[/]]\n[[/]]\n",
+		"[[cdf]]\n\t[[p]][[color fg=#ff0000]]c[[/]] [[size pt=12]]s[[/]] [[font family=Georgia]]f[[/]] [[link dest=https://example.com]]l[[/]][[/]]\n[[/]]\n",
+	}
+	for _, source := range tests {
+		p := parser.NewParser([]byte(source))
+		if err := p.Parse(); err != nil {
+			t.Fatal(err)
+		}
+		out := strings.Builder{}
+		if err := html.NewHTMLExporter(&out, html.HTMLSettings{IncludeHeader: true, IncludeFooter: true}).Export(&p.Tree); err != nil {
+			t.Fatal(err)
+		}
+
+		i := NewHTMLImporter(HTMLSettings{TitleHeadings: true})
+		d, err := i.Import([]byte(out.String()))
+		if err != nil {
+			t.Errorf("%v\n%s", err, out.String())
+			continue
+		}
+		if len(i.Warnings()) != 0 {
+			t.Errorf("unexpected warnings %v\n%s", i.Warnings(), out.String())
+		}
+		got, err := parser.FormatBytes(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != source {
+			t.Errorf("got\n%s\nwant\n%s", got, source)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+// importer/html/inline.go
+// HTML inline content mapping.
+
+package html
+
+import (
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	"gopkg.in/go-playground/colors.v1"
+)
+
+// Formatting elements.
+var formattingElements = map[string]ast.FormattingType{
+	"b": ast.BoldFormatting, "strong": ast.BoldFormatting,
+	"i": ast.ItalicFormatting, "em": ast.ItalicFormatting,
+	"s": ast.StrikethroughFormatting, "strike": ast.StrikethroughFormatting,
+	"del": ast.StrikethroughFormatting, "u": ast.UnderlineFormatting,
+	"ins": ast.UnderlineFormatting, "code": ast.TeletypeFormatting,
+	"tt": ast.TeletypeFormatting, "kbd": ast.TeletypeFormatting,
+	"samp": ast.TeletypeFormatting,
+}
+
+// Convert the content of a paragraph-like element, trimming the whitespace
+// at its edges.
+func (h *HTMLImporter) paragraphInlines(nodes []*node) []ast.InlineBlock {
+	content := h.inlines(nodes)
+	trimLeft(content, true)
+	trimRight(content)
+	return removeEmptyText(content)
+}
+
+// Convert nodes to inline blocks.
+func (h *HTMLImporter) inlines(nodes []*node) []ast.InlineBlock {
+	content := []ast.InlineBlock{}
+	for _, n := range nodes {
+		for _, b := range h.inline(n) {
+			if text, ok := b.(*ast.Text); ok && len(content) > 0 {
+				if previous, ok := content[len(content)-1].(*ast.Text); ok {
+					previous.Value += text.Value
+					continue
+				}
+			}
+			content = append(content, b)
+		}
+	}
+	return content
+}
+
+// Convert a node to inline blocks.
+func (h *HTMLImporter) inline(n *node) []ast.InlineBlock {
+	switch n.tag {
+	case "":
+		return []ast.InlineBlock{&ast.Text{Value: n.text}}
+	case "br":
+		return []ast.InlineBlock{&ast.Text{Value: "\n"}}
+	case "wbr":
+		return nil
+	case "img":
+		return []ast.InlineBlock{h.inlineImage(n)}
+	case "script", "style", "template", "noscript":
+		h.warn(n.line, "element '"+n.tag+"'", "dropped")
+		return nil
+	}
+
+	content := h.inlineStyle(n, h.inlines(n.children))
+	switch n.tag {
+	case "a":
+		href, ok := n.attributes["href"]
+		if !ok {
+			if n.attributes["name"] != "" || n.attributes["id"] != "" {
+				h.warn(n.line, "anchor", "dropped")
+			}
+			return content
+		}
+		return []ast.InlineBlock{&ast.HyperlinkBlock{
+			BaseInlineBlock: ast.BaseInlineBlock{Content: content},
+			Destination:     href,
+		}}
+	case "span":
+		return content
+	case "font":
+		return h.fontElement(n, content)
+	}
+	if attribute, ok := formattingElements[n.tag]; ok {
+		return []ast.InlineBlock{formatting(attribute, content)}
+	}
+	if blockElements[n.tag] {
+		h.warn(n.line, "element '"+n.tag+"'", "block in inline content unwrapped")
+	} else {
+		h.warn(n.line, "element '"+n.tag+"'", "unwrapped")
+	}
+	return content
+}
+
+// Convert an image element to an inline image.
+func (h *HTMLImporter) inlineImage(n *node) *ast.InlineImageBlock {
+	image := &ast.InlineImageBlock{
+		Source: n.attributes["src"],
+		Alt:    n.attributes["alt"],
+	}
+	if n.attributes["src"] == "" {
+		h.warn(n.line, "image", "missing source")
+	}
+	if width, ok := n.attributes["width"]; ok {
+		image.WidthValue, image.WidthType, image.HasWidthParameter = h.imageSize(n, width)
+	}
+	if height, ok := n.attributes["height"]; ok {
+		image.HeightValue, image.HeightType, image.HasHeightParameter = h.imageSize(n, height)
+	}
+	for _, d := range parseStyle(n.attributes["style"]) {
+		switch d.property {
+		case "width":
+			image.WidthValue, image.WidthType, image.HasWidthParameter = h.imageSize(n, d.value)
+		case "height":
+			image.HeightValue, image.HeightType, image.HasHeightParameter = h.imageSize(n, d.value)
+		default:
+			h.warn(n.line, "style property '"+d.property+"'", "dropped")
+		}
+	}
+	return image
+}
+
+// Parse an image dimension.
+func (h *HTMLImporter) imageSize(n *node, value string) (float32, ast.SizeType, bool) {
+	v, t, ok := parseSize(value)
+	if !ok && value != "auto" {
+		h.warn(n.line, "image size '"+value+"'", "dropped")
+	}
+	return v, t, ok
+}
+
+// Wrap inline content in the blocks for an element's style.
+func (h *HTMLImporter) inlineStyle(n *node, content []ast.InlineBlock) []ast.InlineBlock {
+	var foreground, background colors.Color
+	var formats []ast.FormattingType
+	for _, d := range parseStyle(n.attributes["style"]) {
+		value := strings.ToLower(d.value)
+		switch d.property {
+		case "color", "background-color", "background":
+			c, ok := parseColor(d.value)
+			if !ok {
+				h.warn(n.line, "color '"+d.value+"'", "dropped")
+				continue
+			}
+			if d.property == "color" {
+				foreground = c
+			} else {
+				background = c
+			}
+		case "font-size":
+			v, t, ok := parseSize(d.value)
+			if !ok {
+				h.warn(n.line, "font size '"+d.value+"'", "dropped")
+				continue
+			}
+			content = []ast.InlineBlock{&ast.SizeBlock{
+				BaseInlineBlock: ast.BaseInlineBlock{Content: content},
+				Value:           v,
+				Type:            t,
+			}}
+		case "font-family":
+			content = []ast.InlineBlock{&ast.FontBlock{
+				BaseInlineBlock: ast.BaseInlineBlock{Content: content},
+				Family:          d.value,
+			}}
+		case "font-weight":
+			if value == "bold" || value == "bolder" || (len(value) == 3 && value >= "600" && value <= "900") {
+				formats = append(formats, ast.BoldFormatting)
+			}
+		case "font-style":
+			if value == "italic" || value == "oblique" {
+				formats = append(formats, ast.ItalicFormatting)
+			}
+		case "text-decoration", "text-decoration-line":
+			if strings.Contains(value, "underline") {
+				formats = append(formats, ast.UnderlineFormatting)
+			}
+			if strings.Contains(value, "line-through") {
+				formats = append(formats, ast.StrikethroughFormatting)
+			}
+		default:
+			h.warn(n.line, "style property '"+d.property+"'", "dropped")
+		}
+	}
+	if foreground != nil || background != nil {
+		content = []ast.InlineBlock{&ast.ColorBlock{
+			BaseInlineBlock: ast.BaseInlineBlock{Content: content},
+			ForegroundValue: foreground,
+			BackgroundValue: background,
+		}}
+	}
+	for _, attribute := range formats {
+		content = []ast.InlineBlock{formatting(attribute, content)}
+	}
+	return content
+}
+
+// Convert a legacy font element.
+func (h *HTMLImporter) fontElement(n *node, content []ast.InlineBlock) []ast.InlineBlock {
+	if face := n.attributes["face"]; face != "" {
+		content = []ast.InlineBlock{&ast.FontBlock{
+			BaseInlineBlock: ast.BaseInlineBlock{Content: content},
+			Family:          face,
+		}}
+	}
+	if value := n.attributes["color"]; value != "" {
+		if c, ok := parseColor(value); ok {
+			content = []ast.InlineBlock{&ast.ColorBlock{
+				BaseInlineBlock: ast.BaseInlineBlock{Content: content},
+				ForegroundValue: c,
+			}}
+		} else {
+			h.warn(n.line, "color '"+value+"'", "dropped")
+		}
+	}
+	if n.attributes["size"] != "" {
+		h.warn(n.line, "font size '"+n.attributes["size"]+"'", "dropped")
+	}
+	return content
+}
+
+// Create a formatting block.
+func formatting(attribute ast.FormattingType, content []ast.InlineBlock) ast.InlineBlock {
+	return &ast.FormattingBlock{
+		BaseInlineBlock: ast.BaseInlineBlock{Content: content},
+		Attribute:       attribute,
+	}
+}
+
+// Remove collapsible spaces at the start of content and after other spaces.
+// Returns whether the content ends in a space.
+func trimLeft(content []ast.InlineBlock, space bool) bool {
+	for _, b := range content {
+		switch b := b.(type) {
+		case *ast.Text:
+			if space {
+				b.Value = strings.TrimLeft(b.Value, " ")
+			}
+			if b.Value != "" {
+				space = strings.HasSuffix(b.Value, " ") || strings.HasSuffix(b.Value, "\n")
+			}
+		case *ast.InlineImageBlock:
+			space = false
+		default:
+			space = trimLeft(b.Children(), space)
+		}
+	}
+	return space
+}
+
+// Remove spaces at the end of content. Returns whether any non-space content
+// was found.
+func trimRight(content []ast.InlineBlock) bool {
+	for i := len(content) - 1; i >= 0; i-- {
+		switch b := content[i].(type) {
+		case *ast.Text:
+			b.Value = strings.TrimRight(b.Value, " ")
+			if b.Value != "" {
+				return true
+			}
+		case *ast.InlineImageBlock:
+			return true
+		default:
+			if trimRight(b.Children()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Remove empty text nodes.
+func removeEmptyText(content []ast.InlineBlock) []ast.InlineBlock {
+	result := []ast.InlineBlock{}
+	for _, b := range content {
+		if text, ok := b.(*ast.Text); ok && text.Value == "" {
+			continue
+		}
+		if base := baseInline(b); base != nil {
+			base.Content = removeEmptyText(base.Content)
+		}
+		result = append(result, b)
+	}
+	return result
+}
+
+// Get the base of an inline block with children.
+func baseInline(b ast.InlineBlock) *ast.BaseInlineBlock {
+	switch b := b.(type) {
+	case *ast.FormattingBlock:
+		return &b.BaseInlineBlock
+	case *ast.HyperlinkBlock:
+		return &b.BaseInlineBlock
+	case *ast.ColorBlock:
+		return &b.BaseInlineBlock
+	case *ast.SizeBlock:
+		return &b.BaseInlineBlock
+	case *ast.FontBlock:
+		return &b.BaseInlineBlock
+	}
+	return nil
+}
@@ -0,0 +1,388 @@
+// importer/html/parse.go
+// A forgiving HTML tokenizer and tree builder.
+
+package html
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// An HTML element or text node.
+type node struct {
+	// The lowercase tag name, or empty for text.
+	tag        string
+	text       string
+	attributes map[string]string
+	children   []*node
+	line       int
+}
+
+// Elements without content.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// Elements whose content is raw text.
+var rawTextElements = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true,
+}
+
+// Elements whose end tag may be omitted.
+var optionalEndElements = map[string]bool{
+	"p": true, "li": true, "dt": true, "dd": true, "tr": true, "td": true,
+	"th": true, "thead": true, "tbody": true, "tfoot": true, "option": true,
+	"html": true, "head": true, "body": true,
+}
+
+// Elements that close an open paragraph.
+var paragraphClosers = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"details": true, "div": true, "dl": true, "fieldset": true, "figure": true,
+	"footer": true, "form": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"main": true, "nav": true, "ol": true, "p": true, "pre": true,
+	"section": true, "table": true, "ul": true,
+}
+
+// Elements that implicitly close siblings, and the elements that bound the
+// search for them.
+var implicitClosers = map[string]struct {
+	closes []string
+	scope  []string
+}{
+	"li":    {[]string{"li"}, []string{"ul", "ol"}},
+	"dt":    {[]string{"dt", "dd"}, []string{"dl"}},
+	"dd":    {[]string{"dt", "dd"}, []string{"dl"}},
+	"tr":    {[]string{"tr", "td", "th"}, []string{"table", "thead", "tbody", "tfoot"}},
+	"td":    {[]string{"td", "th"}, []string{"tr", "table"}},
+	"th":    {[]string{"td", "th"}, []string{"tr", "table"}},
+	"thead": {[]string{"thead", "tbody", "tfoot", "tr", "td", "th"}, []string{"table"}},
+	"tbody": {[]string{"thead", "tbody", "tfoot", "tr", "td", "th"}, []string{"table"}},
+	"tfoot": {[]string{"thead", "tbody", "tfoot", "tr", "td", "th"}, []string{"table"}},
+}
+
+var (
+	tagNameRegexp    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*`)
+	whitespaceRegexp = regexp.MustCompile(`[ \t\r\n\f]+`)
+)
+
+// Tree builder state.
+type treeBuilder struct {
+	h     *HTMLImporter
+	src   string
+	pos   int
+	stack []*node
+
+	// The last offset a line was computed for, and its line.
+	lineOffset, lineNum int
+}
+
+// Parse HTML into a tree rooted at an unnamed document node.
+func (h *HTMLImporter) parseTree(src string) *node {
+	root := &node{tag: "#document", line: 1}
+	t := &treeBuilder{h: h, src: src, stack: []*node{root}, lineNum: 1}
+	t.parse()
+	return root
+}
+
+// Get the current line.
+func (t *treeBuilder) line() int {
+	return t.lineAt(t.pos)
+}
+
+// Get the line of an offset. Offsets must not decrease between calls.
+func (t *treeBuilder) lineAt(offset int) int {
+	t.lineNum += strings.Count(t.src[t.lineOffset:offset], "\n")
+	t.lineOffset = offset
+	return t.lineNum
+}
+
+// Get the current element.
+func (t *treeBuilder) current() *node {
+	return t.stack[len(t.stack)-1]
+}
+
+// Tokenize the source and build the tree.
+func (t *treeBuilder) parse() {
+	s := t.src
+	for t.pos < len(s) {
+		rest := s[t.pos:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end == -1 {
+				t.pos = len(s)
+			} else {
+				t.pos += end + 7
+			}
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end == -1 {
+				t.pos = len(s)
+			} else {
+				t.pos += end + 1
+			}
+		case strings.HasPrefix(rest, "</") && tagNameRegexp.MatchString(rest[2:]):
+			name := strings.ToLower(tagNameRegexp.FindString(rest[2:]))
+			end := strings.IndexByte(rest, '>')
+			line := t.line()
+			if end == -1 {
+				t.pos = len(s)
+			} else {
+				t.pos += end + 1
+			}
+			t.endTag(name, line)
+		case strings.HasPrefix(rest, "<") && tagNameRegexp.MatchString(rest[1:]):
+			t.startTag()
+		default:
+			t.text()
+		}
+	}
+
+	for len(t.stack) > 1 {
+		n := t.current()
+		if !optionalEndElements[n.tag] {
+			t.h.warn(n.line, "malformed html", "'"+n.tag+"' is not closed")
+		}
+		t.stack = t.stack[:len(t.stack)-1]
+	}
+}
+
+// Parse text up to the next tag.
+func (t *treeBuilder) text() {
+	s := t.src
+	start := t.pos
+	t.pos++
+	for t.pos < len(s) {
+		if s[t.pos] == '<' && t.pos+1 < len(s) {
+			c := s[t.pos+1]
+			if c == '/' || c == '!' || c == '?' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+				break
+			}
+		}
+		t.pos++
+	}
+	t.appendText(s[start:t.pos], start)
+}
+
+// Append text to the current element. Whitespace is collapsed outside of
+// preformatted elements.
+func (t *treeBuilder) appendText(raw string, offset int) {
+	value := html.UnescapeString(raw)
+	if !t.inElement("pre") {
+		value = whitespaceRegexp.ReplaceAllString(value, " ")
+	}
+	if value == "" {
+		return
+	}
+	parent := t.current()
+	if n := len(parent.children); n > 0 && parent.children[n-1].tag == "" {
+		parent.children[n-1].text += value
+		return
+	}
+	parent.children = append(parent.children, &node{
+		text: value,
+		line: t.lineAt(offset),
+	})
+}
+
+// Parse a start tag and its attributes.
+func (t *treeBuilder) startTag() {
+	s := t.src
+	line := t.line()
+	t.pos++
+	name := strings.ToLower(tagNameRegexp.FindString(s[t.pos:]))
+	t.pos += len(name)
+
+	n := &node{tag: name, attributes: map[string]string{}, line: line}
+	selfClosing := false
+	for t.pos < len(s) {
+		c := s[t.pos]
+		if c == '>' {
+			t.pos++
+			break
+		}
+		if c == '/' && t.pos+1 < len(s) && s[t.pos+1] == '>' {
+			selfClosing = true
+			t.pos += 2
+			break
+		}
+		if strings.IndexByte(" \t\r\n\f/", c) != -1 {
+			t.pos++
+			continue
+		}
+
+		// Parse an attribute.
+		start := t.pos
+		for t.pos < len(s) && strings.IndexByte(" \t\r\n\f/>=", s[t.pos]) == -1 {
+			t.pos++
+		}
+		key := strings.ToLower(s[start:t.pos])
+		for t.pos < len(s) && strings.IndexByte(" \t\r\n\f", s[t.pos]) != -1 {
+			t.pos++
+		}
+		value := ""
+		if t.pos < len(s) && s[t.pos] == '=' {
+			t.pos++
+			for t.pos < len(s) && strings.IndexByte(" \t\r\n\f", s[t.pos]) != -1 {
+				t.pos++
+			}
+			if t.pos < len(s) && (s[t.pos] == '"' || s[t.pos] == '\'') {
+				quote := s[t.pos]
+				end := strings.IndexByte(s[t.pos+1:], quote)
+				if end == -1 {
+					end = len(s) - t.pos - 1
+				}
+				value = s[t.pos+1 : t.pos+1+end]
+				t.pos += end + 2
+			} else {
+				start := t.pos
+				for t.pos < len(s) && strings.IndexByte(" \t\r\n\f>", s[t.pos]) == -1 {
+					t.pos++
+				}
+				value = s[start:t.pos]
+			}
+		}
+		if _, ok := n.attributes[key]; !ok {
+			n.attributes[key] = html.UnescapeString(value)
+		}
+	}
+	if t.pos > len(s) {
+		t.pos = len(s)
+	}
+
+	t.implicitlyClose(name)
+	parent := t.current()
+	parent.children = append(parent.children, n)
+	if voidElements[name] || selfClosing {
+		return
+	}
+
+	if rawTextElements[name] {
+		end := strings.Index(strings.ToLower(s[t.pos:]), "</"+name)
+		if end == -1 {
+			end = len(s) - t.pos
+		}
+		n.children = []*node{{text: html.UnescapeString(s[t.pos : t.pos+end]), line: t.line()}}
+		t.pos += end
+		if close := strings.IndexByte(s[t.pos:], '>'); close != -1 {
+			t.pos += close + 1
+		} else {
+			t.pos = len(s)
+		}
+		return
+	}
+	t.stack = append(t.stack, n)
+}
+
+// Close elements that a start tag implicitly ends.
+func (t *treeBuilder) implicitlyClose(name string) {
+	// Block elements close an open paragraph and any unclosed inline
+	// elements.
+	if paragraphClosers[name] {
+		i := len(t.stack) - 1
+		for i > 0 && !blockElements[t.stack[i].tag] {
+			i--
+		}
+		if t.stack[i].tag == "p" {
+			t.close(i)
+		} else if i+1 < len(t.stack) {
+			t.close(i + 1)
+		}
+	}
+	closer, ok := implicitClosers[name]
+	if !ok {
+		return
+	}
+	for i := len(t.stack) - 1; i > 0; i-- {
+		tag := t.stack[i].tag
+		if containsString(closer.scope, tag) {
+			return
+		}
+		if containsString(closer.closes, tag) {
+			t.close(i)
+			return
+		}
+	}
+}
+
+// Handle an end tag.
+func (t *treeBuilder) endTag(name string, line int) {
+	for i := len(t.stack) - 1; i > 0; i-- {
+		if t.stack[i].tag == name {
+			t.close(i)
+			return
+		}
+	}
+	if !voidElements[name] {
+		t.h.warn(line, "malformed html", "unexpected end tag '"+name+"'")
+	}
+}
+
+// Close the element at a stack index and the elements inside it. Elements
+// whose end tag is required are reported.
+func (t *treeBuilder) close(i int) {
+	for _, n := range t.stack[i+1:] {
+		if !optionalEndElements[n.tag] {
+			t.h.warn(n.line, "malformed html", "'"+n.tag+"' is not closed")
+		}
+	}
+	t.stack = t.stack[:i]
+}
+
+// Check if an element is open.
+func (t *treeBuilder) inElement(name string) bool {
+	for i := range t.stack {
+		if t.stack[i].tag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the text content of a node.
+func textContent(n *node) string {
+	if n.tag == "" {
+		return n.text
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+// Check if a node is whitespace-only text.
+func isWhitespace(n *node) bool {
+	return n.tag == "" && strings.Trim(n.text, " \t\r\n\f") == ""
+}
+
+// Check if a node has a class.
+func hasClass(n *node, class string) bool {
+	return containsString(strings.Fields(n.attributes["class"]), class)
+}
+
+// Remove a string from a string slice. Returns nil if nothing is left.
+func removeString(list []string, s string) []string {
+	out := []string(nil)
+	for i := range list {
+		if list[i] != s {
+			out = append(out, list[i])
+		}
+	}
+	return out
+}
+
+// Check if a string slice contains a string.
+func containsString(list []string, s string) bool {
+	for i := range list {
+		if list[i] == s {
+			return true
+		}
+	}
+	return false
+}
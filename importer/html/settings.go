@@ -0,0 +1,28 @@
+// importer/html/settings.go
+// HTML import settings.
+
+package html
+
+// HTML import settings.
+type HTMLSettings struct {
+	// Fail on the first construct that cannot be mapped, instead of dropping
+	// or unwrapping it with a warning.
+	Strict bool
+
+	// Read leading h1, h2 and h3 headings followed by a horizontal rule as the
+	// title, subtitle, date and author, as written by the HTML exporter. A
+	// single h3 is read as the date.
+	TitleHeadings bool
+
+	UseCustomQuoteBlockClass bool
+	QuoteBlockClass          string
+
+	UseCustomImageBlockClass bool
+	ImageBlockClass          string
+
+	UseCustomImageCaptionClass bool
+	ImageCaptionClass          string
+
+	UseCustomNotesClass bool
+	NotesClass          string
+}
@@ -0,0 +1,126 @@
+// importer/html/style.go
+// Inline CSS parsing.
+
+package html
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	"gopkg.in/go-playground/colors.v1"
+)
+
+var sizeRegexp = regexp.MustCompile(`^([0-9]*\.?[0-9]+)\s*(%|px|pt|cm|mm)?$`)
+
+// Basic CSS color keywords.
+var namedColors = map[string]string{
+	"black": "#000000", "silver": "#c0c0c0", "gray": "#808080",
+	"grey": "#808080", "white": "#ffffff", "maroon": "#800000",
+	"red": "#ff0000", "purple": "#800080", "fuchsia": "#ff00ff",
+	"magenta": "#ff00ff", "green": "#008000", "lime": "#00ff00",
+	"olive": "#808000", "yellow": "#ffff00", "navy": "#000080",
+	"blue": "#0000ff", "teal": "#008080", "aqua": "#00ffff",
+	"cyan": "#00ffff", "orange": "#ffa500",
+}
+
+// A CSS declaration.
+type declaration struct {
+	property, value string
+}
+
+// Parse a style attribute into declarations.
+func parseStyle(style string) []declaration {
+	declarations := []declaration{}
+	for _, part := range strings.Split(style, ";") {
+		colon := strings.Index(part, ":")
+		if colon == -1 {
+			continue
+		}
+		property := strings.ToLower(strings.TrimSpace(part[:colon]))
+		value := strings.TrimSpace(part[colon+1:])
+		value = strings.TrimSpace(strings.TrimSuffix(value, "!important"))
+		if property != "" && value != "" {
+			declarations = append(declarations, declaration{property, value})
+		}
+	}
+	return declarations
+}
+
+// Get the alignment and wrapping of a block element from its style and
+// align attribute, along with its classes and id.
+func (h *HTMLImporter) blockStyle(n *node) ast.BaseBlock {
+	base := ast.BaseBlock{ID: strings.TrimSpace(n.attributes["id"])}
+	if classes := strings.Fields(n.attributes["class"]); len(classes) > 0 {
+		base.Classes = classes
+	}
+	if align, ok := n.attributes["align"]; ok {
+		base.Alignment = h.alignment(n, align)
+	}
+	for _, d := range parseStyle(n.attributes["style"]) {
+		switch d.property {
+		case "text-align":
+			base.Alignment = h.alignment(n, d.value)
+		case "float":
+			if strings.ToLower(d.value) == "none" {
+				continue
+			}
+			base.Alignment = h.alignment(n, d.value)
+			base.Wrap = base.Alignment != ast.NoAlign
+		default:
+			h.warn(n.line, "style property '"+d.property+"'", "dropped")
+		}
+	}
+	return base
+}
+
+// Parse an alignment value.
+func (h *HTMLImporter) alignment(n *node, value string) ast.AlignmentType {
+	switch strings.ToLower(value) {
+	case "left", "start":
+		return ast.LeftAlign
+	case "right", "end":
+		return ast.RightAlign
+	case "center", "middle":
+		return ast.CenterAlign
+	}
+	h.warn(n.line, "alignment '"+value+"'", "dropped")
+	return ast.NoAlign
+}
+
+// Parse a CSS length.
+func parseSize(value string) (float32, ast.SizeType, bool) {
+	match := sizeRegexp.FindStringSubmatch(strings.ToLower(strings.TrimSpace(value)))
+	if match == nil {
+		return 0, 0, false
+	}
+	v, err := strconv.ParseFloat(match[1], 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	switch match[2] {
+	case "%":
+		return float32(v), ast.PercentageSizeType, true
+	case "pt":
+		return float32(v), ast.PointSizeType, true
+	case "cm":
+		return float32(v), ast.CentimeterSizeType, true
+	case "mm":
+		return float32(v), ast.MillimeterSizeType, true
+	}
+	return float32(v), ast.PixelSizeType, true
+}
+
+// Parse a CSS color.
+func parseColor(value string) (colors.Color, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if hex, ok := namedColors[value]; ok {
+		value = hex
+	}
+	c, err := colors.Parse(value)
+	if err != nil {
+		return nil, false
+	}
+	return c, true
+}
@@ -0,0 +1,34 @@
+// importer/importer.go
+// Package importer provides a generic interface for importing alternate
+// formats into CDF abstract syntax trees.
+
+package importer
+
+import (
+	"strconv"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+type Importer interface {
+	Import(data []byte) (*ast.Document, error)
+}
+
+// A construct that could not be mapped onto the CDF AST.
+type Warning struct {
+	// The source line of the construct. Zero if unknown.
+	Line int
+
+	// The construct, like "html block" or "front matter field 'tags'".
+	Construct string
+	Message   string
+}
+
+// Format the warning as "line: construct: message".
+func (w Warning) String() string {
+	s := w.Construct + ": " + w.Message
+	if w.Line != 0 {
+		s = strconv.Itoa(w.Line) + ": " + s
+	}
+	return s
+}
@@ -0,0 +1,541 @@
+// importer/markdown/block.go
+// Markdown block parsing.
+
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+var (
+	atxHeadingRegexp     = regexp.MustCompile(`^ {0,3}(#{1,6})(?: +(.*?))? *$`)
+	thematicBreakRegexp  = regexp.MustCompile(`^ {0,3}(?:(?:\* *){3,}|(?:- *){3,}|(?:_ *){3,})$`)
+	fenceRegexp          = regexp.MustCompile("^( {0,3})(`{3,}|~{3,}) *(.*?) *$")
+	setextRegexp         = regexp.MustCompile(`^ {0,3}(=+|-+) *$`)
+	tableDelimiterRegexp = regexp.MustCompile(`^ {0,3}\|? *:?-+:? *(?:\| *:?-+:? *)*\|? *$`)
+	referenceRegexp      = regexp.MustCompile(`^ {0,3}\[((?:[^\]\\]|\\.)+)\]: *(<[^>\n]*>|\S+)(?: +(?:"[^"]*"|'[^']*'|\([^)]*\)))? *$`)
+	taskRegexp           = regexp.MustCompile(`^\[([ xX])\](?: +|$)`)
+	htmlBlockRegexp      = regexp.MustCompile(`^ {0,3}<(?:(/?[A-Za-z][A-Za-z0-9-]*)(?:[ />]|$)|!--|\?|![A-Za-z])`)
+)
+
+// HTML block tags that may interrupt a paragraph.
+var htmlBlockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"details": true, "dialog": true, "div": true, "dl": true, "fieldset": true,
+	"figcaption": true, "figure": true, "footer": true, "form": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "nav": true,
+	"ol": true, "p": true, "pre": true, "section": true, "summary": true,
+	"table": true, "ul": true,
+}
+
+// A list item marker.
+type listMarker struct {
+	ordered bool
+
+	// The bullet character, or the delimiter of an ordered marker.
+	char  byte
+	start int
+
+	// The column the item content starts at.
+	contentIndent int
+	empty         bool
+}
+
+// Parse a sequence of lines into blocks.
+func (m *MarkdownImporter) parseBlocks(lines []line) []ast.Block {
+	blocks := []ast.Block{}
+	for i := 0; i < len(lines); {
+		t := lines[i].text
+		if isBlank(t) {
+			i++
+			continue
+		}
+
+		var block ast.Block
+		var n int
+		switch {
+		case indentation(t) >= 4:
+			block, n = m.parseIndentedCode(lines[i:])
+		case fenceRegexp.MatchString(t) && validFence(t):
+			block, n = m.parseFencedCode(lines[i:])
+		case atxHeadingRegexp.MatchString(t):
+			block, n = m.parseATXHeading(lines[i]), 1
+		case thematicBreakRegexp.MatchString(t):
+			block, n = &ast.HorizontalRule{}, 1
+		case isQuoteLine(t):
+			block, n = m.parseQuote(lines[i:])
+		case isListLine(t):
+			block, n = m.parseList(lines[i:])
+		case htmlBlockRegexp.MatchString(t):
+			block, n = m.parseHTMLBlock(lines[i:])
+		default:
+			block, n = m.parseTable(lines[i:])
+			if n == 0 {
+				block, n = m.parseParagraph(lines[i:])
+			}
+		}
+		if block != nil {
+			blocks = append(blocks, block)
+		}
+		i += n
+	}
+	return blocks
+}
+
+// Parse an indented code block.
+func (m *MarkdownImporter) parseIndentedCode(lines []line) (ast.Block, int) {
+	code := []string{}
+	i := 0
+	for ; i < len(lines); i++ {
+		t := lines[i].text
+		if !isBlank(t) && indentation(t) < 4 {
+			break
+		}
+		if len(t) >= 4 {
+			code = append(code, t[4:])
+		} else {
+			code = append(code, "")
+		}
+	}
+	for len(code) > 0 && isBlank(code[len(code)-1]) {
+		code = code[:len(code)-1]
+	}
+	return codeParagraph(strings.Join(code, "\n")), i
+}
+
+// Parse a fenced code block.
+func (m *MarkdownImporter) parseFencedCode(lines []line) (ast.Block, int) {
+	match := fenceRegexp.FindStringSubmatch(lines[0].text)
+	indent, fence, info := len(match[1]), match[2], match[3]
+	if info != "" {
+		m.warn(lines[0].num, "code block info string", "language '"+strings.Fields(info)[0]+"' dropped")
+	}
+
+	code := []string{}
+	i := 1
+	for ; i < len(lines); i++ {
+		t := lines[i].text
+		if c := fenceRegexp.FindStringSubmatch(t); c != nil && c[2][0] == fence[0] && len(c[2]) >= len(fence) && c[3] == "" {
+			i++
+			break
+		}
+		// Remove up to the fence's indentation.
+		strip := indentation(t)
+		if strip > indent {
+			strip = indent
+		}
+		code = append(code, t[strip:])
+	}
+	return codeParagraph(strings.Join(code, "\n")), i
+}
+
+// Parse an ATX heading.
+func (m *MarkdownImporter) parseATXHeading(l line) ast.Block {
+	match := atxHeadingRegexp.FindStringSubmatch(l.text)
+	content := match[2]
+	// Remove an optional closing sequence.
+	if trimmed := strings.TrimRight(content, "#"); trimmed == "" || strings.HasSuffix(trimmed, " ") {
+		content = strings.TrimRight(trimmed, " ")
+	}
+	return m.heading(len(match[1]), content, l.num)
+}
+
+// Create a heading with a Markdown level.
+func (m *MarkdownImporter) heading(level int, content string, num int) ast.Block {
+	if level > 5 {
+		m.warn(num, "heading level 6", "mapped to level 5")
+		level = 5
+	}
+	return &ast.Heading{
+		Class:   ast.HeadingType(level - 1),
+		Content: m.parseInlines(content, num),
+	}
+}
+
+// Parse a block quote.
+func (m *MarkdownImporter) parseQuote(lines []line) (ast.Block, int) {
+	content := []line{}
+	i := 0
+	for ; i < len(lines); i++ {
+		t := lines[i].text
+		if isQuoteLine(t) {
+			t = strings.TrimLeft(t, " ")[1:]
+			if strings.HasPrefix(t, " ") {
+				t = t[1:]
+			}
+			content = append(content, line{text: t, num: lines[i].num})
+			continue
+		}
+		// Lazy paragraph continuation.
+		if !isBlank(t) && len(content) > 0 && !isBlank(content[len(content)-1].text) && !interruptsParagraph(t) {
+			content = append(content, lines[i])
+			continue
+		}
+		break
+	}
+	return &ast.Quote{Content: m.parseBlocks(content)}, i
+}
+
+// Parse a list.
+func (m *MarkdownImporter) parseList(lines []line) (ast.Block, int) {
+	first, _ := parseListMarker(lines[0].text)
+	list := &ast.List{Ordered: first.ordered}
+	if first.ordered && first.start != 1 {
+		m.warn(lines[0].num, "list start number", "numbering restarts at 1")
+	}
+
+	i := 0
+	for i < len(lines) {
+		marker, ok := parseListMarker(lines[i].text)
+		if !ok || marker.ordered != first.ordered || marker.char != first.char {
+			break
+		}
+
+		// Collect the item's lines, with the content indentation removed.
+		item := []line{{text: contentAfter(lines[i].text, marker.contentIndent), num: lines[i].num}}
+		i++
+		for ; i < len(lines); i++ {
+			t := lines[i].text
+			if isBlank(t) {
+				item = append(item, line{text: "", num: lines[i].num})
+				continue
+			}
+			if indentation(t) >= marker.contentIndent {
+				item = append(item, line{text: t[marker.contentIndent:], num: lines[i].num})
+				continue
+			}
+			if !isBlank(item[len(item)-1].text) && !interruptsParagraph(t) && !isListLine(t) {
+				item = append(item, lines[i])
+				continue
+			}
+			break
+		}
+		list.Items = append(list.Items, m.parseListItem(item))
+	}
+	return list, i
+}
+
+// Parse the content of a list item into a single block.
+func (m *MarkdownImporter) parseListItem(item []line) ast.Block {
+	task, checked := false, false
+	if !m.settings.CommonMarkOnly {
+		if match := taskRegexp.FindStringSubmatch(item[0].text); match != nil {
+			task, checked = true, match[1] != " "
+			item[0].text = item[0].text[len(match[0]):]
+		}
+	}
+
+	blocks := m.parseBlocks(item)
+	if task {
+		box := &ast.Text{Value: "☐ "}
+		if checked {
+			box.Value = "☑ "
+		}
+		if len(blocks) > 0 {
+			if p, ok := blocks[0].(*ast.Paragraph); ok {
+				p.Content = append([]ast.InlineBlock{box}, p.Content...)
+			} else {
+				blocks = append([]ast.Block{&ast.Paragraph{Content: []ast.InlineBlock{box}}}, blocks...)
+			}
+		} else {
+			blocks = []ast.Block{&ast.Paragraph{Content: []ast.InlineBlock{box}}}
+		}
+	}
+
+	switch len(blocks) {
+	case 0:
+		return &ast.Paragraph{Content: []ast.InlineBlock{}}
+	case 1:
+		return blocks[0]
+	}
+	return &ast.BasicBlock{Content: blocks}
+}
+
+// Parse an HTML block. The raw HTML is kept as text.
+func (m *MarkdownImporter) parseHTMLBlock(lines []line) (ast.Block, int) {
+	raw := []string{}
+	i := 0
+	for ; i < len(lines) && !isBlank(lines[i].text); i++ {
+		raw = append(raw, lines[i].text)
+	}
+	m.warn(lines[0].num, "html block", "kept as plain text")
+	return &ast.Paragraph{Content: []ast.InlineBlock{&ast.Text{Value: strings.Join(raw, "\n")}}}, i
+}
+
+// Parse a GFM table. Returns zero lines consumed if the lines don't start a
+// table.
+func (m *MarkdownImporter) parseTable(lines []line) (ast.Block, int) {
+	if m.settings.CommonMarkOnly || len(lines) < 2 || !strings.Contains(lines[0].text, "|") {
+		return nil, 0
+	}
+	if !tableDelimiterRegexp.MatchString(lines[1].text) {
+		return nil, 0
+	}
+	header := splitTableRow(lines[0].text)
+	delimiters := splitTableRow(lines[1].text)
+	if len(header) != len(delimiters) {
+		return nil, 0
+	}
+
+	alignments := make([]ast.AlignmentType, len(delimiters))
+	for i, d := range delimiters {
+		d = strings.TrimSpace(d)
+		left, right := strings.HasPrefix(d, ":"), strings.HasSuffix(d, ":")
+		switch {
+		case left && right:
+			alignments[i] = ast.CenterAlign
+		case left:
+			alignments[i] = ast.LeftAlign
+		case right:
+			alignments[i] = ast.RightAlign
+		}
+	}
+
+	table := &ast.Table{Rows: []ast.TableRow{m.tableRow(header, alignments, true, lines[0].num)}}
+	i := 2
+	for ; i < len(lines); i++ {
+		t := lines[i].text
+		if isBlank(t) || interruptsParagraph(t) {
+			break
+		}
+		table.Rows = append(table.Rows, m.tableRow(splitTableRow(t), alignments, false, lines[i].num))
+	}
+	return table, i
+}
+
+// Create a table row, padding or truncating the cells to the column count.
+func (m *MarkdownImporter) tableRow(cells []string, alignments []ast.AlignmentType, header bool, num int) ast.TableRow {
+	row := ast.TableRow{Cells: make([]ast.TableCell, len(alignments))}
+	for i := range alignments {
+		row.Cells[i] = ast.TableCell{Content: []ast.Block{}, IsHeader: header}
+		if i >= len(cells) {
+			continue
+		}
+		text := strings.TrimSpace(cells[i])
+		if text == "" {
+			continue
+		}
+		row.Cells[i].Content = []ast.Block{&ast.Paragraph{
+			BaseBlock: ast.BaseBlock{Alignment: alignments[i]},
+			Content:   m.parseInlines(text, num),
+		}}
+	}
+	return row
+}
+
+// Parse a paragraph, or a setext heading.
+func (m *MarkdownImporter) parseParagraph(lines []line) (ast.Block, int) {
+	text := []string{}
+	i := 0
+	for ; i < len(lines); i++ {
+		t := lines[i].text
+		if isBlank(t) {
+			break
+		}
+		if i > 0 {
+			if match := setextRegexp.FindStringSubmatch(t); match != nil {
+				text = stripReferences(text)
+				if len(text) == 0 {
+					break
+				}
+				level := 1
+				if match[1][0] == '-' {
+					level = 2
+				}
+				return m.heading(level, strings.Join(text, "\n"), lines[0].num), i + 1
+			}
+			if interruptsParagraph(t) {
+				break
+			}
+		}
+		text = append(text, strings.TrimLeft(t, " "))
+	}
+
+	text = stripReferences(text)
+	if len(text) == 0 {
+		return nil, i
+	}
+	return &ast.Paragraph{Content: m.parseInlines(strings.Join(text, "\n"), lines[0].num)}, i
+}
+
+// Collect link reference definitions from the whole document, since
+// references may be used before they are defined.
+func (m *MarkdownImporter) collectReferences(lines []line) {
+	var fence string
+	for _, l := range lines {
+		t := strings.TrimLeft(l.text, " >")
+		if match := fenceRegexp.FindStringSubmatch(t); match != nil {
+			if fence == "" {
+				fence = match[2]
+			} else if match[2][0] == fence[0] && len(match[2]) >= len(fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+		match := referenceRegexp.FindStringSubmatch(t)
+		if match == nil {
+			continue
+		}
+		label := normalizeLabel(match[1])
+		if _, ok := m.references[label]; !ok {
+			m.references[label] = unescapeDestination(match[2])
+		}
+	}
+}
+
+// Remove leading link reference definitions from paragraph lines.
+func stripReferences(text []string) []string {
+	for len(text) > 0 && referenceRegexp.MatchString(text[0]) {
+		text = text[1:]
+	}
+	return text
+}
+
+// Normalize a link label for matching.
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// Parse a list marker at the start of a line.
+func parseListMarker(t string) (listMarker, bool) {
+	marker := listMarker{}
+	indent := indentation(t)
+	if indent >= 4 {
+		return marker, false
+	}
+	rest := t[indent:]
+	width := 0
+	switch {
+	case rest == "":
+		return marker, false
+	case rest[0] == '-' || rest[0] == '+' || rest[0] == '*':
+		marker.char = rest[0]
+		width = 1
+	default:
+		digits := 0
+		for digits < len(rest) && digits < 9 && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 || digits == len(rest) || (rest[digits] != '.' && rest[digits] != ')') {
+			return marker, false
+		}
+		marker.ordered = true
+		marker.char = rest[digits]
+		marker.start, _ = strconv.Atoi(rest[:digits])
+		width = digits + 1
+	}
+
+	after := rest[width:]
+	if after != "" && after[0] != ' ' {
+		return marker, false
+	}
+	spaces := indentation(after)
+	switch {
+	case isBlank(after):
+		marker.empty = true
+		marker.contentIndent = indent + width + 1
+	case spaces > 4:
+		// The content is indented code.
+		marker.contentIndent = indent + width + 1
+	default:
+		marker.contentIndent = indent + width + spaces
+	}
+	return marker, true
+}
+
+// Check if a line can interrupt a paragraph.
+func interruptsParagraph(t string) bool {
+	if indentation(t) >= 4 {
+		return false
+	}
+	if atxHeadingRegexp.MatchString(t) || thematicBreakRegexp.MatchString(t) || isQuoteLine(t) {
+		return true
+	}
+	if fenceRegexp.MatchString(t) && validFence(t) {
+		return true
+	}
+	if marker, ok := parseListMarker(t); ok && !marker.empty && (!marker.ordered || marker.start == 1) {
+		return true
+	}
+	if match := htmlBlockRegexp.FindStringSubmatch(t); match != nil {
+		return match[1] == "" || htmlBlockTags[strings.ToLower(strings.TrimPrefix(match[1], "/"))]
+	}
+	return false
+}
+
+// Check if a fence line is valid. Backtick fences may not have backticks in
+// their info string.
+func validFence(t string) bool {
+	match := fenceRegexp.FindStringSubmatch(t)
+	return match[2][0] != '`' || !strings.Contains(match[3], "`")
+}
+
+// Check if a line starts a block quote.
+func isQuoteLine(t string) bool {
+	return indentation(t) < 4 && strings.HasPrefix(strings.TrimLeft(t, " "), ">")
+}
+
+// Check if a line starts a list item.
+func isListLine(t string) bool {
+	if thematicBreakRegexp.MatchString(t) {
+		return false
+	}
+	_, ok := parseListMarker(t)
+	return ok
+}
+
+// Get the content of a line after a column.
+func contentAfter(t string, column int) string {
+	if column >= len(t) {
+		return ""
+	}
+	return t[column:]
+}
+
+// Get the number of leading spaces of a line.
+func indentation(t string) int {
+	return len(t) - len(strings.TrimLeft(t, " "))
+}
+
+// Check if a line is blank.
+func isBlank(t string) bool {
+	return strings.TrimSpace(t) == ""
+}
+
+// Split a GFM table row into its cells.
+func splitTableRow(t string) []string {
+	t = strings.TrimSpace(t)
+	t = strings.TrimPrefix(t, "|")
+	if strings.HasSuffix(t, "|") && !strings.HasSuffix(t, "\\|") {
+		t = t[:len(t)-1]
+	}
+
+	cells := []string{}
+	start := 0
+	for i := 0; i < len(t); i++ {
+		switch t[i] {
+		case '\\':
+			i++
+		case '|':
+			cells = append(cells, t[start:i])
+			start = i + 1
+		}
+	}
+	return append(cells, t[start:])
+}
+
+// Create a teletype paragraph for a code block.
+func codeParagraph(code string) ast.Block {
+	return &ast.Paragraph{Content: []ast.InlineBlock{&ast.FormattingBlock{
+		BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{&ast.Text{Value: code}}},
+		Attribute:       ast.TeletypeFormatting,
+	}}}
+}
@@ -0,0 +1,500 @@
+// importer/markdown/inline.go
+// Markdown inline parsing.
+
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+var (
+	autolinkRegexp   = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^<>\x00-\x20]*)>`)
+	emailRegexp      = regexp.MustCompile(`^<([A-Za-z0-9.!#$%&'*+/=?^_{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*)>`)
+	inlineHTMLRegexp = regexp.MustCompile(`^<(?:(/?[A-Za-z][A-Za-z0-9-]*)(?:\s[^<>]*)?/?>|!--[\s\S]*?-->)`)
+	entityRegexp     = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+)
+
+// An item on the inline stack: a node, a delimiter run or a bracket.
+type inlineItem struct {
+	node ast.InlineBlock
+
+	// Delimiter runs. The count is what remains unmatched, the length is the
+	// original run length.
+	delimiter         byte
+	count, length     int
+	canOpen, canClose bool
+
+	// Link and image openers. Start is the offset after the bracket.
+	bracket, image, inactive bool
+	start                    int
+}
+
+// Inline parser state.
+type inlineParser struct {
+	m     *MarkdownImporter
+	src   string
+	num   int
+	items []*inlineItem
+	text  []byte
+}
+
+// Parse inline content.
+func (m *MarkdownImporter) parseInlines(s string, num int) []ast.InlineBlock {
+	p := &inlineParser{m: m, src: strings.TrimRight(s, " "), num: num}
+	p.parse()
+	return flatten(processEmphasis(p.items))
+}
+
+// Tokenize the source onto the inline stack, resolving links as their
+// closing brackets are found.
+func (p *inlineParser) parse() {
+	s := p.src
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				p.lineBreak(true)
+				i = skipSpaces(s, i+2)
+			} else if i+1 < len(s) && isASCIIPunctuation(s[i+1]) {
+				p.text = append(p.text, s[i+1])
+				i += 2
+			} else {
+				p.text = append(p.text, c)
+				i++
+			}
+		case '`':
+			i = p.parseCodeSpan(i)
+		case '*', '_', '~':
+			i = p.parseDelimiter(i)
+		case '!':
+			if i+1 < len(s) && s[i+1] == '[' {
+				p.pushBracket(i+2, true)
+				i += 2
+			} else {
+				p.text = append(p.text, c)
+				i++
+			}
+		case '[':
+			p.pushBracket(i+1, false)
+			i++
+		case ']':
+			i = p.closeBracket(i)
+		case '<':
+			i = p.parseAngle(i)
+		case '&':
+			i = p.parseEntity(i)
+		case '\n':
+			p.lineBreak(strings.HasSuffix(string(p.text), "  "))
+			i = skipSpaces(s, i+1)
+		default:
+			p.text = append(p.text, c)
+			i++
+		}
+	}
+	p.flush()
+}
+
+// Push the pending text onto the stack.
+func (p *inlineParser) flush() {
+	if len(p.text) == 0 {
+		return
+	}
+	p.items = append(p.items, &inlineItem{node: &ast.Text{Value: string(p.text)}})
+	p.text = p.text[:0]
+}
+
+// Write a hard or soft line break.
+func (p *inlineParser) lineBreak(hard bool) {
+	p.text = []byte(strings.TrimRight(string(p.text), " "))
+	if hard {
+		p.text = append(p.text, '\n')
+	} else {
+		p.text = append(p.text, ' ')
+	}
+}
+
+// Parse a code span, or literal backticks if the span isn't closed.
+func (p *inlineParser) parseCodeSpan(i int) int {
+	s := p.src
+	n := runLength(s, i)
+	for j := i + n; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+		k := j + runLength(s, j)
+		if k-j == n {
+			code := strings.ReplaceAll(s[i+n:j], "\n", " ")
+			if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			p.flush()
+			p.items = append(p.items, &inlineItem{node: &ast.FormattingBlock{
+				BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{&ast.Text{Value: code}}},
+				Attribute:       ast.TeletypeFormatting,
+			}})
+			return k
+		}
+		j = k
+	}
+	p.text = append(p.text, s[i:i+n]...)
+	return i + n
+}
+
+// Parse a run of emphasis or strikethrough delimiters.
+func (p *inlineParser) parseDelimiter(i int) int {
+	s := p.src
+	c := s[i]
+	j := i + runLength(s, i)
+	if c == '~' && (p.m.settings.CommonMarkOnly || j-i > 2) {
+		p.text = append(p.text, s[i:j]...)
+		return j
+	}
+
+	before, after := ' ', ' '
+	if i > 0 {
+		before, _ = utf8.DecodeLastRuneInString(s[:i])
+	}
+	if j < len(s) {
+		after, _ = utf8.DecodeRuneInString(s[j:])
+	}
+	left := !unicode.IsSpace(after) && (!isPunctuation(after) || unicode.IsSpace(before) || isPunctuation(before))
+	right := !unicode.IsSpace(before) && (!isPunctuation(before) || unicode.IsSpace(after) || isPunctuation(after))
+
+	item := &inlineItem{delimiter: c, count: j - i, length: j - i, canOpen: left, canClose: right}
+	if c == '_' {
+		item.canOpen = left && (!right || isPunctuation(before))
+		item.canClose = right && (!left || isPunctuation(after))
+	}
+	p.flush()
+	p.items = append(p.items, item)
+	return j
+}
+
+// Push a link or image opener.
+func (p *inlineParser) pushBracket(start int, image bool) {
+	p.flush()
+	p.items = append(p.items, &inlineItem{bracket: true, image: image, start: start})
+}
+
+// Handle a closing bracket, creating a link or image if it has a target.
+func (p *inlineParser) closeBracket(i int) int {
+	p.flush()
+	b := -1
+	for k := len(p.items) - 1; k >= 0; k-- {
+		if p.items[k].bracket {
+			b = k
+			break
+		}
+	}
+	if b == -1 {
+		p.text = append(p.text, ']')
+		return i + 1
+	}
+
+	opener := p.items[b]
+	destination, end, ok := "", i+1, false
+	if !opener.inactive {
+		destination, end, ok = p.parseLinkTarget(i+1, p.src[opener.start:i])
+	}
+	if !ok {
+		opener.bracket = false
+		opener.node = &ast.Text{Value: bracketText(opener)}
+		p.text = append(p.text, ']')
+		return i + 1
+	}
+
+	content := flatten(processEmphasis(p.items[b+1:]))
+	var node ast.InlineBlock
+	if opener.image {
+		node = &ast.InlineImageBlock{Source: destination, Alt: ast.PlainText(content)}
+	} else {
+		node = &ast.HyperlinkBlock{
+			BaseInlineBlock: ast.BaseInlineBlock{Content: content},
+			Destination:     destination,
+		}
+		// Links may not contain other links.
+		for _, item := range p.items[:b] {
+			if item.bracket && !item.image {
+				item.inactive = true
+			}
+		}
+	}
+	p.items = append(p.items[:b], &inlineItem{node: node})
+	return end
+}
+
+// Parse an inline link target, or a full, collapsed or shortcut reference,
+// starting after a closing bracket.
+func (p *inlineParser) parseLinkTarget(i int, label string) (string, int, bool) {
+	s := p.src
+	if i < len(s) && s[i] == '(' {
+		if destination, end, ok := p.parseInlineTarget(i); ok {
+			return destination, end, true
+		}
+	}
+	if i < len(s) && s[i] == '[' {
+		if k := strings.IndexByte(s[i+1:], ']'); k != -1 {
+			ref := s[i+1 : i+1+k]
+			if ref == "" {
+				ref = label
+			}
+			if destination, ok := p.m.references[normalizeLabel(ref)]; ok {
+				return destination, i + 2 + k, true
+			}
+			if k != 0 {
+				return "", i, false
+			}
+		}
+	}
+	if destination, ok := p.m.references[normalizeLabel(label)]; ok {
+		return destination, i, true
+	}
+	return "", i, false
+}
+
+// Parse an inline link target like "(destination "title")".
+func (p *inlineParser) parseInlineTarget(i int) (string, int, bool) {
+	s := p.src
+	j := skipWhitespace(s, i+1)
+	destination := ""
+	if j < len(s) && s[j] == '<' {
+		k := strings.IndexAny(s[j+1:], ">\n")
+		if k == -1 || s[j+1+k] != '>' {
+			return "", i, false
+		}
+		destination = s[j+1 : j+1+k]
+		j += k + 2
+	} else {
+		depth, k := 0, j
+		for ; k < len(s); k++ {
+			c := s[k]
+			if c == '\\' && k+1 < len(s) && isASCIIPunctuation(s[k+1]) {
+				k++
+				continue
+			}
+			if c <= ' ' {
+				break
+			}
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+		}
+		destination = s[j:k]
+		j = k
+	}
+
+	j = skipWhitespace(s, j)
+	if j < len(s) && (s[j] == '"' || s[j] == '\'' || s[j] == '(') {
+		closing := s[j]
+		if closing == '(' {
+			closing = ')'
+		}
+		k := j + 1
+		for ; k < len(s) && s[k] != closing; k++ {
+			if s[k] == '\\' {
+				k++
+			}
+		}
+		if k >= len(s) {
+			return "", i, false
+		}
+		p.m.warn(p.num, "link title", "dropped")
+		j = skipWhitespace(s, k+1)
+	}
+	if j >= len(s) || s[j] != ')' {
+		return "", i, false
+	}
+	return unescapeDestination(destination), j + 1, true
+}
+
+// Parse an autolink or raw HTML, or a literal '<'.
+func (p *inlineParser) parseAngle(i int) int {
+	s := p.src[i:]
+	if match := autolinkRegexp.FindStringSubmatch(s); match != nil {
+		p.pushLink(match[1], match[1])
+		return i + len(match[0])
+	}
+	if match := emailRegexp.FindStringSubmatch(s); match != nil {
+		p.pushLink("mailto:"+match[1], match[1])
+		return i + len(match[0])
+	}
+	if match := inlineHTMLRegexp.FindStringSubmatch(s); match != nil {
+		if strings.EqualFold(match[1], "br") {
+			p.text = append(p.text, '\n')
+		} else {
+			p.m.warn(p.num, "inline html", "'"+match[0]+"' dropped")
+		}
+		return i + len(match[0])
+	}
+	p.text = append(p.text, '<')
+	return i + 1
+}
+
+// Push an autolink.
+func (p *inlineParser) pushLink(destination, text string) {
+	p.flush()
+	p.items = append(p.items, &inlineItem{node: &ast.HyperlinkBlock{
+		BaseInlineBlock: ast.BaseInlineBlock{Content: []ast.InlineBlock{&ast.Text{Value: text}}},
+		Destination:     destination,
+	}})
+}
+
+// Parse an entity or numeric character reference, or a literal '&'.
+func (p *inlineParser) parseEntity(i int) int {
+	match := entityRegexp.FindString(p.src[i:])
+	if match == "" {
+		p.text = append(p.text, '&')
+		return i + 1
+	}
+	p.text = append(p.text, html.UnescapeString(match)...)
+	return i + len(match)
+}
+
+// Match emphasis and strikethrough delimiters, following the CommonMark
+// delimiter algorithm.
+func processEmphasis(items []*inlineItem) []*inlineItem {
+	items = append([]*inlineItem{}, items...)
+	for c := 0; c < len(items); c++ {
+		closer := items[c]
+		if closer.delimiter == 0 || !closer.canClose || closer.count == 0 {
+			continue
+		}
+		for o := c - 1; o >= 0; o-- {
+			opener := items[o]
+			if opener.delimiter != closer.delimiter || !opener.canOpen || opener.count == 0 {
+				continue
+			}
+
+			n, attribute := 1, ast.ItalicFormatting
+			if closer.delimiter == '~' {
+				if opener.count != closer.count {
+					continue
+				}
+				n, attribute = opener.count, ast.StrikethroughFormatting
+			} else {
+				// The rule of three.
+				if (opener.canClose || closer.canOpen) && (opener.length+closer.length)%3 == 0 && !(opener.length%3 == 0 && closer.length%3 == 0) {
+					continue
+				}
+				if opener.count >= 2 && closer.count >= 2 {
+					n, attribute = 2, ast.BoldFormatting
+				}
+			}
+
+			node := &ast.FormattingBlock{
+				BaseInlineBlock: ast.BaseInlineBlock{Content: flatten(items[o+1 : c])},
+				Attribute:       attribute,
+			}
+			opener.count -= n
+			closer.count -= n
+			rest := append([]*inlineItem{{node: node}}, items[c:]...)
+			items = append(items[:o+1], rest...)
+
+			// Look at the closer again, in case it has delimiters left.
+			c = o
+			break
+		}
+	}
+	return items
+}
+
+// Convert stack items to inline blocks, merging adjacent text.
+func flatten(items []*inlineItem) []ast.InlineBlock {
+	blocks := []ast.InlineBlock{}
+	for _, item := range items {
+		node := item.node
+		switch {
+		case item.delimiter != 0:
+			node = &ast.Text{Value: strings.Repeat(string(item.delimiter), item.count)}
+		case item.bracket:
+			node = &ast.Text{Value: bracketText(item)}
+		}
+		text, ok := node.(*ast.Text)
+		if !ok {
+			blocks = append(blocks, node)
+			continue
+		}
+		if text.Value == "" {
+			continue
+		}
+		if len(blocks) > 0 {
+			if previous, ok := blocks[len(blocks)-1].(*ast.Text); ok {
+				previous.Value += text.Value
+				continue
+			}
+		}
+		blocks = append(blocks, &ast.Text{Value: text.Value})
+	}
+	return blocks
+}
+
+// Get the literal text of an unmatched bracket.
+func bracketText(item *inlineItem) string {
+	if item.image {
+		return "!["
+	}
+	return "["
+}
+
+// Remove angle brackets, escapes and entities from a link destination.
+func unescapeDestination(d string) string {
+	if strings.HasPrefix(d, "<") && strings.HasSuffix(d, ">") {
+		d = d[1 : len(d)-1]
+	}
+	var b strings.Builder
+	for i := 0; i < len(d); i++ {
+		if d[i] == '\\' && i+1 < len(d) && isASCIIPunctuation(d[i+1]) {
+			i++
+		}
+		b.WriteByte(d[i])
+	}
+	return html.UnescapeString(b.String())
+}
+
+// Get the length of a run of the same byte.
+func runLength(s string, i int) int {
+	j := i
+	for j < len(s) && s[j] == s[i] {
+		j++
+	}
+	return j - i
+}
+
+// Skip spaces.
+func skipSpaces(s string, i int) int {
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	return i
+}
+
+// Skip spaces and up to one line ending.
+func skipWhitespace(s string, i int) int {
+	i = skipSpaces(s, i)
+	if i < len(s) && s[i] == '\n' {
+		i = skipSpaces(s, i+1)
+	}
+	return i
+}
+
+// Check if a byte is ASCII punctuation.
+func isASCIIPunctuation(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) != -1
+}
+
+// Check if a rune is Unicode punctuation, for flanking rules.
+func isPunctuation(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
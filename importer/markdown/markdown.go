@@ -0,0 +1,149 @@
+// importer/markdown/markdown.go
+// Markdown importer.
+
+package markdown
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	"github.com/cubeflix/cdf/importer"
+)
+
+// Markdown importer. Parses CommonMark, with the GFM table, task list and
+// strikethrough extensions, into a CDF AST.
+type MarkdownImporter struct {
+	settings MarkdownSettings
+	warnings []importer.Warning
+
+	// Link reference definitions, keyed by normalized label.
+	references map[string]string
+}
+
+// Create a new Markdown importer.
+func NewMarkdownImporter(settings MarkdownSettings) *MarkdownImporter {
+	return &MarkdownImporter{
+		settings: settings,
+	}
+}
+
+// Get the constructs that could not be mapped by the last import.
+func (m *MarkdownImporter) Warnings() []importer.Warning {
+	return m.warnings
+}
+
+// A source line.
+type line struct {
+	text string
+	num  int
+}
+
+// Import a Markdown document.
+func (m *MarkdownImporter) Import(data []byte) (*ast.Document, error) {
+	m.warnings = nil
+	m.references = map[string]string{}
+
+	raw := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	lines := make([]line, 0, len(raw))
+	for i := range raw {
+		lines = append(lines, line{text: expandTabs(strings.TrimSuffix(raw[i], "\r")), num: i + 1})
+	}
+
+	d := &ast.Document{}
+	if !m.settings.NoFrontMatter {
+		lines = m.parseFrontMatter(d, lines)
+	}
+	m.collectReferences(lines)
+	d.Content = m.parseBlocks(lines)
+	return d, nil
+}
+
+// Record a construct that could not be mapped.
+func (m *MarkdownImporter) warn(num int, construct, message string) {
+	m.warnings = append(m.warnings, importer.Warning{
+		Line:      num,
+		Construct: construct,
+		Message:   message,
+	})
+}
+
+// Parse YAML front matter into the document metadata, returning the
+// remaining lines. Only flat "key: value" pairs are understood.
+func (m *MarkdownImporter) parseFrontMatter(d *ast.Document, lines []line) []line {
+	if len(lines) == 0 || strings.TrimRight(lines[0].text, " ") != "---" {
+		return lines
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		t := strings.TrimRight(lines[i].text, " ")
+		if t == "---" || t == "..." {
+			end = i
+			break
+		}
+	}
+	if end == -1 {
+		return lines
+	}
+
+	for _, l := range lines[1:end] {
+		t := strings.TrimSpace(l.text)
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		colon := strings.Index(t, ":")
+		if colon == -1 || strings.HasPrefix(l.text, " ") || strings.HasPrefix(t, "-") {
+			m.warn(l.num, "front matter", "only flat 'key: value' pairs are supported")
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(t[:colon]))
+		value := unquoteYAML(strings.TrimSpace(t[colon+1:]))
+		switch key {
+		case "title":
+			d.Title = value
+		case "subtitle":
+			d.Subtitle = value
+		case "author":
+			d.Author = value
+		case "date":
+			d.Date = value
+		default:
+			m.warn(l.num, "front matter field '"+key+"'", "no matching document field")
+		}
+	}
+	return lines[end+1:]
+}
+
+// Unquote a YAML scalar.
+func unquoteYAML(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+		return s[1 : len(s)-1]
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+// Expand tabs to spaces with a tab stop of four.
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
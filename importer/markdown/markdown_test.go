@@ -0,0 +1,128 @@
+// importer/markdown/markdown_test.go
+// Markdown import tests.
+
+package markdown
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cubeflix/cdf/parser"
+)
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		settings MarkdownSettings
+		want     string
+		warnings []string
+	}{
+		{
+			name:     "front matter",
+			markdown: "---\ntitle: \"T\"\nauthor: A\ndate: 2024\ntags: x\n---\ntext",
+			want:     "[[cdf title=T|date=2024|author=A]]\n\t[[p]]text[[/]]\n[[/]]\n",
+			warnings: []string{"5: front matter field 'tags': no matching document field"},
+		},
+		{
+			name:     "no front matter",
+			markdown: "---\ntitle: T\n---\n",
+			settings: MarkdownSettings{NoFrontMatter: true},
+			want:     "[[cdf]]\n\t[[hr]][[/]]\n\t[[h c=2]]title: T[[/]]\n[[/]]\n",
+		},
+		{
+			name:     "headings",
+			markdown: "# One\n## Two\n###### Six\n\nSetext\n======",
+			want:     "[[cdf]]\n\t[[h c=1]]One[[/]]\n\t[[h c=2]]Two[[/]]\n\t[[h c=5]]Six[[/]]\n\t[[h c=1]]Setext[[/]]\n[[/]]\n",
+			warnings: []string{"3: heading level 6: mapped to level 5"},
+		},
+		{
+			name:     "emphasis",
+			markdown: "a *em* **strong** `code` ~~del~~",
+			want:     "[[cdf]]\n\t[[p]]a [[i]]em[[/]] [[b]]strong[[/]] [[t]]code[[/]] [[s]]del[[/]][[/]]\n[[/]]\n",
+		},
+		{
+			name:     "commonmark only",
+			markdown: "~~del~~",
+			settings: MarkdownSettings{CommonMarkOnly: true},
+			want:     "[[cdf]]\n\t[[p]]~~del~~[[/]]\n[[/]]\n",
+		},
+		{
+			name:     "quote",
+			markdown: "> quote\n> more",
+			want:     "[[cdf]]\n\t[[quote]]\n\t\t[[p]]quote more[[/]]\n\t[[/]]\n[[/]]\n",
+		},
+		{
+			name:     "lists",
+			markdown: "- a\n- b\n\n1. x\n2. y",
+			want:     "[[cdf]]\n\t[[list]]\n\t\t[[p]]a[[/]]\n\t\t[[p]]b[[/]]\n\t[[/]]\n\t[[list ordered=]]\n\t\t[[p]]x[[/]]\n\t\t[[p]]y[[/]]\n\t[[/]]\n[[/]]\n",
+		},
+		{
+			name:     "task list",
+			markdown: "- [ ] todo\n- [x] done",
+			want:     "[[cdf]]\n\t[[list]]\n\t\t[[p]]☐ todo[[/]]\n\t\t[[p]]☑ done[[/]]\n\t[[/]]\n[[/]]\n",
+		},
+		{
+			name:     "table",
+			markdown: "| a | b |\n| :-- | --: |\n| 1 | 2 |",
+			want: "[[cdf]]\n\t[[table]]\n" +
+				"\t\t[[row]]\n\t\t\t[[cell is-header=]]\n\t\t\t\t[[p align=left]]a[[/]]\n\t\t\t[[/]]\n\t\t\t[[cell is-header=]]\n\t\t\t\t[[p align=right]]b[[/]]\n\t\t\t[[/]]\n\t\t[[/]]\n" +
+				"\t\t[[row]]\n\t\t\t[[cell]]\n\t\t\t\t[[p align=left]]1[[/]]\n\t\t\t[[/]]\n\t\t\t[[cell]]\n\t\t\t\t[[p align=right]]2[[/]]\n\t\t\t[[/]]\n\t\t[[/]]\n" +
+				"\t[[/]]\n[[/]]\n",
+		},
+		{
+			name:     "links and images",
+			markdown: "[l](https://x.org \"t\") ![alt](i.png) <https://auto.org>\n\n[ref]\n\n[ref]: /r",
+			want:     "[[cdf]]\n\t[[p]][[link dest=https://x.org]]l[[/]] [[inline-image src=i.png|alt=alt]][[/]] [[link dest=https://auto.org]]https://auto.org[[/]][[/]]\n\t[[p]][[link dest=/r]]ref[[/]][[/]]\n[[/]]\n",
+			warnings: []string{"1: link title: dropped"},
+		},
+		{
+			name:     "code blocks",
+			markdown: "```go\nx := 1\n```\n\n    indented",
+			want:     "[[cdf]]\n\t[[p]][[t]]x := 1[[/]][[/]]\n\t[[p]][[t]]indented[[/]][[/]]\n[[/]]\n",
+			warnings: []string{"1: code block info string: language 'go' dropped"},
+		},
+		{
+			name:     "breaks and entities",
+			markdown: "a  \nb\\\nc &amp; &copy;",
+			want:     "[[cdf]]\n\t[[p]]a\nb\nc & ©[[/]]\n[[/]]\n",
+		},
+		{
+			name:     "thematic breaks",
+			markdown: "---\n\n***",
+			want:     "[[cdf]]\n\t[[hr]][[/]]\n\t[[hr]][[/]]\n[[/]]\n",
+		},
+		{
+			name:     "html block",
+			markdown: "<div>html</div>",
+			want:     "[[cdf]]\n\t[[p]]<div>html</div>[[/]]\n[[/]]\n",
+			warnings: []string{"1: html block: kept as plain text"},
+		},
+	}
+	for _, test := range tests {
+		i := NewMarkdownImporter(test.settings)
+		d, err := i.Import([]byte(test.markdown))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		got, err := parser.FormatBytes(d)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+		warnings := []string{}
+		for _, w := range i.Warnings() {
+			warnings = append(warnings, w.String())
+		}
+		if test.warnings == nil {
+			test.warnings = []string{}
+		}
+		if !reflect.DeepEqual(warnings, test.warnings) {
+			t.Errorf("%s: got warnings %q, want %q", test.name, warnings, test.warnings)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+// importer/markdown/settings.go
+// Markdown import settings.
+
+package markdown
+
+// Markdown import settings.
+type MarkdownSettings struct {
+	// Only parse CommonMark, without the GFM table, task list and
+	// strikethrough extensions.
+	CommonMarkOnly bool
+
+	// Don't read YAML front matter.
+	NoFrontMatter bool
+}
@@ -0,0 +1,257 @@
+// importer/pandoc/inline.go
+// Inline element and style reading.
+
+package pandoc
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	exportpandoc "github.com/cubeflix/cdf/export/pandoc"
+	"gopkg.in/go-playground/colors.v1"
+)
+
+var sizeRegexp = regexp.MustCompile(`^([0-9]*\.?[0-9]+)\s*(%|px|pt|cm|mm)?$`)
+
+// Read a slice of inlines, merging adjacent text.
+func (p *PandocImporter) readInlines(v interface{}) []ast.InlineBlock {
+	out := []ast.InlineBlock{}
+	for _, i := range list(v) {
+		out = append(out, p.readInline(i)...)
+	}
+	return merge(out)
+}
+
+// Read an inline. Inlines without a CDF equivalent are unwrapped or
+// dropped.
+func (p *PandocImporter) readInline(v interface{}) []ast.InlineBlock {
+	t, c := element(v)
+	args := list(c)
+
+	switch t {
+	case "Str":
+		s, _ := c.(string)
+		return []ast.InlineBlock{&ast.Text{Value: s}}
+	case "Space", "SoftBreak":
+		return []ast.InlineBlock{&ast.Text{Value: " "}}
+	case "LineBreak":
+		return []ast.InlineBlock{&ast.Text{Value: "\n"}}
+	case "Emph":
+		return []ast.InlineBlock{formatting(ast.ItalicFormatting, p.readInlines(c))}
+	case "Strong":
+		return []ast.InlineBlock{formatting(ast.BoldFormatting, p.readInlines(c))}
+	case "Strikeout":
+		return []ast.InlineBlock{formatting(ast.StrikethroughFormatting, p.readInlines(c))}
+	case "Underline":
+		return []ast.InlineBlock{formatting(ast.UnderlineFormatting, p.readInlines(c))}
+	case "Superscript", "Subscript", "SmallCaps":
+		p.warn(strings.ToLower(t), "unwrapped")
+		return p.readInlines(c)
+	case "Quoted":
+		if len(args) != 2 {
+			break
+		}
+		open, close := "“", "”"
+		if q, _ := element(args[0]); q == "SingleQuote" {
+			open, close = "‘", "’"
+		}
+		out := []ast.InlineBlock{&ast.Text{Value: open}}
+		out = append(out, p.readInlines(args[1])...)
+		return append(out, &ast.Text{Value: close})
+	case "Cite":
+		if len(args) != 2 {
+			break
+		}
+		p.warn("citation", "unwrapped")
+		return p.readInlines(args[1])
+	case "Code":
+		if len(args) != 2 {
+			break
+		}
+		code, _ := args[1].(string)
+		return []ast.InlineBlock{teletype([]ast.InlineBlock{&ast.Text{Value: code}})}
+	case "Math":
+		if len(args) != 2 {
+			break
+		}
+		p.warn("math", "kept as TeX source")
+		tex, _ := args[1].(string)
+		return []ast.InlineBlock{teletype([]ast.InlineBlock{&ast.Text{Value: tex}})}
+	case "RawInline":
+		if len(args) != 2 {
+			break
+		}
+		format, _ := args[0].(string)
+		p.warn("raw "+format+" inline", "dropped")
+		return nil
+	case "Link":
+		if len(args) != 3 {
+			break
+		}
+		target := list(args[2])
+		if len(target) != 2 {
+			break
+		}
+		destination, _ := target[0].(string)
+		return []ast.InlineBlock{&ast.HyperlinkBlock{
+			BaseInlineBlock: ast.BaseInlineBlock{Content: p.readInlines(args[1])},
+			Destination:     destination,
+		}}
+	case "Image":
+		if len(args) != 3 {
+			break
+		}
+		return []ast.InlineBlock{p.readImage(c)}
+	case "Note":
+		p.warn("footnote", "dropped")
+		return nil
+	case "Span":
+		if len(args) != 2 {
+			break
+		}
+		return p.readSpan(args)
+	}
+	p.warn("inline '"+t+"'", "dropped")
+	return nil
+}
+
+// Read an image, with its size from the width and height attributes.
+func (p *PandocImporter) readImage(c interface{}) *ast.InlineImageBlock {
+	image := &ast.InlineImageBlock{}
+	args := list(c)
+	if len(args) != 3 {
+		return image
+	}
+	_, _, attributes := attr(args[0])
+	image.Alt = ast.PlainText(p.readInlines(args[1]))
+	if target := list(args[2]); len(target) == 2 {
+		image.Source, _ = target[0].(string)
+	}
+	if width, ok := attributes["width"]; ok {
+		image.WidthValue, image.WidthType, image.HasWidthParameter = parseSize(width)
+		if !image.HasWidthParameter {
+			p.warn("image width '"+width+"'", "dropped")
+		}
+	}
+	if height, ok := attributes["height"]; ok {
+		image.HeightValue, image.HeightType, image.HasHeightParameter = parseSize(height)
+		if !image.HasHeightParameter {
+			p.warn("image height '"+height+"'", "dropped")
+		}
+	}
+	return image
+}
+
+// Read a span. Colors, sizes and fonts come from the style attribute, and
+// the teletype class is teletype formatting. Other spans are unwrapped.
+func (p *PandocImporter) readSpan(args []interface{}) []ast.InlineBlock {
+	_, classes, attributes := attr(args[0])
+	content := p.readInlines(args[1])
+	if hasClass(classes, exportpandoc.TeletypeClass) {
+		content = []ast.InlineBlock{teletype(content)}
+	}
+
+	var color *ast.ColorBlock
+	for _, part := range strings.Split(attributes["style"], ";") {
+		colon := strings.Index(part, ":")
+		if colon == -1 {
+			continue
+		}
+		property := strings.ToLower(strings.TrimSpace(part[:colon]))
+		value := strings.TrimSpace(part[colon+1:])
+
+		switch property {
+		case "color", "background-color":
+			c, err := colors.Parse(strings.ToLower(value))
+			if err != nil {
+				p.warn("color '"+value+"'", "dropped")
+				continue
+			}
+			if color == nil {
+				color = &ast.ColorBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}}
+			}
+			if property == "color" {
+				color.ForegroundValue = c
+			} else {
+				color.BackgroundValue = c
+			}
+			continue
+		case "font-size":
+			size, sizeType, ok := parseSize(value)
+			if !ok {
+				p.warn("font size '"+value+"'", "dropped")
+				continue
+			}
+			content = []ast.InlineBlock{&ast.SizeBlock{
+				BaseInlineBlock: ast.BaseInlineBlock{Content: content},
+				Value:           size,
+				Type:            sizeType,
+			}}
+		case "font-family":
+			content = []ast.InlineBlock{&ast.FontBlock{
+				BaseInlineBlock: ast.BaseInlineBlock{Content: content},
+				Family:          value,
+			}}
+		default:
+			p.warn("style property '"+property+"'", "dropped")
+		}
+	}
+	if color != nil {
+		color.Content = content
+		content = []ast.InlineBlock{color}
+	}
+	return content
+}
+
+// Get formatted inlines.
+func formatting(attribute ast.FormattingType, content []ast.InlineBlock) *ast.FormattingBlock {
+	return &ast.FormattingBlock{
+		BaseInlineBlock: ast.BaseInlineBlock{Content: content},
+		Attribute:       attribute,
+	}
+}
+
+// Get teletype inlines.
+func teletype(content []ast.InlineBlock) *ast.FormattingBlock {
+	return formatting(ast.TeletypeFormatting, content)
+}
+
+// Merge adjacent text.
+func merge(inlines []ast.InlineBlock) []ast.InlineBlock {
+	out := make([]ast.InlineBlock, 0, len(inlines))
+	for i := range inlines {
+		if t, ok := inlines[i].(*ast.Text); ok && len(out) > 0 {
+			if last, ok := out[len(out)-1].(*ast.Text); ok {
+				out[len(out)-1] = &ast.Text{Value: last.Value + t.Value}
+				continue
+			}
+		}
+		out = append(out, inlines[i])
+	}
+	return out
+}
+
+// Parse a CSS length.
+func parseSize(value string) (float32, ast.SizeType, bool) {
+	match := sizeRegexp.FindStringSubmatch(strings.ToLower(strings.TrimSpace(value)))
+	if match == nil {
+		return 0, 0, false
+	}
+	v, err := strconv.ParseFloat(match[1], 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	switch match[2] {
+	case "%":
+		return float32(v), ast.PercentageSizeType, true
+	case "pt":
+		return float32(v), ast.PointSizeType, true
+	case "cm":
+		return float32(v), ast.CentimeterSizeType, true
+	case "mm":
+		return float32(v), ast.MillimeterSizeType, true
+	}
+	return float32(v), ast.PixelSizeType, true
+}
@@ -0,0 +1,549 @@
+// importer/pandoc/pandoc.go
+// Package pandoc provides functionality for importing Pandoc's JSON AST.
+
+package pandoc
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+	exportpandoc "github.com/cubeflix/cdf/export/pandoc"
+	"github.com/cubeflix/cdf/importer"
+)
+
+// The oldest version of the Pandoc types read, from pandoc-types 1.21, which
+// introduced the current table structure.
+var MinimumAPIVersion = []int{1, 21}
+
+// Pandoc JSON importer.
+type PandocImporter struct {
+	settings PandocSettings
+	warnings []importer.Warning
+}
+
+// Create a new Pandoc JSON importer.
+func NewPandocImporter(settings PandocSettings) *PandocImporter {
+	return &PandocImporter{
+		settings: settings,
+	}
+}
+
+// Get the constructs that could not be mapped by the last import.
+func (p *PandocImporter) Warnings() []importer.Warning {
+	return p.warnings
+}
+
+// A Pandoc document.
+type document struct {
+	APIVersion []int                  `json:"pandoc-api-version"`
+	Meta       map[string]interface{} `json:"meta"`
+	Blocks     []interface{}          `json:"blocks"`
+}
+
+// Import a Pandoc JSON document, as written by "pandoc -t json". In strict
+// mode, the first construct that cannot be mapped is returned as an error.
+func (p *PandocImporter) Import(data []byte) (*ast.Document, error) {
+	p.warnings = nil
+
+	in := document{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, err
+	}
+	if len(in.APIVersion) < 2 || in.APIVersion[0] != MinimumAPIVersion[0] || in.APIVersion[1] < MinimumAPIVersion[1] {
+		return nil, errors.New("unsupported pandoc-api-version")
+	}
+
+	d := &ast.Document{}
+	p.readMeta(d, in.Meta)
+	d.Content = p.readBlocks(in.Blocks)
+
+	if p.settings.Strict && len(p.warnings) > 0 {
+		return nil, errors.New(p.warnings[0].String())
+	}
+	return d, nil
+}
+
+// Record a construct that could not be mapped. Pandoc JSON has no source
+// lines.
+func (p *PandocImporter) warn(construct, message string) {
+	p.warnings = append(p.warnings, importer.Warning{
+		Construct: construct,
+		Message:   message,
+	})
+}
+
+// Read the document metadata.
+func (p *PandocImporter) readMeta(d *ast.Document, meta map[string]interface{}) {
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := meta[key]
+		switch key {
+		case "title":
+			d.Title = p.metaString(value, ", ")
+		case "subtitle":
+			d.Subtitle = p.metaString(value, ", ")
+		case "date":
+			d.Date = p.metaString(value, ", ")
+		case "author":
+			d.Author = p.metaString(value, ", ")
+		default:
+			p.warn("metadata field '"+key+"'", "no matching document field")
+		}
+	}
+}
+
+// Get the plain text of a metadata value. List items are joined by a
+// separator.
+func (p *PandocImporter) metaString(v interface{}, separator string) string {
+	t, c := element(v)
+	switch t {
+	case "MetaString":
+		s, _ := c.(string)
+		return s
+	case "MetaInlines":
+		return ast.PlainText(p.readInlines(c))
+	case "MetaBlocks":
+		parts := []string{}
+		for _, b := range p.readBlocks(c) {
+			if para, ok := b.(*ast.Paragraph); ok {
+				parts = append(parts, ast.PlainText(para.Content))
+			}
+		}
+		return strings.Join(parts, " ")
+	case "MetaList":
+		parts := []string{}
+		for _, item := range list(c) {
+			parts = append(parts, p.metaString(item, separator))
+		}
+		return strings.Join(parts, separator)
+	case "MetaBool":
+		b, _ := c.(bool)
+		return strconv.FormatBool(b)
+	}
+	p.warn("metadata value '"+t+"'", "dropped")
+	return ""
+}
+
+// Read a slice of blocks.
+func (p *PandocImporter) readBlocks(v interface{}) []ast.Block {
+	out := []ast.Block{}
+	for _, b := range list(v) {
+		out = append(out, p.readBlock(b)...)
+	}
+	return out
+}
+
+// Read a block. Some blocks are unwrapped into many or dropped.
+func (p *PandocImporter) readBlock(v interface{}) []ast.Block {
+	t, c := element(v)
+	args := list(c)
+
+	switch t {
+	case "Para", "Plain":
+		// Before pandoc-types 1.23, figures were paragraphs of a single
+		// image titled "fig:".
+		if inlines := list(c); len(inlines) == 1 {
+			if it, ic := element(inlines[0]); it == "Image" && strings.HasPrefix(imageTitle(ic), "fig:") {
+				return []ast.Block{figureImage(p.readImage(ic), p.readInlines(list(ic)[1]))}
+			}
+		}
+		return []ast.Block{&ast.Paragraph{Content: p.readInlines(c)}}
+	case "LineBlock":
+		content := []ast.InlineBlock{}
+		for i, line := range args {
+			if i > 0 {
+				content = append(content, &ast.Text{Value: "\n"})
+			}
+			content = append(content, p.readInlines(line)...)
+		}
+		return []ast.Block{&ast.Paragraph{Content: merge(content)}}
+	case "CodeBlock":
+		if len(args) != 2 {
+			break
+		}
+		if _, classes, _ := attr(args[0]); len(classes) > 0 {
+			p.warn("code block language '"+classes[0]+"'", "dropped")
+		}
+		code, _ := args[1].(string)
+		return []ast.Block{&ast.Paragraph{Content: []ast.InlineBlock{teletype([]ast.InlineBlock{&ast.Text{Value: code}})}}}
+	case "RawBlock":
+		if len(args) != 2 {
+			break
+		}
+		format, _ := args[0].(string)
+		raw, _ := args[1].(string)
+		switch strings.TrimSpace(raw) {
+		case `\newpage`, `\pagebreak`, `\clearpage`:
+			if format == "latex" || format == "tex" {
+				return []ast.Block{&ast.PageBreak{}}
+			}
+		}
+		p.warn("raw "+format+" block", "dropped")
+		return nil
+	case "BlockQuote":
+		return []ast.Block{&ast.Quote{Content: p.readBlocks(c)}}
+	case "OrderedList":
+		if len(args) != 2 {
+			break
+		}
+		if attributes := list(args[0]); len(attributes) > 0 {
+			if start, _ := attributes[0].(float64); start != 1 {
+				p.warn("ordered list start", "lists always start at one")
+			}
+		}
+		return []ast.Block{p.readList(args[1], true)}
+	case "BulletList":
+		return []ast.Block{p.readList(c, false)}
+	case "DefinitionList":
+		p.warn("definition list", "converted to a bullet list")
+		items := []ast.Block{}
+		for _, item := range args {
+			parts := list(item)
+			if len(parts) != 2 {
+				continue
+			}
+			content := []ast.Block{&ast.Paragraph{Content: []ast.InlineBlock{&ast.FormattingBlock{
+				BaseInlineBlock: ast.BaseInlineBlock{Content: p.readInlines(parts[0])},
+				Attribute:       ast.BoldFormatting,
+			}}}}
+			for _, definition := range list(parts[1]) {
+				content = append(content, p.readBlocks(definition)...)
+			}
+			items = append(items, &ast.BasicBlock{Content: content})
+		}
+		return []ast.Block{&ast.List{Items: items}}
+	case "Header":
+		if len(args) != 3 {
+			break
+		}
+		level, _ := args[0].(float64)
+		if level > 5 {
+			p.warn("level "+strconv.Itoa(int(level))+" heading", "converted to level 5")
+			level = 5
+		} else if level < 1 {
+			level = 1
+		}
+		return []ast.Block{&ast.Heading{Class: ast.Heading1Type + ast.HeadingType(level-1), Content: p.readInlines(args[2])}}
+	case "HorizontalRule":
+		return []ast.Block{&ast.HorizontalRule{}}
+	case "Table":
+		if len(args) != 6 {
+			break
+		}
+		return p.readTable(args)
+	case "Figure":
+		if len(args) != 3 {
+			break
+		}
+		return p.readFigure(args)
+	case "Div":
+		if len(args) != 2 {
+			break
+		}
+		return p.readDiv(args)
+	case "Null":
+		return nil
+	}
+	p.warn("block '"+t+"'", "dropped")
+	return nil
+}
+
+// Read list items. Nested lists at the end of an item follow it, and
+// items of many blocks are basic blocks.
+func (p *PandocImporter) readList(v interface{}, ordered bool) *ast.List {
+	l := &ast.List{Ordered: ordered}
+	for _, item := range list(v) {
+		blocks := p.readBlocks(item)
+		end := len(blocks)
+		for end > 0 {
+			if _, ok := blocks[end-1].(*ast.List); !ok {
+				break
+			}
+			end--
+		}
+		if end == 0 && len(blocks) > 0 {
+			end = 1
+		}
+		switch end {
+		case 0:
+			l.Items = append(l.Items, &ast.Paragraph{})
+		case 1:
+			l.Items = append(l.Items, blocks[0])
+		default:
+			l.Items = append(l.Items, &ast.BasicBlock{Content: blocks[:end]})
+		}
+		l.Items = append(l.Items, blocks[end:]...)
+	}
+	return l
+}
+
+// Read a table. Cells in the table head, in row head columns or with the
+// header class are header cells. Spans are not supported.
+func (p *PandocImporter) readTable(args []interface{}) []ast.Block {
+	table := &ast.Table{}
+	readRows := func(rows interface{}, header bool, rowHeads int) {
+		for _, r := range list(rows) {
+			row := ast.TableRow{}
+			parts := list(r)
+			if len(parts) != 2 {
+				continue
+			}
+			for i, c := range list(parts[1]) {
+				cell := list(c)
+				if len(cell) != 5 {
+					continue
+				}
+				rowSpan, _ := cell[2].(float64)
+				colSpan, _ := cell[3].(float64)
+				if rowSpan > 1 || colSpan > 1 {
+					p.warn("table cell span", "dropped")
+				}
+				_, classes, _ := attr(cell[0])
+				row.Cells = append(row.Cells, ast.TableCell{
+					Content:  p.readBlocks(cell[4]),
+					IsHeader: header || i < rowHeads || hasClass(classes, exportpandoc.HeaderCellClass),
+				})
+			}
+			table.Rows = append(table.Rows, row)
+		}
+	}
+
+	if head := list(args[3]); len(head) == 2 {
+		readRows(head[1], true, 0)
+	}
+	for _, b := range list(args[4]) {
+		body := list(b)
+		if len(body) != 4 {
+			continue
+		}
+		rowHeads, _ := body[1].(float64)
+		readRows(body[2], true, 0)
+		readRows(body[3], false, int(rowHeads))
+	}
+	if foot := list(args[5]); len(foot) == 2 {
+		readRows(foot[1], false, 0)
+	}
+
+	out := []ast.Block{table}
+	if caption := list(args[1]); len(caption) == 2 {
+		if blocks := p.readBlocks(caption[1]); len(blocks) > 0 {
+			p.warn("table caption", "converted to a paragraph after the table")
+			out = append(out, blocks...)
+		}
+	}
+	return out
+}
+
+// Read a figure. Figures of a single image are image blocks, and other
+// figures are unwrapped.
+func (p *PandocImporter) readFigure(args []interface{}) []ast.Block {
+	caption := []ast.InlineBlock{}
+	if parts := list(args[1]); len(parts) == 2 {
+		for i, b := range p.readBlocks(parts[1]) {
+			para, ok := b.(*ast.Paragraph)
+			if !ok {
+				p.warn("figure caption", "only text is supported")
+				continue
+			}
+			if i > 0 {
+				caption = append(caption, &ast.Text{Value: " "})
+			}
+			caption = append(caption, para.Content...)
+		}
+	}
+
+	content := p.readBlocks(args[2])
+	if len(content) == 1 {
+		if para, ok := content[0].(*ast.Paragraph); ok && len(para.Content) == 1 {
+			if image, ok := para.Content[0].(*ast.InlineImageBlock); ok {
+				return []ast.Block{figureImage(image, merge(caption))}
+			}
+		}
+	}
+
+	p.warn("figure", "unwrapped")
+	if len(caption) > 0 {
+		content = append(content, &ast.Paragraph{Content: merge(caption)})
+	}
+	return content
+}
+
+// Read a div. Divs with the classes written by the exporter are basic
+// blocks, collapses, page breaks and notes, and divs aligning a single block are
+// unwrapped.
+func (p *PandocImporter) readDiv(args []interface{}) []ast.Block {
+	_, classes, attributes := attr(args[0])
+	base := p.blockAttributes(attributes)
+	children := list(args[1])
+
+	var out ast.Block
+	switch {
+	case hasClass(classes, exportpandoc.PageBreakClass):
+		out = &ast.PageBreak{}
+	case hasClass(classes, exportpandoc.NotesClass):
+		out = &ast.Notes{Content: p.readBlocks(children)}
+	case hasClass(classes, exportpandoc.CollapseClass):
+		collapse := &ast.Collapse{}
+		if len(children) > 0 {
+			if t, c := element(children[0]); t == "Div" {
+				if parts := list(c); len(parts) == 2 {
+					if _, summaryClasses, _ := attr(parts[0]); hasClass(summaryClasses, exportpandoc.SummaryClass) {
+						for _, b := range p.readBlocks(parts[1]) {
+							if para, ok := b.(*ast.Paragraph); ok {
+								collapse.Summary = append(collapse.Summary, para.Content...)
+							}
+						}
+						children = children[1:]
+					}
+				}
+			}
+		}
+		collapse.Content = p.readBlocks(children)
+		out = collapse
+	default:
+		content := p.readBlocks(children)
+		if !hasClass(classes, exportpandoc.BlockClass) && len(content) == 1 && (base.Alignment != ast.NoAlign || base.Wrap) {
+			out = content[0]
+		} else {
+			out = &ast.BasicBlock{Content: content}
+		}
+	}
+	setBase(out, base)
+	return []ast.Block{out}
+}
+
+// Read the align and wrap attributes of a div.
+func (p *PandocImporter) blockAttributes(attributes map[string]string) ast.BaseBlock {
+	base := ast.BaseBlock{}
+	switch align := attributes["align"]; align {
+	case "":
+	case "left":
+		base.Alignment = ast.LeftAlign
+	case "right":
+		base.Alignment = ast.RightAlign
+	case "center":
+		base.Alignment = ast.CenterAlign
+	default:
+		p.warn("alignment '"+align+"'", "dropped")
+	}
+	base.Wrap = attributes["wrap"] == "true"
+	return base
+}
+
+// Get an image block from an inline image.
+func figureImage(image *ast.InlineImageBlock, caption []ast.InlineBlock) *ast.Image {
+	return &ast.Image{
+		Source:             image.Source,
+		Alt:                image.Alt,
+		HasCaption:         len(caption) > 0,
+		Caption:            caption,
+		HasWidthParameter:  image.HasWidthParameter,
+		WidthValue:         image.WidthValue,
+		WidthType:          image.WidthType,
+		HasHeightParameter: image.HasHeightParameter,
+		HeightValue:        image.HeightValue,
+		HeightType:         image.HeightType,
+	}
+}
+
+// Get the title of an image or link.
+func imageTitle(c interface{}) string {
+	args := list(c)
+	if len(args) != 3 {
+		return ""
+	}
+	target := list(args[2])
+	if len(target) != 2 {
+		return ""
+	}
+	title, _ := target[1].(string)
+	return title
+}
+
+// Set the alignment and wrapping of a block.
+func setBase(b ast.Block, base ast.BaseBlock) {
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		block.BaseBlock = base
+	case *ast.BasicBlock:
+		block.BaseBlock = base
+	case *ast.Quote:
+		block.BaseBlock = base
+	case *ast.Image:
+		block.BaseBlock = base
+	case *ast.Heading:
+		block.BaseBlock = base
+	case *ast.HorizontalRule:
+		block.BaseBlock = base
+	case *ast.List:
+		block.BaseBlock = base
+	case *ast.Table:
+		block.BaseBlock = base
+	case *ast.Collapse:
+		block.BaseBlock = base
+	case *ast.PageBreak:
+		block.BaseBlock = base
+	case *ast.Notes:
+		block.BaseBlock = base
+	}
+}
+
+// Get the type and content of an element.
+func element(v interface{}) (string, interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return "", nil
+	}
+	t, _ := m["t"].(string)
+	return t, m["c"]
+}
+
+// Get a JSON array, or nil if the value isn't one.
+func list(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+// Read an attribute triple, of an identifier, classes and key-value pairs.
+func attr(v interface{}) (string, []string, map[string]string) {
+	parts := list(v)
+	attributes := map[string]string{}
+	if len(parts) != 3 {
+		return "", nil, attributes
+	}
+	id, _ := parts[0].(string)
+	classes := []string{}
+	for _, c := range list(parts[1]) {
+		if class, ok := c.(string); ok {
+			classes = append(classes, class)
+		}
+	}
+	for _, pair := range list(parts[2]) {
+		kv := list(pair)
+		if len(kv) != 2 {
+			continue
+		}
+		key, _ := kv[0].(string)
+		value, _ := kv[1].(string)
+		attributes[key] = value
+	}
+	return id, classes, attributes
+}
+
+// Check if a class is in a list of classes.
+func hasClass(classes []string, class string) bool {
+	for i := range classes {
+		if classes[i] == class {
+			return true
+		}
+	}
+	return false
+}
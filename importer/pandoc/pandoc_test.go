@@ -0,0 +1,157 @@
+// importer/pandoc/pandoc_test.go
+// Pandoc JSON import tests.
+
+package pandoc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cubeflix/cdf/export/pandoc"
+	"github.com/cubeflix/cdf/parser"
+)
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		blocks   string
+		want     string
+		warnings []string
+	}{
+		{
+			name:     "heading level",
+			blocks:   `[{"t":"Header","c":[6,["",[],[]],[{"t":"Str","c":"x"}]]}]`,
+			want:     "[[cdf]]\n\t[[h c=5]]x[[/]]\n[[/]]\n",
+			warnings: []string{"level 6 heading: converted to level 5"},
+		},
+		{
+			name:     "code block",
+			blocks:   `[{"t":"CodeBlock","c":[["",["go"],[]],"x := 1"]}]`,
+			want:     "[[cdf]]\n\t[[p]][[t]]x := 1[[/]][[/]]\n[[/]]\n",
+			warnings: []string{"code block language 'go': dropped"},
+		},
+		{
+			name:     "unmapped inlines",
+			blocks:   `[{"t":"Para","c":[{"t":"Math","c":[{"t":"InlineMath"},"x^2"]},{"t":"Space"},{"t":"Note","c":[]},{"t":"SmallCaps","c":[{"t":"Str","c":"sc"}]}]}]`,
+			want:     "[[cdf]]\n\t[[p]][[t]]x^2[[/]] sc[[/]]\n[[/]]\n",
+			warnings: []string{"math: kept as TeX source", "footnote: dropped", "smallcaps: unwrapped"},
+		},
+		{
+			name:     "raw block",
+			blocks:   `[{"t":"RawBlock","c":["html","<div>"]}]`,
+			want:     "[[cdf]]\n[[/]]\n",
+			warnings: []string{"raw html block: dropped"},
+		},
+		{
+			name:     "ordered list start",
+			blocks:   `[{"t":"OrderedList","c":[[3,{"t":"Decimal"},{"t":"Period"}],[[{"t":"Plain","c":[{"t":"Str","c":"a"}]}]]]}]`,
+			want:     "[[cdf]]\n\t[[list ordered=]]\n\t\t[[p]]a[[/]]\n\t[[/]]\n[[/]]\n",
+			warnings: []string{"ordered list start: lists always start at one"},
+		},
+		{
+			name:     "definition list",
+			blocks:   `[{"t":"DefinitionList","c":[[[{"t":"Str","c":"term"}],[[{"t":"Plain","c":[{"t":"Str","c":"def"}]}]]]]}]`,
+			want:     "[[cdf]]\n\t[[list]]\n\t\t[[block]]\n\t\t\t[[p]][[b]]term[[/]][[/]]\n\t\t\t[[p]]def[[/]]\n\t\t[[/]]\n\t[[/]]\n[[/]]\n",
+			warnings: []string{"definition list: converted to a bullet list"},
+		},
+		{
+			name:   "quotes and breaks",
+			blocks: `[{"t":"Para","c":[{"t":"Quoted","c":[{"t":"DoubleQuote"},[{"t":"Str","c":"q"}]]},{"t":"SoftBreak"},{"t":"Str","c":"b"}]}]`,
+			want:   "[[cdf]]\n\t[[p]]“q” b[[/]]\n[[/]]\n",
+		},
+	}
+	for _, test := range tests {
+		data := []byte(`{"pandoc-api-version":[1,23,1],"meta":{},"blocks":` + test.blocks + `}`)
+		i := NewPandocImporter(PandocSettings{})
+		d, err := i.Import(data)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		got, err := parser.FormatBytes(d)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+		warnings := []string{}
+		for _, w := range i.Warnings() {
+			warnings = append(warnings, w.String())
+		}
+		if test.warnings == nil {
+			test.warnings = []string{}
+		}
+		if !reflect.DeepEqual(warnings, test.warnings) {
+			t.Errorf("%s: got warnings %q, want %q", test.name, warnings, test.warnings)
+		}
+
+		// Strict mode fails on the first warning.
+		_, err = NewPandocImporter(PandocSettings{Strict: true}).Import(data)
+		if len(test.warnings) == 0 && err != nil {
+			t.Errorf("%s: strict: %v", test.name, err)
+		} else if len(test.warnings) != 0 && (err == nil || err.Error() != test.warnings[0]) {
+			t.Errorf("%s: strict: got error %v, want %q", test.name, err, test.warnings[0])
+		}
+	}
+}
+
+func TestImportVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		ok      bool
+	}{
+		{"[1,22]", true},
+		{"[1,23,1]", true},
+		{"[1,24]", true},
+		{"[1,20]", false},
+		{"[2,0]", false},
+		{"[]", false},
+	}
+	for _, test := range tests {
+		_, err := NewPandocImporter(PandocSettings{}).Import([]byte(`{"pandoc-api-version":` + test.version + `,"meta":{},"blocks":[]}`))
+		if (err == nil) != test.ok {
+			t.Errorf("%s: got error %v", test.version, err)
+		}
+	}
+}
+
+func TestImportExported(t *testing.T) {
+	tests := []string{
+		"[[cdf title=T|subtitle=S|date=D|author=A]]\n\t[[h c=2]]Heading[[/]]\n[[/]]\n",
+		"[[cdf]]\n\t[[p align=center]]a [[b]]b[[/]] [[i]]i[[/]] [[s]]s[[/]] [[u]]u[[/]] [[t]]t[[/]] [[link dest=https://example.com]]l[[/]]\nnext[[/]]\n[[/]]\n",
+		"[[cdf]]\n\t[[p]][[color fg=#ff0000|bg=#0000ff]]c[[/]][[size pt=12]]s[[/]][[font family=Georgia]]f[[/]][[inline-image src=i.png|alt=a|width-px=10]][[/]][[/]]\n[[/]]\n",
+		"[[cdf]]\n\t[[image src=a.png|alt=x|has-caption=|height-cm=2]]cap[[/]]\n\t[[hr]][[/]]\n\t[[break]][[/]]\n[[/]]\n",
+		"[[cdf]]\n\t[[list ordered=]]\n\t\t[[p]]one[[/]]\n\t\t[[list]]\n\t\t\t[[p]]two[[/]]\n\t\t[[/]]\n\t[[/]]\n[[/]]\n",
+		"[[cdf]]\n\t[[table]]\n" +
+			"\t\t[[row]]\n\t\t\t[[cell is-header=]]\n\t\t\t\t[[p]]h[[/]]\n\t\t\t[[/]]\n\t\t[[/]]\n" +
+			"\t\t[[row]]\n\t\t\t[[cell]]\n\t\t\t\t[[p]]c[[/]]\n\t\t\t[[/]]\n\t\t[[/]]\n" +
+			"\t[[/]]\n[[/]]\n",
+		"[[cdf]]\n\t[[quote]]\n\t\t[[p]]q[[/]]\n\t[[/]]\n\t[[collapse]]\n\t\t[[summary]]sum[[/]]\n\t\t[[content]]\n\t\t\t[[block align=right]]\n\t\t\t\t[[p]]in[[/]]\n\t\t\t[[/]]\n\t\t[[/]]\n\t[[/]]\n\t[[notes]]\n\t\t[[p]]n[[/]]\n\t[[/]]\n[[/]]\n",
+	}
+	for _, source := range tests {
+		p := parser.NewParser([]byte(source))
+		if err := p.Parse(); err != nil {
+			t.Fatal(err)
+		}
+		out := strings.Builder{}
+		if err := pandoc.NewPandocExporter(&out, pandoc.PandocSettings{}).Export(&p.Tree); err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := NewPandocImporter(PandocSettings{Strict: true}).Import([]byte(out.String()))
+		if err != nil {
+			t.Errorf("%v\n%s", err, out.String())
+			continue
+		}
+		got, err := parser.FormatBytes(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != source {
+			t.Errorf("got\n%s\nwant\n%s", got, source)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// importer/pandoc/settings.go
+// Pandoc JSON import settings.
+
+package pandoc
+
+// Pandoc JSON import settings.
+type PandocSettings struct {
+	// Fail on the first construct that cannot be mapped, instead of dropping
+	// or unwrapping it with a warning.
+	Strict bool
+}
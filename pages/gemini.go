@@ -0,0 +1,185 @@
+// pages/gemini.go
+// CDF pages server for the Gemini protocol.
+
+package pages
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"mime"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// The longest Gemini request: a 1024 byte URL and CRLF.
+const maxGeminiRequest = 1026
+
+// How long a Gemini client has to send its request.
+const geminiTimeout = 30 * time.Second
+
+// Create a self-signed certificate for local testing, valid for a year.
+func SelfSignedCertificate(hosts ...string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"cdf-pages"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	if len(hosts) > 0 {
+		template.Subject.CommonName = hosts[0]
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// Serve the pages over the Gemini protocol. The config must hold a
+// certificate.
+func (s *Server) ListenAndServeGemini(addr string, config *tls.Config) error {
+	config = config.Clone()
+	if config.MinVersion < tls.VersionTLS12 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	listener, err := tls.Listen("tcp", addr, config)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return err
+		}
+		go s.ServeGemini(conn)
+	}
+}
+
+// Handle a Gemini request on a connection, then close it.
+func (s *Server) ServeGemini(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(geminiTimeout))
+
+	// Read the request line.
+	line, err := bufio.NewReaderSize(io.LimitReader(conn, maxGeminiRequest), maxGeminiRequest).ReadString('\n')
+	if err != nil || !strings.HasSuffix(line, "\r\n") {
+		geminiHeader(conn, "59", "bad request")
+		return
+	}
+	u, err := url.Parse(strings.TrimSuffix(line, "\r\n"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		geminiHeader(conn, "59", "bad request")
+		return
+	}
+	if host := s.geminiHost(conn); u.Scheme != "gemini" || (host != "" && !strings.EqualFold(u.Hostname(), host)) {
+		geminiHeader(conn, "53", "proxy request refused")
+		return
+	}
+
+	if path.Dir(u.Path) == "/static" {
+		// Serve static file.
+		s.serveGeminiStatic(conn, path.Base(u.Path))
+		return
+	}
+
+	var page string
+
+	if u.Path == "/" || u.Path == "" {
+		page = "index"
+	} else {
+		page = path.Base(u.Path)
+	}
+
+	pageInfo, ok := s.Pages[page]
+	if !ok {
+		geminiHeader(conn, "51", "page "+page+" not found")
+		return
+	}
+	if pageInfo.DidError {
+		geminiHeader(conn, "40", "invalid page: "+pageInfo.Error)
+		return
+	}
+	if pageInfo.GemtextError != "" {
+		geminiHeader(conn, "40", "invalid page: "+pageInfo.GemtextError)
+		return
+	}
+	data, err := os.ReadFile(path.Join(s.Path, "compiled", page+".gmi"))
+	if err != nil {
+		geminiHeader(conn, "40", "internal server error: "+err.Error())
+		return
+	}
+	geminiHeader(conn, "20", "text/gemini; charset=utf-8")
+	conn.Write(data)
+}
+
+// Get the host name Gemini requests must be for.
+func (s *Server) geminiHost(conn net.Conn) string {
+	if s.GeminiHost != "" {
+		return s.GeminiHost
+	}
+	if t, ok := conn.(*tls.Conn); ok {
+		return t.ConnectionState().ServerName
+	}
+	return ""
+}
+
+// Respond with a static file.
+func (s *Server) serveGeminiStatic(conn net.Conn, name string) {
+	if name == "." || name == "/" || name == ".." {
+		geminiHeader(conn, "51", "not found")
+		return
+	}
+	data, err := os.ReadFile(path.Join(s.Path, "static", name))
+	if err != nil {
+		geminiHeader(conn, "51", "not found")
+		return
+	}
+	mimeType := mime.TypeByExtension(path.Ext(name))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	geminiHeader(conn, "20", mimeType)
+	conn.Write(data)
+}
+
+// Write a Gemini response header.
+func geminiHeader(w io.Writer, status, meta string) {
+	meta = strings.NewReplacer("\r", "", "\n", " ").Replace(meta)
+	io.WriteString(w, status+" "+meta+"\r\n")
+}
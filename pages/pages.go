@@ -4,6 +4,7 @@
 package pages
 
 import (
+	"bytes"
 	"html/template"
 	"net/http"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/cubeflix/cdf/export/gemtext"
 	"github.com/cubeflix/cdf/export/html"
 	"github.com/cubeflix/cdf/parser"
 )
@@ -25,6 +27,11 @@ type Server struct {
 	NotFoundTemplate    *template.Template
 	InvalidPageTemplate *template.Template
 
+	// The host name served over Gemini. Requests for other hosts are
+	// refused. If empty, requests must be for the host the client named
+	// when connecting, if it named one.
+	GeminiHost string
+
 	staticHandler http.Handler
 }
 
@@ -37,6 +44,10 @@ type PageInfo struct {
 
 	DidError bool
 	Error    string
+
+	// The error exporting the page for the Gemini server. The HTML page is
+	// still served.
+	GemtextError string
 }
 
 // Page content template.
@@ -118,14 +129,11 @@ func (s *Server) CompilePage(page string) error {
 	if err != nil {
 		return err
 	}
-	outFile, err := os.Create(path.Join(s.Path, "compiled", page+".html"))
-	if err != nil {
-		return err
-	}
-	defer outFile.Close()
 
+	// The page is exported into memory, and only written once it succeeds.
+	var out bytes.Buffer
 	parser := parser.NewParser(source)
-	exporter := html.NewHTMLExporter(outFile, html.HTMLSettings{})
+	exporter := html.NewHTMLExporter(&out, html.HTMLSettings{})
 
 	// Parse the page.
 	if err := parser.Parse(); err != nil {
@@ -155,12 +163,26 @@ func (s *Server) CompilePage(page string) error {
 		return nil
 	}
 
-	s.Pages[page] = PageInfo{
+	if err := os.WriteFile(path.Join(s.Path, "compiled", page+".html"), out.Bytes(), 0666); err != nil {
+		return err
+	}
+
+	info := PageInfo{
 		Title:    parser.Tree.Title,
 		Subtitle: parser.Tree.Subtitle,
 		Author:   parser.Tree.Author,
 		Date:     parser.Tree.Date,
 	}
 
+	// Export the page for the Gemini server. Failing only affects the
+	// gemtext page.
+	var gemtextOut bytes.Buffer
+	if err := gemtext.NewGemtextExporter(&gemtextOut, gemtext.GemtextSettings{}).Export(&parser.Tree); err != nil {
+		info.GemtextError = err.Error()
+	} else if err := os.WriteFile(path.Join(s.Path, "compiled", page+".gmi"), gemtextOut.Bytes(), 0666); err != nil {
+		info.GemtextError = err.Error()
+	}
+	s.Pages[page] = info
+
 	return nil
 }
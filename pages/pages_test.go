@@ -0,0 +1,150 @@
+// pages/pages_test.go
+// Page compilation and Gemini serving tests.
+
+package pages
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Create a pages project with the given pages.
+func testProject(t *testing.T, pages map[string]string) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"template.html": "{{.Content}}",
+		"404.html":      "not found",
+		"invalid.html":  "{{.Error}}",
+	}
+	for name, content := range pages {
+		files[filepath.Join("pages", name+".cdf")] = content
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCompilePage(t *testing.T) {
+	dir := testProject(t, map[string]string{
+		"index":   "[[cdf title=Home]]\n[[p]]Hello [[link dest=javascript:alert(1)]]link[[/]][[/]]\n[[/]]\n",
+		"invalid": "[[cdf]]\n[[p]]Unclosed\n",
+	})
+	s, err := LoadServer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := s.Pages["index"]
+	if index.DidError || index.GemtextError != "" || index.Title != "Home" {
+		t.Errorf("unexpected page info: %+v", index)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "compiled", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "<a>link</a>") {
+		t.Errorf("unsafe link was not left out:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "compiled", "index.gmi")); err != nil {
+		t.Error(err)
+	}
+
+	if !s.Pages["invalid"].DidError {
+		t.Errorf("invalid page compiled: %+v", s.Pages["invalid"])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "compiled", "invalid.html")); !os.IsNotExist(err) {
+		t.Errorf("invalid page was written: %v", err)
+	}
+}
+
+func TestCompilePageGemtextError(t *testing.T) {
+	dir := testProject(t, map[string]string{
+		"index": "[[cdf title=Home]]\n[[p]]Hello[[/]]\n[[/]]\n",
+	})
+	s, err := LoadServer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing the gemtext page fails where a directory is in the way.
+	gmi := filepath.Join(dir, "compiled", "index.gmi")
+	if err := os.Remove(gmi); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(gmi, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CompilePage("index"); err != nil {
+		t.Fatal(err)
+	}
+
+	index := s.Pages["index"]
+	if index.DidError {
+		t.Errorf("html page failed: %+v", index)
+	}
+	if index.GemtextError == "" {
+		t.Errorf("gemtext error was not reported: %+v", index)
+	}
+}
+
+func TestServeGeminiHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		request string
+		want    string
+	}{
+		{"example.com", "gemini://example.com/", "51 page index not found\r\n"},
+		{"example.com", "gemini://EXAMPLE.com:1965/", "51 page index not found\r\n"},
+		{"example.com", "gemini://example.org/", "53 proxy request refused\r\n"},
+		{"example.com", "https://example.com/", "53 proxy request refused\r\n"},
+		{"", "gemini://example.org/", "51 page index not found\r\n"},
+	}
+	for _, test := range tests {
+		s := &Server{GeminiHost: test.host}
+		client, server := net.Pipe()
+		go s.ServeGemini(server)
+		go io.WriteString(client, test.request+"\r\n")
+		got, err := io.ReadAll(client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s for %q: got %q, want %q", test.request, test.host, got, test.want)
+		}
+	}
+}
+
+func TestServeGeminiServerName(t *testing.T) {
+	cert, err := SelfSignedCertificate("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for request, want := range map[string]string{
+		"gemini://example.com/": "51 page index not found\r\n",
+		"gemini://example.org/": "53 proxy request refused\r\n",
+	} {
+		client, server := net.Pipe()
+		go (&Server{}).ServeGemini(tls.Server(server, &tls.Config{Certificates: []tls.Certificate{cert}}))
+		conn := tls.Client(client, &tls.Config{ServerName: "example.com", InsecureSkipVerify: true})
+		go io.WriteString(conn, request+"\r\n")
+		got, err := io.ReadAll(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", request, got, want)
+		}
+	}
+}
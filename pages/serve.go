@@ -43,13 +43,6 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // Respond with a compiled HTML page.
 func (s *Server) ServeCompiledPage(w http.ResponseWriter, r *http.Request, page string) {
-	// Load the page content.
-	data, err := os.ReadFile(path.Join(s.Path, "compiled", page+".html"))
-	if err != nil {
-		s.Error(w, r, err)
-		return
-	}
-
 	// Get page info.
 	pageInfo, ok := s.Pages[page]
 	if !ok {
@@ -60,7 +53,7 @@ func (s *Server) ServeCompiledPage(w http.ResponseWriter, r *http.Request, page
 	// If the page failed to compile, mention it.
 	if pageInfo.DidError {
 		// Error!
-		err = s.InvalidPageTemplate.Execute(w, InvalidPageTemplate{
+		err := s.InvalidPageTemplate.Execute(w, InvalidPageTemplate{
 			Page:  page,
 			Error: pageInfo.Error,
 		})
@@ -71,6 +64,13 @@ func (s *Server) ServeCompiledPage(w http.ResponseWriter, r *http.Request, page
 		return
 	}
 
+	// Load the page content.
+	data, err := os.ReadFile(path.Join(s.Path, "compiled", page+".html"))
+	if err != nil {
+		s.Error(w, r, err)
+		return
+	}
+
 	// Serve the contents.
 	err = s.PageTemplate.Execute(w, PageTemplate{
 		Page:     page,
@@ -43,6 +43,8 @@ func (p *Parser) parseBlockContent() ([]ast.Block, error) {
 
 		// Check if we should wrap the block.
 		_, shouldWrap := tag.Attributes["wrap"]
+		base := ast.BaseBlock{Alignment: alignment, Wrap: shouldWrap}
+		p.tagGetClassesAndID(tag, &base)
 
 		// Parse the inner block.
 		if tag.Name == "p" {
@@ -53,7 +55,7 @@ func (p *Parser) parseBlockContent() ([]ast.Block, error) {
 			}
 
 			blocks = append(blocks, &ast.Paragraph{
-				BaseBlock: ast.BaseBlock{Alignment: alignment, Wrap: shouldWrap},
+				BaseBlock: base,
 				Content:   content,
 			})
 		} else if tag.Name == "block" {
@@ -64,7 +66,7 @@ func (p *Parser) parseBlockContent() ([]ast.Block, error) {
 			}
 
 			blocks = append(blocks, &ast.BasicBlock{
-				BaseBlock: ast.BaseBlock{Alignment: alignment, Wrap: shouldWrap},
+				BaseBlock: base,
 				Content:   content,
 			})
 		} else if tag.Name == "quote" {
@@ -75,7 +77,7 @@ func (p *Parser) parseBlockContent() ([]ast.Block, error) {
 			}
 
 			blocks = append(blocks, &ast.Quote{
-				BaseBlock: ast.BaseBlock{Alignment: alignment, Wrap: shouldWrap},
+				BaseBlock: base,
 				Content:   content,
 			})
 		} else if tag.Name == "image" {
@@ -90,6 +92,9 @@ func (p *Parser) parseBlockContent() ([]ast.Block, error) {
 			// Check if we should include the caption.
 			_, hasCaption := tag.Attributes["has-caption"]
 
+			// Get the alternate text.
+			alt := tag.Attributes["alt"]
+
 			a, b, c, d, e, f, err := p.generateImageBlock(tag)
 			if err != nil {
 				return nil, err
@@ -101,8 +106,9 @@ func (p *Parser) parseBlockContent() ([]ast.Block, error) {
 			}
 
 			blocks = append(blocks, &ast.Image{
-				BaseBlock:          ast.BaseBlock{Alignment: alignment, Wrap: shouldWrap},
+				BaseBlock:          base,
 				Source:             strings.ReplaceAll(imgSrc, "\n", ""),
+				Alt:                alt,
 				HasCaption:         hasCaption,
 				Caption:            content,
 				HasWidthParameter:  a,
@@ -124,7 +130,7 @@ func (p *Parser) parseBlockContent() ([]ast.Block, error) {
 			}
 
 			blocks = append(blocks, &ast.Heading{
-				BaseBlock: ast.BaseBlock{Alignment: alignment, Wrap: shouldWrap},
+				BaseBlock: base,
 				Content:   content,
 				Class:     class,
 			})
@@ -135,7 +141,7 @@ func (p *Parser) parseBlockContent() ([]ast.Block, error) {
 				return nil, err
 			}
 			blocks = append(blocks, &ast.HorizontalRule{
-				BaseBlock: ast.BaseBlock{Alignment: alignment, Wrap: shouldWrap},
+				BaseBlock: base,
 			})
 		} else if tag.Name == "list" {
 			// List block.
@@ -146,7 +152,7 @@ func (p *Parser) parseBlockContent() ([]ast.Block, error) {
 			_, isOrdered := tag.Attributes["ordered"]
 
 			blocks = append(blocks, &ast.List{
-				BaseBlock: ast.BaseBlock{Alignment: alignment, Wrap: shouldWrap},
+				BaseBlock: base,
 				Items:     content,
 				Ordered:   isOrdered,
 			})
@@ -157,7 +163,7 @@ func (p *Parser) parseBlockContent() ([]ast.Block, error) {
 				return nil, err
 			}
 			blocks = append(blocks, &ast.Table{
-				BaseBlock: ast.BaseBlock{Alignment: alignment, Wrap: shouldWrap},
+				BaseBlock: base,
 				Rows:      table,
 			})
 		} else if tag.Name == "collapse" {
@@ -167,7 +173,7 @@ func (p *Parser) parseBlockContent() ([]ast.Block, error) {
 				return nil, err
 			}
 			blocks = append(blocks, &ast.Collapse{
-				BaseBlock: ast.BaseBlock{Alignment: alignment, Wrap: shouldWrap},
+				BaseBlock: base,
 				Summary:   summaryContent,
 				Content:   innerContent,
 			})
@@ -177,7 +183,18 @@ func (p *Parser) parseBlockContent() ([]ast.Block, error) {
 			if err != nil {
 				return nil, err
 			}
-			blocks = append(blocks, &ast.PageBreak{})
+			blocks = append(blocks, &ast.PageBreak{BaseBlock: ast.BaseBlock{Classes: base.Classes, ID: base.ID}})
+		} else if tag.Name == "notes" {
+			// Speaker notes block.
+			content, err := p.parseBlockContent()
+			if err != nil {
+				return nil, err
+			}
+
+			blocks = append(blocks, &ast.Notes{
+				BaseBlock: base,
+				Content:   content,
+			})
 		} else {
 			// Invalid block type.
 			return nil, errors.New("invalid block type")
@@ -367,7 +384,7 @@ func (p *Parser) parseParagraphBlockContent() ([]ast.InlineBlock, error) {
 				if chunkLen != 0 {
 					chunk := p.data[p.cur : p.cur+chunkLen]
 					p.cur += chunkLen
-					blocks = append(blocks, escapeText(chunk))
+					blocks = append(blocks, &ast.Text{Value: escapeText(chunk)})
 				}
 
 				// Parse the tag.
@@ -392,7 +409,7 @@ func (p *Parser) parseParagraphBlockContent() ([]ast.InlineBlock, error) {
 
 					// Get the hyperlink destination.
 					if dest, ok := tag.Attributes["dest"]; ok {
-						blocks = append(blocks, ast.HyperlinkBlock{
+						blocks = append(blocks, &ast.HyperlinkBlock{
 							BaseInlineBlock: ast.BaseInlineBlock{Content: content},
 							Destination:     strings.ReplaceAll(dest, "\n", ""),
 						})
@@ -401,19 +418,19 @@ func (p *Parser) parseParagraphBlockContent() ([]ast.InlineBlock, error) {
 					}
 				} else if tag.Name == "b" {
 					// Bold text.
-					blocks = append(blocks, ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: ast.BoldFormatting})
+					blocks = append(blocks, &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: ast.BoldFormatting})
 				} else if tag.Name == "i" {
 					// Italic text.
-					blocks = append(blocks, ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: ast.ItalicFormatting})
+					blocks = append(blocks, &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: ast.ItalicFormatting})
 				} else if tag.Name == "s" {
 					// Strikethrough text.
-					blocks = append(blocks, ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: ast.StrikethroughFormatting})
+					blocks = append(blocks, &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: ast.StrikethroughFormatting})
 				} else if tag.Name == "u" {
 					// Underline text.
-					blocks = append(blocks, ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: ast.UnderlineFormatting})
+					blocks = append(blocks, &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: ast.UnderlineFormatting})
 				} else if tag.Name == "t" {
 					// Teletype text.
-					blocks = append(blocks, ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: ast.TeletypeFormatting})
+					blocks = append(blocks, &ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Attribute: ast.TeletypeFormatting})
 				} else if tag.Name == "size" {
 					// Size block.
 
@@ -422,13 +439,13 @@ func (p *Parser) parseParagraphBlockContent() ([]ast.InlineBlock, error) {
 					if err != nil {
 						return nil, err
 					}
-					blocks = append(blocks, ast.SizeBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Value: sizeVal, Type: sizeType})
+					blocks = append(blocks, &ast.SizeBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, Value: sizeVal, Type: sizeType})
 				} else if tag.Name == "font" {
 					// Font block.
 
 					// Get the font family.
 					if family, ok := tag.Attributes["family"]; ok {
-						blocks = append(blocks, ast.FontBlock{
+						blocks = append(blocks, &ast.FontBlock{
 							BaseInlineBlock: ast.BaseInlineBlock{Content: content},
 							Family:          strings.ReplaceAll(family, "\n", ""),
 						})
@@ -443,7 +460,7 @@ func (p *Parser) parseParagraphBlockContent() ([]ast.InlineBlock, error) {
 					if err != nil {
 						return nil, err
 					}
-					blocks = append(blocks, ast.ColorBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, ForegroundValue: fore, BackgroundValue: back})
+					blocks = append(blocks, &ast.ColorBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: content}, ForegroundValue: fore, BackgroundValue: back})
 				} else if tag.Name == "inline-image" {
 					// Inline image block.
 					// Get the source.
@@ -451,15 +468,17 @@ func (p *Parser) parseParagraphBlockContent() ([]ast.InlineBlock, error) {
 					if !ok {
 						return nil, errors.New("'inline-image' tag expects a 'src' attribute")
 					}
+					alt := tag.Attributes["alt"]
 
 					a, b, c, d, e, f, err := p.generateImageBlock(tag)
 					if err != nil {
 						return nil, err
 					}
 
-					blocks = append(blocks, ast.InlineImageBlock{
+					blocks = append(blocks, &ast.InlineImageBlock{
 						BaseInlineBlock:    ast.BaseInlineBlock{Content: nil},
 						Source:             strings.ReplaceAll(imgSrc, "\n", ""),
+						Alt:                alt,
 						HasWidthParameter:  a,
 						WidthValue:         b,
 						WidthType:          c,
@@ -0,0 +1,708 @@
+// parser/cst.go
+// Lossless concrete syntax tree for CDF source.
+
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"unicode"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// CST token kinds.
+type TokenKind int64
+
+const (
+	// Raw text content, with escapes preserved.
+	TextToken TokenKind = iota
+	// Whitespace inside of a tag.
+	WhitespaceToken
+	// The '[[' tag opener.
+	OpenToken
+	// The ']]' tag closer.
+	CloseToken
+	// The '/' of a closing tag.
+	SlashToken
+	// A tag or attribute name.
+	NameToken
+	// The '=' between an attribute's name and value.
+	EqualsToken
+	// A raw attribute value, with escapes preserved.
+	ValueToken
+	// The '|' attribute separator.
+	PipeToken
+)
+
+// A single CST token. Every byte of the source belongs to exactly one token.
+type Token struct {
+	Kind TokenKind
+	Raw  string
+
+	// The byte offset of the token in the original source. Tokens created by
+	// edits have an offset of -1.
+	Offset int
+}
+
+// A CST node. Either a *CSTText or a *CSTElement.
+type CSTNode interface {
+	writeTo(w *bytes.Buffer)
+}
+
+// A CST document.
+type CSTDocument struct {
+	// Text before the opening 'cdf' tag.
+	Leading *CSTText
+
+	// The root 'cdf' element.
+	Root *CSTElement
+
+	// Text after the closing tag of the root element.
+	Trailing *CSTText
+}
+
+// A CST element: an opening tag, its content and its closing tag.
+type CSTElement struct {
+	Open     *CSTTag
+	Children []CSTNode
+	Close    *CSTTag
+
+	// The AST node built from this element, set by Bind. Nil for elements
+	// without an AST counterpart, like a collapse block's 'summary' tag.
+	Node interface{}
+}
+
+// A run of text between tags. Inside of block containers, text is
+// whitespace trivia.
+type CSTText struct {
+	Token *Token
+
+	// The AST node built from this text, set by Bind.
+	Node interface{}
+}
+
+// A CST tag, made of every token between and including '[[' and ']]'.
+type CSTTag struct {
+	Tokens []*Token
+}
+
+// Parse CDF source into a concrete syntax tree.
+func ParseCST(data []byte) (*CSTDocument, error) {
+	p := NewParser(data)
+	d := &CSTDocument{}
+
+	// Read the leading whitespace.
+	start := p.cur
+	if err := p.skipWhitespace(); err != nil {
+		return nil, err
+	}
+	d.Leading = p.newCSTText(start)
+
+	// Read the root element.
+	open, err := p.parseCSTTag()
+	if err != nil {
+		return nil, err
+	}
+	if open.IsClosing() {
+		return nil, errors.New("expected an opening tag")
+	}
+	if open.Name() != "cdf" {
+		return nil, errors.New("expected a 'cdf' tag")
+	}
+	d.Root, err = p.parseCSTElement(open)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the trailing data.
+	start = p.cur
+	p.cur = p.length
+	d.Trailing = p.newCSTText(start)
+
+	return d, nil
+}
+
+// Create a text node from the start position to the cursor.
+func (p *Parser) newCSTText(start int) *CSTText {
+	return &CSTText{Token: &Token{Kind: TextToken, Raw: string(p.data[start:p.cur]), Offset: start}}
+}
+
+// Parse an element's content and closing tag, given its opening tag.
+func (p *Parser) parseCSTElement(open *CSTTag) (*CSTElement, error) {
+	e := &CSTElement{Open: open, Children: make([]CSTNode, 0)}
+
+	for {
+		// Read a run of text.
+		start := p.cur
+		for {
+			if p.cur+1 >= p.length {
+				return nil, io.EOF
+			}
+			if p.data[p.cur] == '\\' {
+				p.cur += 2
+				continue
+			}
+			if p.data[p.cur] == '[' && p.data[p.cur+1] == '[' {
+				break
+			}
+			p.cur++
+		}
+		if p.cur != start {
+			e.Children = append(e.Children, p.newCSTText(start))
+		}
+
+		// Read the tag.
+		tag, err := p.parseCSTTag()
+		if err != nil {
+			return nil, err
+		}
+		if tag.IsClosing() {
+			e.Close = tag
+			return e, nil
+		}
+		child, err := p.parseCSTElement(tag)
+		if err != nil {
+			return nil, err
+		}
+		e.Children = append(e.Children, child)
+	}
+}
+
+// Append a token from the start position to the cursor.
+func (p *Parser) appendCSTToken(t *CSTTag, kind TokenKind, start int) {
+	if p.cur == start {
+		return
+	}
+	t.Tokens = append(t.Tokens, &Token{Kind: kind, Raw: string(p.data[start:p.cur]), Offset: start})
+}
+
+// Read whitespace into a tag.
+func (p *Parser) parseCSTWhitespace(t *CSTTag) error {
+	start := p.cur
+	err := p.skipWhitespace()
+	p.appendCSTToken(t, WhitespaceToken, start)
+	return err
+}
+
+// Read a name into a tag.
+func (p *Parser) parseCSTName(t *CSTTag) error {
+	start := p.cur
+	for {
+		if p.cur >= p.length {
+			return io.EOF
+		}
+		if !unicode.IsLetter(rune(p.data[p.cur])) && p.data[p.cur] != '-' {
+			break
+		}
+		p.cur++
+	}
+	p.appendCSTToken(t, NameToken, start)
+	return nil
+}
+
+// Parse a tag, keeping every token. Follows the grammar of parseTag.
+func (p *Parser) parseCSTTag() (*CSTTag, error) {
+	t := &CSTTag{}
+
+	// Expect the opening '[['.
+	if p.cur+1 >= p.length {
+		return nil, io.EOF
+	}
+	if p.data[p.cur] != '[' || p.data[p.cur+1] != '[' {
+		return nil, errors.New("expected '[[' for tag")
+	}
+	p.cur += 2
+	p.appendCSTToken(t, OpenToken, p.cur-2)
+	if err := p.parseCSTWhitespace(t); err != nil {
+		return nil, err
+	}
+
+	// Check for a closing tag.
+	if p.cur >= p.length {
+		return nil, io.EOF
+	}
+	if p.data[p.cur] == '/' {
+		p.cur++
+		p.appendCSTToken(t, SlashToken, p.cur-1)
+		if err := p.parseCSTWhitespace(t); err != nil {
+			return nil, err
+		}
+		if p.cur+1 >= p.length {
+			return nil, io.EOF
+		}
+		if p.data[p.cur] != ']' || p.data[p.cur+1] != ']' {
+			return nil, errors.New("expected ']]' for closing tag")
+		}
+		p.cur += 2
+		p.appendCSTToken(t, CloseToken, p.cur-2)
+		return t, nil
+	}
+
+	// Parse the tag name.
+	if err := p.parseCSTName(t); err != nil {
+		return nil, err
+	}
+	if err := p.parseCSTWhitespace(t); err != nil {
+		return nil, err
+	}
+	if p.cur+1 >= p.length {
+		return nil, io.EOF
+	}
+	if p.data[p.cur] == ']' && p.data[p.cur+1] == ']' {
+		p.cur += 2
+		p.appendCSTToken(t, CloseToken, p.cur-2)
+		return t, nil
+	}
+
+	// Read each tag attribute.
+	for {
+		if err := p.parseCSTName(t); err != nil {
+			return nil, err
+		}
+		if err := p.parseCSTWhitespace(t); err != nil {
+			return nil, err
+		}
+		if p.cur >= p.length {
+			return nil, io.EOF
+		}
+		if p.data[p.cur] != '=' {
+			return nil, errors.New("expected '='")
+		}
+		p.cur++
+		p.appendCSTToken(t, EqualsToken, p.cur-1)
+		if err := p.parseCSTWhitespace(t); err != nil {
+			return nil, err
+		}
+
+		// Parse the attribute value.
+		start := p.cur
+		for {
+			if p.cur+1 >= p.length {
+				return nil, io.EOF
+			}
+			if p.data[p.cur] == '\\' {
+				p.cur += 2
+				continue
+			}
+			if p.data[p.cur] == ']' && p.data[p.cur+1] == ']' {
+				t.Tokens = append(t.Tokens, &Token{Kind: ValueToken, Raw: string(p.data[start:p.cur]), Offset: start})
+				p.cur += 2
+				p.appendCSTToken(t, CloseToken, p.cur-2)
+				return t, nil
+			}
+			if p.data[p.cur] == '|' {
+				t.Tokens = append(t.Tokens, &Token{Kind: ValueToken, Raw: string(p.data[start:p.cur]), Offset: start})
+				p.cur++
+				p.appendCSTToken(t, PipeToken, p.cur-1)
+				break
+			}
+			p.cur++
+		}
+		if err := p.parseCSTWhitespace(t); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Check if the tag is a closing tag.
+func (t *CSTTag) IsClosing() bool {
+	for i := range t.Tokens {
+		if t.Tokens[i].Kind == SlashToken {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the tag's name.
+func (t *CSTTag) Name() string {
+	for i := range t.Tokens {
+		if t.Tokens[i].Kind == NameToken {
+			return t.Tokens[i].Raw
+		}
+	}
+	return ""
+}
+
+// Get the tag's attribute name and value tokens. The first name token is the
+// tag's name.
+func (t *CSTTag) attributeTokens() ([]*Token, []*Token) {
+	var names, values []*Token
+	seenName := false
+	for i := range t.Tokens {
+		switch t.Tokens[i].Kind {
+		case NameToken:
+			if seenName {
+				names = append(names, t.Tokens[i])
+			}
+			seenName = true
+		case ValueToken:
+			values = append(values, t.Tokens[i])
+		}
+	}
+	return names, values
+}
+
+// Get an attribute's unescaped value.
+func (t *CSTTag) Attribute(name string) (string, bool) {
+	names, values := t.attributeTokens()
+	for i := range names {
+		if names[i].Raw == name && i < len(values) {
+			return escapeText([]byte(values[i].Raw)), true
+		}
+	}
+	return "", false
+}
+
+// Get the tag's attributes, in source order.
+func (t *CSTTag) Attributes() [][2]string {
+	names, values := t.attributeTokens()
+	attrs := make([][2]string, 0, len(names))
+	for i := range names {
+		if i < len(values) {
+			attrs = append(attrs, [2]string{names[i].Raw, escapeText([]byte(values[i].Raw))})
+		}
+	}
+	return attrs
+}
+
+// Set an attribute's value. Only the value token is changed if the attribute
+// already exists, otherwise the attribute is appended to the tag.
+func (t *CSTTag) SetAttribute(name, value string) {
+	names, values := t.attributeTokens()
+	for i := range names {
+		if names[i].Raw == name && i < len(values) {
+			values[i].Raw = escapeAttributeValue(value)
+			values[i].Offset = -1
+			return
+		}
+	}
+
+	// Insert the new attribute before the closing ']]'.
+	added := []*Token{}
+	last := len(t.Tokens) - 1
+	if len(names) != 0 {
+		added = append(added, &Token{Kind: PipeToken, Raw: "|", Offset: -1})
+	} else if t.Tokens[last-1].Kind != WhitespaceToken {
+		added = append(added, &Token{Kind: WhitespaceToken, Raw: " ", Offset: -1})
+	}
+	added = append(added,
+		&Token{Kind: NameToken, Raw: name, Offset: -1},
+		&Token{Kind: EqualsToken, Raw: "=", Offset: -1},
+		&Token{Kind: ValueToken, Raw: escapeAttributeValue(value), Offset: -1})
+	t.Tokens = append(t.Tokens[:last], append(added, t.Tokens[last])...)
+}
+
+// Remove an attribute from the tag. Returns false if the attribute does not
+// exist.
+func (t *CSTTag) RemoveAttribute(name string) bool {
+	seenName := false
+	for i := range t.Tokens {
+		if t.Tokens[i].Kind != NameToken {
+			continue
+		}
+		if !seenName {
+			seenName = true
+			continue
+		}
+		if t.Tokens[i].Raw != name {
+			continue
+		}
+
+		// Find the end of the attribute: the token after its value.
+		end := i
+		for end < len(t.Tokens) && t.Tokens[end].Kind != ValueToken {
+			end++
+		}
+		end++
+		start := i
+		if end < len(t.Tokens) && t.Tokens[end].Kind == PipeToken {
+			// Remove the following separator and whitespace.
+			end++
+			for end < len(t.Tokens) && t.Tokens[end].Kind == WhitespaceToken {
+				end++
+			}
+		} else if t.Tokens[start-1].Kind == WhitespaceToken && t.Tokens[start-2].Kind == PipeToken {
+			// Last attribute: remove the preceding separator.
+			start -= 2
+		} else if t.Tokens[start-1].Kind == PipeToken {
+			start--
+		}
+		t.Tokens = append(t.Tokens[:start], t.Tokens[end:]...)
+		return true
+	}
+	return false
+}
+
+// Get the raw text.
+func (t *CSTText) Raw() string {
+	return t.Token.Raw
+}
+
+// Get the unescaped text.
+func (t *CSTText) Text() string {
+	return escapeText([]byte(t.Token.Raw))
+}
+
+// Replace the text, escaping it.
+func (t *CSTText) SetText(s string) {
+	t.Token.Raw = escapeTextContent(s)
+	t.Token.Offset = -1
+}
+
+// Get the element's tag name.
+func (e *CSTElement) Name() string {
+	return e.Open.Name()
+}
+
+// Get the element's child elements.
+func (e *CSTElement) Elements() []*CSTElement {
+	elements := make([]*CSTElement, 0)
+	for i := range e.Children {
+		if child, ok := e.Children[i].(*CSTElement); ok {
+			elements = append(elements, child)
+		}
+	}
+	return elements
+}
+
+// Walk the element and its descendants in source order. If the function
+// returns false, the element's children are skipped.
+func (e *CSTElement) Walk(fn func(n CSTNode) bool) {
+	if !fn(e) {
+		return
+	}
+	for i := range e.Children {
+		if child, ok := e.Children[i].(*CSTElement); ok {
+			child.Walk(fn)
+		} else {
+			fn(e.Children[i])
+		}
+	}
+}
+
+// Find all elements with a tag name.
+func (d *CSTDocument) FindElements(name string) []*CSTElement {
+	elements := make([]*CSTElement, 0)
+	d.Root.Walk(func(n CSTNode) bool {
+		if e, ok := n.(*CSTElement); ok && e.Name() == name {
+			elements = append(elements, e)
+		}
+		return true
+	})
+	return elements
+}
+
+// Find the CST node bound to an AST node. Returns nil if the node was not
+// found. The document must have been bound with Bind or ToAST.
+func (d *CSTDocument) FindNode(node interface{}) CSTNode {
+	var found CSTNode
+	d.Root.Walk(func(n CSTNode) bool {
+		if found != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *CSTElement:
+			if n.Node != nil && n.Node == node {
+				found = n
+			}
+		case *CSTText:
+			if n.Node != nil && n.Node == node {
+				found = n
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// Write the node's tokens.
+func (e *CSTElement) writeTo(w *bytes.Buffer) {
+	e.Open.writeTo(w)
+	for i := range e.Children {
+		e.Children[i].writeTo(w)
+	}
+	e.Close.writeTo(w)
+}
+
+// Write the node's tokens.
+func (t *CSTText) writeTo(w *bytes.Buffer) {
+	w.WriteString(t.Token.Raw)
+}
+
+// Write the tag's tokens.
+func (t *CSTTag) writeTo(w *bytes.Buffer) {
+	for i := range t.Tokens {
+		w.WriteString(t.Tokens[i].Raw)
+	}
+}
+
+// Print the document back into source. An unedited document is printed
+// byte-for-byte identical to the parsed source.
+func (d *CSTDocument) Bytes() []byte {
+	w := bytes.Buffer{}
+	if d.Leading != nil {
+		d.Leading.writeTo(&w)
+	}
+	d.Root.writeTo(&w)
+	if d.Trailing != nil {
+		d.Trailing.writeTo(&w)
+	}
+	return w.Bytes()
+}
+
+// Write the document's source to a stream.
+func (d *CSTDocument) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(d.Bytes())
+	return int64(n), err
+}
+
+// Build the document's AST and bind each CST node to its AST node.
+func (d *CSTDocument) ToAST() (*ast.Document, error) {
+	p := NewParser(d.Bytes())
+	if err := p.Parse(); err != nil {
+		return nil, err
+	}
+	if err := d.Bind(&p.Tree); err != nil {
+		return nil, err
+	}
+	return &p.Tree, nil
+}
+
+// Build a CST from an AST, formatted canonically. Each CST node is bound to
+// its AST node.
+func NewCSTFromAST(tree *ast.Document) (*CSTDocument, error) {
+	source, err := FormatBytes(tree)
+	if err != nil {
+		return nil, err
+	}
+	d, err := ParseCST(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Bind(tree); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Bind each CST node to its AST node. The AST must have the structure of the
+// CST, like one returned by ToAST.
+func (d *CSTDocument) Bind(tree *ast.Document) error {
+	d.Root.Node = tree
+	return bindBlocks(d.Root, tree.Content)
+}
+
+// Bind an element's child elements to a slice of blocks.
+func bindBlocks(e *CSTElement, blocks []ast.Block) error {
+	elements := e.Elements()
+	if len(elements) != len(blocks) {
+		return errors.New("cst does not match ast")
+	}
+	for i := range elements {
+		if err := bindBlock(elements[i], blocks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bind an element to a block.
+func bindBlock(e *CSTElement, b ast.Block) error {
+	e.Node = b
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		return bindInlineBlocks(e, block.Content)
+	case *ast.BasicBlock:
+		return bindBlocks(e, block.Content)
+	case *ast.Quote:
+		return bindBlocks(e, block.Content)
+	case *ast.Image:
+		return bindInlineBlocks(e, block.Caption)
+	case *ast.Heading:
+		return bindInlineBlocks(e, block.Content)
+	case *ast.List:
+		return bindBlocks(e, block.Items)
+	case *ast.Table:
+		rows := e.Elements()
+		if len(rows) != len(block.Rows) {
+			return errors.New("cst does not match ast")
+		}
+		for i := range rows {
+			rows[i].Node = &block.Rows[i]
+			cells := rows[i].Elements()
+			if len(cells) != len(block.Rows[i].Cells) {
+				return errors.New("cst does not match ast")
+			}
+			for j := range cells {
+				cells[j].Node = &block.Rows[i].Cells[j]
+				if err := bindBlocks(cells[j], block.Rows[i].Cells[j].Content); err != nil {
+					return err
+				}
+			}
+		}
+	case *ast.Collapse:
+		parts := e.Elements()
+		if len(parts) != 2 {
+			return errors.New("cst does not match ast")
+		}
+		if err := bindInlineBlocks(parts[0], block.Summary); err != nil {
+			return err
+		}
+		return bindBlocks(parts[1], block.Content)
+	case *ast.Notes:
+		return bindBlocks(e, block.Content)
+	}
+	return nil
+}
+
+// Bind an element's children to a slice of inline blocks.
+func bindInlineBlocks(e *CSTElement, content []ast.InlineBlock) error {
+	if len(e.Children) != len(content) {
+		return errors.New("cst does not match ast")
+	}
+	for i := range e.Children {
+		switch child := e.Children[i].(type) {
+		case *CSTText:
+			child.Node = content[i]
+		case *CSTElement:
+			child.Node = content[i]
+			if _, ok := content[i].(*ast.InlineImageBlock); ok {
+				continue
+			}
+			inner := content[i].Children()
+			if err := bindInlineBlocks(child, inner); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Get the source position of a byte offset. Negative offsets, like those of
+// edited nodes, have an unknown position.
+func Position(data []byte, offset int) ast.Position {
+	if offset < 0 {
+		return ast.Position{}
+	}
+	if offset > len(data) {
+		offset = len(data)
+	}
+	before := data[:offset]
+	return ast.Position{
+		Offset: offset,
+		Line:   bytes.Count(before, []byte{'\n'}) + 1,
+		Column: offset - bytes.LastIndexByte(before, '\n'),
+	}
+}
+
+// Get the source offset of a node. Returns -1 if the node was created by an
+// edit.
+func (e *CSTElement) Offset() int {
+	if e.Open == nil || len(e.Open.Tokens) == 0 {
+		return -1
+	}
+	return e.Open.Tokens[0].Offset
+}
@@ -0,0 +1,455 @@
+// parser/format.go
+// Format an abstract syntax tree back into CDF source.
+
+package parser
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Format a document into CDF source and write it to the stream.
+func Format(w io.Writer, d *ast.Document) error {
+	f := formatter{w: bufio.NewWriter(w)}
+
+	// Write the document tag.
+	f.writeString("[[cdf")
+	f.writeAttributes([][2]string{
+		{"title", d.Title},
+		{"subtitle", d.Subtitle},
+		{"date", d.Date},
+		{"author", d.Author},
+	}, true)
+	f.writeString("]]\n")
+
+	// Write the content.
+	for i := range d.Content {
+		if err := f.formatBlock(d.Content[i], 1); err != nil {
+			return err
+		}
+	}
+	f.writeString("[[/]]\n")
+
+	if f.err != nil {
+		return f.err
+	}
+	return f.w.Flush()
+}
+
+// Format a document into a CDF source byte slice.
+func FormatBytes(d *ast.Document) ([]byte, error) {
+	out := strings.Builder{}
+	if err := Format(&out, d); err != nil {
+		return nil, err
+	}
+	return []byte(out.String()), nil
+}
+
+// The CDF source formatter.
+type formatter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// Write a string to the output, keeping the first error.
+func (f *formatter) writeString(s string) {
+	if f.err != nil {
+		return
+	}
+	_, f.err = f.w.WriteString(s)
+}
+
+// Write a block's indentation.
+func (f *formatter) writeIndent(depth int) {
+	f.writeString(strings.Repeat("\t", depth))
+}
+
+// Write a list of tag attributes. Empty values are skipped if omitEmpty is
+// set, otherwise they are written as flag attributes ('name=').
+func (f *formatter) writeAttributes(attrs [][2]string, omitEmpty bool) {
+	first := true
+	for i := range attrs {
+		if omitEmpty && attrs[i][1] == "" {
+			continue
+		}
+		if first {
+			f.writeString(" ")
+			first = false
+		} else {
+			f.writeString("|")
+		}
+		f.writeString(attrs[i][0] + "=" + escapeAttributeValue(attrs[i][1]))
+	}
+}
+
+// Write an opening block tag with its alignment attributes.
+func (f *formatter) writeBlockTag(name string, b ast.Block, attrs [][2]string, depth int) error {
+	f.writeIndent(depth)
+	f.writeString("[[" + name)
+	alignment, err := formatAlignment(b.GetAlignment())
+	if err != nil {
+		return err
+	}
+	if alignment != "" {
+		attrs = append(attrs, [2]string{"align", alignment})
+	}
+	if b.GetWrap() {
+		attrs = append(attrs, [2]string{"wrap", ""})
+	}
+	if classes := b.GetClasses(); len(classes) > 0 {
+		attrs = append(attrs, [2]string{"class", strings.Join(classes, " ")})
+	}
+	if id := b.GetID(); id != "" {
+		attrs = append(attrs, [2]string{"id", id})
+	}
+	f.writeAttributes(attrs, false)
+	f.writeString("]]")
+	return nil
+}
+
+// Format a single block.
+func (f *formatter) formatBlock(b ast.Block, depth int) error {
+	switch block := b.(type) {
+	case *ast.Paragraph:
+		if err := f.writeBlockTag("p", b, nil, depth); err != nil {
+			return err
+		}
+		if err := f.formatInlineContent(block.Content); err != nil {
+			return err
+		}
+		f.writeString("[[/]]\n")
+	case *ast.BasicBlock:
+		if err := f.writeBlockTag("block", b, nil, depth); err != nil {
+			return err
+		}
+		if err := f.formatBlockContent(block.Content, depth); err != nil {
+			return err
+		}
+	case *ast.Quote:
+		if err := f.writeBlockTag("quote", b, nil, depth); err != nil {
+			return err
+		}
+		if err := f.formatBlockContent(block.Content, depth); err != nil {
+			return err
+		}
+	case *ast.Image:
+		attrs := [][2]string{{"src", block.Source}}
+		if block.Alt != "" {
+			attrs = append(attrs, [2]string{"alt", block.Alt})
+		}
+		if block.HasCaption {
+			attrs = append(attrs, [2]string{"has-caption", ""})
+		}
+		sizeAttrs, err := formatImageSize("width", block.HasWidthParameter, block.WidthValue, block.WidthType)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, sizeAttrs...)
+		sizeAttrs, err = formatImageSize("height", block.HasHeightParameter, block.HeightValue, block.HeightType)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, sizeAttrs...)
+		if err := f.writeBlockTag("image", b, attrs, depth); err != nil {
+			return err
+		}
+		if err := f.formatInlineContent(block.Caption); err != nil {
+			return err
+		}
+		f.writeString("[[/]]\n")
+	case *ast.Heading:
+		class, err := formatHeadingClass(block.Class)
+		if err != nil {
+			return err
+		}
+		if err := f.writeBlockTag("h", b, [][2]string{{"c", class}}, depth); err != nil {
+			return err
+		}
+		if err := f.formatInlineContent(block.Content); err != nil {
+			return err
+		}
+		f.writeString("[[/]]\n")
+	case *ast.HorizontalRule:
+		if err := f.writeBlockTag("hr", b, nil, depth); err != nil {
+			return err
+		}
+		f.writeString("[[/]]\n")
+	case *ast.List:
+		var attrs [][2]string
+		if block.Ordered {
+			attrs = append(attrs, [2]string{"ordered", ""})
+		}
+		if err := f.writeBlockTag("list", b, attrs, depth); err != nil {
+			return err
+		}
+		if err := f.formatBlockContent(block.Items, depth); err != nil {
+			return err
+		}
+	case *ast.Table:
+		if err := f.writeBlockTag("table", b, nil, depth); err != nil {
+			return err
+		}
+		f.writeString("\n")
+		for i := range block.Rows {
+			f.writeIndent(depth + 1)
+			f.writeString("[[row]]\n")
+			for j := range block.Rows[i].Cells {
+				cell := block.Rows[i].Cells[j]
+				f.writeIndent(depth + 2)
+				if cell.IsHeader {
+					f.writeString("[[cell is-header=]]")
+				} else {
+					f.writeString("[[cell]]")
+				}
+				if err := f.formatBlockContent(cell.Content, depth+2); err != nil {
+					return err
+				}
+			}
+			f.writeIndent(depth + 1)
+			f.writeString("[[/]]\n")
+		}
+		f.writeIndent(depth)
+		f.writeString("[[/]]\n")
+	case *ast.Collapse:
+		if err := f.writeBlockTag("collapse", b, nil, depth); err != nil {
+			return err
+		}
+		f.writeString("\n")
+		f.writeIndent(depth + 1)
+		f.writeString("[[summary]]")
+		if err := f.formatInlineContent(block.Summary); err != nil {
+			return err
+		}
+		f.writeString("[[/]]\n")
+		f.writeIndent(depth + 1)
+		f.writeString("[[content]]")
+		if err := f.formatBlockContent(block.Content, depth+1); err != nil {
+			return err
+		}
+		f.writeIndent(depth)
+		f.writeString("[[/]]\n")
+	case *ast.PageBreak:
+		if err := f.writeBlockTag("break", b, nil, depth); err != nil {
+			return err
+		}
+		f.writeString("[[/]]\n")
+	case *ast.Notes:
+		if err := f.writeBlockTag("notes", b, nil, depth); err != nil {
+			return err
+		}
+		if err := f.formatBlockContent(block.Content, depth); err != nil {
+			return err
+		}
+	default:
+		return errors.New("invalid ast")
+	}
+	return nil
+}
+
+// Format the content of a block containing more blocks, followed by the
+// block's closing tag.
+func (f *formatter) formatBlockContent(content []ast.Block, depth int) error {
+	f.writeString("\n")
+	for i := range content {
+		if err := f.formatBlock(content[i], depth+1); err != nil {
+			return err
+		}
+	}
+	f.writeIndent(depth)
+	f.writeString("[[/]]\n")
+	return nil
+}
+
+// Format a slice of inline blocks.
+func (f *formatter) formatInlineContent(content []ast.InlineBlock) error {
+	for i := range content {
+		if err := f.formatInlineBlock(content[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Format a single inline block.
+func (f *formatter) formatInlineBlock(b ast.InlineBlock) error {
+	var name string
+	var attrs [][2]string
+	var content []ast.InlineBlock
+
+	switch block := b.(type) {
+	case *ast.Text:
+		f.writeString(escapeTextContent(block.Value))
+		return nil
+	case *ast.HyperlinkBlock:
+		name = "link"
+		attrs = [][2]string{{"dest", block.Destination}}
+		content = block.Content
+	case *ast.FormattingBlock:
+		var err error
+		name, err = formatFormattingTagName(block.Attribute)
+		if err != nil {
+			return err
+		}
+		content = block.Content
+	case *ast.SizeBlock:
+		unit, err := formatSizeUnit(block.Type)
+		if err != nil {
+			return err
+		}
+		name = "size"
+		attrs = [][2]string{{unit, formatFloat(block.Value)}}
+		content = block.Content
+	case *ast.FontBlock:
+		name = "font"
+		attrs = [][2]string{{"family", block.Family}}
+		content = block.Content
+	case *ast.ColorBlock:
+		name = "color"
+		if block.ForegroundValue != nil {
+			attrs = append(attrs, [2]string{"fg", block.ForegroundValue.String()})
+		}
+		if block.BackgroundValue != nil {
+			attrs = append(attrs, [2]string{"bg", block.BackgroundValue.String()})
+		}
+		content = block.Content
+	case *ast.InlineImageBlock:
+		name = "inline-image"
+		attrs = [][2]string{{"src", block.Source}}
+		if block.Alt != "" {
+			attrs = append(attrs, [2]string{"alt", block.Alt})
+		}
+		sizeAttrs, err := formatImageSize("width", block.HasWidthParameter, block.WidthValue, block.WidthType)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, sizeAttrs...)
+		sizeAttrs, err = formatImageSize("height", block.HasHeightParameter, block.HeightValue, block.HeightType)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, sizeAttrs...)
+	default:
+		return errors.New("invalid ast")
+	}
+
+	f.writeString("[[" + name)
+	f.writeAttributes(attrs, false)
+	f.writeString("]]")
+	if err := f.formatInlineContent(content); err != nil {
+		return err
+	}
+	f.writeString("[[/]]")
+	return nil
+}
+
+// Get the 'align' attribute value for an alignment type.
+func formatAlignment(a ast.AlignmentType) (string, error) {
+	switch a {
+	case ast.NoAlign:
+		return "", nil
+	case ast.LeftAlign:
+		return "left", nil
+	case ast.RightAlign:
+		return "right", nil
+	case ast.CenterAlign:
+		return "center", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Get the 'c' attribute value for a heading type.
+func formatHeadingClass(c ast.HeadingType) (string, error) {
+	switch c {
+	case ast.Heading1Type:
+		return "1", nil
+	case ast.Heading2Type:
+		return "2", nil
+	case ast.Heading3Type:
+		return "3", nil
+	case ast.Heading4Type:
+		return "4", nil
+	case ast.Heading5Type:
+		return "5", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Get the tag name for a formatting type.
+func formatFormattingTagName(t ast.FormattingType) (string, error) {
+	switch t {
+	case ast.BoldFormatting:
+		return "b", nil
+	case ast.ItalicFormatting:
+		return "i", nil
+	case ast.StrikethroughFormatting:
+		return "s", nil
+	case ast.UnderlineFormatting:
+		return "u", nil
+	case ast.TeletypeFormatting:
+		return "t", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Get the attribute name suffix for a size type.
+func formatSizeUnit(t ast.SizeType) (string, error) {
+	switch t {
+	case ast.PercentageSizeType:
+		return "percent", nil
+	case ast.PixelSizeType:
+		return "px", nil
+	case ast.PointSizeType:
+		return "pt", nil
+	case ast.CentimeterSizeType:
+		return "cm", nil
+	case ast.MillimeterSizeType:
+		return "mm", nil
+	}
+	return "", errors.New("invalid ast")
+}
+
+// Get the image size attributes for a width or height parameter.
+func formatImageSize(prefix string, has bool, value float32, t ast.SizeType) ([][2]string, error) {
+	if !has {
+		return nil, nil
+	}
+	unit, err := formatSizeUnit(t)
+	if err != nil {
+		return nil, err
+	}
+	return [][2]string{{prefix + "-" + unit, formatFloat(value)}}, nil
+}
+
+// Format a size value.
+func formatFloat(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
+
+// Escape an attribute value so that it is read back unchanged.
+func escapeAttributeValue(s string) string {
+	out := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' || s[i] == '|' || s[i] == ']' {
+			out.WriteByte('\\')
+		}
+		out.WriteByte(s[i])
+	}
+	return out.String()
+}
+
+// Escape text content so that it is read back unchanged.
+func escapeTextContent(s string) string {
+	out := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' || s[i] == '[' {
+			out.WriteByte('\\')
+		}
+		out.WriteByte(s[i])
+	}
+	return out.String()
+}
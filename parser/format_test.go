@@ -0,0 +1,181 @@
+// parser/format_test.go
+// Formatting and concrete syntax tree round trip tests.
+
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+	"gopkg.in/go-playground/colors.v1"
+)
+
+// Create inline content holding text.
+func testText(s string) []ast.InlineBlock {
+	return []ast.InlineBlock{&ast.Text{Value: s}}
+}
+
+// Create a document using every block and inline block.
+func testDocument(t *testing.T) *ast.Document {
+	red, err := colors.Parse("#ff0000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ast.Document{Title: "Title", Subtitle: "Sub|title", Date: "2024", Author: "A [[B]]", Content: []ast.Block{
+		&ast.Heading{Class: ast.Heading2Type, Content: testText("Heading")},
+		&ast.Paragraph{BaseBlock: ast.BaseBlock{Alignment: ast.CenterAlign, Wrap: true, Classes: []string{"a", "b"}, ID: "p1"}, Content: []ast.InlineBlock{
+			&ast.Text{Value: "a [[b]] \\ c\nd"},
+			&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("bold")}, Attribute: ast.BoldFormatting},
+			&ast.FormattingBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("code")}, Attribute: ast.TeletypeFormatting},
+			&ast.HyperlinkBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("link")}, Destination: "https://example.com/a b?c=d|e"},
+			&ast.ColorBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("red")}, ForegroundValue: red},
+			&ast.SizeBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("size")}, Value: 1.5, Type: ast.CentimeterSizeType},
+			&ast.FontBlock{BaseInlineBlock: ast.BaseInlineBlock{Content: testText("font")}, Family: "Georgia"},
+			&ast.InlineImageBlock{Source: "i.png", Alt: "alt", HasWidthParameter: true, WidthValue: 10, WidthType: ast.PixelSizeType},
+		}},
+		&ast.Image{Source: "a.png", Alt: "x", HasCaption: true, Caption: testText("caption"), HasHeightParameter: true, HeightValue: 50, HeightType: ast.PercentageSizeType},
+		&ast.List{Ordered: true, Items: []ast.Block{
+			&ast.Paragraph{Content: testText("one")},
+			&ast.List{Items: []ast.Block{&ast.Paragraph{Content: testText("two")}}},
+		}},
+		&ast.Table{Rows: []ast.TableRow{
+			{Cells: []ast.TableCell{{IsHeader: true, Content: []ast.Block{&ast.Paragraph{Content: testText("h")}}}}},
+			{Cells: []ast.TableCell{{Content: []ast.Block{&ast.Paragraph{Content: testText("c")}}}}},
+		}},
+		&ast.Quote{Content: []ast.Block{&ast.Paragraph{Content: testText("quote")}}},
+		&ast.HorizontalRule{},
+		&ast.Collapse{Summary: testText("summary"), Content: []ast.Block{&ast.BasicBlock{Content: []ast.Block{&ast.Paragraph{Content: testText("inside")}}}}},
+		&ast.PageBreak{},
+		&ast.Notes{Content: []ast.Block{&ast.Paragraph{Content: testText("notes")}}},
+	}}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  *ast.Document
+	}{
+		{"empty", &ast.Document{Content: []ast.Block{}}},
+		{"information", &ast.Document{Title: "a=b", Author: "c]]d", Date: "e\\f", Content: []ast.Block{}}},
+		{"everything", testDocument(t)},
+	}
+	for _, test := range tests {
+		source, err := FormatBytes(test.doc)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		cst, err := ParseCST(source)
+		if err != nil {
+			t.Errorf("%s: %v\n%s", test.name, err, source)
+			continue
+		}
+		if string(cst.Bytes()) != string(source) {
+			t.Errorf("%s: cst printed\n%s\nwant\n%s", test.name, cst.Bytes(), source)
+		}
+		got, err := cst.ToAST()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.doc) {
+			t.Errorf("%s: round trip changed the document\n%s", test.name, source)
+		}
+	}
+}
+
+func TestCSTBytes(t *testing.T) {
+	tests := []string{
+		"[[cdf]][[/]]",
+		"\n  [[cdf   title=a |  author=b]]\n\n[[p]]x[[/]]  \n[[/]]\n\n",
+		"[[cdf]]\r\n\t[[p align=left|class=c]]a \\[\\[ [[b]]b[[/]][[/]]\r\n[[/]]",
+		"[[cdf]][[image src=a.png|width-px=1|width-px=2]][[/]][[hr]] ignored [[/]][[/]]",
+	}
+	for _, source := range tests {
+		d, err := ParseCST([]byte(source))
+		if err != nil {
+			t.Errorf("%q: %v", source, err)
+			continue
+		}
+		if got := string(d.Bytes()); got != source {
+			t.Errorf("got %q, want %q", got, source)
+		}
+	}
+}
+
+func TestCSTEdit(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		edit   func(d *CSTDocument)
+		want   string
+	}{
+		{
+			name:   "set attribute",
+			source: "[[cdf]]\n  [[p]][[link  dest=a]]x[[/]][[/]]\n[[/]]",
+			edit:   func(d *CSTDocument) { d.FindElements("link")[0].Open.SetAttribute("dest", "b|c") },
+			want:   "[[cdf]]\n  [[p]][[link  dest=b\\|c]]x[[/]][[/]]\n[[/]]",
+		},
+		{
+			name:   "add attribute",
+			source: "[[cdf]][[p  id=x]]a[[/]][[/]]",
+			edit:   func(d *CSTDocument) { d.FindElements("p")[0].Open.SetAttribute("align", "right") },
+			want:   "[[cdf]][[p  id=x|align=right]]a[[/]][[/]]",
+		},
+		{
+			name:   "remove attribute",
+			source: "[[cdf]][[p id=x | align=left]]a[[/]][[/]]",
+			edit:   func(d *CSTDocument) { d.FindElements("p")[0].Open.RemoveAttribute("id") },
+			want:   "[[cdf]][[p align=left]]a[[/]][[/]]",
+		},
+		{
+			name:   "set text",
+			source: "[[cdf]]\n[[p]]a[[/]]\n[[/]]",
+			edit:   func(d *CSTDocument) { d.FindElements("p")[0].Children[0].(*CSTText).SetText("[[b]]") },
+			want:   "[[cdf]]\n[[p]]\\[\\[b]][[/]]\n[[/]]",
+		},
+	}
+	for _, test := range tests {
+		d, err := ParseCST([]byte(test.source))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		test.edit(d)
+		if got := string(d.Bytes()); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+		if _, err := d.ToAST(); err != nil {
+			t.Errorf("%s: edited source does not parse: %v", test.name, err)
+		}
+	}
+}
+
+func TestCSTOffset(t *testing.T) {
+	d, err := ParseCST([]byte("[[cdf]]\n  [[p]]a[[/]][[hr]][[/]][[/]]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		element *CSTElement
+		want    int
+	}{
+		{"root", d.Root, 0},
+		{"paragraph", d.FindElements("p")[0], 10},
+		{"rule", d.FindElements("hr")[0], 21},
+		{"no tokens", &CSTElement{Open: &CSTTag{}}, -1},
+		{"no tag", &CSTElement{}, -1},
+	}
+	for _, test := range tests {
+		if got := test.element.Offset(); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, got, test.want)
+		}
+	}
+
+	// Edited tokens have no position.
+	if got := Position([]byte("abc"), -1); got.Line != 0 {
+		t.Errorf("got %+v for a negative offset", got)
+	}
+}
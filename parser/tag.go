@@ -6,6 +6,7 @@ package parser
 import (
 	"errors"
 	"io"
+	"strings"
 	"unicode"
 
 	"github.com/cubeflix/cdf/ast"
@@ -219,6 +220,20 @@ func (p *Parser) tagGetAlignment(t tagItem) (ast.AlignmentType, error) {
 	return ast.NoAlign, nil
 }
 
+// Get a block's classes and identifier from the tag. Classes are separated
+// by spaces.
+func (p *Parser) tagGetClassesAndID(t tagItem, base *ast.BaseBlock) {
+	if classes, ok := t.Attributes["class"]; ok {
+		base.Classes = strings.Fields(classes)
+		if len(base.Classes) == 0 {
+			base.Classes = nil
+		}
+	}
+	if id, ok := t.Attributes["id"]; ok {
+		base.ID = strings.TrimSpace(id)
+	}
+}
+
 // Fill in the document's fields from the header tag.
 func (p *Parser) tagGetDocumentFields(t tagItem) {
 	if title, ok := t.Attributes["title"]; ok {
@@ -0,0 +1,171 @@
+// parser/validate.go
+// Validate CDF source.
+
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+// Source validation rules.
+type ValidationRules struct {
+	ast.ValidationRules
+
+	// Report content in tags that ignore it, like 'hr' and 'break'.
+	IgnoredContent bool
+
+	// Report attributes that a tag ignores, like alignment on a 'break'.
+	IgnoredAttributes bool
+
+	// Report images with more than one width or height attribute.
+	ContradictorySizes bool
+}
+
+// Get the default source validation rules.
+func DefaultValidationRules() ValidationRules {
+	return ValidationRules{
+		ValidationRules:    ast.DefaultValidationRules(),
+		IgnoredContent:     true,
+		IgnoredAttributes:  true,
+		ContradictorySizes: true,
+	}
+}
+
+// The attributes each tag reads. Attributes ending in '-' are prefixes.
+var tagAttributes = map[string][]string{
+	"cdf":          {"title", "subtitle", "date", "author"},
+	"p":            {"align", "wrap", "class", "id"},
+	"block":        {"align", "wrap", "class", "id"},
+	"quote":        {"align", "wrap", "class", "id"},
+	"image":        {"align", "wrap", "class", "id", "src", "alt", "has-caption", "width-", "height-"},
+	"h":            {"align", "wrap", "class", "id", "c"},
+	"hr":           {"align", "wrap", "class", "id"},
+	"list":         {"align", "wrap", "class", "id", "ordered"},
+	"table":        {"align", "wrap", "class", "id"},
+	"row":          {},
+	"cell":         {"is-header"},
+	"collapse":     {"align", "wrap", "class", "id"},
+	"summary":      {},
+	"content":      {},
+	"break":        {"class", "id"},
+	"notes":        {"align", "wrap", "class", "id"},
+	"link":         {"dest"},
+	"b":            {},
+	"i":            {},
+	"s":            {},
+	"u":            {},
+	"t":            {},
+	"size":         {"percent", "px", "pt", "cm", "mm"},
+	"font":         {"family"},
+	"color":        {"fg", "bg"},
+	"inline-image": {"src", "alt", "width-", "height-"},
+}
+
+// Validate CDF source. The document's AST is validated with the rules, and
+// the source is checked for constructs the parser accepts but ignores. Each
+// warning has a source position. Returns an error if the source does not
+// parse.
+func Validate(data []byte, rules ValidationRules) ([]ast.Warning, error) {
+	d, err := ParseCST(data)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := d.ToAST()
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the AST and find each warning's position.
+	warnings := ast.Validate(tree, rules.ValidationRules)
+	for i := range warnings {
+		switch n := d.FindNode(warnings[i].Node).(type) {
+		case *CSTElement:
+			warnings[i].Position = Position(data, n.Offset())
+		case *CSTText:
+			warnings[i].Position = Position(data, n.Token.Offset)
+		}
+	}
+
+	// Check the source.
+	d.Root.Walk(func(n CSTNode) bool {
+		e, ok := n.(*CSTElement)
+		if !ok {
+			return true
+		}
+		warn := func(rule, message string) {
+			warnings = append(warnings, ast.Warning{
+				Rule:     rule,
+				Message:  message,
+				Node:     e.Node,
+				Position: Position(data, e.Offset()),
+			})
+		}
+		name := e.Name()
+
+		if rules.IgnoredContent && (name == "hr" || name == "break" || name == "inline-image") {
+			for i := range e.Children {
+				if text, ok := e.Children[i].(*CSTText); ok && strings.TrimSpace(text.Raw()) == "" {
+					continue
+				}
+				warn("ignored-content", "content of '"+name+"' tag is ignored")
+				break
+			}
+		}
+
+		attrs := e.Open.Attributes()
+		if rules.IgnoredAttributes {
+			known := tagAttributes[name]
+			for i := range attrs {
+				if !isKnownAttribute(known, attrs[i][0]) {
+					warn("ignored-attribute", "attribute '"+attrs[i][0]+"' of '"+name+"' tag is ignored")
+				}
+			}
+		}
+
+		if rules.ContradictorySizes && (name == "image" || name == "inline-image") {
+			var widths, heights int
+			for i := range attrs {
+				if strings.HasPrefix(attrs[i][0], "width-") {
+					widths++
+				} else if strings.HasPrefix(attrs[i][0], "height-") {
+					heights++
+				}
+			}
+			if widths > 1 {
+				warn("contradictory-size", "'"+name+"' tag has more than one width attribute")
+			}
+			if heights > 1 {
+				warn("contradictory-size", "'"+name+"' tag has more than one height attribute")
+			}
+		}
+		return true
+	})
+
+	sortWarnings(warnings)
+	return warnings, nil
+}
+
+// Sort warnings by source position. Warnings without a position keep their
+// document order and come last.
+func sortWarnings(warnings []ast.Warning) {
+	sort.SliceStable(warnings, func(i, j int) bool {
+		a, b := warnings[i].Position, warnings[j].Position
+		if a.Line == 0 || b.Line == 0 {
+			return a.Line != 0 && b.Line == 0
+		}
+		return a.Offset < b.Offset
+	})
+}
+
+// Check if an attribute is in a list of known attributes and prefixes.
+func isKnownAttribute(known []string, name string) bool {
+	for i := range known {
+		if known[i] == name || (strings.HasSuffix(known[i], "-") && strings.HasPrefix(name, known[i])) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// parser/validate_test.go
+// Source validation tests.
+
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cubeflix/cdf/ast"
+)
+
+func TestSortWarnings(t *testing.T) {
+	at := func(path string, offset, line int) ast.Warning {
+		return ast.Warning{Path: path, Position: ast.Position{Offset: offset, Line: line, Column: 1}}
+	}
+	tests := []struct {
+		name     string
+		warnings []ast.Warning
+		want     []string
+	}{
+		{"by offset", []ast.Warning{at("b", 20, 2), at("a", 0, 1), at("c", 30, 3)}, []string{"a", "b", "c"}},
+		{"stable", []ast.Warning{at("a", 10, 1), at("b", 10, 1), at("c", 5, 1)}, []string{"c", "a", "b"}},
+		{"positionless last", []ast.Warning{at("x", 0, 0), at("b", 20, 2), at("y", 0, 0), at("a", 0, 1)}, []string{"a", "b", "x", "y"}},
+		{"only positionless", []ast.Warning{at("y", 0, 0), at("x", 0, 0)}, []string{"y", "x"}},
+	}
+	for _, test := range tests {
+		sortWarnings(test.warnings)
+		got := []string{}
+		for i := range test.warnings {
+			got = append(got, test.warnings[i].Path)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"[[cdf]][[hr]]x[[/]][[/]]", []string{"1:8: content of 'hr' tag is ignored (ignored-content)"}},
+		{
+			"[[cdf]]\n[[h c=1]]a[[/]]\n[[h c=3]]b[[/]]\n[[p foo=1]][[link dest=]]c[[/]][[/]]\n[[/]]",
+			[]string{
+				"3:1: level 3 heading follows level 1 heading (heading-level-skip)",
+				"4:1: attribute 'foo' of 'p' tag is ignored (ignored-attribute)",
+				"4:12: link has no destination (empty-link)",
+			},
+		},
+		{"[[cdf]]\n[[h c=2]]a[[/]]\n[[h c=3]]b[[/]]\n[[/]]", []string{}},
+		{"[[cdf title=t]]\n[[h c=2]]a[[/]]\n[[/]]", []string{}},
+		{
+			"[[cdf]]\n[[h c=3]]a[[/]]\n[[/]]",
+			[]string{"2:1: level 3 heading follows level 1 heading (heading-level-skip)"},
+		},
+	}
+	for _, test := range tests {
+		warnings, err := Validate([]byte(test.source), DefaultValidationRules())
+		if err != nil {
+			t.Errorf("%q: %v", test.source, err)
+			continue
+		}
+		got := []string{}
+		for i := range warnings {
+			got = append(got, warnings[i].String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}